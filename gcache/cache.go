@@ -95,6 +95,17 @@ type SetCache interface {
 	SetIsMember(key string, member []byte) (bool, error)
 }
 
+// ScriptCacheWithContext defines the interface for running Lua scripts atomically with context.
+// Integer replies are returned as int64, bulk strings as string and arrays as []interface{}.
+type ScriptCacheWithContext interface {
+	EvalWithContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// ScriptCache defines the interface for running Lua scripts atomically.
+type ScriptCache interface {
+	Eval(script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
 // HealthCheckerWithContext defines the interface for health check operations with context.
 type HealthCheckerWithContext interface {
 	PingWithContext(ctx context.Context) error
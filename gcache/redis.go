@@ -3,13 +3,15 @@ package gcache
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisCache struct {
-	client *redis.Client
+	client  *redis.Client
+	scripts sync.Map // map[string]*redis.Script
 }
 
 func NewRedisCache(opts ...Option) (*RedisCache, error) {
@@ -236,6 +238,24 @@ func (r *RedisCache) SetIsMember(key string, member []byte) (bool, error) {
 	return r.SetIsMemberWithContext(context.Background(), key, member)
 }
 
+// EvalWithContext runs script via EVALSHA, loading it on first use.
+func (r *RedisCache) EvalWithContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	s, ok := r.scripts.Load(script)
+	if !ok {
+		s, _ = r.scripts.LoadOrStore(script, redis.NewScript(script))
+	}
+	result, err := s.(*redis.Script).Run(ctx, r.client, keys, args...).Result()
+	if err != nil {
+		return nil, translateRedisError(err)
+	}
+	return result, nil
+}
+
+// Eval runs script using context.Background().
+func (r *RedisCache) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return r.EvalWithContext(context.Background(), script, keys, args...)
+}
+
 func (r *RedisCache) Close() error {
 	return r.client.Close()
 }
@@ -259,6 +279,8 @@ func translateRedisError(err error) error {
 }
 
 var (
-	_ Cache            = (*RedisCache)(nil)
-	_ CacheWithContext = (*RedisCache)(nil)
+	_ Cache                  = (*RedisCache)(nil)
+	_ CacheWithContext       = (*RedisCache)(nil)
+	_ ScriptCache            = (*RedisCache)(nil)
+	_ ScriptCacheWithContext = (*RedisCache)(nil)
 )
@@ -2,14 +2,17 @@ package gcache
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/valkey-io/valkey-go"
 )
 
 type ValkeyCache struct {
-	client valkey.Client
+	client  valkey.Client
+	scripts sync.Map // map[string]*valkey.Lua
 }
 
 func NewValkeyCache(opts ...Option) (*ValkeyCache, error) {
@@ -282,6 +285,29 @@ func (v *ValkeyCache) SetIsMember(key string, member []byte) (bool, error) {
 	return v.SetIsMemberWithContext(context.Background(), key, member)
 }
 
+// EvalWithContext runs script via EVALSHA, loading it on first use.
+// Arguments are sent in their fmt.Sprint form.
+func (v *ValkeyCache) EvalWithContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	s, ok := v.scripts.Load(script)
+	if !ok {
+		s, _ = v.scripts.LoadOrStore(script, valkey.NewLuaScript(script))
+	}
+	strArgs := make([]string, len(args))
+	for i, arg := range args {
+		strArgs[i] = fmt.Sprint(arg)
+	}
+	result, err := s.(*valkey.Lua).Exec(ctx, v.client, keys, strArgs).ToAny()
+	if err != nil {
+		return nil, translateValkeyError(err)
+	}
+	return result, nil
+}
+
+// Eval runs script using context.Background().
+func (v *ValkeyCache) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return v.EvalWithContext(context.Background(), script, keys, args...)
+}
+
 // Close closes the Valkey client.
 func (v *ValkeyCache) Close() error {
 	v.client.Close()
@@ -309,6 +335,8 @@ func translateValkeyError(err error) error {
 }
 
 var (
-	_ Cache            = (*ValkeyCache)(nil)
-	_ CacheWithContext = (*ValkeyCache)(nil)
+	_ Cache                  = (*ValkeyCache)(nil)
+	_ CacheWithContext       = (*ValkeyCache)(nil)
+	_ ScriptCache            = (*ValkeyCache)(nil)
+	_ ScriptCacheWithContext = (*ValkeyCache)(nil)
 )
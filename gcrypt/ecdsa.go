@@ -0,0 +1,92 @@
+package gcrypt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// GenerateECDSAKeyPair 生成ECDSA密钥对，curve 为 nil 时使用 P-256
+func GenerateECDSAKeyPair(curve elliptic.Curve) (*ecdsa.PrivateKey, *ecdsa.PublicKey, error) {
+	if curve == nil {
+		curve = elliptic.P256()
+	}
+	privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, &privateKey.PublicKey, nil
+}
+
+// SignWithECDSA ECDSA签名 (SHA-256，ASN.1 DER 编码)
+func SignWithECDSA(data []byte, privateKey *ecdsa.PrivateKey) ([]byte, error) {
+	hashed := sha256.Sum256(data)
+	return ecdsa.SignASN1(rand.Reader, privateKey, hashed[:])
+}
+
+// VerifyWithECDSA ECDSA验证签名 (SHA-256，ASN.1 DER 编码)
+func VerifyWithECDSA(data, signature []byte, publicKey *ecdsa.PublicKey) error {
+	hashed := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(publicKey, hashed[:], signature) {
+		return errors.New("ecdsa: verification error")
+	}
+	return nil
+}
+
+// EncodeECDSAPrivateKeyToPEM 将ECDSA私钥编码为PEM格式
+func EncodeECDSAPrivateKeyToPEM(privateKey *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// EncodeECDSAPublicKeyToPEM 将ECDSA公钥编码为PEM格式 (PKIX)
+func EncodeECDSAPublicKeyToPEM(publicKey *ecdsa.PublicKey) ([]byte, error) {
+	if publicKey == nil {
+		return nil, errors.New("publicKey is nil")
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// DecodeECDSAPrivateKeyFromPEM 从PEM格式解码ECDSA私钥 (SEC 1 或 PKCS8)
+func DecodeECDSAPrivateKeyFromPEM(privateKeyPEM []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("无法解码PEM块")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if ecKey, ok := key.(*ecdsa.PrivateKey); ok {
+			return ecKey, nil
+		}
+		return nil, fmt.Errorf("PEM块不是ECDSA私钥")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+// DecodeECDSAPublicKeyFromPEM 从PEM格式解码ECDSA公钥
+func DecodeECDSAPublicKeyFromPEM(publicKeyPEM []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("无法解码PEM块")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("PEM块不是ECDSA公钥")
+	}
+	return ecKey, nil
+}
@@ -0,0 +1,87 @@
+package gcrypt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// GenerateEd25519KeyPair 生成Ed25519密钥对
+func GenerateEd25519KeyPair() (ed25519.PrivateKey, ed25519.PublicKey, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, publicKey, nil
+}
+
+// SignWithEd25519 Ed25519签名
+func SignWithEd25519(data []byte, privateKey ed25519.PrivateKey) ([]byte, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("ed25519: bad private key length")
+	}
+	return ed25519.Sign(privateKey, data), nil
+}
+
+// VerifyWithEd25519 Ed25519验证签名
+func VerifyWithEd25519(data, signature []byte, publicKey ed25519.PublicKey) error {
+	if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, data, signature) {
+		return errors.New("ed25519: verification error")
+	}
+	return nil
+}
+
+// EncodeEd25519PrivateKeyToPEM 将Ed25519私钥编码为PEM格式 (PKCS8)
+func EncodeEd25519PrivateKeyToPEM(privateKey ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// EncodeEd25519PublicKeyToPEM 将Ed25519公钥编码为PEM格式 (PKIX)
+func EncodeEd25519PublicKeyToPEM(publicKey ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// DecodeEd25519PrivateKeyFromPEM 从PEM格式解码Ed25519私钥
+func DecodeEd25519PrivateKeyFromPEM(privateKeyPEM []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("无法解码PEM块")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("PEM块不是Ed25519私钥")
+	}
+	return edKey, nil
+}
+
+// DecodeEd25519PublicKeyFromPEM 从PEM格式解码Ed25519公钥
+func DecodeEd25519PublicKeyFromPEM(publicKeyPEM []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("无法解码PEM块")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("PEM块不是Ed25519公钥")
+	}
+	return edKey, nil
+}
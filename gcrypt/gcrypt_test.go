@@ -177,6 +177,75 @@ func TestRSA(t *testing.T) {
 	}
 }
 
+func TestECDSA(t *testing.T) {
+	priv, pub, err := GenerateECDSAKeyPair(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("secret message")
+
+	sig, err := SignWithECDSA(msg, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyWithECDSA(msg, sig, pub); err != nil {
+		t.Error("ecdsa verify failed")
+	}
+	if err := VerifyWithECDSA([]byte("tampered"), sig, pub); err == nil {
+		t.Error("expected ecdsa verify to fail for tampered data")
+	}
+
+	pemPriv, err := EncodeECDSAPrivateKeyToPEM(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPriv, err := DecodeECDSAPrivateKeyFromPEM(pemPriv)
+	if err != nil || !parsedPriv.Equal(priv) {
+		t.Fatalf("parsed ecdsa priv mismatch: %v", err)
+	}
+	pemPub, err := EncodeECDSAPublicKeyToPEM(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPub, err := DecodeECDSAPublicKeyFromPEM(pemPub)
+	if err != nil || !parsedPub.Equal(pub) {
+		t.Fatalf("parsed ecdsa pub mismatch: %v", err)
+	}
+}
+
+func TestEd25519(t *testing.T) {
+	priv, pub, err := GenerateEd25519KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("secret message")
+
+	sig, err := SignWithEd25519(msg, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyWithEd25519(msg, sig, pub); err != nil {
+		t.Error("ed25519 verify failed")
+	}
+
+	pemPriv, err := EncodeEd25519PrivateKeyToPEM(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPriv, err := DecodeEd25519PrivateKeyFromPEM(pemPriv)
+	if err != nil || !parsedPriv.Equal(priv) {
+		t.Fatalf("parsed ed25519 priv mismatch: %v", err)
+	}
+	pemPub, err := EncodeEd25519PublicKeyToPEM(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPub, err := DecodeEd25519PublicKeyFromPEM(pemPub)
+	if err != nil || !parsedPub.Equal(pub) {
+		t.Fatalf("parsed ed25519 pub mismatch: %v", err)
+	}
+}
+
 func TestPKCS7(t *testing.T) {
 	// Indirectly tested via AES/DES, but let's test directly if exported?
 	// pkcs7Padding is unexported. 
@@ -1,6 +1,14 @@
 package gerr
 
-import "testing"
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
 
 func TestErrors(t *testing.T) {
 	e := Err{}
@@ -15,3 +23,85 @@ func TestErrors(t *testing.T) {
 		t.Error("bad code")
 	}
 }
+
+var errUserNotFound = New(404, "user_not_found", "user not found")
+
+type quotaError struct{ limit int }
+
+func (e *quotaError) Error() string { return "quota exceeded" }
+
+func TestErrIsAndWith(t *testing.T) {
+	err := errUserNotFound.WithDetail("id", 7).WithCause(io.EOF)
+	if !errors.Is(err, errUserNotFound) || !errors.Is(err, io.EOF) {
+		t.Fatal("derived error must match its sentinel and cause")
+	}
+	if errUserNotFound.Details != nil || errUserNotFound.Cause != nil {
+		t.Fatal("With methods must not modify the sentinel")
+	}
+	if got := err.Error(); got != "user_not_found: user not found: EOF" {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
+
+func TestFromRegistry(t *testing.T) {
+	sentinel := errors.New("gone")
+	Register(sentinel, 410)
+	RegisterAs[*quotaError](429)
+
+	cases := map[error]int{
+		fmt.Errorf("wrap: %w", sentinel):              410,
+		fmt.Errorf("wrap: %w", &quotaError{limit: 1}): 429,
+		fmt.Errorf("wrap: %w", errUserNotFound):       404,
+		&HttpErr{Code: 403}:                           403,
+		errors.New("boom"):                            500,
+	}
+	for err, want := range cases {
+		if got := StatusOf(err); got != want {
+			t.Errorf("StatusOf(%v) = %d, want %d", err, got, want)
+		}
+	}
+	if From(nil) != nil {
+		t.Fatal("From(nil) must be nil")
+	}
+}
+
+func TestProblemJSONRoundTrip(t *testing.T) {
+	src := errUserNotFound.WithDetail("id", "7").WithInstance("/users/7")
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"code":"user_not_found","detail":"user not found","id":"7","instance":"/users/7","status":404,"title":"Not Found"}`
+	if string(data) != want {
+		t.Fatalf("unexpected JSON %s", data)
+	}
+
+	var decoded Err
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(&decoded, errUserNotFound) || decoded.Status != 404 || decoded.Details["id"] != "7" {
+		t.Fatalf("unexpected decoded error %+v", decoded)
+	}
+}
+
+func TestProblemXMLRoundTrip(t *testing.T) {
+	src := New(400, "invalid", "invalid input").WithDetail("fields", []string{"name", "age"})
+	data, err := xml.Marshal(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `<problem xmlns="urn:ietf:rfc:7807">`) ||
+		!strings.Contains(string(data), "<fields><i>name</i><i>age</i></fields>") {
+		t.Fatalf("unexpected XML %s", data)
+	}
+
+	var decoded Err
+	if err := xml.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	fields, _ := decoded.Details["fields"].([]interface{})
+	if decoded.Code != "invalid" || decoded.Status != 400 || len(fields) != 2 || fields[1] != "age" {
+		t.Fatalf("unexpected decoded error %+v", decoded)
+	}
+}
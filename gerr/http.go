@@ -1,7 +1,31 @@
 package gerr
 
+import "net/http"
+
+// HttpErr is a plain status code and message. From converts it to an *Err.
 type HttpErr struct {
 	Code int
 	Msg  string
 	Err  error
 }
+
+func (e *HttpErr) Error() string {
+	if e == nil {
+		return ""
+	}
+	msg := e.Msg
+	if msg == "" {
+		msg = http.StatusText(e.Code)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *HttpErr) Unwrap() error {
+	if e == nil {
+		return nil
+	}
+	return e.Err
+}
@@ -1,4 +1,131 @@
 package gerr
 
+import (
+	"net/http"
+	"strings"
+)
+
+// Err is the common error model. It is serialized as an RFC 9457 problem details
+// object: Status, Title, Message, Type and Instance become the status, title,
+// detail, type and instance members, Code and Details become extension members.
+// Cause stays on the server and is never serialized.
 type Err struct {
+	// Code is a stable, machine readable identifier such as "user_not_found".
+	// Errors with the same Code match with errors.Is.
+	Code   string
+	Status int
+	// Title is a short summary of the problem type, default the status text.
+	Title   string
+	Message string
+	// Type is a URI identifying the problem type, "about:blank" when empty.
+	Type     string
+	Instance string
+	Details  map[string]interface{}
+	Cause    error
+}
+
+// New returns an error with an HTTP status, a code and a message. Declare
+// sentinels with it and derive request specific errors with the With methods.
+func New(status int, code, message string) *Err {
+	return &Err{Status: status, Code: code, Message: message}
+}
+
+// Wrap returns an error with an HTTP status, a code and a message caused by cause.
+func Wrap(cause error, status int, code, message string) *Err {
+	return &Err{Status: status, Code: code, Message: message, Cause: cause}
+}
+
+func (e *Err) Error() string {
+	if e == nil {
+		return ""
+	}
+	var parts []string
+	if e.Code != "" {
+		parts = append(parts, e.Code)
+	}
+	if msg := e.Message; msg != "" {
+		parts = append(parts, msg)
+	} else if title := e.title(); title != "" && e.Code == "" {
+		parts = append(parts, title)
+	}
+	if e.Cause != nil {
+		parts = append(parts, e.Cause.Error())
+	}
+	return strings.Join(parts, ": ")
+}
+
+func (e *Err) Unwrap() error {
+	if e == nil {
+		return nil
+	}
+	return e.Cause
+}
+
+// Is reports whether target is an *Err with the same non-empty Code, so copies
+// made by the With methods or decoded from a response match their sentinel.
+func (e *Err) Is(target error) bool {
+	t, ok := target.(*Err)
+	if !ok || e == nil || t == nil || t.Code == "" {
+		return false
+	}
+	return e.Code == t.Code
+}
+
+// HTTPStatus returns Status, or 500 when it is not set.
+func (e *Err) HTTPStatus() int {
+	if e == nil || e.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.Status
+}
+
+// WithMessage returns a copy of e with message.
+func (e *Err) WithMessage(message string) *Err {
+	c := e.clone()
+	c.Message = message
+	return c
+}
+
+// WithCause returns a copy of e wrapping cause.
+func (e *Err) WithCause(cause error) *Err {
+	c := e.clone()
+	c.Cause = cause
+	return c
+}
+
+// WithDetail returns a copy of e with an extra extension member.
+func (e *Err) WithDetail(key string, value interface{}) *Err {
+	c := e.clone()
+	details := make(map[string]interface{}, len(e.Details)+1)
+	for k, v := range e.Details {
+		details[k] = v
+	}
+	details[key] = value
+	c.Details = details
+	return c
+}
+
+// WithInstance returns a copy of e with the URI of this occurrence of the problem.
+func (e *Err) WithInstance(instance string) *Err {
+	c := e.clone()
+	c.Instance = instance
+	return c
+}
+
+func (e *Err) clone() *Err {
+	if e == nil {
+		return &Err{}
+	}
+	c := *e
+	return &c
+}
+
+func (e *Err) title() string {
+	if e.Title != "" {
+		return e.Title
+	}
+	if e.Status != 0 {
+		return http.StatusText(e.Status)
+	}
+	return ""
 }
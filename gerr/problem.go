@@ -0,0 +1,253 @@
+package gerr
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types of RFC 9457 problem details documents.
+const (
+	MediaTypeProblemJSON = "application/problem+json"
+	MediaTypeProblemXML  = "application/problem+xml"
+)
+
+// problemXMLNamespace is the namespace of the XML format in RFC 9457 appendix B.
+const problemXMLNamespace = "urn:ietf:rfc:7807"
+
+var problemMembers = map[string]bool{
+	"type": true, "title": true, "status": true, "detail": true, "instance": true, "code": true,
+}
+
+// MarshalJSON encodes e as a problem details object.
+func (e Err) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(e.Details)+6)
+	for k, v := range e.Details {
+		m[k] = v
+	}
+	if e.Type != "" {
+		m["type"] = e.Type
+	}
+	if title := e.title(); title != "" {
+		m["title"] = title
+	}
+	if e.Status != 0 {
+		m["status"] = e.Status
+	}
+	if e.Message != "" {
+		m["detail"] = e.Message
+	}
+	if e.Instance != "" {
+		m["instance"] = e.Instance
+	}
+	if e.Code != "" {
+		m["code"] = e.Code
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes a problem details object. Unknown members are kept in Details.
+func (e *Err) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*e = Err{}
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "type":
+			err = json.Unmarshal(v, &e.Type)
+		case "title":
+			err = json.Unmarshal(v, &e.Title)
+		case "status":
+			err = json.Unmarshal(v, &e.Status)
+		case "detail":
+			err = json.Unmarshal(v, &e.Message)
+		case "instance":
+			err = json.Unmarshal(v, &e.Instance)
+		case "code":
+			err = json.Unmarshal(v, &e.Code)
+		default:
+			var value interface{}
+			if err = json.Unmarshal(v, &value); err == nil {
+				if e.Details == nil {
+					e.Details = make(map[string]interface{})
+				}
+				e.Details[k] = value
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("problem member %q: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// MarshalXML encodes e in the XML format of RFC 9457 appendix B. Arrays become
+// <i> elements and objects nested elements.
+func (e Err) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Space: problemXMLNamespace, Local: "problem"}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	text := func(name, value string) error {
+		if value == "" {
+			return nil
+		}
+		return enc.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: name}})
+	}
+	if err := text("type", e.Type); err != nil {
+		return err
+	}
+	if err := text("title", e.title()); err != nil {
+		return err
+	}
+	if e.Status != 0 {
+		if err := text("status", strconv.Itoa(e.Status)); err != nil {
+			return err
+		}
+	}
+	for _, m := range [][2]string{{"detail", e.Message}, {"instance", e.Instance}, {"code", e.Code}} {
+		if err := text(m[0], m[1]); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0, len(e.Details))
+	for k := range e.Details {
+		if !problemMembers[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// A JSON round trip turns structs and typed slices into maps and slices.
+		value := e.Details[k]
+		if data, err := json.Marshal(value); err == nil {
+			_ = json.Unmarshal(data, &value)
+		}
+		if err := encodeXMLValue(enc, k, value); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(start.End()); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func encodeXMLValue(enc *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	switch v := value.(type) {
+	case nil:
+		return enc.EncodeElement("", start)
+	case []interface{}:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := encodeXMLValue(enc, "i", item); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case map[string]interface{}:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encodeXMLValue(enc, k, v[k]); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	default:
+		return enc.EncodeElement(fmt.Sprint(v), start)
+	}
+}
+
+// UnmarshalXML decodes the XML format of RFC 9457 appendix B. Extension values
+// are decoded as strings, []interface{} for <i> lists and maps for nested elements.
+func (e *Err) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	*e = Err{}
+	value, err := decodeXMLValue(dec)
+	if err != nil {
+		return err
+	}
+	members, _ := value.(map[string]interface{})
+	for k, v := range members {
+		s, _ := v.(string)
+		switch k {
+		case "type":
+			e.Type = s
+		case "title":
+			e.Title = s
+		case "status":
+			e.Status, _ = strconv.Atoi(s)
+		case "detail":
+			e.Message = s
+		case "instance":
+			e.Instance = s
+		case "code":
+			e.Code = s
+		default:
+			if e.Details == nil {
+				e.Details = make(map[string]interface{})
+			}
+			e.Details[k] = v
+		}
+	}
+	return nil
+}
+
+// decodeXMLValue reads the content of the current element up to its end.
+func decodeXMLValue(dec *xml.Decoder) (interface{}, error) {
+	var text strings.Builder
+	var names []string
+	var values []interface{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			v, err := decodeXMLValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, t.Name.Local)
+			values = append(values, v)
+		case xml.EndElement:
+			if len(names) == 0 {
+				return strings.TrimSpace(text.String()), nil
+			}
+			list := true
+			for _, n := range names {
+				if n != "i" {
+					list = false
+					break
+				}
+			}
+			if list {
+				return values, nil
+			}
+			m := make(map[string]interface{}, len(names))
+			for i, n := range names {
+				m[n] = values[i]
+			}
+			return m, nil
+		}
+	}
+}
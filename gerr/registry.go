@@ -0,0 +1,83 @@
+package gerr
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+type registration struct {
+	match  func(error) bool
+	status int
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []registration
+)
+
+// Register maps errors matching target with errors.Is to status.
+//
+//	gerr.Register(sql.ErrNoRows, http.StatusNotFound)
+func Register(target error, status int) {
+	register(func(err error) bool { return errors.Is(err, target) }, status)
+}
+
+// RegisterAs maps errors for which errors.As finds a T to status.
+//
+//	gerr.RegisterAs[*json.SyntaxError](http.StatusBadRequest)
+func RegisterAs[T error](status int) {
+	register(func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}, status)
+}
+
+func register(match func(error) bool, status int) {
+	registryMu.Lock()
+	registry = append(registry, registration{match: match, status: status})
+	registryMu.Unlock()
+}
+
+// StatusOf returns the HTTP status for err: the status of an *Err or *HttpErr in
+// its chain, else the first registration matching it, else 500.
+func StatusOf(err error) int {
+	return From(err).HTTPStatus()
+}
+
+// From converts err to an *Err. It returns a copy of the first *Err in the chain,
+// converts an *HttpErr, applies the registrations and falls back to 500 with the
+// error text as message. It returns nil for a nil error.
+func From(err error) *Err {
+	if err == nil {
+		return nil
+	}
+	var e *Err
+	if errors.As(err, &e) && e != nil {
+		c := *e
+		if c.Status == 0 {
+			c.Status = lookupStatus(err)
+		}
+		return &c
+	}
+	var he *HttpErr
+	if errors.As(err, &he) && he != nil {
+		msg := he.Msg
+		if msg == "" {
+			msg = http.StatusText(he.Code)
+		}
+		return &Err{Status: he.Code, Message: msg, Cause: he.Err}
+	}
+	return &Err{Status: lookupStatus(err), Message: err.Error(), Cause: err}
+}
+
+func lookupStatus(err error) int {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, r := range registry {
+		if r.match(err) {
+			return r.status
+		}
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,435 @@
+package gclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is matched, through errors.Is, by the *CircuitOpenError returned
+// when a circuit breaker rejects a request.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through and measures their outcome.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects requests until BreakerConfig.OpenTimeout has elapsed.
+	CircuitOpen
+	// CircuitHalfOpen lets a few probe requests through to test the upstream.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitOpenError is returned, without sending the request, while a circuit is
+// open or its half-open probes are all in flight.
+type CircuitOpenError struct {
+	Name  string
+	State CircuitState
+	// RetryAfter is the time left before the circuit becomes half-open.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker %q is %s", e.Name, e.State)
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// BreakerConfig configures a CircuitBreaker. Zero fields take the defaults.
+type BreakerConfig struct {
+	// Window is the rolling window the rates are computed over, split into
+	// Buckets. Defaults to 10s and 10 buckets.
+	Window  time.Duration
+	Buckets int
+	// MinRequests is the number of calls in the window before the rates can trip
+	// the circuit. Default 10.
+	MinRequests int
+	// FailureRate trips the circuit when failed calls reach this share of the
+	// window, between 0 and 1. Default 0.5.
+	FailureRate float64
+	// ConsecutiveFailures trips the circuit after that many failures in a row,
+	// regardless of the window. Zero disables the policy.
+	ConsecutiveFailures int
+	// SlowCallDuration marks calls taking at least this long as slow. Zero
+	// disables the slow call policy.
+	SlowCallDuration time.Duration
+	// SlowCallRate trips the circuit when slow calls reach this share of the
+	// window. Default 1.
+	SlowCallRate float64
+	// OpenTimeout is how long the circuit stays open before probing. Default 30s.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of probes let through in the half-open
+	// state; the circuit closes when they all succeed. Default 1.
+	HalfOpenRequests int
+	// IsFailure classifies a call. The default counts transport errors and 5xx
+	// responses; a cancelled caller context is never counted.
+	IsFailure func(resp *Response, err error) bool
+	// OnStateChange is called after every transition.
+	OnStateChange func(name string, from, to CircuitState)
+}
+
+func (cfg *BreakerConfig) applyDefaults() {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.Buckets <= 0 {
+		cfg.Buckets = 10
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 10
+	}
+	if cfg.FailureRate <= 0 {
+		cfg.FailureRate = 0.5
+	}
+	if cfg.SlowCallRate <= 0 {
+		cfg.SlowCallRate = 1
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = DefaultBreakerFailure
+	}
+}
+
+// DefaultBreakerFailure counts transport errors and 5xx responses as failures.
+func DefaultBreakerFailure(resp *Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp != nil && resp.StatusCode >= http.StatusInternalServerError
+}
+
+// BreakerCounts are the calls measured in the current window.
+type BreakerCounts struct {
+	Requests            int
+	Failures            int
+	SlowCalls           int
+	ConsecutiveFailures int
+}
+
+type breakerBucket struct {
+	epoch     int64
+	requests  int
+	failures  int
+	slowCalls int
+}
+
+// CircuitBreaker stops calling an upstream that keeps failing. It is closed
+// while calls succeed, opens when a trip policy fires, and after OpenTimeout
+// lets HalfOpenRequests probes through to decide whether to close again.
+type CircuitBreaker struct {
+	name string
+	cfg  BreakerConfig
+
+	mu          sync.Mutex
+	state       CircuitState
+	generation  uint64
+	openedAt    time.Time
+	buckets     []breakerBucket
+	consecutive int
+	probes      int
+	successes   int
+
+	now func() time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker.
+func NewCircuitBreaker(name string, cfg BreakerConfig) *CircuitBreaker {
+	cfg.applyDefaults()
+	return &CircuitBreaker{
+		name:    name,
+		cfg:     cfg,
+		buckets: make([]breakerBucket, cfg.Buckets),
+		now:     time.Now,
+	}
+}
+
+func (cb *CircuitBreaker) Name() string {
+	return cb.name
+}
+
+// State returns the current state, moving an expired open circuit to half-open.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	from, to := cb.refresh(cb.now())
+	state := cb.state
+	cb.mu.Unlock()
+	cb.notify(from, to)
+	return state
+}
+
+// Counts returns the calls measured in the current window.
+func (cb *CircuitBreaker) Counts() BreakerCounts {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	counts := cb.windowCounts(cb.now())
+	counts.ConsecutiveFailures = cb.consecutive
+	return counts
+}
+
+// Reset closes the circuit and forgets the measured calls.
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	from := cb.state
+	cb.setState(CircuitClosed, cb.now())
+	cb.mu.Unlock()
+	cb.notify(from, CircuitClosed)
+}
+
+// breakerCall is a call let through by allow, to be reported with done.
+type breakerCall struct {
+	cb         *CircuitBreaker
+	generation uint64
+	start      time.Time
+}
+
+// allow admits a call or returns a *CircuitOpenError.
+func (cb *CircuitBreaker) allow() (*breakerCall, error) {
+	cb.mu.Lock()
+	now := cb.now()
+	from, to := cb.refresh(now)
+	var err error
+	switch cb.state {
+	case CircuitOpen:
+		err = &CircuitOpenError{Name: cb.name, State: CircuitOpen, RetryAfter: cb.openedAt.Add(cb.cfg.OpenTimeout).Sub(now)}
+	case CircuitHalfOpen:
+		if cb.probes >= cb.cfg.HalfOpenRequests {
+			err = &CircuitOpenError{Name: cb.name, State: CircuitHalfOpen}
+		} else {
+			cb.probes++
+		}
+	}
+	generation := cb.generation
+	cb.mu.Unlock()
+	cb.notify(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &breakerCall{cb: cb, generation: generation, start: now}, nil
+}
+
+// done records the outcome of the call.
+func (c *breakerCall) done(resp *Response, err error) {
+	cb := c.cb
+	if err != nil && errors.Is(err, context.Canceled) {
+		c.ignore()
+		return
+	}
+	failed := cb.cfg.IsFailure(resp, err)
+
+	cb.mu.Lock()
+	now := cb.now()
+	slow := cb.cfg.SlowCallDuration > 0 && now.Sub(c.start) >= cb.cfg.SlowCallDuration
+	from, to := cb.refresh(now)
+	if c.generation == cb.generation {
+		switch cb.state {
+		case CircuitClosed:
+			cb.record(now, failed, slow)
+			if cb.shouldTrip(now) {
+				cb.setState(CircuitOpen, now)
+			}
+		case CircuitHalfOpen:
+			if failed || slow {
+				cb.setState(CircuitOpen, now)
+			} else if cb.successes++; cb.successes >= cb.cfg.HalfOpenRequests {
+				cb.setState(CircuitClosed, now)
+			}
+		}
+		if cb.state != from {
+			to = cb.state
+		}
+	}
+	cb.mu.Unlock()
+	cb.notify(from, to)
+}
+
+// ignore releases the call without counting it, e.g. when the caller gave up.
+func (c *breakerCall) ignore() {
+	cb := c.cb
+	cb.mu.Lock()
+	if c.generation == cb.generation && cb.state == CircuitHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+	cb.mu.Unlock()
+}
+
+// refresh moves an open circuit whose timeout expired to half-open. It returns
+// the transition to notify, if any. cb.mu must be held.
+func (cb *CircuitBreaker) refresh(now time.Time) (from, to CircuitState) {
+	if cb.state == CircuitOpen && !now.Before(cb.openedAt.Add(cb.cfg.OpenTimeout)) {
+		cb.setState(CircuitHalfOpen, now)
+		return CircuitOpen, CircuitHalfOpen
+	}
+	return cb.state, cb.state
+}
+
+func (cb *CircuitBreaker) setState(state CircuitState, now time.Time) {
+	cb.state = state
+	cb.generation++
+	cb.probes = 0
+	cb.successes = 0
+	cb.consecutive = 0
+	switch state {
+	case CircuitOpen:
+		cb.openedAt = now
+	case CircuitClosed:
+		for i := range cb.buckets {
+			cb.buckets[i] = breakerBucket{}
+		}
+	}
+}
+
+func (cb *CircuitBreaker) notify(from, to CircuitState) {
+	if from != to && cb.cfg.OnStateChange != nil {
+		cb.cfg.OnStateChange(cb.name, from, to)
+	}
+}
+
+func (cb *CircuitBreaker) epoch(now time.Time) int64 {
+	width := int64(cb.cfg.Window) / int64(len(cb.buckets))
+	if width <= 0 {
+		width = 1
+	}
+	return now.UnixNano() / width
+}
+
+func (cb *CircuitBreaker) record(now time.Time, failed, slow bool) {
+	epoch := cb.epoch(now)
+	b := &cb.buckets[epoch%int64(len(cb.buckets))]
+	if b.epoch != epoch {
+		*b = breakerBucket{epoch: epoch}
+	}
+	b.requests++
+	if failed {
+		b.failures++
+		cb.consecutive++
+	} else {
+		cb.consecutive = 0
+	}
+	if slow {
+		b.slowCalls++
+	}
+}
+
+func (cb *CircuitBreaker) windowCounts(now time.Time) BreakerCounts {
+	epoch := cb.epoch(now)
+	oldest := epoch - int64(len(cb.buckets))
+	var counts BreakerCounts
+	for _, b := range cb.buckets {
+		if b.epoch > oldest && b.epoch <= epoch {
+			counts.Requests += b.requests
+			counts.Failures += b.failures
+			counts.SlowCalls += b.slowCalls
+		}
+	}
+	return counts
+}
+
+func (cb *CircuitBreaker) shouldTrip(now time.Time) bool {
+	if cb.cfg.ConsecutiveFailures > 0 && cb.consecutive >= cb.cfg.ConsecutiveFailures {
+		return true
+	}
+	counts := cb.windowCounts(now)
+	if counts.Requests < cb.cfg.MinRequests {
+		return false
+	}
+	total := float64(counts.Requests)
+	if float64(counts.Failures)/total >= cb.cfg.FailureRate {
+		return true
+	}
+	return cb.cfg.SlowCallDuration > 0 && float64(counts.SlowCalls)/total >= cb.cfg.SlowCallRate
+}
+
+// CircuitBreakers keeps one CircuitBreaker per upstream host, created on first
+// use with the same configuration.
+type CircuitBreakers struct {
+	cfg      BreakerConfig
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+func NewCircuitBreakers(cfg BreakerConfig) *CircuitBreakers {
+	return &CircuitBreakers{cfg: cfg, breakers: make(map[string]*CircuitBreaker)}
+}
+
+// Get returns the breaker of host, a host[:port] as found in request URLs.
+func (s *CircuitBreakers) Get(host string) *CircuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cb, ok := s.breakers[host]
+	if !ok {
+		cb = NewCircuitBreaker(host, s.cfg)
+		s.breakers[host] = cb
+	}
+	return cb
+}
+
+// WithCircuitBreaker guards every request of the client with cb.
+func WithCircuitBreaker(cb *CircuitBreaker) ClientOption {
+	return func(c *Client) {
+		c.breaker = cb
+	}
+}
+
+// WithHostCircuitBreakers guards requests with one breaker per upstream host.
+func WithHostCircuitBreakers(cfg BreakerConfig) ClientOption {
+	return func(c *Client) {
+		c.hostBreakers = NewCircuitBreakers(cfg)
+	}
+}
+
+func (c *Client) SetCircuitBreaker(cb *CircuitBreaker) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.breaker = cb
+	return c
+}
+
+// HostCircuitBreakers returns the per-host breakers, or nil when not enabled.
+func (c *Client) HostCircuitBreakers() *CircuitBreakers {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hostBreakers
+}
+
+// breakerFor picks the breaker guarding r: the request's or endpoint's own, then
+// the one of its host, then the client's.
+func (c *Client) breakerFor(r *Request) *CircuitBreaker {
+	if r.breaker != nil {
+		return r.breaker
+	}
+	c.mu.RLock()
+	hostBreakers, breaker := c.hostBreakers, c.breaker
+	c.mu.RUnlock()
+	if hostBreakers != nil {
+		if u, err := url.Parse(r.URL); err == nil && u.Host != "" {
+			return hostBreakers.Get(u.Host)
+		}
+	}
+	return breaker
+}
@@ -0,0 +1,170 @@
+package gclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBreaker(cfg BreakerConfig) (*CircuitBreaker, *fakeClock) {
+	cb := NewCircuitBreaker("test", cfg)
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	cb.now = clock.now
+	return cb, clock
+}
+
+func callBreaker(t *testing.T, cb *CircuitBreaker, status int) {
+	t.Helper()
+	call, err := cb.allow()
+	if err != nil {
+		t.Fatalf("call rejected: %v", err)
+	}
+	call.done(&Response{StatusCode: status}, nil)
+}
+
+func TestCircuitBreakerFailureRateAndHalfOpen(t *testing.T) {
+	var transitions []string
+	cb, clock := newTestBreaker(BreakerConfig{
+		MinRequests: 4,
+		FailureRate: 0.5,
+		OpenTimeout: time.Minute,
+		OnStateChange: func(name string, from, to CircuitState) {
+			transitions = append(transitions, fmt.Sprintf("%s:%s->%s", name, from, to))
+		},
+	})
+
+	callBreaker(t, cb, http.StatusOK)
+	callBreaker(t, cb, http.StatusInternalServerError)
+	callBreaker(t, cb, http.StatusOK)
+	if cb.State() != CircuitClosed {
+		t.Fatal("circuit must stay closed below MinRequests")
+	}
+	callBreaker(t, cb, http.StatusBadGateway)
+	if cb.State() != CircuitOpen {
+		t.Fatalf("expected open at 50%% failures, got %s (%+v)", cb.State(), cb.Counts())
+	}
+
+	clock.advance(20 * time.Second)
+	_, err := cb.allow()
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) || openErr.RetryAfter != 40*time.Second {
+		t.Fatalf("expected a CircuitOpenError, got %v", err)
+	}
+
+	// After the timeout a single probe decides.
+	clock.advance(40 * time.Second)
+	probe, err := cb.allow()
+	if err != nil || cb.State() != CircuitHalfOpen {
+		t.Fatalf("expected a half-open probe, got %v %s", err, cb.State())
+	}
+	if _, err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("a second probe must be rejected, got %v", err)
+	}
+	probe.done(nil, errors.New("connection refused"))
+	if cb.State() != CircuitOpen {
+		t.Fatal("a failed probe must reopen the circuit")
+	}
+	clock.advance(time.Minute)
+	callBreaker(t, cb, http.StatusOK)
+	if cb.State() != CircuitClosed || cb.Counts().Requests != 0 {
+		t.Fatalf("a successful probe must close the circuit, got %s", cb.State())
+	}
+
+	want := []string{
+		"test:closed->open", "test:open->half-open", "test:half-open->open",
+		"test:open->half-open", "test:half-open->closed",
+	}
+	if fmt.Sprint(transitions) != fmt.Sprint(want) {
+		t.Fatalf("unexpected transitions %v", transitions)
+	}
+}
+
+func TestCircuitBreakerConsecutiveSlowAndWindow(t *testing.T) {
+	cb, clock := newTestBreaker(BreakerConfig{ConsecutiveFailures: 3, MinRequests: 100})
+	callBreaker(t, cb, http.StatusInternalServerError)
+	callBreaker(t, cb, http.StatusInternalServerError)
+	callBreaker(t, cb, http.StatusOK)
+	callBreaker(t, cb, http.StatusInternalServerError)
+	callBreaker(t, cb, http.StatusInternalServerError)
+	if cb.State() != CircuitClosed {
+		t.Fatal("a success must reset the consecutive failures")
+	}
+	callBreaker(t, cb, http.StatusInternalServerError)
+	if cb.State() != CircuitOpen {
+		t.Fatal("expected open after 3 consecutive failures")
+	}
+
+	cb, clock = newTestBreaker(BreakerConfig{MinRequests: 2, SlowCallDuration: time.Second, SlowCallRate: 0.5})
+	for i := 0; i < 2; i++ {
+		call, _ := cb.allow()
+		clock.advance(2 * time.Second)
+		call.done(&Response{StatusCode: http.StatusOK}, nil)
+	}
+	if cb.State() != CircuitOpen {
+		t.Fatal("expected slow calls to open the circuit")
+	}
+
+	// Failures older than the window are forgotten.
+	cb, clock = newTestBreaker(BreakerConfig{MinRequests: 2, Window: 10 * time.Second})
+	callBreaker(t, cb, http.StatusInternalServerError)
+	clock.advance(11 * time.Second)
+	callBreaker(t, cb, http.StatusOK)
+	if counts := cb.Counts(); counts.Requests != 1 || cb.State() != CircuitClosed {
+		t.Fatalf("expected only the recent call in the window, got %+v", counts)
+	}
+}
+
+func TestClientCircuitBreakerStopsRetries(t *testing.T) {
+	var hits int32
+	executor := newMockExecutor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	client := NewClient(
+		WithExecutor(executor),
+		WithHostCircuitBreakers(BreakerConfig{ConsecutiveFailures: 2, OpenTimeout: time.Hour}),
+		WithRetry(&RetryConfig{
+			MaxRetries:      5,
+			RetryConditions: []RetryCondition{DefaultRetryCondition},
+			Backoff:         func(int) time.Duration { return 0 },
+		}),
+	)
+
+	resp, err := client.R().Get("http://down.test/a")
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the last 503, got %v %v", resp, err)
+	}
+	if hits != 2 {
+		t.Fatalf("retries must stop once the circuit opens, got %d calls", hits)
+	}
+
+	_, err = client.R().Get("http://down.test/b")
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || openErr.Name != "down.test" || hits != 2 {
+		t.Fatalf("expected a fast failure, got %v after %d calls", err, hits)
+	}
+	if DefaultRetryCondition(nil, err) {
+		t.Fatal("an open circuit must not be retried")
+	}
+
+	// Other hosts have their own breaker, endpoints can bring theirs.
+	if _, err := client.R().Get("http://other.test/"); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("other hosts must not be affected")
+	}
+	endpointBreaker := NewCircuitBreaker("endpoint", BreakerConfig{})
+	if _, err := client.NewEndpoint(http.MethodGet, "http://down.test/c").SetCircuitBreaker(endpointBreaker).Execute(); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("the endpoint breaker must take precedence")
+	}
+	if client.HostCircuitBreakers().Get("down.test").State() != CircuitOpen {
+		t.Fatal("expected the host breaker to stay open")
+	}
+}
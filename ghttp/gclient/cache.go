@@ -1,8 +1,669 @@
 package gclient
 
-import "time"
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/sofiworker/gk/gcache"
+)
+
+// Cache stores the responses of the HTTP cache. Implementations may also
+// provide Delete(key string) to evict entries invalidated by unsafe requests.
 type Cache interface {
 	Get(key string) ([]byte, bool)
 	Set(key string, data []byte, expiration time.Duration)
 }
+
+type cacheDeleter interface {
+	Delete(key string)
+}
+
+// CacheConfig configures the HTTP cache installed by WithHTTPCache.
+type CacheConfig struct {
+	// Storage keeps the cached responses. NewGCacheStorage adapts memory, Redis
+	// and Valkey caches. Required.
+	Storage Cache
+	// Shared makes the cache behave as a shared cache: responses marked private
+	// or answering requests with Authorization are not stored unless allowed,
+	// and s-maxage takes precedence over max-age.
+	Shared bool
+	// DefaultTTL is the freshness lifetime of cacheable responses without
+	// explicit expiration time. Zero uses 10% of the age of Last-Modified.
+	DefaultTTL time.Duration
+	// KeepStale is how long stale responses with validators are kept to be
+	// revalidated with a conditional request. Default 1h.
+	KeepStale time.Duration
+}
+
+// CacheStatus tells how the HTTP cache answered a request.
+type CacheStatus string
+
+const (
+	// CacheMiss is a response fetched from the origin.
+	CacheMiss CacheStatus = "miss"
+	// CacheHit is a fresh stored response.
+	CacheHit CacheStatus = "hit"
+	// CacheStale is a stale stored response, allowed by max-stale,
+	// stale-while-revalidate or stale-if-error.
+	CacheStale CacheStatus = "stale"
+	// CacheRevalidated is a stored response validated by a 304 Not Modified.
+	CacheRevalidated CacheStatus = "revalidated"
+)
+
+const (
+	defaultKeepStale = time.Hour
+	// maxHeuristicTTL bounds the freshness computed from Last-Modified.
+	maxHeuristicTTL  = 24 * time.Hour
+	maxCacheVariants = 8
+)
+
+// WithHTTPCache caches the responses of GET requests following RFC 9111:
+// Cache-Control, Expires and Vary are honored and stale responses are
+// revalidated with If-None-Match and If-Modified-Since. Requests opt out with
+// Request.DisableCache. It panics without cfg.Storage.
+func WithHTTPCache(cfg CacheConfig) ClientOption {
+	if cfg.Storage == nil {
+		panic("gclient: CacheConfig.Storage is required")
+	}
+	return func(c *Client) {
+		c.cache = cfg.Storage
+		c.cacheConfig = &cfg
+	}
+}
+
+// httpCache applies the caching rules to one request.
+type httpCache struct {
+	client  *Client
+	storage Cache
+	cfg     CacheConfig
+	key     string
+}
+
+// httpCacheFor returns the cache of r, or nil when r is not cached. Without
+// WithHTTPCache only requests calling UseCache are.
+func (c *Client) httpCacheFor(r *Request) *httpCache {
+	c.mu.RLock()
+	storage, cfg := c.cache, c.cacheConfig
+	c.mu.RUnlock()
+	if storage == nil || r.skipCache || (cfg == nil && !r.useCache) {
+		return nil
+	}
+	hc := &httpCache{client: c, storage: storage, key: r.cacheKey}
+	if cfg != nil {
+		hc.cfg = *cfg
+	}
+	if r.cacheTTL > 0 {
+		hc.cfg.DefaultTTL = r.cacheTTL
+	}
+	if hc.cfg.KeepStale <= 0 {
+		hc.cfg.KeepStale = defaultKeepStale
+	}
+	if hc.key == "" {
+		hc.key = r.URL
+	}
+	return hc
+}
+
+// cacheLookup is the outcome of looking a request up.
+type cacheLookup struct {
+	// response answers the request without contacting the origin.
+	response *Response
+	// entry is the stored response being revalidated, usable on errors
+	// within stale-if-error.
+	entry *cacheEntry
+	// conditional is set when the validators of entry were added to the request.
+	conditional bool
+}
+
+func (h *httpCache) lookup(r *Request) cacheLookup {
+	if !isCacheableMethod(r.Method) {
+		return cacheLookup{}
+	}
+	reqCC := parseCacheControl(r.Header)
+	if reqCC.has("no-store") {
+		return cacheLookup{}
+	}
+	entry := h.find(h.client.cacheRequestHeader(r))
+	if entry == nil {
+		if reqCC.has("only-if-cached") {
+			return cacheLookup{response: h.gatewayTimeout(r)}
+		}
+		return cacheLookup{}
+	}
+
+	now := time.Now()
+	respCC := parseCacheControl(entry.Header)
+	age := entry.age(now)
+	lifetime := entry.lifetime(h.cfg, respCC)
+	noCache := reqCC.has("no-cache") || respCC.has("no-cache") ||
+		(len(r.Header.Values("Cache-Control")) == 0 && strings.EqualFold(r.Header.Get("Pragma"), "no-cache"))
+
+	if !noCache && age < lifetime && reqCC.allowsAge(age, lifetime) {
+		return cacheLookup{response: h.respond(r, entry, now, CacheHit)}
+	}
+	staleness := age - lifetime
+	canServeStale := !noCache && !h.mustRevalidate(respCC)
+	if canServeStale {
+		if maxStale, ok := reqCC["max-stale"]; ok {
+			if d, err := parseDeltaSeconds(maxStale); maxStale == "" || (err == nil && staleness <= d) {
+				return cacheLookup{response: h.respond(r, entry, now, CacheStale)}
+			}
+		}
+		if swr, ok := respCC.seconds("stale-while-revalidate"); ok && staleness < swr && entry.hasValidators() {
+			h.revalidateInBackground(r)
+			return cacheLookup{response: h.respond(r, entry, now, CacheStale)}
+		}
+	}
+	if reqCC.has("only-if-cached") {
+		return cacheLookup{response: h.gatewayTimeout(r)}
+	}
+
+	lookup := cacheLookup{entry: entry}
+	if r.Header.Get("If-None-Match") == "" && r.Header.Get("If-Modified-Since") == "" && entry.hasValidators() {
+		if r.Header == nil {
+			r.Header = make(http.Header)
+		}
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		if lm := entry.Header.Get("Last-Modified"); lm != "" {
+			r.Header.Set("If-Modified-Since", lm)
+		}
+		lookup.conditional = true
+	}
+	return lookup
+}
+
+// update stores, revalidates or replaces the response of the origin.
+func (h *httpCache) update(r *Request, lookup cacheLookup, requestTime time.Time, resp *Response, err error) (*Response, error) {
+	if lookup.conditional {
+		r.Header.Del("If-None-Match")
+		r.Header.Del("If-Modified-Since")
+	}
+	if !isCacheableMethod(r.Method) {
+		if err == nil && resp != nil {
+			h.invalidate(r, resp)
+		}
+		return resp, err
+	}
+	now := time.Now()
+
+	if lookup.entry != nil && h.staleIfError(r, lookup.entry, now, resp, err) {
+		return h.respond(r, lookup.entry, now, CacheStale), nil
+	}
+	if err != nil || resp == nil {
+		return resp, err
+	}
+
+	if lookup.conditional && resp.StatusCode == http.StatusNotModified {
+		entry := lookup.entry
+		entry.refresh(resp.Header, requestTime, now)
+		h.store(r, entry, now)
+		revalidated := h.respond(r, entry, now, CacheRevalidated)
+		revalidated.Duration = resp.Duration
+		revalidated.Attempts = resp.Attempts
+		return revalidated, nil
+	}
+
+	resp.cacheStatus = CacheMiss
+	entry := &cacheEntry{
+		Status:       resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         resp.Body,
+		RequestTime:  requestTime,
+		ResponseTime: now,
+	}
+	if h.storable(r, entry) {
+		h.store(r, entry, now)
+	}
+	return resp, nil
+}
+
+// staleIfError reports whether a stale entry may replace a failed response.
+func (h *httpCache) staleIfError(r *Request, entry *cacheEntry, now time.Time, resp *Response, err error) bool {
+	if err == nil && (resp == nil || !isServerError(resp.StatusCode)) {
+		return false
+	}
+	respCC := parseCacheControl(entry.Header)
+	if h.mustRevalidate(respCC) {
+		return false
+	}
+	window, ok := respCC.seconds("stale-if-error")
+	if d, reqOK := parseCacheControl(r.Header).seconds("stale-if-error"); reqOK {
+		window, ok = d, true
+	}
+	return ok && entry.age(now)-entry.lifetime(h.cfg, respCC) < window
+}
+
+func isServerError(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (h *httpCache) mustRevalidate(cc cacheControl) bool {
+	return cc.has("must-revalidate") || (h.cfg.Shared && (cc.has("proxy-revalidate") || cc.has("s-maxage")))
+}
+
+// storable implements the storage rules of RFC 9111 section 3.
+func (h *httpCache) storable(r *Request, entry *cacheEntry) bool {
+	reqCC := parseCacheControl(r.Header)
+	respCC := parseCacheControl(entry.Header)
+	if reqCC.has("no-store") || respCC.has("no-store") {
+		return false
+	}
+	if entry.Status == http.StatusPartialContent || entry.Status < 200 || entry.Status == http.StatusNotModified {
+		return false
+	}
+	if entry.Header.Get("Vary") == "*" {
+		return false
+	}
+	if h.cfg.Shared {
+		if respCC.has("private") {
+			return false
+		}
+		if r.RawRequest != nil && r.RawRequest.Header.Get("Authorization") != "" &&
+			!respCC.has("public") && !respCC.has("must-revalidate") && !respCC.has("s-maxage") {
+			return false
+		}
+	}
+	explicit := respCC.has("public") || respCC.has("max-age") || entry.Header.Get("Expires") != "" ||
+		(h.cfg.Shared && respCC.has("s-maxage")) || (!h.cfg.Shared && respCC.has("private"))
+	return explicit || heuristicallyCacheable(entry.Status)
+}
+
+func heuristicallyCacheable(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusPermanentRedirect,
+		http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// store saves entry as the variant matching the request headers.
+func (h *httpCache) store(r *Request, entry *cacheEntry, now time.Time) {
+	respCC := parseCacheControl(entry.Header)
+	retention := entry.lifetime(h.cfg, respCC) - entry.age(now)
+	var extra time.Duration
+	if entry.hasValidators() {
+		extra = h.cfg.KeepStale
+	}
+	for _, directive := range []string{"stale-while-revalidate", "stale-if-error"} {
+		if d, ok := respCC.seconds(directive); ok && d > extra {
+			extra = d
+		}
+	}
+	retention += extra
+	if retention <= 0 {
+		return
+	}
+	entry.Until = now.Add(retention)
+	entry.Vary = varyValues(entry.Header, h.client.cacheRequestHeader(r))
+
+	variants := []*cacheEntry{entry}
+	until := entry.Until
+	for _, v := range h.load() {
+		if len(variants) == maxCacheVariants || !v.Until.After(now) || sameVary(v.Vary, entry.Vary) {
+			continue
+		}
+		variants = append(variants, v)
+		if v.Until.After(until) {
+			until = v.Until
+		}
+	}
+	data, err := json.Marshal(variants)
+	if err != nil {
+		return
+	}
+	h.storage.Set(h.key, data, until.Sub(now))
+}
+
+func (h *httpCache) load() []*cacheEntry {
+	data, ok := h.storage.Get(h.key)
+	if !ok || len(data) == 0 {
+		return nil
+	}
+	var variants []*cacheEntry
+	if err := json.Unmarshal(data, &variants); err != nil {
+		return nil
+	}
+	return variants
+}
+
+// find returns the stored variant selected by the request headers.
+func (h *httpCache) find(header http.Header) *cacheEntry {
+	now := time.Now()
+	for _, v := range h.load() {
+		if v == nil || !v.Until.After(now) {
+			continue
+		}
+		if sameVary(v.Vary, varyValues(v.Header, header)) {
+			return v
+		}
+	}
+	return nil
+}
+
+// invalidate evicts the responses of the target URI after a successful
+// unsafe request, as well as those of Location and Content-Location on the
+// same host.
+func (h *httpCache) invalidate(r *Request, resp *Response) {
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return
+	}
+	h.evict(h.key)
+	base, err := url.Parse(r.URL)
+	if err != nil {
+		return
+	}
+	for _, name := range []string{"Location", "Content-Location"} {
+		value := resp.Header.Get(name)
+		if value == "" {
+			continue
+		}
+		if target, err := base.Parse(value); err == nil && target.Host == base.Host {
+			h.evict(target.String())
+		}
+	}
+}
+
+func (h *httpCache) evict(key string) {
+	if d, ok := h.storage.(cacheDeleter); ok {
+		d.Delete(key)
+		return
+	}
+	h.storage.Set(key, nil, time.Nanosecond)
+}
+
+// revalidateInBackground refreshes the stored response of r once per key.
+func (h *httpCache) revalidateInBackground(r *Request) {
+	if _, busy := h.client.revalidating.LoadOrStore(h.key, true); busy {
+		return
+	}
+	bg := r.Clone()
+	bg.ctx = context.WithoutCancel(r.Context())
+	bg.Result, bg.ResultError = nil, nil
+	bg.isResponseSaveToFile = false
+	if bg.Header == nil {
+		bg.Header = make(http.Header)
+	}
+	// Forces the conditional request instead of serving the stale entry again.
+	bg.Header.Set("Cache-Control", "no-cache")
+	go func() {
+		defer h.client.revalidating.Delete(h.key)
+		requestTime := time.Now()
+		lookup := h.lookup(bg)
+		resp, err := h.client.send(bg)
+		_, _ = h.update(bg, lookup, requestTime, resp, err)
+	}()
+}
+
+// respond builds the response served from entry.
+func (h *httpCache) respond(r *Request, entry *cacheEntry, now time.Time, status CacheStatus) *Response {
+	header := http.Header(entry.Header).Clone()
+	header.Set("Age", strconv.FormatInt(int64(entry.age(now)/time.Second), 10))
+	resp := &Response{
+		client:      h.client,
+		Request:     r,
+		StatusCode:  entry.Status,
+		Status:      strconv.Itoa(entry.Status) + " " + http.StatusText(entry.Status),
+		Header:      header,
+		Body:        entry.Body,
+		ContentType: header.Get("Content-Type"),
+		cacheStatus: status,
+	}
+	_ = resp.bindResult()
+	return resp
+}
+
+// gatewayTimeout answers only-if-cached requests without stored response.
+func (h *httpCache) gatewayTimeout(r *Request) *Response {
+	return &Response{
+		client:      h.client,
+		Request:     r,
+		StatusCode:  http.StatusGatewayTimeout,
+		Status:      "504 " + http.StatusText(http.StatusGatewayTimeout),
+		Header:      make(http.Header),
+		cacheStatus: CacheMiss,
+	}
+}
+
+// cacheRequestHeader returns the headers the request is sent with, for Vary.
+func (c *Client) cacheRequestHeader(r *Request) http.Header {
+	header := c.cloneDefaultHeaders()
+	for k, v := range r.Header {
+		header[k] = v
+	}
+	return header
+}
+
+func isCacheableMethod(method string) bool {
+	return method == "" || strings.EqualFold(method, http.MethodGet)
+}
+
+type cacheEntry struct {
+	Status int                 `json:"status"`
+	Header http.Header         `json:"header"`
+	Body   []byte              `json:"body"`
+	Vary   map[string][]string `json:"vary,omitempty"`
+	// RequestTime and ResponseTime frame the exchange that produced the entry,
+	// for the age calculation of RFC 9111 section 4.2.3.
+	RequestTime  time.Time `json:"request_time"`
+	ResponseTime time.Time `json:"response_time"`
+	// Until is when the entry stops being usable, even stale.
+	Until time.Time `json:"until"`
+}
+
+func (e *cacheEntry) date() time.Time {
+	if t, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return t
+	}
+	return e.ResponseTime
+}
+
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparent := e.ResponseTime.Sub(e.date())
+	if apparent < 0 {
+		apparent = 0
+	}
+	corrected := e.ResponseTime.Sub(e.RequestTime)
+	if v, err := parseDeltaSeconds(e.Header.Get("Age")); err == nil {
+		corrected += v
+	}
+	if apparent > corrected {
+		corrected = apparent
+	}
+	return corrected + now.Sub(e.ResponseTime)
+}
+
+func (e *cacheEntry) lifetime(cfg CacheConfig, cc cacheControl) time.Duration {
+	if cfg.Shared {
+		if d, ok := cc.seconds("s-maxage"); ok {
+			return d
+		}
+	}
+	if d, ok := cc.seconds("max-age"); ok {
+		return d
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(e.date())
+	}
+	if !heuristicallyCacheable(e.Status) && !cc.has("public") {
+		return 0
+	}
+	if cfg.DefaultTTL > 0 {
+		return cfg.DefaultTTL
+	}
+	if lm, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil {
+		if d := e.date().Sub(lm) / 10; d > 0 {
+			return min(d, maxHeuristicTTL)
+		}
+	}
+	return 0
+}
+
+func (e *cacheEntry) hasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// refresh applies the headers of a 304 response, RFC 9111 section 4.3.4.
+func (e *cacheEntry) refresh(header http.Header, requestTime, responseTime time.Time) {
+	for k, v := range header {
+		switch k {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding", "Content-Range":
+			continue
+		}
+		e.Header[k] = append([]string(nil), v...)
+	}
+	if header.Get("Age") == "" {
+		e.Header.Del("Age")
+	}
+	e.RequestTime, e.ResponseTime = requestTime, responseTime
+}
+
+// varyValues returns the request headers named by the Vary header of a response.
+func varyValues(respHeader, reqHeader http.Header) map[string][]string {
+	var values map[string][]string
+	for _, field := range respHeader.Values("Vary") {
+		for _, name := range strings.Split(field, ",") {
+			name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if values == nil {
+				values = make(map[string][]string)
+			}
+			var normalized []string
+			for _, v := range reqHeader.Values(name) {
+				for _, part := range strings.Split(v, ",") {
+					if part = strings.TrimSpace(part); part != "" {
+						normalized = append(normalized, part)
+					}
+				}
+			}
+			values[name] = normalized
+		}
+	}
+	return values
+}
+
+func sameVary(a, b map[string][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, av := range a {
+		bv, ok := b[k]
+		if !ok || strings.Join(av, ",") != strings.Join(bv, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheControl holds the directives of Cache-Control headers, lower-cased.
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, field := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(field, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(directive, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	value, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	d, err := parseDeltaSeconds(value)
+	if err != nil {
+		// An invalid value is treated as already stale.
+		return 0, directive == "max-age" || directive == "s-maxage"
+	}
+	return d, true
+}
+
+// allowsAge applies the max-age and min-fresh request directives.
+func (cc cacheControl) allowsAge(age, lifetime time.Duration) bool {
+	if d, ok := cc.seconds("max-age"); ok && age > d {
+		return false
+	}
+	if d, ok := cc.seconds("min-fresh"); ok && lifetime-age < d {
+		return false
+	}
+	return true
+}
+
+func parseDeltaSeconds(value string) (time.Duration, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > int64(1<<31) {
+		n = 1 << 31
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// gcacheStorage adapts a gcache backend to Cache.
+type gcacheStorage struct {
+	store  gcache.KeyValueCache
+	prefix string
+}
+
+// NewGCacheStorage stores the HTTP cache in store, such as *gcache.MemoryCache,
+// *gcache.RedisCache or *gcache.ValkeyCache, under keys starting with prefix.
+func NewGCacheStorage(store gcache.KeyValueCache, prefix string) Cache {
+	if store == nil {
+		panic("gclient: gcache store is required")
+	}
+	return &gcacheStorage{store: store, prefix: prefix}
+}
+
+func (s *gcacheStorage) Get(key string) ([]byte, bool) {
+	data, err := s.store.Get(s.prefix + key)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (s *gcacheStorage) Set(key string, data []byte, expiration time.Duration) {
+	_ = s.store.Set(s.prefix+key, data, expiration)
+}
+
+func (s *gcacheStorage) Delete(key string) {
+	_ = s.store.Delete(s.prefix + key)
+}
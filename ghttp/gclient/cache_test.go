@@ -0,0 +1,159 @@
+package gclient
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sofiworker/gk/gcache"
+)
+
+func TestHTTPCacheFreshnessAndVary(t *testing.T) {
+	var hits int32
+	executor := newMockExecutor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		}
+		fmt.Fprintf(w, "%s#%d", r.Header.Get("Accept-Language"), n)
+	}))
+	client := NewClient(WithBaseURL("http://cache.test"), WithExecutor(executor),
+		WithHTTPCache(CacheConfig{Storage: newMemoryCache(), Shared: true}))
+
+	get := func(path, lang string) *Response {
+		t.Helper()
+		req := client.R()
+		if lang != "" {
+			req.SetHeader("Accept-Language", lang)
+		}
+		resp, err := req.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	first := get("/fresh", "")
+	second := get("/fresh", "")
+	if first.CacheStatus() != CacheMiss || second.CacheStatus() != CacheHit || second.String() != first.String() {
+		t.Fatalf("expected a fresh hit, got %s %q then %s %q", first.CacheStatus(), first, second.CacheStatus(), second)
+	}
+	if second.Header.Get("Age") == "" {
+		t.Fatal("cached responses must carry Age")
+	}
+	if resp, _ := client.R().DisableCache().Get("/fresh"); resp.CacheStatus() != "" || resp.String() == first.String() {
+		t.Fatal("DisableCache must bypass the cache")
+	}
+
+	if en, fr := get("/vary", "en"), get("/vary", "fr"); en.String() == fr.String() {
+		t.Fatal("variants must not be mixed up")
+	}
+	if en := get("/vary", "en"); en.CacheStatus() != CacheHit || en.String()[:3] != "en#" {
+		t.Fatalf("expected the en variant from the cache, got %s %q", en.CacheStatus(), en)
+	}
+
+	for _, path := range []string{"/no-store", "/private"} {
+		get(path, "")
+		if resp := get(path, ""); resp.CacheStatus() != CacheMiss {
+			t.Fatalf("%s must not be stored by a shared cache", path)
+		}
+	}
+
+	miss := client.R().SetHeader("Cache-Control", "only-if-cached")
+	if resp, err := miss.Get("/unknown"); err != nil || resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("only-if-cached must answer 504, got %v %v", resp, err)
+	}
+}
+
+func TestHTTPCacheRevalidation(t *testing.T) {
+	var hits, failing int32
+	executor := newMockExecutor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		switch r.URL.Path {
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache, stale-if-error=60")
+		case "/swr":
+			w.Header().Set("Cache-Control", "max-age=1, stale-while-revalidate=60")
+			w.Header().Set("Age", "5")
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.Header().Set("X-Revalidated", "yes")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, "body")
+	}))
+	client := NewClient(WithBaseURL("http://cache.test"), WithExecutor(executor),
+		WithHTTPCache(CacheConfig{Storage: newMemoryCache()}))
+
+	if resp, err := client.R().Get("/etag"); err != nil || resp.CacheStatus() != CacheMiss {
+		t.Fatalf("unexpected first response %v %v", resp, err)
+	}
+	resp, err := client.R().Get("/etag")
+	if err != nil || resp.CacheStatus() != CacheRevalidated || resp.StatusCode != http.StatusOK ||
+		resp.String() != "body" || resp.Header.Get("X-Revalidated") != "yes" || hits != 2 {
+		t.Fatalf("expected a revalidated 200, got %v %v after %d hits", resp, err, hits)
+	}
+
+	atomic.StoreInt32(&failing, 1)
+	if resp, err := client.R().Get("/etag"); err != nil || resp.CacheStatus() != CacheStale || resp.String() != "body" {
+		t.Fatalf("stale-if-error must serve the stored response, got %v %v", resp, err)
+	}
+	atomic.StoreInt32(&failing, 0)
+
+	client.R().Get("/swr")
+	before := atomic.LoadInt32(&hits)
+	if resp, _ := client.R().Get("/swr"); resp.CacheStatus() != CacheStale || resp.String() != "body" {
+		t.Fatalf("stale-while-revalidate must serve the stale response, got %s", resp.CacheStatus())
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&hits) == before {
+		if time.Now().After(deadline) {
+			t.Fatal("the stale response was not revalidated in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHTTPCacheGCacheStorageAndInvalidation(t *testing.T) {
+	store, err := gcache.NewMemoryCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	var hits int32
+	executor := newMockExecutor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "%d", n)
+	}))
+	client := NewClient(WithBaseURL("http://cache.test"), WithExecutor(executor),
+		WithHTTPCache(CacheConfig{Storage: NewGCacheStorage(store, "http:")}))
+
+	client.R().Get("/items")
+	if ok, _ := store.Exists("http:http://cache.test/items"); !ok {
+		t.Fatal("expected the response in the gcache store")
+	}
+	if resp, _ := client.R().Get("/items"); resp.CacheStatus() != CacheHit {
+		t.Fatal("expected a hit")
+	}
+	client.R().Post("/items")
+	if resp, _ := client.R().Get("/items"); resp.CacheStatus() != CacheMiss {
+		t.Fatal("a successful POST must invalidate the stored response")
+	}
+}
@@ -3,7 +3,6 @@ package gclient
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
@@ -39,9 +38,19 @@ type Client struct {
 
 	logger Logger
 	tracer Tracer
-	cache  Cache
+
+	cache       Cache
+	cacheConfig *CacheConfig
+	// revalidating holds the cache keys refreshed in the background.
+	revalidating sync.Map
+
+	breaker      *CircuitBreaker
+	hostBreakers *CircuitBreakers
+	discovery    *DiscoveryTransport
+	hedger       *Hedger
 
 	retryConfig *RetryConfig
+	retryBudget *retryBudget
 
 	requestMiddlewares  []RequestMiddleware
 	responseMiddlewares []ResponseMiddleware
@@ -121,7 +130,10 @@ func (c *Client) init() {
 		c.retryConfig = &rc
 	}
 	if c.retryConfig == nil {
-		c.retryConfig = DefaultRetryConfig()
+		c.retryConfig = &RetryConfig{}
+	}
+	if c.retryBudget == nil {
+		c.retryBudget = newRetryBudget(c.retryConfig)
 	}
 
 	if c.tracer == nil {
@@ -146,6 +158,7 @@ func (c *Client) init() {
 			c.httpClient = c.buildHTTPClient()
 		}
 	}
+	c.wrapDiscoveryLocked()
 
 	if c.baseURLRaw != "" && c.baseURL == nil {
 		if parsed, err := url.Parse(c.baseURLRaw); err == nil {
@@ -280,16 +293,18 @@ func (c *Client) SetCache(cache Cache) *Client {
 	return c
 }
 
+// SetRetryConfig sets the retry policy of the client; nil disables retries.
 func (c *Client) SetRetryConfig(cfg *RetryConfig) *Client {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if cfg == nil {
-		c.retryConfig = DefaultRetryConfig()
+		c.retryConfig = &RetryConfig{}
 	} else {
 		copyCfg := *cfg
 		copyCfg.RetryConditions = append([]RetryCondition(nil), cfg.RetryConditions...)
 		c.retryConfig = &copyCfg
 	}
+	c.retryBudget = newRetryBudget(c.retryConfig)
 	return c
 }
 
@@ -581,7 +596,13 @@ func (c *Client) Clone() *Client {
 		logger:                c.logger,
 		tracer:                c.tracer,
 		cache:                 c.cache,
+		cacheConfig:           c.cacheConfig,
+		breaker:               c.breaker,
+		hostBreakers:          c.hostBreakers,
+		discovery:             c.discovery,
+		hedger:                c.hedger,
 		retryConfig:           c.retryConfig,
+		retryBudget:           c.retryBudget,
 		requestMiddlewares:    append([]RequestMiddleware(nil), c.requestMiddlewares...),
 		responseMiddlewares:   append([]ResponseMiddleware(nil), c.responseMiddlewares...),
 		defaultHeaders:        c.defaultHeaders.Clone(),
@@ -769,23 +790,71 @@ func (c *Client) execute(r *Request) (*Response, error) {
 		return nil, err
 	}
 
-	if cached, ok, err := c.tryCacheHit(r); err == nil && ok {
-		return cached, nil
-	} else if err != nil {
-		return nil, err
+	cache := c.httpCacheFor(r)
+	var lookup cacheLookup
+	if cache != nil {
+		lookup = cache.lookup(r)
+		if lookup.response != nil {
+			return lookup.response, c.applyResponseMiddleware(lookup.response)
+		}
+	}
+
+	requestTime := time.Now()
+	resp, err := c.send(r)
+	if cache != nil {
+		resp, err = cache.update(r, lookup, requestTime, resp, err)
+	}
+	if err != nil {
+		return resp, err
 	}
 
+	if err := c.applyResponseMiddleware(resp); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// send performs the request, with retries and circuit breaking.
+func (c *Client) send(r *Request) (*Response, error) {
 	builder := newHTTPRequestBuilder(r, c)
 	executor := c.effectiveExecutorForRequest(r)
+	breaker := c.breakerFor(r)
+	hedger := c.hedgerFor(r)
 
 	attempt := 0
 	start := time.Now()
 	var lastErr error
 	var resp *Response
+	var attempts []Attempt
+	finish := func(resp *Response, err error) (*Response, error) {
+		if resp != nil {
+			resp.Attempts = attempts
+		} else if err != nil && len(attempts) > 0 {
+			err = &AttemptsError{Err: err, Attempts: attempts}
+		}
+		return resp, err
+	}
+	if c.retryBudget != nil {
+		c.retryBudget.deposit()
+	}
 
 	for {
+		var call *breakerCall
+		if breaker != nil {
+			var openErr error
+			if call, openErr = breaker.allow(); openErr != nil {
+				if attempt == 0 {
+					return nil, openErr
+				}
+				break
+			}
+		}
+
 		httpReq, err := builder.Build()
 		if err != nil {
+			if call != nil {
+				call.ignore()
+			}
 			return nil, err
 		}
 		r.RawRequest = httpReq
@@ -803,6 +872,7 @@ func (c *Client) execute(r *Request) (*Response, error) {
 			ctx = context.Background()
 			httpReq = httpReq.WithContext(ctx)
 		}
+		baseCtx := ctx
 
 		tracer := c.tracer
 		if r.tracer != nil {
@@ -824,7 +894,14 @@ func (c *Client) execute(r *Request) (*Response, error) {
 			httpReq = httpReq.WithContext(ctx)
 		}
 
-		httpResp, execErr := executor.Do(httpReq)
+		attemptStart := time.Now()
+		var httpResp *http.Response
+		var execErr error
+		if hedger != nil && isIdempotent(httpReq) {
+			httpResp, execErr = hedger.do(executor, httpReq, tracer)
+		} else {
+			httpResp, execErr = executor.Do(httpReq)
+		}
 		if cancel != nil {
 			cancel()
 		}
@@ -832,40 +909,42 @@ func (c *Client) execute(r *Request) (*Response, error) {
 			spanEnd()
 		}
 
+		var attemptResp *Response
 		if execErr != nil {
-			lastErr = execErr
-			if !c.shouldRetry(nil, execErr, attempt, time.Since(start)) {
-				return nil, execErr
+			resp, lastErr = nil, execErr
+			if call != nil {
+				call.done(nil, execErr)
 			}
 		} else {
 			resp, lastErr = c.buildResponse(r, httpResp, time.Since(start))
-			if lastErr != nil {
-				if !c.shouldRetry(resp, lastErr, attempt, time.Since(start)) {
-					return resp, lastErr
-				}
-			} else if c.shouldRetry(resp, nil, attempt, time.Since(start)) {
-				// continue retry loop
-			} else {
-				break
+			attemptResp = resp
+			if call != nil {
+				call.done(resp, lastErr)
 			}
 		}
+		record := Attempt{Err: lastErr, Start: attemptStart, Duration: time.Since(attemptStart)}
+		if attemptResp != nil {
+			record.StatusCode = attemptResp.StatusCode
+		}
+		attempts = append(attempts, record)
+
+		delay, retry := c.nextRetry(httpReq, attemptResp, lastErr, attempt, time.Since(start))
+		// Retries stop as soon as the circuit opens.
+		if retry && breaker != nil && breaker.State() == CircuitOpen {
+			retry = false
+		}
+		if !retry {
+			return finish(resp, lastErr)
+		}
 
+		attempts[len(attempts)-1].Delay = delay
 		attempt++
-		if !c.retryDelay(ctx, attempt) {
+		if sleepWithContext(baseCtx, delay) != nil {
 			break
 		}
 	}
 
-	if lastErr != nil {
-		return resp, lastErr
-	}
-
-	if err := c.applyResponseMiddleware(resp); err != nil {
-		return resp, err
-	}
-
-	c.storeCache(r, resp)
-	return resp, nil
+	return finish(resp, lastErr)
 }
 
 func (c *Client) applyRequestMiddleware(r *Request) error {
@@ -909,6 +988,9 @@ func (c *Client) shouldRetry(resp *Response, err error, attempt int, elapsed tim
 	if c.retryConfig.MaxRetryTime > 0 && elapsed >= c.retryConfig.MaxRetryTime {
 		return false
 	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
 	for _, cond := range c.retryConfig.RetryConditions {
 		if cond != nil && cond(resp, err) {
 			return true
@@ -917,26 +999,6 @@ func (c *Client) shouldRetry(resp *Response, err error, attempt int, elapsed tim
 	return false
 }
 
-func (c *Client) retryDelay(ctx context.Context, attempt int) bool {
-	if c.retryConfig == nil || c.retryConfig.Backoff == nil {
-		return true
-	}
-	delay := c.retryConfig.Backoff(attempt)
-	if delay <= 0 {
-		return true
-	}
-
-	timer := time.NewTimer(delay)
-	defer timer.Stop()
-
-	select {
-	case <-ctx.Done():
-		return false
-	case <-timer.C:
-		return true
-	}
-}
-
 func (c *Client) buildResponse(r *Request, httpResp *http.Response, duration time.Duration) (*Response, error) {
 	if httpResp == nil {
 		return nil, errors.New("nil http response")
@@ -1087,72 +1149,6 @@ func (c *Client) applyRequestRedirectPolicy(httpClient *http.Client, r *Request)
 	}
 }
 
-func (c *Client) tryCacheHit(r *Request) (*Response, bool, error) {
-	if c.cache == nil || !r.useCache || !strings.EqualFold(r.Method, http.MethodGet) {
-		return nil, false, nil
-	}
-	key := r.cacheKey
-	if key == "" {
-		key = r.URL
-	}
-	data, ok := c.cache.Get(key)
-	if !ok || len(data) == 0 {
-		return nil, false, nil
-	}
-	resp, err := c.decodeCacheEntry(data, r)
-	if err != nil {
-		return nil, false, err
-	}
-	return resp, true, nil
-}
-
-func (c *Client) storeCache(r *Request, resp *Response) {
-	if c.cache == nil || !r.useCache || resp == nil || !strings.EqualFold(r.Method, http.MethodGet) {
-		return
-	}
-	key := r.cacheKey
-	if key == "" {
-		key = r.URL
-	}
-	entry, err := c.encodeCacheEntry(resp)
-	if err != nil {
-		return
-	}
-	c.cache.Set(key, entry, r.cacheTTL)
-}
-
-type cacheEntry struct {
-	Status int                 `json:"status"`
-	Header map[string][]string `json:"header"`
-	Body   []byte              `json:"body"`
-}
-
-func (c *Client) encodeCacheEntry(resp *Response) ([]byte, error) {
-	entry := cacheEntry{
-		Status: resp.StatusCode,
-		Header: map[string][]string(resp.Header.Clone()),
-		Body:   resp.Body,
-	}
-	return json.Marshal(entry)
-}
-
-func (c *Client) decodeCacheEntry(data []byte, r *Request) (*Response, error) {
-	var entry cacheEntry
-	if err := json.Unmarshal(data, &entry); err != nil {
-		return nil, err
-	}
-	return &Response{
-		client:      c,
-		Request:     r,
-		StatusCode:  entry.Status,
-		Status:      http.StatusText(entry.Status),
-		Header:      http.Header(entry.Header),
-		Body:        entry.Body,
-		Duration:    0,
-		ContentType: http.Header(entry.Header).Get("Content-Type"),
-	}, nil
-}
-
 func WithConfig(cfg *Config) ClientOption {
 	return func(c *Client) {
 		if cfg == nil {
@@ -1270,6 +1266,8 @@ func WithCache(cache Cache) ClientOption {
 	}
 }
 
+// WithRetry enables retries. Clients do not retry by default; WithRetry(DefaultRetryConfig())
+// retries failed requests 3 times with exponential backoff.
 func WithRetry(cfg *RetryConfig) ClientOption {
 	return func(c *Client) {
 		if cfg != nil {
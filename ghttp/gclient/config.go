@@ -101,7 +101,7 @@ func (c *Config) applyDefaults() {
 	}
 
 	if c.RetryConfig == nil {
-		c.RetryConfig = DefaultRetryConfig()
+		c.RetryConfig = &RetryConfig{}
 	}
 
 	if c.DumpConfig == nil {
@@ -148,9 +148,10 @@ func (c *Client) refreshHTTPTransportLocked() {
 	c.config.Transport = transport
 	if c.httpClient == nil {
 		c.httpClient = c.buildHTTPClient()
-		return
+	} else {
+		c.httpClient.Transport = transport
 	}
-	c.httpClient.Transport = transport
+	c.wrapDiscoveryLocked()
 }
 
 func (c *Client) refreshRedirectPolicyLocked() {
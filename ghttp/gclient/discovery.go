@@ -0,0 +1,372 @@
+package gclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sofiworker/gk/glb"
+)
+
+// DiscoveryConfig configures a DiscoveryTransport.
+type DiscoveryConfig struct {
+	// Discovery resolves logical service names into instances. A
+	// gsd.ServiceDiscovery can be adapted with gsd.NewLBDiscovery. Required.
+	Discovery glb.Discovery
+	// Strategy picks an instance. Defaults to round robin.
+	Strategy glb.Strategy
+	// Services are the hosts resolved through Discovery; other hosts are called
+	// directly. When empty a host is a service once Discovery has instances for
+	// it, and hosts Discovery does not know are called directly. A host stays a
+	// service once seen, so a service without instances fails to resolve
+	// instead of being called directly.
+	Services []string
+	// UnknownTTL is how long a host Discovery has no instances for is called
+	// directly before Discovery is asked again, when Services is empty.
+	// Default 30s.
+	UnknownTTL time.Duration
+	// MaxAttempts bounds the instances tried when connecting fails. Default 3.
+	MaxAttempts int
+	// EjectDuration is how long an instance that could not be reached is
+	// skipped. Default 10s.
+	EjectDuration time.Duration
+	// DisableWatch keeps the first instance set of a service instead of
+	// following its changes with Discovery.Watch.
+	DisableWatch bool
+	// Transport sends the requests once rewritten to an instance. WithDiscovery
+	// sets it to the client's transport; the default is http.DefaultTransport.
+	Transport http.RoundTripper
+	// DialContext dials instances in DiscoveryTransport.DialContext.
+	// WithDiscovery sets it to the client's dialer; the default is a net.Dialer.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DiscoveryTransport is an http.RoundTripper that sends requests for a logical
+// host such as http://orders-svc/ to an instance picked by a glb.LoadBalancer.
+// Each request is balanced independently; when connecting to an instance fails,
+// the instance is ejected for a while and the request is sent to another one.
+// Errors after the connection was established are returned as is, since the
+// request may have been processed.
+type DiscoveryTransport struct {
+	cfg      DiscoveryConfig
+	lb       *glb.LoadBalancer
+	services map[string]bool
+	watched  sync.Map
+	// known holds the hosts Discovery returned instances for, and unknown the
+	// time until which the other hosts are called directly, when Services is empty.
+	known   sync.Map
+	unknown sync.Map
+
+	mu        sync.RWMutex
+	transport http.RoundTripper
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	// inheritTransport and inheritDial are set when the config leaves them
+	// empty, so WithDiscovery can use the client's transport and dialer.
+	inheritTransport bool
+	inheritDial      bool
+}
+
+// NewDiscoveryTransport creates a DiscoveryTransport. It panics without Discovery.
+func NewDiscoveryTransport(cfg DiscoveryConfig) *DiscoveryTransport {
+	if cfg.Discovery == nil {
+		panic("gclient: DiscoveryConfig.Discovery is required")
+	}
+	if cfg.Strategy == nil {
+		cfg.Strategy = glb.NewRoundRobinStrategy()
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.EjectDuration <= 0 {
+		cfg.EjectDuration = 10 * time.Second
+	}
+	if cfg.UnknownTTL <= 0 {
+		cfg.UnknownTTL = 30 * time.Second
+	}
+	t := &DiscoveryTransport{
+		cfg:              cfg,
+		lb:               glb.NewLoadBalancer(cfg.Discovery, cfg.Strategy),
+		transport:        cfg.Transport,
+		dial:             cfg.DialContext,
+		inheritTransport: cfg.Transport == nil,
+		inheritDial:      cfg.DialContext == nil,
+	}
+	if t.transport == nil {
+		t.transport = http.DefaultTransport
+	}
+	if t.dial == nil {
+		t.dial = (&net.Dialer{Timeout: DefaultTimeout}).DialContext
+	}
+	if len(cfg.Services) > 0 {
+		t.services = make(map[string]bool, len(cfg.Services))
+		for _, name := range cfg.Services {
+			t.services[strings.ToLower(name)] = true
+		}
+	}
+	return t
+}
+
+// LoadBalancer returns the balancer, for instance to eject instances after
+// application level failures.
+func (t *DiscoveryTransport) LoadBalancer() *glb.LoadBalancer {
+	return t.lb
+}
+
+// inherit adopts the transport and dialer of a client for the fields the
+// config left empty.
+func (t *DiscoveryTransport) inherit(transport http.RoundTripper, dial func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.inheritTransport && transport != nil {
+		t.transport = transport
+	}
+	if t.inheritDial && dial != nil {
+		t.dial = dial
+	}
+}
+
+func (t *DiscoveryTransport) next() (http.RoundTripper, func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.transport, t.dial
+}
+
+func (t *DiscoveryTransport) isService(host string) bool {
+	host = strings.ToLower(host)
+	if t.services != nil {
+		return t.services[host]
+	}
+	if _, ok := t.known.Load(host); ok {
+		return true
+	}
+	if until, ok := t.unknown.Load(host); ok && time.Now().Before(until.(time.Time)) {
+		return false
+	}
+	instances, err := t.cfg.Discovery.GetInstances(host)
+	if err != nil || len(instances) == 0 {
+		t.unknown.Store(host, time.Now().Add(t.cfg.UnknownTTL))
+		return false
+	}
+	t.known.Store(host, true)
+	t.unknown.Delete(host)
+	return true
+}
+
+// watch follows the instances of service once it is first used.
+func (t *DiscoveryTransport) watch(service string) {
+	if t.cfg.DisableWatch {
+		return
+	}
+	if _, loaded := t.watched.LoadOrStore(service, true); loaded {
+		return
+	}
+	if err := t.lb.StartWatching(service); err != nil {
+		// Retry on a later request rather than never updating the instances.
+		t.watched.Delete(service)
+	}
+}
+
+// pick returns the next instance of service not yet tried.
+func (t *DiscoveryTransport) pick(ctx context.Context, service string, tried []string) (glb.Instance, error) {
+	t.watch(service)
+	// The copies of a hedged request go to different instances when possible.
+	group := hedgeGroupFrom(ctx)
+	picked := group.picked()
+	inst, err := t.lb.GetInstance(excluding(ctx, append(picked, tried...)), service)
+	if err != nil && len(picked) > 0 {
+		inst, err = t.lb.GetInstance(excluding(ctx, tried), service)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gclient: resolve service %s: %w", service, err)
+	}
+	group.add(inst.GetAddress())
+	return inst, nil
+}
+
+func excluding(ctx context.Context, addresses []string) context.Context {
+	if len(addresses) == 0 {
+		return ctx
+	}
+	return glb.WithExclude(ctx, addresses...)
+}
+
+func (t *DiscoveryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, _ := t.next()
+	service := req.URL.Hostname()
+	if !t.isService(service) {
+		return transport.RoundTrip(req)
+	}
+	ctx := req.Context()
+	var (
+		tried   []string
+		lastErr error
+	)
+	for attempt := 0; attempt < t.cfg.MaxAttempts; attempt++ {
+		inst, err := t.pick(ctx, service, tried)
+		if err != nil {
+			if lastErr != nil {
+				break
+			}
+			closeRequestBody(req)
+			return nil, err
+		}
+		tried = append(tried, inst.GetAddress())
+
+		out := req.Clone(ctx)
+		scheme, host := instanceTarget(inst.GetAddress(), req.URL.Port())
+		if scheme != "" {
+			out.URL.Scheme = scheme
+		}
+		out.URL.Host = host
+		if out.Host == "" {
+			out.Host = req.URL.Host
+		}
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				break
+			}
+			if out.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		release := t.lb.Acquire(inst)
+		resp, err := transport.RoundTrip(out)
+		if err == nil {
+			resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+			return resp, nil
+		}
+		release()
+		if !isDialError(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		t.lb.MarkUnhealthy(inst, t.cfg.EjectDuration)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// DialContext dials an instance of the service named by the host of addr, for
+// connections that do not go through RoundTrip such as websockets.
+func (t *DiscoveryTransport) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	_, dial := t.next()
+	service, port, err := net.SplitHostPort(addr)
+	if err != nil || !t.isService(service) {
+		return dial(ctx, network, addr)
+	}
+	var (
+		tried   []string
+		lastErr error
+	)
+	for attempt := 0; attempt < t.cfg.MaxAttempts; attempt++ {
+		inst, err := t.pick(ctx, service, tried)
+		if err != nil {
+			if lastErr != nil {
+				break
+			}
+			return nil, err
+		}
+		tried = append(tried, inst.GetAddress())
+		_, host := instanceTarget(inst.GetAddress(), port)
+		conn, err := dial(ctx, network, host)
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		t.lb.MarkUnhealthy(inst, t.cfg.EjectDuration)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// instanceTarget splits an instance address, "host:port" or a URL such as
+// "https://10.0.0.1:8443", into a scheme and a host[:port]. An address without
+// port takes the port of the logical URL.
+func instanceTarget(address, port string) (scheme, host string) {
+	host = address
+	if strings.Contains(address, "://") {
+		if u, err := url.Parse(address); err == nil {
+			scheme, host = u.Scheme, u.Host
+		}
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil && port != "" {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	}
+	return scheme, host
+}
+
+// isDialError reports whether the request failed before it could be sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+// releaseBody ends the connection count of an instance when the body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// WithDiscovery resolves logical hosts through cfg.Discovery. The client's
+// transport is wrapped in a DiscoveryTransport and websocket dials go through
+// DiscoveryTransport.DialContext, so requests, endpoints, pipelines, SSE and
+// websockets are all balanced. Requests sent by a custom executor or through a
+// transport given to SetTransport are not.
+func WithDiscovery(cfg DiscoveryConfig) ClientOption {
+	return func(c *Client) {
+		c.discovery = NewDiscoveryTransport(cfg)
+	}
+}
+
+// DiscoveryTransport returns the transport installed by WithDiscovery, or nil.
+func (c *Client) DiscoveryTransport() *DiscoveryTransport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.discovery
+}
+
+// wrapDiscoveryLocked puts the discovery transport in front of the transport
+// of the HTTP client. c.mu must be held.
+func (c *Client) wrapDiscoveryLocked() {
+	if c.discovery == nil || c.httpClient == nil || c.executor != c.httpClient {
+		return
+	}
+	if current, ok := c.httpClient.Transport.(*DiscoveryTransport); ok && current == c.discovery {
+		return
+	}
+	var dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	if c.config != nil && c.config.ConConfig != nil {
+		dial = c.config.ConConfig.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{Timeout: c.config.ConConfig.Timeout, KeepAlive: c.config.ConConfig.KeepAlive}).DialContext
+		}
+	}
+	c.discovery.inherit(c.httpClient.Transport, dial)
+	c.httpClient.Transport = c.discovery
+}
@@ -0,0 +1,235 @@
+package gclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sofiworker/gk/glb"
+)
+
+type staticDiscovery struct {
+	mu        sync.Mutex
+	instances []glb.Instance
+	updates   chan []glb.Instance
+}
+
+func newStaticDiscovery(addrs ...string) *staticDiscovery {
+	d := &staticDiscovery{updates: make(chan []glb.Instance, 1)}
+	d.instances = instancesOf(addrs...)
+	return d
+}
+
+func instancesOf(addrs ...string) []glb.Instance {
+	instances := make([]glb.Instance, 0, len(addrs))
+	for _, addr := range addrs {
+		instances = append(instances, &glb.BaseInstance{Address: addr, Healthy: true, Weight: 1})
+	}
+	return instances
+}
+
+func (d *staticDiscovery) GetInstances(string) ([]glb.Instance, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.instances, nil
+}
+
+func (d *staticDiscovery) Watch(string) (<-chan []glb.Instance, error) {
+	return d.updates, nil
+}
+
+// deadAddress returns an address nothing listens on.
+func deadAddress(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	return addr
+}
+
+func namedServer(t *testing.T, name string, hosts *sync.Map) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts.Store(name, r.Host)
+		body, _ := io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, name+":"+string(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDiscoveryBalancesAndSkipsDeadInstances(t *testing.T) {
+	var hosts sync.Map
+	a := namedServer(t, "a", &hosts)
+	b := namedServer(t, "b", &hosts)
+	dead := deadAddress(t)
+	discovery := newStaticDiscovery(dead, a.Listener.Addr().String(), "http://"+b.Listener.Addr().String())
+
+	client := NewClient(WithDiscovery(DiscoveryConfig{
+		Discovery: discovery,
+		Services:  []string{"orders-svc"},
+	}))
+
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		resp, err := client.R().SetBody("x").Post("http://orders-svc/orders")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		seen[resp.String()]++
+	}
+	if seen["a:x"] != 3 || seen["b:x"] != 3 {
+		t.Fatalf("expected requests spread over the live instances, got %v", seen)
+	}
+	if host, _ := hosts.Load("a"); host != "orders-svc" {
+		t.Fatalf("the logical Host header must be kept, got %v", host)
+	}
+
+	// Hosts outside Services are called directly.
+	resp, err := client.R().Get(a.URL)
+	if err != nil || resp.String() != "a:" {
+		t.Fatalf("unexpected direct response %v %v", resp, err)
+	}
+
+	// Endpoints go through the same transport, and Watch replaces the instances.
+	discovery.updates <- instancesOf(b.Listener.Addr().String())
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err := client.NewEndpoint(http.MethodGet, "http://orders-svc/").Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.String() == "b:" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("watch update was not applied")
+		}
+	}
+}
+
+func TestDiscoveryErrors(t *testing.T) {
+	transport := NewDiscoveryTransport(DiscoveryConfig{
+		Discovery:    newStaticDiscovery(deadAddress(t), deadAddress(t)),
+		DisableWatch: true,
+	})
+	client := NewClient(WithHTTPClient(&http.Client{Transport: transport}))
+	if _, err := client.R().Get("http://orders-svc/"); err == nil || !isDialError(err) {
+		t.Fatalf("expected the last dial error, got %v", err)
+	}
+	if _, err := transport.LoadBalancer().GetInstance(context.Background(), "orders-svc"); err == nil {
+		t.Fatal("unreachable instances must be ejected")
+	}
+	if _, err := client.R().Get("http://orders-svc/"); err == nil || !strings.Contains(err.Error(), "resolve service orders-svc") {
+		t.Fatalf("expected a resolve error, got %v", err)
+	}
+}
+
+func TestDiscoveryDialContext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	transport := NewDiscoveryTransport(DiscoveryConfig{
+		Discovery: newStaticDiscovery(deadAddress(t), "127.0.0.1"),
+	})
+
+	// The instance without port takes the port of the dialed address.
+	conn, err := transport.DialContext(context.Background(), "tcp", net.JoinHostPort("chat-svc", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	if scheme, host := instanceTarget("https://[::1]", "8443"); scheme != "https" || host != "[::1]:8443" {
+		t.Fatalf("unexpected target %s %s", scheme, host)
+	}
+}
+
+// serviceDiscovery only knows the instances of one service.
+type serviceDiscovery struct {
+	*staticDiscovery
+	name string
+	// misses counts the lookups of other names.
+	misses *int32
+}
+
+func (d serviceDiscovery) GetInstances(service string) ([]glb.Instance, error) {
+	if service != d.name {
+		if d.misses != nil {
+			atomic.AddInt32(d.misses, 1)
+		}
+		return nil, errors.New("unknown service " + service)
+	}
+	return d.staticDiscovery.GetInstances(service)
+}
+
+func TestDiscoveryWithoutServicesCallsUnknownHostsDirectly(t *testing.T) {
+	var hosts sync.Map
+	a := namedServer(t, "a", &hosts)
+	b := namedServer(t, "b", &hosts)
+	client := NewClient(WithDiscovery(DiscoveryConfig{
+		Discovery: serviceDiscovery{staticDiscovery: newStaticDiscovery(b.Listener.Addr().String()), name: "orders-svc"},
+	}))
+
+	if resp, err := client.R().Get(a.URL); err != nil || resp.String() != "a:" {
+		t.Fatalf("a host unknown to discovery must be called directly, got %v %v", resp, err)
+	}
+	if resp, err := client.R().Get("http://orders-svc/"); err != nil || resp.String() != "b:" {
+		t.Fatalf("a known service must be resolved, got %v %v", resp, err)
+	}
+}
+
+func TestDiscoveryWithoutServicesCachesLookups(t *testing.T) {
+	var hosts sync.Map
+	a := namedServer(t, "a", &hosts)
+	b := namedServer(t, "b", &hosts)
+	var misses int32
+	static := newStaticDiscovery(b.Listener.Addr().String())
+	transport := NewDiscoveryTransport(DiscoveryConfig{
+		Discovery: serviceDiscovery{staticDiscovery: static, name: "orders-svc", misses: &misses},
+	})
+	client := NewClient(WithHTTPClient(&http.Client{Transport: transport}))
+
+	for i := 0; i < 3; i++ {
+		if resp, err := client.R().Get(a.URL); err != nil || resp.String() != "a:" {
+			t.Fatalf("a host unknown to discovery must be called directly, got %v %v", resp, err)
+		}
+	}
+	if n := atomic.LoadInt32(&misses); n != 1 {
+		t.Fatalf("an unknown host must be looked up once per UnknownTTL, got %d lookups", n)
+	}
+	transport.unknown.Store("127.0.0.1", time.Now().Add(-time.Second))
+	if _, err := client.R().Get(a.URL); err != nil || atomic.LoadInt32(&misses) != 2 {
+		t.Fatalf("an expired unknown host must be looked up again, got %v %d", err, atomic.LoadInt32(&misses))
+	}
+
+	if resp, err := client.R().Get("http://orders-svc/"); err != nil || resp.String() != "b:" {
+		t.Fatalf("a known service must be resolved, got %v %v", resp, err)
+	}
+	// A service left without instances fails instead of being called directly.
+	static.updates <- nil
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err := client.R().Get("http://orders-svc/")
+		if err != nil && strings.Contains(err.Error(), "resolve service orders-svc") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("a service without instances must fail to resolve, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -7,6 +7,9 @@ type Endpoint struct {
 	Method string
 	URL    string
 	steps  []RequestStep
+	// breaker is shared by the clones of the endpoint.
+	breaker *CircuitBreaker
+	hedger  *Hedger
 }
 
 func NewEndpoint(client *Client, method, rawURL string, steps ...RequestStep) *Endpoint {
@@ -41,6 +44,18 @@ func (e *Endpoint) SetURL(rawURL string) *Endpoint {
 	return e
 }
 
+// SetCircuitBreaker guards the requests of this endpoint with cb.
+func (e *Endpoint) SetCircuitBreaker(cb *CircuitBreaker) *Endpoint {
+	e.breaker = cb
+	return e
+}
+
+// SetHedger hedges the idempotent requests of this endpoint with h.
+func (e *Endpoint) SetHedger(h *Hedger) *Endpoint {
+	e.hedger = h
+	return e
+}
+
 func (e *Endpoint) Use(steps ...RequestStep) *Endpoint {
 	e.steps = append(e.steps, steps...)
 	return e
@@ -61,6 +76,12 @@ func (e *Endpoint) Request(extraSteps ...RequestStep) (*Request, error) {
 	if e.URL != "" {
 		req.SetURL(e.URL)
 	}
+	if e.breaker != nil {
+		req.SetCircuitBreaker(e.breaker)
+	}
+	if e.hedger != nil {
+		req.SetHedger(e.hedger)
+	}
 	if err := req.Apply(e.steps...); err != nil {
 		return nil, err
 	}
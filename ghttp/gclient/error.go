@@ -2,6 +2,8 @@ package gclient
 
 import (
 	"fmt"
+
+	"github.com/sofiworker/gk/gerr"
 )
 
 var (
@@ -18,12 +20,23 @@ type HTTPError struct {
 	StatusCode int
 	Message    string
 	Response   *Response
+	// Problem is the decoded RFC 9457 body when the server answered with
+	// application/problem+json or application/problem+xml.
+	Problem *gerr.Err
 }
 
 func (e *HTTPError) Error() string {
 	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
 }
 
+// Unwrap returns Problem, so errors.Is matches gerr sentinels sharing its code.
+func (e *HTTPError) Unwrap() error {
+	if e == nil || e.Problem == nil {
+		return nil
+	}
+	return e.Problem
+}
+
 type BusinessError struct {
 	Code     interface{}
 	Message  string
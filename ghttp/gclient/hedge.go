@@ -0,0 +1,279 @@
+package gclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HedgeConfig configures a Hedger. Zero fields take the defaults.
+type HedgeConfig struct {
+	// Delay is the wait before each extra copy of a request. When Percentile is
+	// set it is used until MinSamples latencies have been observed. Default 100ms.
+	Delay time.Duration
+	// Percentile, such as 0.95, sends the next copy once a request is slower
+	// than this percentile of the recent latencies.
+	Percentile float64
+	// MinSamples are the latencies needed before Percentile applies. Default 20.
+	MinSamples int
+	// Samples is the number of recent latencies kept. Default 256.
+	Samples int
+	// MaxHedges is the number of extra copies. Default 1.
+	MaxHedges int
+	// IsSuccess tells whether a copy ends the race. Default: no error and a
+	// status below 500. When every copy fails the first failure is returned.
+	IsSuccess func(resp *http.Response, err error) bool
+}
+
+func (cfg *HedgeConfig) applyDefaults() {
+	if cfg.Delay <= 0 {
+		cfg.Delay = 100 * time.Millisecond
+	}
+	if cfg.MinSamples <= 0 {
+		cfg.MinSamples = 20
+	}
+	if cfg.Samples <= 0 {
+		cfg.Samples = 256
+	}
+	if cfg.MaxHedges <= 0 {
+		cfg.MaxHedges = 1
+	}
+	if cfg.IsSuccess == nil {
+		cfg.IsSuccess = func(resp *http.Response, err error) bool {
+			return err == nil && resp != nil && resp.StatusCode < http.StatusInternalServerError
+		}
+	}
+}
+
+// Hedger cuts tail latency by sending extra copies of slow idempotent
+// requests, returning the first successful response and canceling the others.
+// With WithDiscovery each copy goes to a different instance. A Hedger keeps
+// the latencies of its requests, so share one per client or endpoint.
+type Hedger struct {
+	cfg HedgeConfig
+
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+// NewHedger creates a Hedger.
+func NewHedger(cfg HedgeConfig) *Hedger {
+	cfg.applyDefaults()
+	return &Hedger{cfg: cfg, samples: make([]time.Duration, 0, cfg.Samples)}
+}
+
+// Delay returns the current wait before an extra copy is sent.
+func (h *Hedger) Delay() time.Duration {
+	if h.cfg.Percentile <= 0 {
+		return h.cfg.Delay
+	}
+	h.mu.Lock()
+	if len(h.samples) < h.cfg.MinSamples {
+		h.mu.Unlock()
+		return h.cfg.Delay
+	}
+	sorted := append([]time.Duration(nil), h.samples...)
+	h.mu.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(h.cfg.Percentile*float64(len(sorted))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+func (h *Hedger) observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.samples) < h.cfg.Samples {
+		h.samples = append(h.samples, d)
+		return
+	}
+	h.samples[h.next] = d
+	h.next = (h.next + 1) % h.cfg.Samples
+}
+
+type hedgeResult struct {
+	copy     int
+	resp     *http.Response
+	err      error
+	duration time.Duration
+	cancel   context.CancelFunc
+}
+
+// do sends req and its copies through executor.
+func (h *Hedger) do(executor HTTPExecutor, req *http.Request, tracer Tracer) (*http.Response, error) {
+	ctx := context.WithValue(req.Context(), hedgeGroupKey{}, &hedgeGroup{})
+	results := make(chan hedgeResult, h.cfg.MaxHedges+1)
+	var cancels []context.CancelFunc
+	launch := func(copy int) bool {
+		body := req.Body
+		if copy > 0 && req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return false
+			}
+			var err error
+			if body, err = req.GetBody(); err != nil {
+				return false
+			}
+		}
+		copyCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		out := req.Clone(copyCtx)
+		out.Body = body
+		go func() {
+			start := time.Now()
+			resp, err := executor.Do(out)
+			results <- hedgeResult{copy: copy, resp: resp, err: err, duration: time.Since(start), cancel: cancel}
+		}()
+		return true
+	}
+
+	launch(0)
+	launched, inflight := 1, 1
+	timer := time.NewTimer(h.Delay())
+	defer timer.Stop()
+	var failure *hedgeResult
+	for {
+		select {
+		case res := <-results:
+			inflight--
+			if h.cfg.IsSuccess(res.resp, res.err) {
+				h.observe(res.duration)
+				discardHedges(results, inflight, cancels, res.copy, failure)
+				if tracer != nil {
+					tracer.SetAttribute("http.hedge.copies", launched)
+					tracer.SetAttribute("http.hedge.winner", res.copy)
+				}
+				res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: res.cancel}
+				return res.resp, res.err
+			}
+			if failure == nil {
+				failure = &res
+			} else {
+				closeHedge(res)
+			}
+			if inflight == 0 {
+				if tracer != nil {
+					tracer.SetAttribute("http.hedge.copies", launched)
+				}
+				if failure.resp != nil {
+					failure.resp.Body = &cancelOnClose{ReadCloser: failure.resp.Body, cancel: failure.cancel}
+				} else {
+					failure.cancel()
+				}
+				return failure.resp, failure.err
+			}
+		case <-timer.C:
+			if launched <= h.cfg.MaxHedges && launch(launched) {
+				launched++
+				inflight++
+				timer.Reset(h.Delay())
+			}
+		}
+	}
+}
+
+// discardHedges cancels the copies still in flight and releases their
+// responses once they report back.
+func discardHedges(results <-chan hedgeResult, inflight int, cancels []context.CancelFunc, winner int, failure *hedgeResult) {
+	if failure != nil {
+		closeHedge(*failure)
+	}
+	for i, cancel := range cancels {
+		if i != winner {
+			cancel()
+		}
+	}
+	if inflight == 0 {
+		return
+	}
+	go func() {
+		for ; inflight > 0; inflight-- {
+			closeHedge(<-results)
+		}
+	}()
+}
+
+func closeHedge(res hedgeResult) {
+	if res.resp != nil && res.resp.Body != nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(res.resp.Body, 4096))
+		_ = res.resp.Body.Close()
+	}
+	res.cancel()
+}
+
+// cancelOnClose releases the context of a hedged copy with its body.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// hedgeGroup records the instances picked for the copies of a request, so
+// that DiscoveryTransport sends each copy to a different one.
+type hedgeGroupKey struct{}
+
+type hedgeGroup struct {
+	mu        sync.Mutex
+	addresses []string
+}
+
+func hedgeGroupFrom(ctx context.Context) *hedgeGroup {
+	g, _ := ctx.Value(hedgeGroupKey{}).(*hedgeGroup)
+	return g
+}
+
+func (g *hedgeGroup) picked() []string {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.addresses...)
+}
+
+func (g *hedgeGroup) add(address string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.addresses = append(g.addresses, address)
+	g.mu.Unlock()
+}
+
+// WithHedger hedges the idempotent requests of the client with h.
+func WithHedger(h *Hedger) ClientOption {
+	return func(c *Client) {
+		c.hedger = h
+	}
+}
+
+func (c *Client) SetHedger(h *Hedger) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hedger = h
+	return c
+}
+
+// hedgerFor picks the hedger of r: the request's or endpoint's own, then the client's.
+func (c *Client) hedgerFor(r *Request) *Hedger {
+	if r.hedger != nil {
+		return r.hedger
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hedger
+}
@@ -0,0 +1,135 @@
+package gclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgerCutsTailLatency(t *testing.T) {
+	var calls int32
+	canceled := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				canceled <- struct{}{}
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		_, _ = io.WriteString(w, "fast:"+string(body))
+	}))
+	defer srv.Close()
+
+	tracer := &recordingTracer{}
+	client := NewClient(WithHedger(NewHedger(HedgeConfig{Delay: 20 * time.Millisecond})), WithTracer(tracer))
+	started := time.Now()
+	resp, err := client.R().SetBody("x").Put(srv.URL)
+	if err != nil || resp.String() != "fast:x" {
+		t.Fatalf("expected the hedged response, got %v %v", resp, err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("the slow copy was waited for: %v", elapsed)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the slow copy was not canceled")
+	}
+	tracer.mu.Lock()
+	copies, winner := tracer.attributes["http.hedge.copies"], tracer.attributes["http.hedge.winner"]
+	tracer.mu.Unlock()
+	if copies != 2 || winner != 1 {
+		t.Fatalf("unexpected hedge attributes copies=%v winner=%v", copies, winner)
+	}
+
+	// Non-idempotent requests are never hedged.
+	atomic.StoreInt32(&calls, 0)
+	start := time.Now()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		srv.CloseClientConnections()
+	}()
+	_, _ = client.R().Post(srv.URL)
+	if n := atomic.LoadInt32(&calls); n != 1 || time.Since(start) < 100*time.Millisecond {
+		t.Fatalf("a POST must be sent once, got %d calls", n)
+	}
+}
+
+func TestHedgerPercentileAndFailures(t *testing.T) {
+	h := NewHedger(HedgeConfig{Delay: time.Second, Percentile: 0.9, MinSamples: 10, Samples: 10})
+	for i := 1; i <= 9; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	if h.Delay() != time.Second {
+		t.Fatal("Delay must be used until MinSamples latencies are known")
+	}
+	h.observe(10 * time.Millisecond)
+	if h.Delay() != 9*time.Millisecond {
+		t.Fatalf("expected the p90 latency, got %v", h.Delay())
+	}
+	for i := 0; i < 10; i++ {
+		h.observe(time.Millisecond)
+	}
+	if h.Delay() != time.Millisecond {
+		t.Fatalf("old latencies must be forgotten, got %v", h.Delay())
+	}
+
+	// When every copy fails the first failure is returned.
+	var calls int32
+	executor := newMockExecutor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		time.Sleep(30 * time.Millisecond)
+		w.WriteHeader(http.StatusInternalServerError + int(n))
+	}))
+	client := NewClient(WithExecutor(executor),
+		WithHedger(NewHedger(HedgeConfig{Delay: 5 * time.Millisecond, MaxHedges: 2})))
+	resp, err := client.R().Get("http://hedge.test/")
+	if err != nil || resp.StatusCode != http.StatusInternalServerError+1 || calls != 3 {
+		t.Fatalf("expected the first failure after 3 copies, got %v %v %d", resp, err, calls)
+	}
+}
+
+func TestHedgerWithDiscoveryAndEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	handler := func(name string, delay time.Duration) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(delay):
+			}
+			_, _ = io.WriteString(w, name)
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	slow := handler("slow", 5*time.Second)
+	fast := handler("fast", 0)
+	client := NewClient(WithDiscovery(DiscoveryConfig{
+		Discovery: newStaticDiscovery(slow.Listener.Addr().String(), fast.Listener.Addr().String()),
+		Services:  []string{"orders-svc"},
+	}))
+	endpoint := client.NewEndpoint(http.MethodGet, "http://orders-svc/").
+		SetHedger(NewHedger(HedgeConfig{Delay: 20 * time.Millisecond}))
+
+	resp, err := endpoint.Execute()
+	if err != nil || resp.String() != "fast" {
+		t.Fatalf("expected the fast instance to win, got %v %v", resp, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 2 || order[0] != "slow" || order[1] != "fast" {
+		t.Fatalf("the copies must go to different instances, got %v", order)
+	}
+}
@@ -44,9 +44,10 @@ type Request struct {
 
 	bodyBytes []byte
 
-	cacheKey string
-	cacheTTL time.Duration
-	useCache bool
+	cacheKey  string
+	cacheTTL  time.Duration
+	useCache  bool
+	skipCache bool
 
 	AuthToken              string
 	AuthScheme             string
@@ -60,6 +61,8 @@ type Request struct {
 	responseUnwrapper      ResponseUnwrapper
 	responseStatusChecker  ResponseStatusChecker
 	tracer                 Tracer
+	breaker                *CircuitBreaker
+	hedger                 *Hedger
 	timeout                time.Duration
 	basicAuthUser          string
 	basicAuthPass          string
@@ -399,15 +402,33 @@ func (r *Request) SetTracer(tracer Tracer) *Request {
 	return r
 }
 
+// SetCircuitBreaker guards this request with cb instead of the client's breakers.
+func (r *Request) SetCircuitBreaker(cb *CircuitBreaker) *Request {
+	r.breaker = cb
+	return r
+}
+
+// SetHedger hedges this request with h instead of the client's hedger. Only
+// idempotent requests are hedged.
+func (r *Request) SetHedger(h *Hedger) *Request {
+	r.hedger = h
+	return r
+}
+
+// UseCache caches the response under key, the URL when empty. A positive ttl
+// is the freshness lifetime of responses without explicit expiration time.
 func (r *Request) UseCache(key string, ttl time.Duration) *Request {
 	r.useCache = true
+	r.skipCache = false
 	r.cacheKey = key
 	r.cacheTTL = ttl
 	return r
 }
 
+// DisableCache bypasses the cache, including the one of WithHTTPCache.
 func (r *Request) DisableCache() *Request {
 	r.useCache = false
+	r.skipCache = true
 	r.cacheKey = ""
 	r.cacheTTL = 0
 	return r
@@ -580,6 +601,7 @@ func (r *Request) Clone() *Request {
 	clone.cacheKey = r.cacheKey
 	clone.cacheTTL = r.cacheTTL
 	clone.useCache = r.useCache
+	clone.skipCache = r.skipCache
 	clone.Result = r.Result
 	clone.ResultError = r.ResultError
 	clone.responseUnwrapper = r.responseUnwrapper
@@ -597,6 +619,8 @@ func (r *Request) Clone() *Request {
 	clone.maxRedirects = r.maxRedirects
 	clone.redirectHandlers = append([]func(*Response) bool(nil), r.redirectHandlers...)
 	clone.tracer = r.tracer
+	clone.breaker = r.breaker
+	clone.hedger = r.hedger
 	clone.timeout = r.timeout
 	clone.basicAuthUser = r.basicAuthUser
 	clone.basicAuthPass = r.basicAuthPass
@@ -662,6 +686,12 @@ func (b *httpRequestBuilder) Build() (*http.Request, error) {
 	if err != nil {
 		return nil, err
 	}
+	if data := req.bodyBytes; body != nil && data != nil && httpReq.GetBody == nil {
+		// Lets transports resend the body, e.g. to another instance.
+		httpReq.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
 
 	headers := b.client.cloneDefaultHeaders()
 	for k, values := range req.Header {
@@ -3,11 +3,14 @@ package gclient
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"time"
 
+	"github.com/sofiworker/gk/gerr"
 	"github.com/sofiworker/gk/ghttp/codec"
 )
 
@@ -24,7 +27,18 @@ type Response struct {
 	Duration      time.Duration
 	Proto         string
 	ContentType   string
+	Attempts      []Attempt
 	businessError error
+	cacheStatus   CacheStatus
+}
+
+// CacheStatus tells how the HTTP cache answered the request, or is empty when
+// the request was not cached.
+func (r *Response) CacheStatus() CacheStatus {
+	if r == nil {
+		return ""
+	}
+	return r.cacheStatus
 }
 
 func (r *Response) Bytes() []byte {
@@ -88,15 +102,46 @@ func (r *Response) OK() error {
 		return r.businessError
 	}
 	if !r.IsSuccess() {
-		return &HTTPError{
+		err := &HTTPError{
 			StatusCode: r.StatusCode,
 			Message:    r.Status,
 			Response:   r,
+			Problem:    r.Problem(),
+		}
+		if err.Problem != nil && err.Problem.Message != "" {
+			err.Message = err.Problem.Message
 		}
+		return err
 	}
 	return nil
 }
 
+// Problem decodes an RFC 9457 problem details body. It returns nil when the
+// response is not application/problem+json or application/problem+xml.
+func (r *Response) Problem() *gerr.Err {
+	if r == nil || len(r.Body) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.ContentType)
+	p := &gerr.Err{}
+	var err error
+	switch mediaType {
+	case gerr.MediaTypeProblemJSON:
+		err = json.Unmarshal(r.Body, p)
+	case gerr.MediaTypeProblemXML:
+		err = xml.Unmarshal(r.Body, p)
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	if p.Status == 0 {
+		p.Status = r.StatusCode
+	}
+	return p
+}
+
 func (r *Response) MustOK() *Response {
 	if err := r.OK(); err != nil {
 		panic(err)
@@ -1,12 +1,15 @@
 package gclient
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/sofiworker/gk/gerr"
 )
 
 func TestResponseHelpers(t *testing.T) {
@@ -161,3 +164,35 @@ func TestResponseCookiesFallbackAndToHTTPResponseStatus(t *testing.T) {
 		t.Fatalf("unexpected status %q", httpResp.Status)
 	}
 }
+
+func TestResponseProblemDetails(t *testing.T) {
+	errNotFound := gerr.New(http.StatusNotFound, "user_not_found", "user not found")
+	resp := &Response{
+		StatusCode:  http.StatusNotFound,
+		Status:      "404 Not Found",
+		ContentType: "application/problem+json; charset=utf-8",
+		Body:        []byte(`{"code":"user_not_found","detail":"user 7 not found","status":404,"title":"Not Found","id":7}`),
+	}
+	err := resp.OK()
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Message != "user 7 not found" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !errors.Is(err, errNotFound) {
+		t.Fatalf("expected %v to match the sentinel", err)
+	}
+	if p := gerr.From(err); p.Status != http.StatusNotFound || p.Details["id"] != float64(7) {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+
+	resp.ContentType = "application/problem+xml"
+	resp.Body = []byte(`<problem xmlns="urn:ietf:rfc:7807"><status>404</status><code>user_not_found</code></problem>`)
+	if p := resp.Problem(); p == nil || !errors.Is(p, errNotFound) {
+		t.Fatalf("unexpected xml problem %+v", p)
+	}
+
+	resp.ContentType = "application/json"
+	if resp.Problem() != nil || errors.Is(resp.OK(), errNotFound) {
+		t.Fatalf("plain json must not be decoded as a problem")
+	}
+}
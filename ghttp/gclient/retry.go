@@ -1,7 +1,11 @@
 package gclient
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/exp/rand"
@@ -12,12 +16,57 @@ type RetryConfig struct {
 	RetryConditions []RetryCondition
 	Backoff         BackoffStrategy
 	MaxRetryTime    time.Duration
+
+	// MaxRetryAfter bounds the Retry-After of 429 and 503 responses: a longer
+	// wait ends the retries. Retry-After waits are also bounded by MaxRetryTime.
+	MaxRetryAfter time.Duration
+	// IgnoreRetryAfter waits for Backoff only, whatever Retry-After says.
+	IgnoreRetryAfter bool
+	// RetryNonIdempotent retries POST, PATCH and other non-idempotent methods
+	// without an Idempotency-Key header. By default they are only retried when
+	// the connection could not be established.
+	RetryNonIdempotent bool
+
+	// BudgetRatio caps the retries of a client to this fraction of its requests
+	// over BudgetWindow, in addition to BudgetMinRetries. Zero disables it.
+	BudgetRatio float64
+	// BudgetMinRetries are allowed per BudgetWindow whatever the ratio. Default 10.
+	BudgetMinRetries int
+	// BudgetWindow is the period the budget is computed over. Default 10s.
+	BudgetWindow time.Duration
 }
 
 type RetryCondition func(*Response, error) bool
 
 type BackoffStrategy func(attempt int) time.Duration
 
+// Attempt describes one try of a request. Response.Attempts lists every try,
+// retries included, as does AttemptsError when no response was received.
+type Attempt struct {
+	// StatusCode is zero when no response was received.
+	StatusCode int
+	Err        error
+	Start      time.Time
+	Duration   time.Duration
+	// Delay is the wait before the next attempt, zero for the last one.
+	Delay time.Duration
+}
+
+// AttemptsError is returned when the last attempt of a request got no
+// response. It wraps the error of that attempt and lists every attempt.
+type AttemptsError struct {
+	Err      error
+	Attempts []Attempt
+}
+
+func (e *AttemptsError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AttemptsError) Unwrap() error {
+	return e.Err
+}
+
 func ExponentialBackoff(baseDelay time.Duration) BackoffStrategy {
 	return func(attempt int) time.Duration {
 		delay := baseDelay * time.Duration(1<<uint(attempt))
@@ -28,7 +77,7 @@ func ExponentialBackoff(baseDelay time.Duration) BackoffStrategy {
 
 func DefaultRetryCondition(resp *Response, err error) bool {
 	if err != nil {
-		return true
+		return !errors.Is(err, ErrCircuitOpen)
 	}
 	if resp == nil {
 		return false
@@ -39,11 +88,164 @@ func DefaultRetryCondition(resp *Response, err error) bool {
 	return resp.StatusCode >= 500
 }
 
+// DefaultRetryConfig retries connection errors, 429 and 5xx responses 3 times
+// with exponential backoff, within a 20% retry budget. It is opt-in: clients
+// without a RetryConfig do not retry.
 func DefaultRetryConfig() *RetryConfig {
 	return &RetryConfig{
 		MaxRetries:      3,
 		RetryConditions: []RetryCondition{DefaultRetryCondition},
 		Backoff:         ExponentialBackoff(500 * time.Millisecond),
 		MaxRetryTime:    5 * time.Second,
+		BudgetRatio:     0.2,
+	}
+}
+
+// nextRetry tells whether a failed attempt is retried and after which delay.
+// Request bodies are buffered by the request builder, so every attempt sends
+// the same body.
+func (c *Client) nextRetry(req *http.Request, resp *Response, err error, attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if !c.shouldRetry(resp, err, attempt, elapsed) {
+		return 0, false
+	}
+	cfg := c.retryConfig
+	if !cfg.RetryNonIdempotent && !isIdempotent(req) && !isDialError(err) {
+		return 0, false
+	}
+	var delay time.Duration
+	if cfg.Backoff != nil {
+		delay = cfg.Backoff(attempt + 1)
+	}
+	if wait, ok := retryAfter(resp, time.Now()); ok && !cfg.IgnoreRetryAfter {
+		if cfg.MaxRetryAfter > 0 && wait > cfg.MaxRetryAfter {
+			return 0, false
+		}
+		if cfg.MaxRetryTime > 0 && elapsed+wait > cfg.MaxRetryTime {
+			return 0, false
+		}
+		if wait > delay {
+			delay = wait
+		}
+	}
+	if c.retryBudget != nil && !c.retryBudget.withdraw() {
+		return 0, false
+	}
+	return delay, true
+}
+
+var idempotentMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodOptions: true,
+	http.MethodTrace: true, http.MethodPut: true, http.MethodDelete: true,
+}
+
+// isIdempotent reports whether req may be sent twice, RFC 9110 section 9.2.2.
+func isIdempotent(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+	if idempotentMethods[strings.ToUpper(req.Method)] || req.Method == "" {
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// retryAfter returns the wait requested by a 429 or 503 response, in seconds
+// or as an HTTP-date.
+func retryAfter(resp *Response, now time.Time) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if wait := t.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+const budgetBuckets = 10
+
+// retryBudget limits the retries of a client to a ratio of its requests over
+// a rolling window, so that retries cannot multiply the load of a failing
+// upstream.
+type retryBudget struct {
+	ratio      float64
+	minRetries int
+	width      time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	buckets [budgetBuckets]budgetBucket
+}
+
+type budgetBucket struct {
+	epoch    int64
+	requests int
+	retries  int
+}
+
+// newRetryBudget returns nil when cfg has no budget.
+func newRetryBudget(cfg *RetryConfig) *retryBudget {
+	if cfg == nil || cfg.BudgetRatio <= 0 {
+		return nil
+	}
+	b := &retryBudget{ratio: cfg.BudgetRatio, minRetries: cfg.BudgetMinRetries, now: time.Now}
+	if b.minRetries <= 0 {
+		b.minRetries = 10
+	}
+	window := cfg.BudgetWindow
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	b.width = window / budgetBuckets
+	if b.width <= 0 {
+		b.width = time.Millisecond
+	}
+	return b
+}
+
+// current returns the bucket of now. b.mu must be held.
+func (b *retryBudget) current() (*budgetBucket, int64) {
+	epoch := b.now().UnixNano() / int64(b.width)
+	bucket := &b.buckets[epoch%budgetBuckets]
+	if bucket.epoch != epoch {
+		*bucket = budgetBucket{epoch: epoch}
+	}
+	return bucket, epoch
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bucket, _ := b.current()
+	bucket.requests++
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bucket, epoch := b.current()
+	var requests, retries int
+	for _, bk := range b.buckets {
+		if epoch-bk.epoch < budgetBuckets {
+			requests += bk.requests
+			retries += bk.retries
+		}
+	}
+	if float64(retries) >= float64(b.minRetries)+b.ratio*float64(requests) {
+		return false
 	}
+	bucket.retries++
+	return true
 }
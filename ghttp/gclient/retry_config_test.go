@@ -1,8 +1,10 @@
 package gclient
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -38,3 +40,131 @@ func TestDefaultRetryCondition(t *testing.T) {
 		t.Error("should retry on 429")
 	}
 }
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	resp := &Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"7"}}}
+	if d, ok := retryAfter(resp, now); !ok || d != 7*time.Second {
+		t.Fatalf("expected 7s, got %v %v", d, ok)
+	}
+	resp.StatusCode = http.StatusServiceUnavailable
+	resp.Header.Set("Retry-After", now.Add(time.Minute).Format(http.TimeFormat))
+	if d, ok := retryAfter(resp, now); !ok || d != time.Minute {
+		t.Fatalf("expected 1m, got %v %v", d, ok)
+	}
+	resp.StatusCode = http.StatusInternalServerError
+	if _, ok := retryAfter(resp, now); ok {
+		t.Fatal("Retry-After only applies to 429 and 503")
+	}
+}
+
+func TestClientRetryPolicy(t *testing.T) {
+	var hits int32
+	executor := newMockExecutor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/later":
+			w.Header().Set("Retry-After", "120")
+		case "/past":
+			w.Header().Set("Retry-After", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	client := NewClient(WithBaseURL("http://retry.test"), WithExecutor(executor), WithRetry(&RetryConfig{
+		MaxRetries:      2,
+		RetryConditions: []RetryCondition{DefaultRetryCondition},
+		MaxRetryAfter:   time.Minute,
+	}))
+	send := func(req *Request, method, path string) (*Response, int32) {
+		t.Helper()
+		atomic.StoreInt32(&hits, 0)
+		resp, err := req.Execute(method, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, atomic.LoadInt32(&hits)
+	}
+
+	resp, n := send(client.R(), http.MethodGet, "/past")
+	if n != 3 || len(resp.Attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %d hits and %d attempts", n, len(resp.Attempts))
+	}
+	for i, a := range resp.Attempts {
+		if a.StatusCode != http.StatusServiceUnavailable || a.Start.IsZero() || a.Err != nil {
+			t.Fatalf("unexpected attempt %d: %+v", i, a)
+		}
+	}
+	if _, n := send(client.R(), http.MethodGet, "/later"); n != 1 {
+		t.Fatalf("a Retry-After beyond MaxRetryAfter must stop the retries, got %d hits", n)
+	}
+	if _, n := send(client.R().SetBody("x"), http.MethodPost, "/post"); n != 1 {
+		t.Fatalf("POST must not be retried, got %d hits", n)
+	}
+	if _, n := send(client.R().SetBody("x").SetHeader("Idempotency-Key", "k1"), http.MethodPost, "/post"); n != 3 {
+		t.Fatalf("POST with an Idempotency-Key must be retried, got %d hits", n)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	b := newRetryBudget(&RetryConfig{BudgetRatio: 0.5, BudgetMinRetries: 1, BudgetWindow: 10 * time.Second})
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	b.now = clock.now
+	for i := 0; i < 4; i++ {
+		b.deposit()
+	}
+	allowed := 0
+	for b.withdraw() {
+		allowed++
+	}
+	if allowed != 3 {
+		t.Fatalf("expected 1 + 50%% of 4 retries, got %d", allowed)
+	}
+	clock.advance(11 * time.Second)
+	if !b.withdraw() {
+		t.Fatal("the budget must recover once the window has passed")
+	}
+	if newRetryBudget(&RetryConfig{}) != nil {
+		t.Fatal("a zero ratio disables the budget")
+	}
+}
+
+type failingExecutor struct{ err error }
+
+func (f failingExecutor) Do(*http.Request) (*http.Response, error) { return nil, f.err }
+
+func TestAttemptsErrorOnTransportFailure(t *testing.T) {
+	dialErr := errors.New("connection refused")
+	client := NewClient(WithExecutor(failingExecutor{err: dialErr}), WithRetry(&RetryConfig{
+		MaxRetries:      2,
+		RetryConditions: []RetryCondition{DefaultRetryCondition},
+	}))
+	_, err := client.R().Get("http://retry.test/")
+	var attemptsErr *AttemptsError
+	if !errors.As(err, &attemptsErr) || !errors.Is(err, dialErr) {
+		t.Fatalf("expected an AttemptsError wrapping the transport error, got %v", err)
+	}
+	if len(attemptsErr.Attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(attemptsErr.Attempts))
+	}
+	for i, a := range attemptsErr.Attempts {
+		if a.StatusCode != 0 || a.Err != dialErr || a.Start.IsZero() {
+			t.Fatalf("unexpected attempt %d: %+v", i, a)
+		}
+	}
+}
+
+func TestClientDoesNotRetryByDefault(t *testing.T) {
+	var hits int32
+	executor := newMockExecutor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	client := NewClient(WithExecutor(executor))
+	if resp, err := client.R().Get("http://retry.test/"); err != nil || resp.StatusCode != http.StatusServiceUnavailable || hits != 1 {
+		t.Fatalf("expected a single attempt, got %v %v after %d hits", resp, err, hits)
+	}
+	client.SetRetryConfig(DefaultRetryConfig())
+	if client.retryConfig.MaxRetries != 3 {
+		t.Fatal("DefaultRetryConfig must enable retries")
+	}
+}
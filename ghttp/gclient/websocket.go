@@ -363,6 +363,9 @@ func applyClientDialerConfig(dialer *websocket.Dialer, client *Client) {
 			}
 		}
 	}
+	if client.discovery != nil && dialer.NetDialContext == nil && dialer.NetDial == nil {
+		dialer.NetDialContext = client.discovery.DialContext
+	}
 }
 
 func applyRequestDialerProxy(dialer *websocket.Dialer, req *Request) {
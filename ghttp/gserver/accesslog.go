@@ -0,0 +1,306 @@
+package gserver
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sofiworker/gk/glog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AccessLogFormat selects how AccessLog renders an entry.
+type AccessLogFormat int
+
+const (
+	// AccessLogStructured logs the fields as key/value pairs of the glog entry,
+	// encoded by the logger (console or JSON).
+	AccessLogStructured AccessLogFormat = iota
+	// AccessLogJSON logs the fields as one JSON object in the message, for
+	// loggers whose encoder is not JSON.
+	AccessLogJSON
+	// AccessLogCombined logs an Apache combined log line as the message and
+	// keeps the fields on the entry.
+	AccessLogCombined
+)
+
+const redactedValue = "[REDACTED]"
+
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// AccessLogConfig configures AccessLog.
+type AccessLogConfig struct {
+	// Logger defaults to glog.Default().
+	Logger glog.GLogger
+	Format AccessLogFormat
+	// Message is the message of structured entries. Default "access".
+	Message string
+	// SampleRate is the fraction of requests answered below 400 that are logged,
+	// between 0 and 1. Client and server errors are always logged. Zero logs
+	// every request.
+	SampleRate float64
+	// SkipPaths are request paths or route templates that are never logged,
+	// such as health checks.
+	SkipPaths []string
+	// Skip reports whether a request is not logged. It runs after the handler.
+	Skip func(c *Context) bool
+	// RequestHeaders and ResponseHeaders add the headers to the entry.
+	RequestHeaders  bool
+	ResponseHeaders bool
+	// RedactHeaders are logged as "[REDACTED]". Defaults to Authorization,
+	// Proxy-Authorization, Cookie, Set-Cookie and X-Api-Key.
+	RedactHeaders []string
+	// RequestBody and ResponseBody add the bodies to the entry, truncated to
+	// MaxBodySize bytes. Streamed response bodies are never captured.
+	RequestBody  bool
+	ResponseBody bool
+	// MaxBodySize defaults to 4KB.
+	MaxBodySize int
+}
+
+// AccessLog writes one structured entry per request through a glog.GLogger:
+// method, route template, status, latency, bytes in and out, client IP,
+// request ID, trace ID and user agent. Entries are logged at info level, at
+// warn level for 4xx and at error level for 5xx responses.
+func AccessLog(cfg AccessLogConfig) HandlerFunc {
+	if cfg.Logger == nil {
+		cfg.Logger = glog.Default()
+	}
+	if cfg.Message == "" {
+		cfg.Message = "access"
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		panic("gserver: AccessLogConfig.SampleRate must be between 0 and 1")
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 4 << 10
+	}
+	if cfg.RedactHeaders == nil {
+		cfg.RedactHeaders = defaultRedactHeaders
+	}
+	redact := make(map[string]bool, len(cfg.RedactHeaders))
+	for _, h := range cfg.RedactHeaders {
+		redact[http.CanonicalHeaderKey(h)] = true
+	}
+	skipPaths := make(map[string]bool, len(cfg.SkipPaths))
+	for _, p := range cfg.SkipPaths {
+		skipPaths[p] = true
+	}
+
+	return func(c *Context) {
+		start := time.Now()
+		path := string(c.fastCtx.Path())
+		if skipPaths[path] {
+			c.Next()
+			return
+		}
+		var reqBody string
+		if cfg.RequestBody {
+			// Read before the handler, which may consume a streamed body.
+			reqBody = truncateBody(c.BodyBytes(), cfg.MaxBodySize)
+		}
+
+		c.Next()
+
+		status := c.StatusCode()
+		if skipPaths[c.FullPath()] || (cfg.Skip != nil && cfg.Skip(c)) {
+			return
+		}
+		if status < http.StatusBadRequest && cfg.SampleRate > 0 && cfg.SampleRate < 1 && rand.Float64() >= cfg.SampleRate {
+			return
+		}
+
+		req := &c.fastCtx.Request
+		resp := &c.fastCtx.Response
+		e := accessEntry{
+			Time:      start,
+			Method:    string(c.fastCtx.Method()),
+			Route:     c.FullPath(),
+			Path:      path,
+			Query:     string(c.fastCtx.QueryArgs().QueryString()),
+			Protocol:  string(req.Header.Protocol()),
+			Status:    status,
+			Latency:   time.Since(start),
+			BytesIn:   len(req.Body()),
+			BytesOut:  len(resp.Body()),
+			ClientIP:  c.ClientIP(),
+			RequestID: c.RequestID(),
+			UserAgent: string(req.Header.UserAgent()),
+			Referer:   string(req.Header.Referer()),
+		}
+		if req.IsBodyStream() {
+			e.BytesIn = max(req.Header.ContentLength(), 0)
+		}
+		if resp.IsBodyStream() {
+			e.BytesOut = max(resp.Header.ContentLength(), c.Writer.Size())
+		}
+		if sc := trace.SpanContextFromContext(c.Context()); sc.IsValid() {
+			e.TraceID = sc.TraceID().String()
+		}
+		if cfg.RequestHeaders {
+			e.RequestHeaders = make(map[string]string)
+			req.Header.All()(func(k, v []byte) bool {
+				addLogHeader(e.RequestHeaders, redact, k, v)
+				return true
+			})
+		}
+		if cfg.ResponseHeaders {
+			e.ResponseHeaders = make(map[string]string)
+			resp.Header.All()(func(k, v []byte) bool {
+				addLogHeader(e.ResponseHeaders, redact, k, v)
+				return true
+			})
+		}
+		e.RequestBody = reqBody
+		if cfg.ResponseBody && !resp.IsBodyStream() {
+			e.ResponseBody = truncateBody(resp.Body(), cfg.MaxBodySize)
+		}
+
+		log := cfg.Logger.Info
+		switch {
+		case status >= http.StatusInternalServerError:
+			log = cfg.Logger.Error
+		case status >= http.StatusBadRequest:
+			log = cfg.Logger.Warn
+		}
+		switch cfg.Format {
+		case AccessLogJSON:
+			data, err := json.Marshal(e)
+			if err != nil {
+				return
+			}
+			log(string(data))
+		case AccessLogCombined:
+			log(e.combined(), e.fields()...)
+		default:
+			log(cfg.Message, e.fields()...)
+		}
+	}
+}
+
+// RequestID returns the ID assigned by the RequestID middleware, or "".
+func (c *Context) RequestID() string {
+	id, _ := c.Value(requestIDKey{}).(string)
+	return id
+}
+
+type accessEntry struct {
+	Time            time.Time         `json:"time"`
+	Method          string            `json:"method"`
+	Route           string            `json:"route,omitempty"`
+	Path            string            `json:"path"`
+	Query           string            `json:"query,omitempty"`
+	Protocol        string            `json:"protocol"`
+	Status          int               `json:"status"`
+	Latency         time.Duration     `json:"-"`
+	BytesIn         int               `json:"bytes_in"`
+	BytesOut        int               `json:"bytes_out"`
+	ClientIP        string            `json:"client_ip"`
+	RequestID       string            `json:"request_id,omitempty"`
+	TraceID         string            `json:"trace_id,omitempty"`
+	UserAgent       string            `json:"user_agent,omitempty"`
+	Referer         string            `json:"referer,omitempty"`
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	ResponseBody    string            `json:"response_body,omitempty"`
+}
+
+func (e accessEntry) MarshalJSON() ([]byte, error) {
+	type entry accessEntry
+	return json.Marshal(struct {
+		entry
+		LatencyMS float64 `json:"latency_ms"`
+	}{entry(e), e.latencyMS()})
+}
+
+func (e accessEntry) latencyMS() float64 {
+	return float64(e.Latency.Microseconds()) / 1000
+}
+
+// fields returns the entry as glog key/value pairs.
+func (e accessEntry) fields() []interface{} {
+	kv := []interface{}{
+		"method", e.Method,
+		"route", e.Route,
+		"path", e.Path,
+		"status", e.Status,
+		"latency_ms", e.latencyMS(),
+		"bytes_in", e.BytesIn,
+		"bytes_out", e.BytesOut,
+		"client_ip", e.ClientIP,
+	}
+	add := func(k, v string) {
+		if v != "" {
+			kv = append(kv, k, v)
+		}
+	}
+	add("query", e.Query)
+	add("request_id", e.RequestID)
+	add("trace_id", e.TraceID)
+	add("user_agent", e.UserAgent)
+	if e.RequestHeaders != nil {
+		kv = append(kv, "request_headers", e.RequestHeaders)
+	}
+	if e.ResponseHeaders != nil {
+		kv = append(kv, "response_headers", e.ResponseHeaders)
+	}
+	add("request_body", e.RequestBody)
+	add("response_body", e.ResponseBody)
+	return kv
+}
+
+// combined renders the Apache combined log format:
+// host ident user [time] "request" status bytes "referer" "user agent".
+func (e accessEntry) combined() string {
+	uri := e.Path
+	if e.Query != "" {
+		uri += "?" + e.Query
+	}
+	var b strings.Builder
+	b.WriteString(e.ClientIP)
+	b.WriteString(" - - [")
+	b.WriteString(e.Time.Format("02/Jan/2006:15:04:05 -0700"))
+	b.WriteString(`] "`)
+	b.WriteString(e.Method + " " + uri + " " + e.Protocol)
+	b.WriteString(`" `)
+	b.WriteString(strconv.Itoa(e.Status))
+	b.WriteByte(' ')
+	if e.BytesOut > 0 {
+		b.WriteString(strconv.Itoa(e.BytesOut))
+	} else {
+		b.WriteByte('-')
+	}
+	b.WriteString(" " + strconv.Quote(orDash(e.Referer)))
+	b.WriteString(" " + strconv.Quote(orDash(e.UserAgent)))
+	return b.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func addLogHeader(dst map[string]string, redact map[string]bool, k, v []byte) {
+	name := http.CanonicalHeaderKey(string(k))
+	value := string(v)
+	if redact[name] {
+		value = redactedValue
+	}
+	if prev, ok := dst[name]; ok {
+		value = prev + ", " + value
+	}
+	dst[name] = value
+}
+
+func truncateBody(body []byte, limit int) string {
+	if len(body) <= limit {
+		return string(body)
+	}
+	return string(body[:limit]) + "...(truncated)"
+}
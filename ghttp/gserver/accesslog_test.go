@@ -0,0 +1,173 @@
+package gserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sofiworker/gk/glog"
+)
+
+type logEntry struct {
+	level string
+	msg   string
+	kv    map[string]interface{}
+}
+
+// recordLogger is a glog.GLogger that keeps its entries in memory.
+type recordLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordLogger) add(level, msg string, args []interface{}) {
+	kv := make(map[string]interface{})
+	for i := 0; i+1 < len(args); i += 2 {
+		kv[fmt.Sprint(args[i])] = args[i+1]
+	}
+	l.mu.Lock()
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, kv: kv})
+	l.mu.Unlock()
+}
+
+func (l *recordLogger) take() []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := l.entries
+	l.entries = nil
+	return entries
+}
+
+func (l *recordLogger) Debugf(string, ...interface{})         {}
+func (l *recordLogger) Infof(string, ...interface{})          {}
+func (l *recordLogger) Warnf(string, ...interface{})          {}
+func (l *recordLogger) Errorf(string, ...interface{})         {}
+func (l *recordLogger) With(...interface{}) glog.GLogger      { return l }
+func (l *recordLogger) Debug(msg string, args ...interface{}) { l.add("debug", msg, args) }
+func (l *recordLogger) Info(msg string, args ...interface{})  { l.add("info", msg, args) }
+func (l *recordLogger) Warn(msg string, args ...interface{})  { l.add("warn", msg, args) }
+func (l *recordLogger) Error(msg string, args ...interface{}) { l.add("error", msg, args) }
+func (l *recordLogger) Fatal(msg string, args ...interface{}) { l.add("fatal", msg, args) }
+func (l *recordLogger) SetLevel(glog.Level)                   {}
+func (l *recordLogger) Config() *glog.Config                  { return nil }
+func (l *recordLogger) Sync() error                           { return nil }
+func (l *recordLogger) DebugContext(_ context.Context, msg string, args ...interface{}) {
+	l.Debug(msg, args...)
+}
+func (l *recordLogger) InfoContext(_ context.Context, msg string, args ...interface{}) {
+	l.Info(msg, args...)
+}
+func (l *recordLogger) WarnContext(_ context.Context, msg string, args ...interface{}) {
+	l.Warn(msg, args...)
+}
+func (l *recordLogger) ErrorContext(_ context.Context, msg string, args ...interface{}) {
+	l.Error(msg, args...)
+}
+
+func newAccessLogServer(cfg AccessLogConfig) (*Server, *recordLogger) {
+	logger := &recordLogger{}
+	cfg.Logger = logger
+	server := NewServer()
+	server.Use(RequestID(RequestIDConfig{}), AccessLog(cfg))
+	server.POST("/users/:id", func(c *Context) {
+		c.Header("Set-Cookie", "session=secret")
+		c.String(http.StatusCreated, "created %s", c.Param("id"))
+	})
+	server.GET("/health", func(c *Context) { c.String(http.StatusOK, "ok") })
+	server.GET("/fail", func(c *Context) { c.String(http.StatusInternalServerError, "boom") })
+	server.GET("/gone", func(c *Context) { c.Status(http.StatusGone) })
+	return server, logger
+}
+
+func TestAccessLogStructuredFields(t *testing.T) {
+	server, logger := newAccessLogServer(AccessLogConfig{
+		SkipPaths:       []string{"/health"},
+		RequestHeaders:  true,
+		ResponseHeaders: true,
+		RequestBody:     true,
+		ResponseBody:    true,
+		MaxBodySize:     4,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users/42?x=1", strings.NewReader(`{"name":"alice"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-Request-ID", "req-1")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	entries := logger.take()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.level != "info" || e.msg != "access" {
+		t.Fatalf("unexpected entry %s %q", e.level, e.msg)
+	}
+	want := map[string]interface{}{
+		"method":        "POST",
+		"route":         "/users/:id",
+		"path":          "/users/42",
+		"query":         "x=1",
+		"status":        http.StatusCreated,
+		"bytes_in":      16,
+		"bytes_out":     len("created 42"),
+		"request_id":    "req-1",
+		"user_agent":    "test-agent",
+		"request_body":  `{"na...(truncated)`,
+		"response_body": "crea...(truncated)",
+	}
+	for k, v := range want {
+		if e.kv[k] != v {
+			t.Errorf("%s = %v, want %v", k, e.kv[k], v)
+		}
+	}
+	if _, ok := e.kv["latency_ms"].(float64); !ok || e.kv["client_ip"] == "" {
+		t.Errorf("missing latency or client ip: %v", e.kv)
+	}
+	if h := e.kv["request_headers"].(map[string]string); h["Authorization"] != redactedValue || h["User-Agent"] != "test-agent" {
+		t.Errorf("unexpected request headers %v", h)
+	}
+	if h := e.kv["response_headers"].(map[string]string); h["Set-Cookie"] != redactedValue {
+		t.Errorf("unexpected response headers %v", h)
+	}
+}
+
+func TestAccessLogFormatsAndSampling(t *testing.T) {
+	server, logger := newAccessLogServer(AccessLogConfig{Format: AccessLogCombined, SampleRate: 0.0001})
+	for i := 0; i < 20; i++ {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	}
+	req := httptest.NewRequest(http.MethodGet, "/fail?q=1", nil)
+	req.Header.Set("User-Agent", "curl/8")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Successful requests are sampled away, errors are always logged.
+	entries := logger.take()
+	if len(entries) != 1 || entries[0].level != "error" {
+		t.Fatalf("expected only the error entry, got %v", entries)
+	}
+	line := entries[0].msg
+	if !strings.Contains(line, `"GET /fail?q=1 HTTP/1.1" 500 4 "-" "curl/8"`) || !strings.HasPrefix(line, entries[0].kv["client_ip"].(string)+" - - [") {
+		t.Fatalf("unexpected combined line %q", line)
+	}
+
+	server, logger = newAccessLogServer(AccessLogConfig{Format: AccessLogJSON})
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/gone", nil))
+	entries = logger.take()
+	if len(entries) != 1 || entries[0].level != "warn" {
+		t.Fatalf("expected a warn entry, got %v", entries)
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(entries[0].msg), &obj); err != nil {
+		t.Fatalf("message is not JSON: %v", err)
+	}
+	if obj["status"] != float64(http.StatusGone) || obj["path"] != "/gone" || obj["latency_ms"] == nil || obj["request_id"] == "" {
+		t.Fatalf("unexpected JSON entry %v", obj)
+	}
+}
@@ -0,0 +1,433 @@
+package gserver
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sofiworker/gk/grx"
+)
+
+var (
+	ErrBindTarget         = errors.New("bind: target must be a non-nil pointer to a struct")
+	ErrUnsupportedBinding = errors.New("bind: unsupported content type")
+
+	bindFieldCache = grx.NewFieldCache()
+	bindPlans      sync.Map // map[reflect.Type]*bindPlan
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// Binding sources, in the order they are applied. Later sources override the body.
+const (
+	BindURI    = "uri"
+	BindQuery  = "query"
+	BindForm   = "form"
+	BindHeader = "header"
+	BindCookie = "cookie"
+)
+
+var bindSources = []string{BindURI, BindQuery, BindForm, BindHeader, BindCookie}
+
+type bindField struct {
+	index      []int
+	source     string
+	name       string
+	defaultVal string
+	hasDefault bool
+	nested     *bindPlan
+}
+
+type bindPlan struct {
+	fields []bindField
+}
+
+// ShouldBind fills obj from the request body (decoded with the CodecFactory codec
+// registered for the Content-Type) and from uri, query, form, header and cookie
+// struct tags, then validates it against its validate tags.
+// Tag options: `query:"page,default=1"`.
+func (c *Context) ShouldBind(obj interface{}) error {
+	if err := c.bindBody(obj); err != nil {
+		return err
+	}
+	if err := c.BindValues(obj); err != nil {
+		return err
+	}
+	return Validate(obj)
+}
+
+// Bind is ShouldBind that also renders a 400 through ErrorResult and aborts on failure.
+func (c *Context) Bind(obj interface{}) error {
+	err := c.ShouldBind(obj)
+	if err != nil {
+		ErrorCode(err, http.StatusBadRequest).Execute(c)
+		c.Abort()
+	}
+	return err
+}
+
+// BindValues fills obj from uri, query, form, header and cookie tags only.
+func (c *Context) BindValues(obj interface{}) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrBindTarget
+	}
+	var errs ValidationErrors
+	c.bindPlanInto(planFor(rv.Elem().Type()), rv.Elem(), "", &errs, make(map[*bindPlan]bool))
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// BindQuery fills obj from query tags and validates it.
+func (c *Context) BindQuery(obj interface{}) error {
+	if err := c.BindValues(obj); err != nil {
+		return err
+	}
+	return Validate(obj)
+}
+
+func (c *Context) bindBody(obj interface{}) error {
+	if c.fastCtx == nil {
+		return nil
+	}
+	body := c.fastCtx.Request.Body()
+	if len(body) == 0 {
+		return nil
+	}
+	ct := normalizeContentType(c.ContentType())
+	switch ct {
+	case "", MIMEPOSTForm, MIMEMultipartPOSTForm:
+		return nil
+	}
+	if c.codec == nil {
+		return ErrUnsupportedBinding
+	}
+	codec := c.codec.Get(ct)
+	if codec == nil {
+		return fmt.Errorf("%w: %s", ErrUnsupportedBinding, ct)
+	}
+	if err := codec.DecodeBytes(body, obj); err != nil {
+		return ValidationErrors{{Field: "body", Tag: "decode", Message: err.Error()}}
+	}
+	return nil
+}
+
+func planFor(t reflect.Type) *bindPlan {
+	if v, ok := bindPlans.Load(t); ok {
+		return v.(*bindPlan)
+	}
+	building := make(map[reflect.Type]*bindPlan)
+	plan := buildPlan(t, building)
+	// Plans are published only once complete; nested plans may point back to
+	// plan, so they are published with it.
+	for bt, bp := range building {
+		if bt != t {
+			bindPlans.LoadOrStore(bt, bp)
+		}
+	}
+	actual, _ := bindPlans.LoadOrStore(t, plan)
+	return actual.(*bindPlan)
+}
+
+// buildPlan builds the plan of t. building holds the plans in progress so
+// that recursive types terminate.
+func buildPlan(t reflect.Type, building map[reflect.Type]*bindPlan) *bindPlan {
+	plan := &bindPlan{}
+	building[t] = plan
+	for _, info := range sortedFields(t) {
+		field := info.Field
+		if !field.IsExported() {
+			continue
+		}
+		bf := bindField{index: info.Index}
+		for _, source := range bindSources {
+			tag, ok := field.Tag.Lookup(source)
+			if !ok || tag == "-" {
+				continue
+			}
+			bf.source = source
+			bf.name, bf.defaultVal, bf.hasDefault = parseBindTag(tag, field.Name)
+			break
+		}
+		if bf.source == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() != reflect.Struct || ft == timeType || reflect.PointerTo(ft).Implements(textUnmarshalerType) {
+				continue
+			}
+			if v, ok := bindPlans.Load(ft); ok {
+				bf.nested = v.(*bindPlan)
+			} else if inProgress, ok := building[ft]; ok {
+				bf.nested = inProgress
+			} else {
+				bf.nested = buildPlan(ft, building)
+			}
+			bf.name = field.Name
+		}
+		plan.fields = append(plan.fields, bf)
+	}
+	return plan
+}
+
+// sortedFields returns the cached fields of t in declaration order.
+func sortedFields(t reflect.Type) []grx.StructFieldInfo {
+	fields := bindFieldCache.Fields(t)
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i].Index, fields[j].Index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return fields
+}
+
+func parseBindTag(tag, fallback string) (name, def string, hasDefault bool) {
+	parts := strings.Split(tag, ",")
+	name = strings.TrimSpace(parts[0])
+	if name == "" {
+		name = fallback
+	}
+	for _, opt := range parts[1:] {
+		if strings.HasPrefix(opt, "default=") {
+			return name, strings.TrimPrefix(opt, "default="), true
+		}
+	}
+	return name, "", false
+}
+
+// bindPlanInto binds the fields of plan into v. path holds the plans being
+// bound, so a field referring back to one of them, such as Child *T in T, is
+// left alone instead of recursing forever.
+func (c *Context) bindPlanInto(plan *bindPlan, v reflect.Value, prefix string, errs *ValidationErrors, path map[*bindPlan]bool) {
+	path[plan] = true
+	defer delete(path, plan)
+	for _, bf := range plan.fields {
+		fv := v.FieldByIndex(bf.index)
+		if bf.nested != nil {
+			if path[bf.nested] {
+				continue
+			}
+			if fv.Kind() == reflect.Ptr {
+				if !c.planHasValues(bf.nested, path) {
+					continue
+				}
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			c.bindPlanInto(bf.nested, fv, prefix+bf.name+".", errs, path)
+			continue
+		}
+
+		if bf.source == BindForm && (fv.Type() == fileHeaderType || fv.Type() == reflect.SliceOf(fileHeaderType)) {
+			c.bindFormFiles(fv, bf.name)
+			continue
+		}
+
+		values := c.bindSourceValues(bf.source, bf.name)
+		if len(values) == 0 {
+			if !bf.hasDefault {
+				continue
+			}
+			values = []string{bf.defaultVal}
+		}
+		if err := setFieldValues(fv, values); err != nil {
+			*errs = append(*errs, FieldError{
+				Field:   prefix + bf.name,
+				Tag:     bf.source,
+				Message: fmt.Sprintf("invalid %s value %q: %v", bf.source, strings.Join(values, ","), err),
+			})
+		}
+	}
+}
+
+// planHasValues reports whether the request has values for the fields of plan,
+// skipping the plans on path like bindPlanInto does.
+func (c *Context) planHasValues(plan *bindPlan, path map[*bindPlan]bool) bool {
+	path[plan] = true
+	defer delete(path, plan)
+	for _, bf := range plan.fields {
+		if bf.nested != nil {
+			if path[bf.nested] {
+				continue
+			}
+			if c.planHasValues(bf.nested, path) {
+				return true
+			}
+			continue
+		}
+		if bf.hasDefault || len(c.bindSourceValues(bf.source, bf.name)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Context) bindSourceValues(source, name string) []string {
+	if c.fastCtx == nil {
+		if source == BindURI {
+			if v, ok := c.pathParams[name]; ok {
+				return []string{v}
+			}
+		}
+		return nil
+	}
+	switch source {
+	case BindURI:
+		if v, ok := c.pathParams[name]; ok {
+			return []string{v}
+		}
+	case BindQuery:
+		return bytesToStrings(c.fastCtx.QueryArgs().PeekMulti(name))
+	case BindForm:
+		if values := bytesToStrings(c.fastCtx.PostArgs().PeekMulti(name)); len(values) > 0 {
+			return values
+		}
+		if isMultipart(c.ContentType()) {
+			if form, err := c.fastCtx.MultipartForm(); err == nil && form != nil {
+				return form.Value[name]
+			}
+		}
+	case BindHeader:
+		return bytesToStrings(c.fastCtx.Request.Header.PeekAll(name))
+	case BindCookie:
+		if v := c.fastCtx.Request.Header.Cookie(name); v != nil {
+			return []string{string(v)}
+		}
+	}
+	return nil
+}
+
+func (c *Context) bindFormFiles(fv reflect.Value, name string) {
+	if c.fastCtx == nil || !isMultipart(c.ContentType()) {
+		return
+	}
+	form, err := c.fastCtx.MultipartForm()
+	if err != nil || form == nil || len(form.File[name]) == 0 {
+		return
+	}
+	files := form.File[name]
+	if fv.Kind() == reflect.Slice {
+		fv.Set(reflect.ValueOf(files))
+		return
+	}
+	fv.Set(reflect.ValueOf(files[0]))
+}
+
+func isMultipart(ct string) bool {
+	return normalizeContentType(ct) == MIMEMultipartPOSTForm
+}
+
+func bytesToStrings(values [][]byte) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = string(v)
+	}
+	return out
+}
+
+func setFieldValues(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(fv.Type(), 0, len(values))
+		for _, raw := range values {
+			elem := reflect.New(fv.Type().Elem()).Elem()
+			if err := setFieldValue(elem, raw); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setFieldValue(fv, values[0])
+}
+
+func setFieldValue(fv reflect.Value, raw string) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setFieldValue(fv.Elem(), raw)
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	switch fv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case timeType:
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(ts))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Bool:
+		if raw == "" || raw == "on" {
+			fv.SetBool(raw == "on")
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		// []byte
+		fv.SetBytes([]byte(raw))
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package gserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type bindPaging struct {
+	Page int `query:"page,default=1" validate:"min=1"`
+	Size int `query:"size,default=20" validate:"max=100"`
+}
+
+type bindUserRequest struct {
+	ID      int      `uri:"id" validate:"required,gt=0"`
+	Name    string   `json:"name" validate:"required,min=2,max=16"`
+	Email   string   `json:"email" validate:"omitempty,email"`
+	Role    string   `json:"role" validate:"oneof=admin user"`
+	Tags    []string `query:"tag"`
+	TraceID string   `header:"X-Trace-Id"`
+	Session string   `cookie:"sid"`
+	Code    string   `json:"code" validate:"omitempty,regexp=^[a-z]{2,3}$"`
+	Paging  bindPaging
+}
+
+func TestShouldBindMergesSources(t *testing.T) {
+	server := NewServer()
+	var got bindUserRequest
+	server.POST("/users/:id", func(c *Context) {
+		if err := c.ShouldBind(&got); err != nil {
+			c.String(http.StatusBadRequest, "%v", err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users/42?tag=a&tag=b&size=50",
+		strings.NewReader(`{"name":"alice","email":"alice@example.com","role":"admin","code":"ab"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Trace-Id", "trace-1")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "s1"})
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got.ID != 42 || got.Name != "alice" || got.TraceID != "trace-1" || got.Session != "s1" {
+		t.Fatalf("unexpected binding %+v", got)
+	}
+	if len(got.Tags) != 2 || got.Tags[1] != "b" {
+		t.Fatalf("unexpected tags %v", got.Tags)
+	}
+	if got.Paging.Page != 1 || got.Paging.Size != 50 {
+		t.Fatalf("unexpected paging %+v", got.Paging)
+	}
+}
+
+type bindTree struct {
+	Name  string `query:"name"`
+	Child *bindTree
+}
+
+func TestBindPlanConcurrentFirstUse(t *testing.T) {
+	var wg sync.WaitGroup
+	plans := make([]*bindPlan, 8)
+	for i := range plans {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			plans[i] = planFor(reflect.TypeOf(bindTree{}))
+		}(i)
+	}
+	wg.Wait()
+	for _, plan := range plans {
+		if len(plan.fields) != 2 || plan.fields[1].nested == nil || len(plan.fields[1].nested.fields) != 2 {
+			t.Fatalf("expected a complete recursive plan, got %+v", plan.fields)
+		}
+	}
+}
+
+type bindCycleA struct {
+	B *bindCycleB
+}
+
+type bindCycleB struct {
+	V int `query:"v"`
+	A *bindCycleA
+}
+
+func TestBindSelfReferentialTypes(t *testing.T) {
+	server := NewServer()
+	var tree bindTree
+	var cycle bindCycleA
+	server.GET("/bind", func(c *Context) {
+		if err := c.BindValues(&tree); err != nil {
+			c.String(http.StatusBadRequest, "%v", err)
+			return
+		}
+		if err := c.BindValues(&cycle); err != nil {
+			c.String(http.StatusBadRequest, "%v", err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/bind?name=root&v=7", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if tree.Name != "root" || tree.Child != nil {
+		t.Fatalf("a field referring back to its own type must be left alone, got %+v", tree)
+	}
+	if cycle.B == nil || cycle.B.V != 7 || cycle.B.A != nil {
+		t.Fatalf("unexpected binding of mutually recursive types %+v", cycle.B)
+	}
+}
+
+func TestBindRendersValidationErrors(t *testing.T) {
+	server := NewServer()
+	server.POST("/users/:id", func(c *Context) {
+		var req bindUserRequest
+		if c.Bind(&req) != nil {
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users/abc?size=500",
+		strings.NewReader(`{"name":"a","email":"nope","role":"root","code":"ABC"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	var body struct {
+		Message string       `json:"message"`
+		Errors  []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+	}
+	// A malformed path parameter stops binding before validation runs.
+	if len(body.Errors) != 1 || body.Errors[0].Field != "id" || body.Errors[0].Tag != BindURI {
+		t.Fatalf("unexpected errors %+v", body.Errors)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/users/7?size=500",
+		strings.NewReader(`{"name":"a","email":"nope","role":"root","code":"ABC"}`))
+	req.Header.Set("Content-Type", "application/json")
+	server.ServeHTTP(rec, req)
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+	}
+	tags := map[string]string{}
+	for _, e := range body.Errors {
+		tags[e.Field] = e.Tag
+	}
+	want := map[string]string{"name": "min", "email": "email", "role": "oneof", "code": "regexp", "Paging.size": "max"}
+	for field, tag := range want {
+		if tags[field] != tag {
+			t.Fatalf("expected %s to fail %s, got %+v", field, tag, body.Errors)
+		}
+	}
+}
+
+func TestValidateCustomRuleAndNested(t *testing.T) {
+	RegisterValidation("even", func(v reflect.Value, _ string) bool {
+		return v.Int()%2 == 0
+	})
+	type item struct {
+		N int `json:"n" validate:"even"`
+	}
+	type payload struct {
+		Items []item `json:"items" validate:"min=1"`
+	}
+
+	err := Validate(&payload{Items: []item{{N: 2}, {N: 3}}})
+	verrs, ok := err.(ValidationErrors)
+	if !ok || len(verrs) != 1 || verrs[0].Field != "items[1].n" || verrs[0].Tag != "even" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	if err := Validate(&payload{}); err == nil {
+		t.Fatal("expected min=1 to reject an empty slice")
+	}
+}
+
+func TestRegisterValidationWhileValidating(t *testing.T) {
+	type payload struct {
+		N int `validate:"odd"`
+	}
+	RegisterValidation("odd", func(v reflect.Value, _ string) bool { return v.Int()%2 == 1 })
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = Validate(&payload{N: 1})
+			}
+		}()
+	}
+	for j := 0; j < 20; j++ {
+		RegisterValidation("odd", func(v reflect.Value, _ string) bool { return v.Int()%2 == 1 })
+	}
+	wg.Wait()
+	if err := Validate(&payload{N: 2}); err == nil {
+		t.Fatal("expected the odd rule to reject 2")
+	}
+}
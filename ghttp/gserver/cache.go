@@ -0,0 +1,295 @@
+package gserver
+
+import (
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheControl builds a Cache-Control header value. Zero fields are omitted.
+type CacheControl struct {
+	Public               bool
+	Private              bool
+	NoCache              bool
+	NoStore              bool
+	NoTransform          bool
+	MustRevalidate       bool
+	ProxyRevalidate      bool
+	Immutable            bool
+	MaxAge               time.Duration
+	SMaxAge              time.Duration
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+}
+
+func (cc CacheControl) String() string {
+	var parts []string
+	flag := func(on bool, name string) {
+		if on {
+			parts = append(parts, name)
+		}
+	}
+	seconds := func(d time.Duration, name string) {
+		if d > 0 {
+			parts = append(parts, name+"="+strconv.FormatInt(int64(d/time.Second), 10))
+		}
+	}
+	flag(cc.Public, "public")
+	flag(cc.Private, "private")
+	flag(cc.NoCache, "no-cache")
+	flag(cc.NoStore, "no-store")
+	flag(cc.NoTransform, "no-transform")
+	flag(cc.MustRevalidate, "must-revalidate")
+	flag(cc.ProxyRevalidate, "proxy-revalidate")
+	flag(cc.Immutable, "immutable")
+	seconds(cc.MaxAge, "max-age")
+	seconds(cc.SMaxAge, "s-maxage")
+	seconds(cc.StaleWhileRevalidate, "stale-while-revalidate")
+	seconds(cc.StaleIfError, "stale-if-error")
+	return strings.Join(parts, ", ")
+}
+
+// NoStore forbids caching the response anywhere.
+func NoStore() CacheControl {
+	return CacheControl{NoStore: true}
+}
+
+// MaxAge lets shared and private caches reuse the response for d.
+func MaxAge(d time.Duration) CacheControl {
+	return CacheControl{Public: true, MaxAge: d}
+}
+
+// Revalidate lets caches store the response but requires a conditional request on every use.
+func Revalidate() CacheControl {
+	return CacheControl{NoCache: true}
+}
+
+// CacheResult decorates a Result with caching headers. When the request's
+// preconditions already decide the outcome, such as a matching If-None-Match,
+// the wrapped Result is never executed.
+type CacheResult struct {
+	Result       Result
+	Control      *CacheControl
+	ETag         string
+	LastModified time.Time
+}
+
+func (r *CacheResult) Execute(ctx *Context) {
+	if ctx == nil {
+		return
+	}
+	if r.Control != nil {
+		if v := r.Control.String(); v != "" {
+			ctx.Header("Cache-Control", v)
+		}
+	}
+	if !ctx.CheckPreconditions(r.ETag, r.LastModified) {
+		return
+	}
+	if r.Result != nil {
+		r.Result.Execute(ctx)
+	}
+}
+
+// Cached wraps result with a Cache-Control header.
+func Cached(result Result, cc CacheControl) *CacheResult {
+	return &CacheResult{Result: result, Control: &cc}
+}
+
+// WithETag wraps result with an entity tag. Unquoted tags are quoted; pass a
+// "W/" prefixed tag for a weak validator.
+func WithETag(result Result, etag string) *CacheResult {
+	return &CacheResult{Result: result, ETag: etag}
+}
+
+func (r *CacheResult) WithETag(etag string) *CacheResult {
+	r.ETag = etag
+	return r
+}
+
+func (r *CacheResult) WithLastModified(t time.Time) *CacheResult {
+	r.LastModified = t
+	return r
+}
+
+func (r *CacheResult) WithCacheControl(cc CacheControl) *CacheResult {
+	r.Control = &cc
+	return r
+}
+
+// CheckPreconditions sets the ETag and Last-Modified validators of the current
+// representation and evaluates the request's conditional headers against them
+// in RFC 9110 order. It responds 304 or 412 and returns false when the handler
+// must not continue. An empty etag or zero lastModified is not emitted or
+// compared, and without either validator every request proceeds.
+func (c *Context) CheckPreconditions(etag string, lastModified time.Time) bool {
+	if etag == "" && lastModified.IsZero() {
+		return true
+	}
+	etag = quoteETag(etag)
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	status := evaluatePreconditions(string(c.fastCtx.Method()), c.requestHeader, etag, lastModified, true)
+	if status == 0 {
+		return true
+	}
+	c.Status(status)
+	return false
+}
+
+// evaluatePreconditions implements RFC 9110 section 13.2.2 and returns 0, 304 or 412.
+func evaluatePreconditions(method string, header func(string) string, etag string, lastModified time.Time, exists bool) int {
+	safe := method == http.MethodGet || method == http.MethodHead
+
+	if im := header("If-Match"); im != "" {
+		if !etagListMatch(im, etag, exists, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := header("If-Unmodified-Since"); ius != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ius); err == nil && lastModified.Truncate(time.Second).After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := header("If-None-Match"); inm != "" {
+		if etagListMatch(inm, etag, exists, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := header("If-Modified-Since"); ims != "" && safe && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.Truncate(time.Second).After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// etagListMatch reports whether etag matches a conditional header list. "*"
+// matches any existing representation. Strong comparison never matches weak tags.
+func etagListMatch(list, etag string, exists, strong bool) bool {
+	list = strings.TrimSpace(list)
+	if list == "*" {
+		return exists
+	}
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		if etagEqual(strings.TrimSpace(candidate), etag, strong) {
+			return true
+		}
+	}
+	return false
+}
+
+func etagEqual(a, b string, strong bool) bool {
+	weakA, weakB := strings.HasPrefix(a, "W/"), strings.HasPrefix(b, "W/")
+	if strong && (weakA || weakB) {
+		return false
+	}
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+func quoteETag(etag string) string {
+	if etag == "" {
+		return ""
+	}
+	weak := strings.HasPrefix(etag, "W/")
+	tag := strings.TrimPrefix(etag, "W/")
+	if !strings.HasPrefix(tag, `"`) {
+		tag = strconv.Quote(tag)
+	}
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+type ETagConfig struct {
+	// Weak emits W/ prefixed tags, appropriate when equivalent bodies may differ
+	// byte for byte.
+	Weak bool
+	// Generate returns the tag of a buffered body. The default is a 64-bit FNV-1a
+	// hash of the body and its length.
+	Generate func(body []byte) string
+	// Current resolves the validators of the target resource before an unsafe
+	// request runs, so If-Match and If-Unmodified-Since can answer 412 without
+	// executing the handler. exists is false when the resource is absent.
+	Current func(c *Context) (etag string, lastModified time.Time, exists bool)
+
+	Skip func(c *Context) bool
+}
+
+// ETag adds an entity tag to successful GET and HEAD responses that do not set
+// one and answers If-None-Match and If-Modified-Since with 304 Not Modified.
+// For other methods it enforces If-Match and If-Unmodified-Since with 412
+// Precondition Failed when Current is configured.
+func ETag(cfg ETagConfig) HandlerFunc {
+	if cfg.Generate == nil {
+		cfg.Generate = hashETag
+	}
+
+	return func(c *Context) {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+
+		method := string(c.fastCtx.Method())
+		if method != http.MethodGet && method != http.MethodHead {
+			if cfg.Current != nil && (c.GetHeader("If-Match") != "" || c.GetHeader("If-Unmodified-Since") != "") {
+				etag, lastModified, exists := cfg.Current(c)
+				if status := evaluatePreconditions(method, c.requestHeader, quoteETag(etag), lastModified, exists); status != 0 {
+					c.Status(status)
+					c.Abort()
+					return
+				}
+			}
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		resp := &c.fastCtx.Response
+		if c.StatusCode() != http.StatusOK || resp.IsBodyStream() {
+			return
+		}
+		etag := string(resp.Header.Peek("ETag"))
+		if etag == "" {
+			etag = quoteETag(cfg.Generate(resp.Body()))
+			if etag == "" {
+				return
+			}
+			if cfg.Weak && !strings.HasPrefix(etag, "W/") {
+				etag = "W/" + etag
+			}
+			resp.Header.Set("ETag", etag)
+		}
+		var lastModified time.Time
+		if lm := resp.Header.Peek("Last-Modified"); len(lm) > 0 {
+			lastModified, _ = http.ParseTime(string(lm))
+		}
+
+		if evaluatePreconditions(method, c.requestHeader, etag, lastModified, true) == http.StatusNotModified {
+			resp.ResetBody()
+			resp.Header.Del("Content-Length")
+			c.Status(http.StatusNotModified)
+		}
+	}
+}
+
+func hashETag(body []byte) string {
+	h := fnv.New64a()
+	_, _ = h.Write(body)
+	return `"` + strconv.FormatInt(int64(len(body)), 16) + "-" + strconv.FormatUint(h.Sum64(), 16) + `"`
+}
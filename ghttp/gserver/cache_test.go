@@ -0,0 +1,169 @@
+package gserver
+
+import (
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheControlString(t *testing.T) {
+	cc := CacheControl{Public: true, Immutable: true, MaxAge: time.Hour, StaleWhileRevalidate: 30 * time.Second}
+	if got := cc.String(); got != "public, immutable, max-age=3600, stale-while-revalidate=30" {
+		t.Fatalf("unexpected header %q", got)
+	}
+	if got := NoStore().String(); got != "no-store" {
+		t.Fatalf("unexpected header %q", got)
+	}
+}
+
+func TestETagMiddlewareNotModified(t *testing.T) {
+	server := NewServer()
+	server.Use(ETag(ETagConfig{}))
+	server.GET("/items", func(c *Context) { c.JSON(http.StatusOK, map[string]int{"n": 1}) })
+
+	do := func(inm string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := do("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with ETag, got %d %q", first.Code, etag)
+	}
+	if rec := do("W/" + etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected 304 for a weakly matching tag, got %d", rec.Code)
+	}
+	if rec := do(`"other"`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a different tag, got %d", rec.Code)
+	}
+}
+
+func TestETagMiddlewareIfMatch(t *testing.T) {
+	version := `"v2"`
+	server := NewServer()
+	server.Use(ETag(ETagConfig{Current: func(c *Context) (string, time.Time, bool) {
+		return version, time.Time{}, true
+	}}))
+	server.PUT("/items/1", func(c *Context) { c.Status(http.StatusNoContent) })
+
+	do := func(ifMatch string) int {
+		req := httptest.NewRequest(http.MethodPut, "/items/1", nil)
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := do(`"v1"`); code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a stale tag, got %d", code)
+	}
+	if code := do(`W/"v2"`); code != http.StatusPreconditionFailed {
+		t.Fatalf("If-Match must use strong comparison, got %d", code)
+	}
+	if code := do(`"v1", "v2"`); code != http.StatusNoContent {
+		t.Fatalf("expected 204 for a current tag, got %d", code)
+	}
+}
+
+type resultFunc func(c *Context)
+
+func (f resultFunc) Execute(c *Context) { f(c) }
+
+func TestCacheResultPreconditions(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	executed := 0
+	server := NewServer()
+	server.GET("/doc", Wrap(func(c *Context) Result {
+		return Cached(resultFunc(func(c *Context) {
+			executed++
+			c.String(http.StatusOK, "doc")
+		}), MaxAge(time.Minute)).WithETag("doc-1").WithLastModified(modified)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/doc", nil)
+	req.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || executed != 0 {
+		t.Fatalf("expected 304 without executing the result, got %d (executed %d)", rec.Code, executed)
+	}
+	if rec.Header().Get("Cache-Control") != "public, max-age=60" || rec.Header().Get("ETag") != `"doc-1"` {
+		t.Fatalf("unexpected headers %v", rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/doc", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "doc" || executed != 1 {
+		t.Fatalf("expected 200, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFileResultRanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	if err := os.WriteFile(path, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer()
+	server.GET("/file", Wrap(func(c *Context) Result { return File(path) }))
+
+	do := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/file", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	full := do(nil)
+	etag := full.Header().Get("ETag")
+	if full.Code != http.StatusOK || full.Header().Get("Accept-Ranges") != "bytes" || etag == "" {
+		t.Fatalf("unexpected full response %d %v", full.Code, full.Header())
+	}
+
+	rec := do(map[string]string{"Range": "bytes=2-4"})
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "234" || rec.Header().Get("Content-Range") != "bytes 2-4/10" {
+		t.Fatalf("unexpected single range %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+
+	rec = do(map[string]string{"Range": "bytes=0-1,8-9"})
+	mediaType, params, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if rec.Code != http.StatusPartialContent || mediaType != "multipart/byteranges" {
+		t.Fatalf("expected multipart/byteranges, got %d %q", rec.Code, mediaType)
+	}
+	mr := multipart.NewReader(rec.Body, params["boundary"])
+	var parts []string
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		buf := make([]byte, 8)
+		n, _ := p.Read(buf)
+		parts = append(parts, string(buf[:n]))
+	}
+	if len(parts) != 2 || parts[0] != "01" || parts[1] != "89" {
+		t.Fatalf("unexpected parts %q", parts)
+	}
+
+	if rec := do(map[string]string{"Range": "bytes=2-4", "If-Range": `"stale"`}); rec.Code != http.StatusOK || rec.Body.Len() != 10 {
+		t.Fatalf("stale If-Range must return the full body, got %d", rec.Code)
+	}
+	if rec := do(map[string]string{"Range": "bytes=2-4", "If-Range": etag}); rec.Code != http.StatusPartialContent {
+		t.Fatalf("matching If-Range must return a range, got %d", rec.Code)
+	}
+	if rec := do(map[string]string{"If-None-Match": etag}); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,228 @@
+package gserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay coalesces the bursts of events written by a certificate rotation.
+const reloadDelay = 100 * time.Millisecond
+
+// CertificateFiles is a PEM certificate chain and its private key.
+type CertificateFiles struct {
+	CertFile string
+	KeyFile  string
+}
+
+// TLSConfig configures TLS for a listener of RunListeners.
+type TLSConfig struct {
+	// Certificates are chosen by SNI; the first one is served when none matches.
+	Certificates []CertificateFiles
+	// ClientCAFile is a PEM bundle of the CAs trusted for client certificates.
+	// Setting it enables mutual TLS.
+	ClientCAFile string
+	// ClientAuth defaults to tls.RequireAndVerifyClientCert with ClientCAFile,
+	// else tls.NoClientCert.
+	ClientAuth tls.ClientAuthType
+	// MinVersion defaults to TLS 1.2.
+	MinVersion uint16
+	// Reload watches the files and reloads them when they change. A failed reload
+	// keeps the previous certificates.
+	Reload bool
+	// OnReload is called after each reload triggered by a file change.
+	OnReload func(err error)
+}
+
+// CertReloader serves certificates loaded from files and swaps them on Reload
+// without interrupting established connections.
+type CertReloader struct {
+	cfg   TLSConfig
+	state atomic.Pointer[certState]
+
+	watcher   *fsnotify.Watcher
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+type certState struct {
+	certs     []*tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// NewCertReloader loads the files of cfg and, with cfg.Reload, starts watching them.
+func NewCertReloader(cfg TLSConfig) (*CertReloader, error) {
+	if len(cfg.Certificates) == 0 {
+		return nil, errors.New("gserver: TLS needs at least one certificate")
+	}
+	if cfg.ClientAuth == tls.NoClientCert && cfg.ClientCAFile != "" {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	r := &CertReloader{cfg: cfg, done: make(chan struct{})}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	if cfg.Reload {
+		if err := r.watch(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Reload reads the certificate, key and CA files again.
+func (r *CertReloader) Reload() error {
+	st := &certState{}
+	for _, files := range r.cfg.Certificates {
+		cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+		if err != nil {
+			return fmt.Errorf("gserver: load certificate %s: %w", files.CertFile, err)
+		}
+		st.certs = append(st.certs, &cert)
+	}
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("gserver: load client CAs: %w", err)
+		}
+		st.clientCAs = x509.NewCertPool()
+		if !st.clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("gserver: no certificate found in %s", r.cfg.ClientCAFile)
+		}
+	}
+	r.state.Store(st)
+	return nil
+}
+
+// GetCertificate picks the first certificate valid for the ClientHello, using the
+// SNI name among others, and falls back to the first certificate.
+func (r *CertReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certs := r.state.Load().certs
+	for _, cert := range certs {
+		if hello.SupportsCertificate(cert) == nil {
+			return cert, nil
+		}
+	}
+	return certs[0], nil
+}
+
+// TLSConfig returns a tls.Config that always uses the latest certificates and CAs.
+func (r *CertReloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion:     r.cfg.MinVersion,
+		ClientAuth:     r.cfg.ClientAuth,
+		GetCertificate: r.GetCertificate,
+		NextProtos:     []string{"http/1.1"},
+	}
+	cfg := base.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		st := r.state.Load()
+		if st.clientCAs == nil {
+			return nil, nil
+		}
+		c := base.Clone()
+		c.ClientCAs = st.clientCAs
+		return c, nil
+	}
+	return cfg
+}
+
+// Close stops watching the files.
+func (r *CertReloader) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.done)
+		if r.watcher != nil {
+			err = r.watcher.Close()
+		}
+	})
+	return err
+}
+
+// watch observes the directories of the files, since rotations often replace
+// files or swap symlinks (as Kubernetes secrets do) rather than write in place.
+func (r *CertReloader) watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	add := func(name string) {
+		if name == "" {
+			return
+		}
+		name = filepath.Clean(name)
+		files[name] = true
+		dirs[filepath.Dir(name)] = true
+	}
+	for _, f := range r.cfg.Certificates {
+		add(f.CertFile)
+		add(f.KeyFile)
+	}
+	add(r.cfg.ClientCAFile)
+	for dir := range dirs {
+		if err := w.Add(dir); err != nil {
+			_ = w.Close()
+			return err
+		}
+	}
+	r.watcher = w
+
+	go func() {
+		var timer *time.Timer
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			select {
+			case <-r.done:
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Clean(ev.Name)
+				if !files[name] && !strings.HasPrefix(filepath.Base(name), "..") {
+					continue
+				}
+				if timer == nil {
+					timer = time.AfterFunc(reloadDelay, r.reloadFromWatch)
+				} else {
+					timer.Reset(reloadDelay)
+				}
+			case _, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *CertReloader) reloadFromWatch() {
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+	err := r.Reload()
+	if r.cfg.OnReload != nil {
+		r.cfg.OnReload(err)
+	}
+}
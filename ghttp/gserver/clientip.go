@@ -0,0 +1,147 @@
+package gserver
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Default headers ClientIP reads, in order, when the peer is a trusted proxy.
+var defaultRemoteIPHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"}
+
+// WithTrustedProxies sets the proxies, as CIDRs or single IPs, whose forwarding
+// headers ClientIP believes. Without trusted proxies ClientIP is the peer address.
+// It panics on an invalid entry.
+//
+//	gserver.WithTrustedProxies("10.0.0.0/8", "192.168.1.10")
+func WithTrustedProxies(proxies ...string) ServerOption {
+	nets, err := parseCIDRs(proxies)
+	if err != nil {
+		panic(err)
+	}
+	return func(c *Config) {
+		c.trustedProxies = nets
+	}
+}
+
+// WithRemoteIPHeaders sets the headers ClientIP reads, in order, when the peer is
+// a trusted proxy. The default is Forwarded, X-Forwarded-For and X-Real-IP.
+func WithRemoteIPHeaders(headers ...string) ServerOption {
+	return func(c *Config) {
+		c.remoteIPHeaders = headers
+	}
+}
+
+// ClientIP returns the real client IP. When the peer is a trusted proxy it walks
+// the first forwarding header present from right to left and returns the first
+// address that is not a trusted proxy. Otherwise it returns the peer address,
+// which is the original client when the PROXY protocol is enabled.
+func (c *Context) ClientIP() string {
+	remote := c.fastCtx.RemoteIP()
+	if c.engine == nil || !containsIP(c.engine.trustedProxies, remote) {
+		return remote.String()
+	}
+	headers := c.engine.remoteIPHeaders
+	if headers == nil {
+		headers = defaultRemoteIPHeaders
+	}
+	for _, name := range headers {
+		values := c.fastCtx.Request.Header.PeekAll(name)
+		if len(values) == 0 {
+			continue
+		}
+		var hops []string
+		for _, v := range values {
+			if strings.EqualFold(name, "Forwarded") {
+				hops = append(hops, forwardedFor(string(v))...)
+			} else {
+				hops = append(hops, strings.Split(string(v), ",")...)
+			}
+		}
+		if ip := clientFromHops(hops, c.engine.trustedProxies); ip != nil {
+			return ip.String()
+		}
+	}
+	return remote.String()
+}
+
+// RemoteIP returns the IP of the peer, ignoring forwarding headers.
+func (c *Context) RemoteIP() string {
+	return c.fastCtx.RemoteIP().String()
+}
+
+// clientFromHops returns the rightmost hop that is not a trusted proxy, or the
+// leftmost hop when all are trusted. It returns nil when a hop that has to be
+// inspected is not an IP.
+func clientFromHops(hops []string, trusted []*net.IPNet) net.IP {
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHopIP(hops[i])
+		if ip == nil {
+			return nil
+		}
+		if i == 0 || !containsIP(trusted, ip) {
+			return ip
+		}
+	}
+	return nil
+}
+
+// forwardedFor extracts the for= parameters of a Forwarded header (RFC 7239).
+func forwardedFor(value string) []string {
+	var hops []string
+	for _, element := range strings.Split(value, ",") {
+		for _, pair := range strings.Split(element, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(k, "for") {
+				hops = append(hops, strings.Trim(v, `"`))
+			}
+		}
+	}
+	return hops
+}
+
+// parseHopIP parses "1.2.3.4", "1.2.3.4:80", "2001:db8::1" and "[2001:db8::1]:80".
+func parseHopIP(hop string) net.IP {
+	hop = strings.TrimSpace(hop)
+	if ip := net.ParseIP(hop); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.Trim(hop, "[]"))
+}
+
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("gserver: invalid proxy address %q", s)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("gserver: invalid proxy CIDR %q: %w", s, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package gserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPTrustedProxies(t *testing.T) {
+	server := NewServer(WithTrustedProxies("10.0.0.0/8", "192.0.2.1"))
+	server.GET("/ip", func(c *Context) {
+		c.String(http.StatusOK, "%s %s", c.ClientIP(), c.RemoteIP())
+	})
+
+	cases := []struct {
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		// Untrusted peers cannot spoof their address.
+		{"203.0.113.9:1000", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "203.0.113.9 203.0.113.9"},
+		{"10.0.0.2:1000", map[string]string{"X-Forwarded-For": "6.6.6.6, 1.1.1.1, 10.1.1.1"}, "1.1.1.1 10.0.0.2"},
+		{"10.0.0.2:1000", map[string]string{"X-Forwarded-For": "10.2.2.2, 192.0.2.1"}, "10.2.2.2 10.0.0.2"},
+		{"192.0.2.1:1000", map[string]string{"X-Real-IP": "2001:db8::1"}, "2001:db8::1 192.0.2.1"},
+		{"10.0.0.2:1000", map[string]string{
+			"Forwarded":       `for=192.0.2.60;proto=http, for="[2001:db8::2]:4711"`,
+			"X-Forwarded-For": "1.1.1.1",
+		}, "2001:db8::2 10.0.0.2"},
+		// An unusable header falls through to the next one.
+		{"10.0.0.2:1000", map[string]string{"Forwarded": "for=unknown", "X-Real-IP": "8.8.8.8"}, "8.8.8.8 10.0.0.2"},
+		{"10.0.0.2:1000", nil, "10.0.0.2 10.0.0.2"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = tc.remote
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Body.String() != tc.want {
+			t.Errorf("remote %s headers %v: got %q, want %q", tc.remote, tc.headers, rec.Body.String(), tc.want)
+		}
+	}
+}
+
+func TestWithTrustedProxiesInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for an invalid CIDR")
+		}
+	}()
+	WithTrustedProxies("10.0.0.0/33")
+}
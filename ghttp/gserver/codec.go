@@ -15,6 +15,9 @@ var (
 
 type CodecFactory struct {
 	codecs sync.Map
+
+	mu    sync.Mutex
+	types []string
 }
 
 func newCodecFactory() *CodecFactory {
@@ -27,27 +30,45 @@ func newCodecFactory() *CodecFactory {
 	cf.Register("application/x-yaml", gcodec.NewYAMLCodec())
 	cf.Register("application/yaml", gcodec.NewYAMLCodec())
 	cf.Register("text/yaml", gcodec.NewYAMLCodec())
+	cf.Register("text/plain", gcodec.NewPlainCodec())
 
 	return cf
 }
 
+// Get returns the codec registered for a media type. A type with a structured
+// syntax suffix such as application/vnd.acme+json falls back to application/json.
 func (c *CodecFactory) Get(name string) gcodec.Codec {
 	key := normalizeContentType(name)
 	if v, ok := c.codecs.Load(key); ok {
 		return v.(gcodec.Codec)
 	}
+	if base := suffixBase(key); base != "" {
+		if v, ok := c.codecs.Load(base); ok {
+			return v.(gcodec.Codec)
+		}
+	}
 	return nil
 }
 
+// Types returns the registered media types in registration order, which is the
+// server preference used by content negotiation.
+func (c *CodecFactory) Types() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.types...)
+}
+
 func (c *CodecFactory) Register(name string, codec gcodec.Codec) error {
 	if codec == nil {
 		return ErrInvalidCodec
 	}
 	key := normalizeContentType(name)
-	if _, ok := c.codecs.Load(key); ok {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, loaded := c.codecs.LoadOrStore(key, codec); loaded {
 		return ErrAlreadyRegistered
 	}
-	c.codecs.Store(key, codec)
+	c.types = append(c.types, key)
 	return nil
 }
 
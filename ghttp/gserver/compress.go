@@ -0,0 +1,307 @@
+package gserver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/sofiworker/gk/gcompress"
+	"github.com/valyala/fasthttp"
+)
+
+// Content codings understood by Compress and the precompressed static files.
+const (
+	EncodingBrotli  = "br"
+	EncodingZstd    = "zstd"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+const defaultCompressMinSize = 1024
+
+var defaultCompressEncodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate}
+
+var defaultCompressContentTypes = []string{
+	"text/*",
+	MIMEJSON,
+	"application/*+json",
+	"application/javascript",
+	MIMEXML,
+	"application/*+xml",
+	MIMEYAML,
+	MIMEYAML2,
+	MIMETOML,
+	"application/wasm",
+	"image/svg+xml",
+}
+
+type CompressConfig struct {
+	// Encodings lists the codings to offer in server preference order, which breaks
+	// ties between equal client q-values. Default br, zstd, gzip, deflate.
+	Encodings []string
+
+	// GzipLevel is used for gzip and deflate, default gcompress.NewGzipUtil().CompressionLevel.
+	GzipLevel int
+	// BrotliLevel ranges 0-11, default 5.
+	BrotliLevel int
+	// ZstdLevel ranges 1-22, default 3.
+	ZstdLevel int
+
+	// MinSize skips buffered bodies smaller than this many bytes, default 1024.
+	// Streamed bodies have no known size and are always compressed.
+	MinSize int
+	// ContentTypes allowlists compressible media types. Entries may use path.Match
+	// patterns such as "text/*" or "application/*+json".
+	ContentTypes []string
+
+	Skip func(c *Context) bool
+}
+
+// Compress compresses responses for clients that accept it. Buffered bodies are
+// compressed after the handler returns; bodies streamed by StreamResult or
+// SSEResult are compressed and flushed chunk by chunk. Responses that already
+// have a Content-Encoding, such as precompressed static files, are left alone.
+func Compress(cfg CompressConfig) HandlerFunc {
+	if len(cfg.Encodings) == 0 {
+		cfg.Encodings = defaultCompressEncodings
+	}
+	if cfg.GzipLevel == 0 {
+		cfg.GzipLevel = gcompress.NewGzipUtil().CompressionLevel
+	}
+	if cfg.BrotliLevel == 0 {
+		cfg.BrotliLevel = 5
+	}
+	if cfg.ZstdLevel == 0 {
+		cfg.ZstdLevel = 3
+	}
+	if cfg.MinSize == 0 {
+		cfg.MinSize = defaultCompressMinSize
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = defaultCompressContentTypes
+	}
+	// EncodeAll is safe for concurrent use, so buffered zstd bodies share one encoder.
+	zstdEncoder, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(cfg.ZstdLevel)))
+
+	return func(c *Context) {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+
+		encoding := NegotiateEncoding(c.GetHeader("Accept-Encoding"), cfg.Encodings)
+		if encoding != "" {
+			c.streamFilters = append(c.streamFilters, func(c *Context, sw fasthttp.StreamWriter) fasthttp.StreamWriter {
+				if !cfg.compressible(c) {
+					return sw
+				}
+				setContentEncoding(&c.fastCtx.Response, encoding)
+				return compressStreamWriter(sw, func(w io.Writer) compressWriter {
+					return cfg.newWriter(encoding, w)
+				})
+			})
+		}
+
+		c.Next()
+
+		resp := &c.fastCtx.Response
+		addVary(&resp.Header, "Accept-Encoding")
+		if encoding == "" || resp.IsBodyStream() || !cfg.compressible(c) {
+			return
+		}
+		body := resp.Body()
+		if len(body) < cfg.MinSize {
+			return
+		}
+
+		var out []byte
+		var err error
+		switch encoding {
+		case EncodingGzip:
+			out, err = (&gcompress.GzipUtil{CompressionLevel: cfg.GzipLevel}).Compress(body)
+		case EncodingZstd:
+			out = zstdEncoder.EncodeAll(body, make([]byte, 0, len(body)/2))
+		default:
+			var buf bytes.Buffer
+			w := cfg.newWriter(encoding, &buf)
+			if _, err = w.Write(body); err == nil {
+				err = w.Close()
+			}
+			out = buf.Bytes()
+		}
+		if err != nil || len(out) >= len(body) {
+			return
+		}
+		resp.SetBodyRaw(out)
+		setContentEncoding(resp, encoding)
+	}
+}
+
+func (cfg *CompressConfig) compressible(c *Context) bool {
+	if c.fastCtx.IsHead() {
+		return false
+	}
+	resp := &c.fastCtx.Response
+	switch status := resp.StatusCode(); {
+	case status < 200, status == 204, status == 206, status == 304:
+		return false
+	}
+	if len(resp.Header.ContentEncoding()) > 0 {
+		return false
+	}
+	if strings.Contains(strings.ToLower(string(resp.Header.Peek("Cache-Control"))), "no-transform") {
+		return false
+	}
+
+	contentType := string(resp.Header.ContentType())
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, pattern := range cfg.ContentTypes {
+		if ok, _ := path.Match(pattern, contentType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func (cfg *CompressConfig) newWriter(encoding string, w io.Writer) compressWriter {
+	switch encoding {
+	case EncodingBrotli:
+		return brotli.NewWriterLevel(w, cfg.BrotliLevel)
+	case EncodingZstd:
+		enc, _ := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(cfg.ZstdLevel)), zstd.WithEncoderConcurrency(1))
+		return enc
+	case EncodingDeflate:
+		// HTTP "deflate" is the zlib format (RFC 9110 section 8.4.1.2).
+		zw, err := zlib.NewWriterLevel(w, cfg.GzipLevel)
+		if err != nil {
+			zw = zlib.NewWriter(w)
+		}
+		return zw
+	default:
+		gw, err := gzip.NewWriterLevel(w, cfg.GzipLevel)
+		if err != nil {
+			gw = gzip.NewWriter(w)
+		}
+		return gw
+	}
+}
+
+// compressStreamWriter wraps sw so that every flush of its writer emits a
+// decodable compressed chunk.
+func compressStreamWriter(sw fasthttp.StreamWriter, newWriter func(io.Writer) compressWriter) fasthttp.StreamWriter {
+	return func(bw *bufio.Writer) {
+		enc := newWriter(bw)
+		inner := bufio.NewWriter(&flushWriter{enc: enc, w: bw})
+		sw(inner)
+		_ = inner.Flush()
+		_ = enc.Close()
+		_ = bw.Flush()
+	}
+}
+
+type flushWriter struct {
+	enc compressWriter
+	w   *bufio.Writer
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.enc.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if err := f.enc.Flush(); err != nil {
+		return n, err
+	}
+	return n, f.w.Flush()
+}
+
+func setContentEncoding(resp *fasthttp.Response, encoding string) {
+	resp.Header.SetContentEncoding(encoding)
+	resp.Header.Del("Content-Length")
+	// The encoded representation differs byte for byte, so a strong validator must not be reused.
+	if etag := string(resp.Header.Peek("ETag")); etag != "" && !strings.HasPrefix(etag, "W/") {
+		resp.Header.Set("ETag", "W/"+etag)
+	}
+}
+
+// addVary adds field to the Vary header unless it is already listed.
+func addVary(h *fasthttp.ResponseHeader, field string) {
+	for _, v := range h.PeekAll("Vary") {
+		for _, f := range strings.Split(string(v), ",") {
+			f = strings.TrimSpace(f)
+			if f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
+
+// NegotiateEncoding picks the content coding from an Accept-Encoding header. Codings
+// with the highest q-value win and ties follow the order of supported. It returns
+// "" when identity should be used.
+func NegotiateEncoding(acceptEncoding string, supported []string) string {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return ""
+	}
+	weights := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, q := parseQuality(part)
+		switch name {
+		case "":
+			continue
+		case "*":
+			wildcard = q
+		case "x-gzip":
+			weights[EncodingGzip] = q
+		default:
+			weights[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range supported {
+		q, ok := weights[enc]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// parseQuality splits a list member such as "gzip;q=0.8" into its lowercase token
+// and weight. A missing or malformed q defaults to 1.
+func parseQuality(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	name := strings.ToLower(strings.TrimSpace(params[0]))
+	q := 1.0
+	for _, p := range params[1:] {
+		k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(k), "q") {
+			continue
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && f >= 0 && f <= 1 {
+			q = f
+		}
+	}
+	return name, q
+}
@@ -0,0 +1,163 @@
+package gserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate}
+	cases := map[string]string{
+		"":                          "",
+		"gzip":                      EncodingGzip,
+		"gzip, br":                  EncodingBrotli,
+		"gzip;q=1, br;q=0.5":        EncodingGzip,
+		"br;q=0, gzip;q=0.1":        EncodingGzip,
+		"*":                         EncodingBrotli,
+		"*;q=0.5, br;q=0, zstd;q=0": EncodingGzip,
+		"identity":                  "",
+		"x-gzip":                    EncodingGzip,
+		"gzip;q=0":                  "",
+	}
+	for header, want := range cases {
+		if got := NegotiateEncoding(header, supported); got != want {
+			t.Errorf("NegotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompressBufferedBody(t *testing.T) {
+	large := strings.Repeat("compress me please ", 200)
+	server := NewServer()
+	server.Use(Compress(CompressConfig{}))
+	server.GET("/large", func(c *Context) { c.String(http.StatusOK, "%s", large) })
+	server.GET("/small", func(c *Context) { c.String(http.StatusOK, "tiny") })
+	server.GET("/image", func(c *Context) { c.Data(http.StatusOK, "image/png", []byte(large)) })
+
+	do := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		EncodingGzip:   func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		EncodingBrotli: func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		EncodingZstd:   func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+	for enc, newReader := range decoders {
+		rec := do("/large", enc)
+		if rec.Header().Get("Content-Encoding") != enc || rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("%s: unexpected headers %v", enc, rec.Header())
+		}
+		r, err := newReader(rec.Body)
+		if err != nil {
+			t.Fatalf("%s: %v", enc, err)
+		}
+		if got, _ := io.ReadAll(r); string(got) != large {
+			t.Fatalf("%s: body mismatch", enc)
+		}
+	}
+
+	if rec := do("/small", "gzip"); rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "tiny" {
+		t.Fatalf("small body must not be compressed: %v", rec.Header())
+	}
+	if rec := do("/image", "gzip"); rec.Header().Get("Content-Encoding") != "" {
+		t.Fatal("content types outside the allowlist must not be compressed")
+	}
+	if rec := do("/large", "identity"); rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != large {
+		t.Fatal("identity must not be compressed")
+	}
+}
+
+func TestCompressStreamResult(t *testing.T) {
+	server := NewServer()
+	server.Use(Compress(CompressConfig{}))
+	server.GET("/stream", Wrap(func(c *Context) Result {
+		return StreamWithContentType(strings.NewReader("streamed body"), MIMEPlain)
+	}))
+	server.GET("/events", Wrap(func(c *Context) Result {
+		return SSE(func(w *SSEWriter) error {
+			return w.Send(SSEEvent{Data: "hello"})
+		}).WithHeartbeat(0)
+	}))
+
+	get := func(path string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Header().Get("Content-Encoding") != EncodingGzip {
+			t.Fatalf("%s: expected gzip stream, got %v", path, rec.Header())
+		}
+		r, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(r)
+		return string(got)
+	}
+
+	if got := get("/stream"); got != "streamed body" {
+		t.Fatalf("unexpected body %q", got)
+	}
+	if got := get("/events"); !strings.Contains(got, "data: hello\n\n") {
+		t.Fatalf("unexpected events %q", got)
+	}
+}
+
+func TestStaticPrecompressedSibling(t *testing.T) {
+	dir := t.TempDir()
+	js := []byte("console.log('hello')")
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write(js)
+	_ = zw.Close()
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), js, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.js.gz"), gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(WithPrecompressedStatic())
+	server.Use(Compress(CompressConfig{MinSize: 1}))
+	server.Static("/assets", dir)
+
+	do := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/assets/app.js", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// br is preferred but only the .gz sibling exists.
+	rec := do("br, gzip")
+	if rec.Header().Get("Content-Encoding") != EncodingGzip || !bytes.Equal(rec.Body.Bytes(), gz.Bytes()) {
+		t.Fatalf("expected the .gz sibling, got %v", rec.Header())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.Contains(ct, "javascript") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if vary := rec.Header().Values("Vary"); len(vary) != 1 {
+		t.Fatalf("expected a single Vary header, got %v", vary)
+	}
+
+	rec = do("")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != string(js) {
+		t.Fatalf("expected the original file, got %v", rec.Header())
+	}
+}
@@ -1,6 +1,9 @@
 package gserver
 
-import "time"
+import (
+	"net"
+	"time"
+)
 
 type ServerOption func(config *Config)
 
@@ -11,6 +14,19 @@ type Config struct {
 	UseRawPath bool
 	render     Render
 
+	precompressed []string
+
+	trustedProxies  []*net.IPNet
+	remoteIPHeaders []string
+	proxyProtocol   *proxyProtocol
+
+	handleMethodNotAllowed bool
+	handleOptions          bool
+	redirectTrailingSlash  bool
+	redirectFixedPath      bool
+	noRoute                []HandlerFunc
+	noMethod               []HandlerFunc
+
 	// The fields below are all fasthttp, so we will expose them
 	Concurrency                   int
 	ReadBufferSize                int
@@ -74,3 +90,62 @@ func WithRender(r Render) ServerOption {
 		}
 	}
 }
+
+// WithPrecompressedStatic makes Static and StaticFS serve a precompressed sibling
+// such as "app.js.br" or "app.js.gz" instead of "app.js" when the client accepts
+// its coding. Encodings default to br and gzip; zstd siblings use ".zst".
+func WithPrecompressedStatic(encodings ...string) ServerOption {
+	return func(c *Config) {
+		if len(encodings) == 0 {
+			encodings = []string{EncodingBrotli, EncodingGzip}
+		}
+		c.precompressed = encodings
+	}
+}
+
+// WithMethodNotAllowed controls whether a path registered only for other methods
+// answers 405 Method Not Allowed with an Allow header instead of 404. Enabled by default.
+func WithMethodNotAllowed(enabled bool) ServerOption {
+	return func(c *Config) {
+		c.handleMethodNotAllowed = enabled
+	}
+}
+
+// WithAutoOptions controls whether OPTIONS requests without an explicit route are
+// answered with 204 and an Allow header. Enabled by default.
+func WithAutoOptions(enabled bool) ServerOption {
+	return func(c *Config) {
+		c.handleOptions = enabled
+	}
+}
+
+// WithRedirectTrailingSlash redirects "/users/" to "/users" (and the reverse) when
+// only the other form is registered. When disabled the other form is served directly.
+func WithRedirectTrailingSlash(enabled bool) ServerOption {
+	return func(c *Config) {
+		c.redirectTrailingSlash = enabled
+	}
+}
+
+// WithRedirectFixedPath redirects unclean paths such as "//Users/./1" to their
+// cleaned form and retries the lookup case-insensitively, e.g. "/USERS/1" to "/users/1".
+func WithRedirectFixedPath(enabled bool) ServerOption {
+	return func(c *Config) {
+		c.redirectFixedPath = enabled
+	}
+}
+
+// WithNoRoute sets the handlers run, after the global middleware, when no route matches.
+func WithNoRoute(handlers ...HandlerFunc) ServerOption {
+	return func(c *Config) {
+		c.noRoute = handlers
+	}
+}
+
+// WithNoMethod sets the handlers run, after the global middleware, when the path
+// matches other methods only. The Allow header is already set.
+func WithNoMethod(handlers ...HandlerFunc) ServerOption {
+	return func(c *Config) {
+		c.noMethod = handlers
+	}
+}
@@ -14,4 +14,5 @@ const (
 	MIMEYAML              = "application/x-yaml"
 	MIMEYAML2             = "application/yaml"
 	MIMETOML              = "application/toml"
+	MIMEOctetStream       = "application/octet-stream"
 )
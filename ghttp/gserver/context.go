@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"net/url"
 	"strings"
 	"sync"
@@ -43,6 +44,11 @@ type Context struct {
 	codec *CodecFactory
 
 	render Render
+
+	engine *Server
+
+	// streamFilters wrap streamed response bodies, see Compress.
+	streamFilters []func(*Context, fasthttp.StreamWriter) fasthttp.StreamWriter
 }
 
 func (c *Context) Reset() {
@@ -56,7 +62,9 @@ func (c *Context) Reset() {
 	c.logger = nil
 	c.codec = nil
 	c.render = nil
+	c.engine = nil
 	c.fullPath = ""
+	c.streamFilters = c.streamFilters[:0]
 
 	// Optimize pathParams reset for better performance
 	// Instead of recreating the map, clear it to reduce allocations
@@ -365,20 +373,11 @@ func (c *Context) ShouldBindXML(obj interface{}) error {
 	return c.BindXML(obj)
 }
 
-// RespAuto automatically encodes and writes data based on the Accept header
+// RespAuto encodes data with the codec negotiated from the Accept header among
+// the CodecFactory types, or the route's Produces types, and answers 406 when
+// none is acceptable.
 func (c *Context) RespAuto(data interface{}) {
-	accept := c.requestHeader("Accept")
-	if accept == "" {
-		accept = "application/json"
-	}
-	bytes, err := c.codec.Get(accept).EncodeBytes(data)
-	if err != nil {
-		panic(err)
-	}
-	_, err = c.Writer.Write(bytes)
-	if err != nil {
-		panic(err)
-	}
+	c.writeNegotiated(http.StatusOK, data)
 }
 
 // JSON serializes the given struct as JSON into the response body
@@ -450,9 +449,22 @@ func (c *Context) Response() *fasthttp.Response {
 	return &c.fastCtx.Response
 }
 
-// ClientIP returns the real client IP
-func (c *Context) ClientIP() string {
-	return c.fastCtx.RemoteIP().String()
+// setBodyStream streams r as the response body. The reader is closed afterwards
+// when it implements io.Closer.
+func (c *Context) setBodyStream(r io.Reader) {
+	if len(c.streamFilters) == 0 {
+		c.fastCtx.SetBodyStream(r, -1)
+		return
+	}
+	c.setBodyStreamWriter(flushingStreamWriter(r))
+}
+
+// setBodyStreamWriter streams the response body through the registered stream filters.
+func (c *Context) setBodyStreamWriter(sw fasthttp.StreamWriter) {
+	for i := len(c.streamFilters) - 1; i >= 0; i-- {
+		sw = c.streamFilters[i](c, sw)
+	}
+	c.fastCtx.SetBodyStreamWriter(sw)
 }
 
 // ContentType returns the Content-Type header of the request
@@ -0,0 +1,120 @@
+package gservertest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/sofiworker/gk/ghttp/gserver"
+)
+
+func newTestServer(t *testing.T) *Server {
+	server := gserver.NewServer()
+	server.GET("/users/:id", func(c *gserver.Context) {
+		c.Header("X-User", c.Param("id"))
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"id":    c.Param("id"),
+			"roles": []string{"admin", "dev"},
+			"page":  c.QueryDefault("page", "1"),
+		})
+	})
+	server.POST("/echo", func(c *gserver.Context) {
+		c.Data(http.StatusCreated, c.ContentType(), c.BodyBytes())
+	})
+	server.GET("/ws", gserver.WebSocket(func(conn *gserver.WebSocketConn) {
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = conn.WriteMessage(mt, msg)
+		}
+	}))
+	return New(t, server)
+}
+
+func TestRequestAssertions(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.GET("/users/7").Query("page", "2").Expect().
+		Status(http.StatusOK).
+		Header("X-User", "7").
+		HeaderContains("Content-Type", "json").
+		JSONPath("id", "7").
+		JSONPath("roles[1]", "dev").
+		JSONPath("roles.0", "admin").
+		JSON(map[string]interface{}{"id": "7", "roles": []string{"admin", "dev"}, "page": "2"})
+
+	ts.POST("/echo").JSON(map[string]int{"n": 1}).Expect().
+		Status(http.StatusCreated).
+		JSONPath("n", 1)
+
+	ts.GET("/missing").Expect().Status(http.StatusNotFound)
+}
+
+// recordingTB captures assertion failures instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestFailedAssertionsAreReported(t *testing.T) {
+	ts := newTestServer(t)
+	rec := &recordingTB{TB: t}
+	resp := ts.GET("/users/7").Expect()
+	resp.tb = rec
+
+	resp.Status(http.StatusTeapot).
+		Header("X-User", "8").
+		JSONPath("roles[5]", "x").
+		JSONPath("id", 7).
+		Body("nope")
+	if len(rec.errors) != 5 {
+		t.Fatalf("expected 5 failures, got %d: %q", len(rec.errors), rec.errors)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ts := newTestServer(t)
+	ts.GET("/users/42").Expect().Status(http.StatusOK).Snapshot("user")
+}
+
+func TestGClientEndToEnd(t *testing.T) {
+	ts := newTestServer(t)
+	resp, err := ts.Client().R().SetPathParam("id", "3").Get("/users/{id}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var user struct {
+		ID    string   `json:"id"`
+		Roles []string `json:"roles"`
+	}
+	if err := resp.Into(&user); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || user.ID != "3" || len(user.Roles) != 2 {
+		t.Fatalf("unexpected response %d %+v", resp.StatusCode, user)
+	}
+}
+
+func TestWebSocketDialer(t *testing.T) {
+	ts := newTestServer(t)
+	conn, _, err := ts.WebSocketDialer().Dial("ws://gservertest.local/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "ping" {
+		t.Fatalf("unexpected echo %q %v", msg, err)
+	}
+}
@@ -0,0 +1,123 @@
+package gservertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Request builds a request against a test Server. Builder methods record the
+// first error and Expect reports it.
+type Request struct {
+	ts     *Server
+	tb     testing.TB
+	method string
+	path   string
+	header http.Header
+	query  url.Values
+	body   []byte
+	err    error
+}
+
+func newRequest(ts *Server, method, path string) *Request {
+	return &Request{
+		ts:     ts,
+		tb:     ts.tb,
+		method: method,
+		path:   path,
+		header: make(http.Header),
+		query:  make(url.Values),
+	}
+}
+
+func (r *Request) Header(key, value string) *Request {
+	r.header.Add(key, value)
+	return r
+}
+
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+func (r *Request) Cookie(cookie *http.Cookie) *Request {
+	r.header.Add("Cookie", cookie.String())
+	return r
+}
+
+func (r *Request) BearerToken(token string) *Request {
+	r.header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+// Body sets a raw body with its content type.
+func (r *Request) Body(contentType string, body []byte) *Request {
+	r.header.Set("Content-Type", contentType)
+	r.body = body
+	return r
+}
+
+// JSON encodes v as the request body.
+func (r *Request) JSON(v interface{}) *Request {
+	body, err := json.Marshal(v)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.Body("application/json", body)
+}
+
+// Form sets an application/x-www-form-urlencoded body.
+func (r *Request) Form(values url.Values) *Request {
+	return r.Body("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+// Do sends the request and returns the raw response. The body is fully read.
+func (r *Request) Do() (*http.Response, []byte, error) {
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	target := r.ts.URL + r.path
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(r.path, "?") {
+			sep = "&"
+		}
+		target += sep + r.query.Encode()
+	}
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequest(r.method, target, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	if host := r.header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	resp, err := r.ts.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp, data, err
+}
+
+// Expect sends the request and returns the response for assertions. Transport
+// errors fail the test immediately.
+func (r *Request) Expect() *Response {
+	r.tb.Helper()
+	resp, body, err := r.Do()
+	if err != nil {
+		r.tb.Fatalf("%s %s: %v", r.method, r.path, err)
+	}
+	return &Response{tb: r.tb, name: r.method + " " + r.path, Raw: resp, BodyBytes: body}
+}
@@ -0,0 +1,255 @@
+package gservertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// UpdateSnapshotsEnv names the environment variable that rewrites snapshot files
+// instead of comparing against them, e.g. GSERVERTEST_UPDATE=1 go test ./...
+const UpdateSnapshotsEnv = "GSERVERTEST_UPDATE"
+
+// Response is a received response with chainable assertions. Failed assertions
+// are reported with Errorf, so a chain reports every mismatch.
+type Response struct {
+	Raw       *http.Response
+	BodyBytes []byte
+
+	tb   testing.TB
+	name string
+}
+
+func (r *Response) Status(code int) *Response {
+	r.tb.Helper()
+	if r.Raw.StatusCode != code {
+		r.tb.Errorf("%s: status = %d, want %d; body: %s", r.name, r.Raw.StatusCode, code, truncate(r.BodyBytes))
+	}
+	return r
+}
+
+// Header asserts the first value of a response header.
+func (r *Response) Header(key, want string) *Response {
+	r.tb.Helper()
+	if got := r.Raw.Header.Get(key); got != want {
+		r.tb.Errorf("%s: header %s = %q, want %q", r.name, key, got, want)
+	}
+	return r
+}
+
+func (r *Response) HeaderContains(key, substr string) *Response {
+	r.tb.Helper()
+	if got := strings.Join(r.Raw.Header.Values(key), ", "); !strings.Contains(got, substr) {
+		r.tb.Errorf("%s: header %s = %q, want it to contain %q", r.name, key, got, substr)
+	}
+	return r
+}
+
+func (r *Response) NoHeader(key string) *Response {
+	r.tb.Helper()
+	if got := r.Raw.Header.Values(key); len(got) > 0 {
+		r.tb.Errorf("%s: unexpected header %s = %q", r.name, key, got)
+	}
+	return r
+}
+
+func (r *Response) Body(want string) *Response {
+	r.tb.Helper()
+	if got := string(r.BodyBytes); got != want {
+		r.tb.Errorf("%s: body = %q, want %q", r.name, got, want)
+	}
+	return r
+}
+
+func (r *Response) BodyContains(substr string) *Response {
+	r.tb.Helper()
+	if !bytes.Contains(r.BodyBytes, []byte(substr)) {
+		r.tb.Errorf("%s: body %s does not contain %q", r.name, truncate(r.BodyBytes), substr)
+	}
+	return r
+}
+
+// JSON asserts the body is JSON equal to want. want is compared after a JSON
+// round trip, so structs, maps and raw json.RawMessage values all work and key
+// order does not matter.
+func (r *Response) JSON(want interface{}) *Response {
+	r.tb.Helper()
+	got, ok := r.decodeJSON()
+	if !ok {
+		return r
+	}
+	if exp, err := normalizeJSON(want); err != nil {
+		r.tb.Errorf("%s: encode expected value: %v", r.name, err)
+	} else if !reflect.DeepEqual(got, exp) {
+		r.tb.Errorf("%s: JSON body = %s, want %s", r.name, mustJSON(got), mustJSON(exp))
+	}
+	return r
+}
+
+// JSONPath asserts the value at path, such as "data.items[0].name" or
+// "data.items.0.name", is JSON equal to want.
+func (r *Response) JSONPath(path string, want interface{}) *Response {
+	r.tb.Helper()
+	doc, ok := r.decodeJSON()
+	if !ok {
+		return r
+	}
+	got, err := lookupJSONPath(doc, path)
+	if err != nil {
+		r.tb.Errorf("%s: JSON path %q: %v in %s", r.name, path, err, truncate(r.BodyBytes))
+		return r
+	}
+	if exp, err := normalizeJSON(want); err != nil {
+		r.tb.Errorf("%s: encode expected value: %v", r.name, err)
+	} else if !reflect.DeepEqual(got, exp) {
+		r.tb.Errorf("%s: JSON path %q = %s, want %s", r.name, path, mustJSON(got), mustJSON(exp))
+	}
+	return r
+}
+
+// Decode unmarshals the JSON body into v.
+func (r *Response) Decode(v interface{}) *Response {
+	r.tb.Helper()
+	if err := json.Unmarshal(r.BodyBytes, v); err != nil {
+		r.tb.Errorf("%s: decode body: %v", r.name, err)
+	}
+	return r
+}
+
+// Snapshot compares the body with testdata/snapshots/<test name>/<name>.snap.
+// JSON bodies are indented before comparing so snapshots diff well. A missing
+// snapshot is written and the assertion passes; set GSERVERTEST_UPDATE=1 to
+// rewrite existing ones.
+func (r *Response) Snapshot(name string) *Response {
+	r.tb.Helper()
+	body := r.BodyBytes
+	var indented bytes.Buffer
+	if json.Indent(&indented, body, "", "  ") == nil {
+		body = append(indented.Bytes(), '\n')
+	}
+
+	file := filepath.Join("testdata", "snapshots", sanitizeName(r.tb.Name()), sanitizeName(name)+".snap")
+	want, err := os.ReadFile(file)
+	if os.IsNotExist(err) || os.Getenv(UpdateSnapshotsEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			r.tb.Errorf("%s: create snapshot dir: %v", r.name, err)
+			return r
+		}
+		if err := os.WriteFile(file, body, 0o644); err != nil {
+			r.tb.Errorf("%s: write snapshot: %v", r.name, err)
+			return r
+		}
+		r.tb.Logf("%s: wrote snapshot %s", r.name, file)
+		return r
+	}
+	if err != nil {
+		r.tb.Errorf("%s: read snapshot: %v", r.name, err)
+		return r
+	}
+	if !bytes.Equal(body, want) {
+		r.tb.Errorf("%s: body does not match snapshot %s (set %s=1 to update)\ngot:\n%s\nwant:\n%s",
+			r.name, file, UpdateSnapshotsEnv, body, want)
+	}
+	return r
+}
+
+func (r *Response) decodeJSON() (interface{}, bool) {
+	r.tb.Helper()
+	var doc interface{}
+	if err := json.Unmarshal(r.BodyBytes, &doc); err != nil {
+		r.tb.Errorf("%s: body is not JSON: %v; body: %s", r.name, err, truncate(r.BodyBytes))
+		return nil, false
+	}
+	return doc, true
+}
+
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+var indexSuffix = regexp.MustCompile(`^(.*)\[(\d+)\]$`)
+
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return doc, nil
+	}
+	var steps []string
+	for _, part := range strings.Split(path, ".") {
+		// Expand "items[0][1]" into "items", "0", "1".
+		var indexes []string
+		for {
+			m := indexSuffix.FindStringSubmatch(part)
+			if m == nil {
+				break
+			}
+			indexes = append([]string{m[2]}, indexes...)
+			part = m[1]
+		}
+		if part != "" {
+			steps = append(steps, part)
+		}
+		steps = append(steps, indexes...)
+	}
+
+	cur := doc
+	for i, step := range steps {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			v, ok := node[step]
+			if !ok {
+				return nil, fmt.Errorf("key %q not found at %s", step, strings.Join(steps[:i], "."))
+			}
+			cur = v
+		case []interface{}:
+			idx, err := strconv.Atoi(step)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, fmt.Errorf("index %q out of range at %s", step, strings.Join(steps[:i], "."))
+			}
+			cur = node[idx]
+		default:
+			return nil, fmt.Errorf("cannot descend into %T at %s", cur, strings.Join(steps[:i], "."))
+		}
+	}
+	return cur, nil
+}
+
+func mustJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func truncate(body []byte) string {
+	const max = 512
+	if len(body) > max {
+		return string(body[:max]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,126 @@
+// Package gservertest runs a gserver.Server over an in-memory listener for tests.
+//
+//	ts := gservertest.New(t, server)
+//	ts.GET("/users/1").Header("Accept", "application/json").Expect().
+//		Status(http.StatusOK).
+//		JSONPath("name", "alice")
+//
+// No sockets are opened, so tests can run in parallel without port allocation.
+package gservertest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sofiworker/gk/ghttp/gclient"
+	"github.com/sofiworker/gk/ghttp/gserver"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// URL is the base URL of every test server. Requests to any host are routed to
+// the in-memory listener, so the host only shows up in the Host header.
+const URL = "http://gservertest.local"
+
+// Server is a gserver.Server listening in memory.
+type Server struct {
+	Server *gserver.Server
+	URL    string
+
+	tb        testing.TB
+	ln        *fasthttputil.InmemoryListener
+	client    *http.Client
+	closeOnce sync.Once
+}
+
+// New starts server on an in-memory listener and stops it when the test ends.
+func New(tb testing.TB, server *gserver.Server) *Server {
+	tb.Helper()
+	ts := &Server{
+		Server: server,
+		URL:    URL,
+		tb:     tb,
+		ln:     fasthttputil.NewInmemoryListener(),
+	}
+	ts.client = &http.Client{
+		Transport: ts.Transport(),
+		// Assertions usually target the redirect itself.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	go func() {
+		_ = server.RunListener(ts.ln)
+	}()
+	tb.Cleanup(ts.Close)
+	return ts
+}
+
+// Dial opens a raw connection to the server.
+func (ts *Server) Dial() (net.Conn, error) {
+	return ts.ln.Dial()
+}
+
+// Transport returns a transport whose connections all go to the server.
+func (ts *Server) Transport() *http.Transport {
+	return &http.Transport{
+		DialContext: func(context.Context, string, string) (net.Conn, error) {
+			return ts.ln.Dial()
+		},
+		DialTLSContext: func(context.Context, string, string) (net.Conn, error) {
+			return ts.ln.Dial()
+		},
+		DisableCompression: true,
+	}
+}
+
+// HTTPClient returns a net/http client wired to the server. It does not follow redirects.
+func (ts *Server) HTTPClient() *http.Client {
+	return ts.client
+}
+
+// Client returns a gclient.Client wired to the server with URL as its base URL.
+// Options are applied after the transport and base URL, so they may override them.
+func (ts *Server) Client(opts ...gclient.ClientOption) *gclient.Client {
+	base := []gclient.ClientOption{
+		gclient.WithHTTPClient(&http.Client{Transport: ts.Transport(), Timeout: gclient.DefaultTimeout}),
+		gclient.WithBaseURL(ts.URL),
+	}
+	return gclient.NewClient(append(base, opts...)...)
+}
+
+// WebSocketDialer returns a dialer that connects to the server. Dial it with a
+// ws:// URL built from any host, for example "ws://gservertest.local/ws".
+func (ts *Server) WebSocketDialer() *websocket.Dialer {
+	return &websocket.Dialer{
+		NetDialContext: func(context.Context, string, string) (net.Conn, error) {
+			return ts.ln.Dial()
+		},
+		HandshakeTimeout: 5 * time.Second,
+	}
+}
+
+// Close shuts the server down. It is called automatically when the test ends.
+func (ts *Server) Close() {
+	ts.closeOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = ts.Server.ShutdownWithContext(ctx)
+		_ = ts.ln.Close()
+	})
+}
+
+// Request starts a request to path, which may include a query string.
+func (ts *Server) Request(method, path string) *Request {
+	return newRequest(ts, method, path)
+}
+
+func (ts *Server) GET(path string) *Request     { return ts.Request(http.MethodGet, path) }
+func (ts *Server) HEAD(path string) *Request    { return ts.Request(http.MethodHead, path) }
+func (ts *Server) POST(path string) *Request    { return ts.Request(http.MethodPost, path) }
+func (ts *Server) PUT(path string) *Request     { return ts.Request(http.MethodPut, path) }
+func (ts *Server) PATCH(path string) *Request   { return ts.Request(http.MethodPatch, path) }
+func (ts *Server) DELETE(path string) *Request  { return ts.Request(http.MethodDelete, path) }
+func (ts *Server) OPTIONS(path string) *Request { return ts.Request(http.MethodOptions, path) }
@@ -0,0 +1,253 @@
+package gserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sofiworker/gk/gcache"
+	"github.com/sofiworker/gk/gerr"
+)
+
+// Errors rendered by Idempotency, following the IETF Idempotency-Key draft.
+var (
+	ErrIdempotencyKeyMissing  = gerr.New(http.StatusBadRequest, "idempotency_key_missing", "the Idempotency-Key header is required")
+	ErrIdempotencyKeyInvalid  = gerr.New(http.StatusBadRequest, "idempotency_key_invalid", "the Idempotency-Key header is too long")
+	ErrIdempotencyKeyReused   = gerr.New(http.StatusUnprocessableEntity, "idempotency_key_reused", "the Idempotency-Key was used for a different request")
+	ErrIdempotencyKeyInFlight = gerr.New(http.StatusConflict, "idempotency_key_in_flight", "a request with this Idempotency-Key is still being processed")
+)
+
+// maxIdempotencyKeyLen bounds keys so they cannot blow up store keys.
+const maxIdempotencyKeyLen = 255
+
+// Response headers that are regenerated for every reply and never replayed.
+var unreplayedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Date":              true,
+	"Server":            true,
+	"Connection":        true,
+	"Transfer-Encoding": true,
+}
+
+// IdempotencyConfig configures Idempotency.
+type IdempotencyConfig struct {
+	// Store keeps locks and recorded responses, e.g. *gcache.MemoryCache,
+	// *gcache.RedisCache or *gcache.ValkeyCache. Required.
+	Store gcache.CacheWithContext
+	// Header defaults to "Idempotency-Key".
+	Header string
+	// Methods default to POST and PATCH.
+	Methods []string
+	// Required answers 400 to requests of Methods without the header.
+	Required bool
+	// TTL is how long a recorded response is replayed. Default 24h.
+	TTL time.Duration
+	// LockTimeout frees the key of a first request that never completed, e.g.
+	// because the process died. Default 1m.
+	LockTimeout time.Duration
+	// KeyPrefix defaults to "idempotency:".
+	KeyPrefix string
+	// Scope namespaces keys, for example by authenticated user, so that clients
+	// cannot replay each other's responses.
+	Scope func(c *Context) string
+	// Fingerprint identifies the request a key was first used with. The default
+	// is a SHA-256 of the method, the request URI and the body.
+	Fingerprint func(c *Context) string
+	// FailOpen runs the handler when the store fails instead of answering 503.
+	FailOpen bool
+}
+
+// idempotencyRecord is a completed response as kept in the store.
+type idempotencyRecord struct {
+	Fingerprint string              `json:"fingerprint"`
+	Status      int                 `json:"status"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+}
+
+// Idempotency gives unsafe requests carrying an Idempotency-Key exactly-once
+// semantics. The first request locks the key and runs the handler; its complete
+// response is recorded and replayed, with an Idempotent-Replayed header, to later
+// requests with the same key and fingerprint. Reusing a key for a different
+// request answers 422 and a key whose first request is still running 409.
+// Server errors (5xx) and streamed responses are not recorded, so such requests
+// can be retried.
+func Idempotency(cfg IdempotencyConfig) HandlerFunc {
+	if cfg.Store == nil {
+		panic("gserver: IdempotencyConfig.Store is required")
+	}
+	if cfg.Header == "" {
+		cfg.Header = "Idempotency-Key"
+	}
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = time.Minute
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "idempotency:"
+	}
+	if cfg.Fingerprint == nil {
+		cfg.Fingerprint = defaultIdempotencyFingerprint
+	}
+
+	return func(c *Context) {
+		if !containsString(cfg.Methods, string(c.fastCtx.Method())) {
+			c.Next()
+			return
+		}
+		key := c.GetHeader(cfg.Header)
+		switch {
+		case key == "" && cfg.Required:
+			c.AbortWithError(ErrIdempotencyKeyMissing)
+			return
+		case key == "":
+			c.Next()
+			return
+		case len(key) > maxIdempotencyKeyLen:
+			c.AbortWithError(ErrIdempotencyKeyInvalid)
+			return
+		}
+
+		recordKey := cfg.KeyPrefix
+		if cfg.Scope != nil {
+			recordKey += cfg.Scope(c) + ":"
+		}
+		recordKey += key
+		lockKey := recordKey + ":lock"
+		fingerprint := cfg.Fingerprint(c)
+		// The lock must be released and the record written even when the server shuts down.
+		ctx := context.WithoutCancel(c.Context())
+
+		storeFailed := func(err error) {
+			if logger := c.Logger(); logger != nil {
+				logger.Warnf("idempotency store: %v", err)
+			}
+			if cfg.FailOpen {
+				c.Next()
+				return
+			}
+			c.AbortWithError(gerr.Wrap(err, http.StatusServiceUnavailable, "", http.StatusText(http.StatusServiceUnavailable)))
+		}
+
+		if done, err := replayIdempotent(ctx, c, cfg.Store, recordKey, fingerprint); done || err != nil {
+			if err != nil {
+				storeFailed(err)
+			}
+			return
+		}
+
+		n, err := cfg.Store.IncrementWithContext(ctx, lockKey, 1)
+		if err != nil {
+			storeFailed(err)
+			return
+		}
+		if n != 1 {
+			// Repair a lock left without expiry by a crash between the two calls.
+			if ttl, err := cfg.Store.TTLWithContext(ctx, lockKey); err == nil && ttl < 0 {
+				_ = cfg.Store.ExpireWithContext(ctx, lockKey, cfg.LockTimeout)
+			}
+			c.Header("Retry-After", "1")
+			c.AbortWithError(ErrIdempotencyKeyInFlight)
+			return
+		}
+		defer func() {
+			if err := cfg.Store.DeleteWithContext(ctx, lockKey); err != nil {
+				if logger := c.Logger(); logger != nil {
+					logger.Warnf("idempotency unlock: %v", err)
+				}
+			}
+		}()
+		if err := cfg.Store.ExpireWithContext(ctx, lockKey, cfg.LockTimeout); err != nil {
+			storeFailed(err)
+			return
+		}
+
+		// The first request may have completed between the lookup and the lock.
+		if done, err := replayIdempotent(ctx, c, cfg.Store, recordKey, fingerprint); done || err != nil {
+			if err != nil {
+				storeFailed(err)
+			}
+			return
+		}
+
+		c.Next()
+
+		resp := &c.fastCtx.Response
+		status := c.StatusCode()
+		if status >= http.StatusInternalServerError || resp.IsBodyStream() {
+			return
+		}
+		rec := idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      make(map[string][]string),
+			Body:        append([]byte(nil), resp.Body()...),
+		}
+		resp.Header.All()(func(k, v []byte) bool {
+			name := http.CanonicalHeaderKey(string(k))
+			if !unreplayedHeaders[name] {
+				rec.Header[name] = append(rec.Header[name], string(v))
+			}
+			return true
+		})
+		data, err := json.Marshal(rec)
+		if err == nil {
+			err = cfg.Store.SetWithContext(ctx, recordKey, data, cfg.TTL)
+		}
+		if err != nil {
+			if logger := c.Logger(); logger != nil {
+				logger.Warnf("idempotency record: %v", err)
+			}
+		}
+	}
+}
+
+// replayIdempotent answers from a recorded response. done reports that the
+// request was answered.
+func replayIdempotent(ctx context.Context, c *Context, store gcache.CacheWithContext, key, fingerprint string) (done bool, err error) {
+	data, err := store.GetWithContext(ctx, key)
+	if errors.Is(err, gcache.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var rec idempotencyRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return false, err
+	}
+	if rec.Fingerprint != fingerprint {
+		c.AbortWithError(ErrIdempotencyKeyReused)
+		return true, nil
+	}
+	h := c.Writer.Header()
+	for k, values := range rec.Header {
+		for _, v := range values {
+			h.Add(k, v)
+		}
+	}
+	h.Set("Idempotent-Replayed", "true")
+	c.Status(rec.Status)
+	_, _ = c.Writer.Write(rec.Body)
+	c.Abort()
+	return true, nil
+}
+
+func defaultIdempotencyFingerprint(c *Context) string {
+	h := sha256.New()
+	h.Write(c.fastCtx.Method())
+	h.Write([]byte{'\n'})
+	h.Write(c.fastCtx.RequestURI())
+	h.Write([]byte{'\n'})
+	h.Write(c.BodyBytes())
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,115 @@
+package gserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sofiworker/gk/gcache"
+)
+
+func newIdempotencyServer(t *testing.T, cfg IdempotencyConfig, handler HandlerFunc) *Server {
+	t.Helper()
+	cache, err := gcache.NewMemoryCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cache.Close() })
+	cfg.Store = cache
+	server := NewServer()
+	server.Use(Idempotency(cfg))
+	server.POST("/payments", handler)
+	return server
+}
+
+func postPayment(server *Server, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplaysAndRejectsReuse(t *testing.T) {
+	var calls atomic.Int32
+	server := newIdempotencyServer(t, IdempotencyConfig{}, func(c *Context) {
+		n := calls.Add(1)
+		c.Header("X-Payment", fmt.Sprint(n))
+		c.String(http.StatusCreated, "payment %d", n)
+	})
+
+	first := postPayment(server, "k1", `{"amount":10}`)
+	if first.Code != http.StatusCreated || first.Body.String() != "payment 1" {
+		t.Fatalf("unexpected first response %d %q", first.Code, first.Body.String())
+	}
+
+	replay := postPayment(server, "k1", `{"amount":10}`)
+	if replay.Code != http.StatusCreated || replay.Body.String() != "payment 1" ||
+		replay.Header().Get("X-Payment") != "1" || replay.Header().Get("Idempotent-Replayed") != "true" ||
+		!strings.HasPrefix(replay.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected replay %d %q %v", replay.Code, replay.Body.String(), replay.Header())
+	}
+
+	if rec := postPayment(server, "k1", `{"amount":99}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a different payload, got %d", rec.Code)
+	}
+	if rec := postPayment(server, "", `{"amount":10}`); rec.Code != http.StatusCreated || rec.Body.String() != "payment 2" {
+		t.Fatalf("requests without a key must run, got %d %q", rec.Code, rec.Body.String())
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 executions, got %d", calls.Load())
+	}
+}
+
+func TestIdempotencyInFlightAndServerErrors(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls atomic.Int32
+	server := newIdempotencyServer(t, IdempotencyConfig{Required: true}, func(c *Context) {
+		switch calls.Add(1) {
+		case 1:
+			close(started)
+			<-release
+			c.String(http.StatusOK, "done")
+		case 2:
+			c.Status(http.StatusInternalServerError)
+		default:
+			c.String(http.StatusOK, "retried")
+		}
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		postPayment(server, "slow", "x")
+	}()
+	<-started
+	rec := postPayment(server, "slow", "x")
+	if rec.Code != http.StatusConflict || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 409 while in flight, got %d", rec.Code)
+	}
+	close(release)
+	wg.Wait()
+	if rec := postPayment(server, "slow", "x"); rec.Body.String() != "done" {
+		t.Fatalf("expected the recorded response, got %q", rec.Body.String())
+	}
+
+	// A failed attempt is not recorded and can be retried with the same key.
+	if rec := postPayment(server, "flaky", "y"); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	if rec := postPayment(server, "flaky", "y"); rec.Code != http.StatusOK || rec.Body.String() != "retried" {
+		t.Fatalf("expected the retry to run, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	if rec := postPayment(server, "", "z"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a required key, got %d", rec.Code)
+	}
+}
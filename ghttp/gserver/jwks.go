@@ -0,0 +1,292 @@
+package gserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sofiworker/gk/ghttp/gclient"
+)
+
+const (
+	defaultJWKSRefreshInterval    = time.Hour
+	defaultJWKSMinRefreshInterval = time.Minute
+)
+
+var ErrJWKSSource = errors.New("jwks: URL or File is required")
+
+type JWKSConfig struct {
+	// URL is fetched with Client; File is read from disk. One of them is required.
+	URL    string
+	File   string
+	Client *gclient.Client
+
+	// RefreshInterval is the maximum age of the cached set, default 1h.
+	RefreshInterval time.Duration
+	// MinRefreshInterval limits reloads triggered by unknown key ids or a stale
+	// set, including failed ones, default 1m.
+	MinRefreshInterval time.Duration
+}
+
+// JWKS is a JWTKeySet backed by a JSON Web Key Set. Keys are cached and reloaded
+// periodically and when a token names an unknown kid, so issuers can rotate keys.
+// A stale set is reloaded in the background while the cached keys keep being served.
+type JWKS struct {
+	cfg JWKSConfig
+
+	mu          sync.RWMutex
+	keys        map[string]jwk
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	lastErr     error
+	refreshing  bool
+
+	refreshMu sync.Mutex
+}
+
+type jwk struct {
+	alg string
+	key interface{}
+}
+
+func NewJWKS(cfg JWKSConfig) (*JWKS, error) {
+	if cfg.URL == "" && cfg.File == "" {
+		return nil, ErrJWKSSource
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = defaultJWKSRefreshInterval
+	}
+	if cfg.MinRefreshInterval <= 0 {
+		cfg.MinRefreshInterval = defaultJWKSMinRefreshInterval
+	}
+	if cfg.URL != "" && cfg.Client == nil {
+		cfg.Client = gclient.NewClient()
+		cfg.Client.SetTimeout(10 * time.Second)
+	}
+	return &JWKS{cfg: cfg, keys: make(map[string]jwk)}, nil
+}
+
+// Key implements JWTKeySet. An empty kid matches the only key of a single-key set.
+func (s *JWKS) Key(ctx context.Context, kid, alg string) (interface{}, error) {
+	if !s.loaded() {
+		if err := s.refresh(ctx, s.cfg.MinRefreshInterval); err != nil {
+			return nil, err
+		}
+	} else {
+		s.mu.RLock()
+		stale := time.Since(s.fetchedAt) > s.cfg.RefreshInterval
+		s.mu.RUnlock()
+		if stale {
+			s.refreshInBackground()
+		}
+	}
+
+	if key, ok := s.lookup(kid, alg); ok {
+		return key, nil
+	}
+	if err := s.refresh(ctx, s.cfg.MinRefreshInterval); err != nil {
+		return nil, err
+	}
+	if key, ok := s.lookup(kid, alg); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: kid %q", ErrTokenKeyNotFound, kid)
+}
+
+// Refresh reloads the key set now.
+func (s *JWKS) Refresh(ctx context.Context) error {
+	return s.refresh(ctx, 0)
+}
+
+func (s *JWKS) loaded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys) > 0
+}
+
+func (s *JWKS) lookup(kid, alg string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k.key, k.alg == "" || k.alg == alg
+		}
+	}
+	k, ok := s.keys[kid]
+	if !ok || (k.alg != "" && k.alg != alg) {
+		return nil, false
+	}
+	return k.key, true
+}
+
+// refreshInBackground reloads a stale set. A failed reload is retried after
+// MinRefreshInterval.
+func (s *JWKS) refreshInBackground() {
+	s.mu.Lock()
+	failed := s.attemptedAt.After(s.fetchedAt)
+	if s.refreshing || (failed && time.Since(s.attemptedAt) < s.cfg.MinRefreshInterval) {
+		s.mu.Unlock()
+		return
+	}
+	s.refreshing = true
+	s.mu.Unlock()
+	go func() {
+		_ = s.refresh(context.Background(), 0)
+		s.mu.Lock()
+		s.refreshing = false
+		s.mu.Unlock()
+	}()
+}
+
+// refresh reloads unless another caller tried within minAge, in which case
+// it returns the error of that attempt.
+func (s *JWKS) refresh(ctx context.Context, minAge time.Duration) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.mu.Lock()
+	if !s.attemptedAt.IsZero() && time.Since(s.attemptedAt) < minAge {
+		err := s.lastErr
+		s.mu.Unlock()
+		return err
+	}
+	s.attemptedAt = time.Now()
+	s.mu.Unlock()
+
+	keys, err := s.fetch(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+func (s *JWKS) fetch(ctx context.Context) (map[string]jwk, error) {
+	data, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+func (s *JWKS) load(ctx context.Context) ([]byte, error) {
+	if s.cfg.File != "" {
+		return os.ReadFile(s.cfg.File)
+	}
+	resp, err := s.cfg.Client.R().SetContext(ctx).Get(s.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.OK(); err != nil {
+		return nil, err
+	}
+	return resp.Bytes(), nil
+}
+
+type jwkJSON struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// parseJWKS decodes the RSA, EC P-256, Ed25519 and symmetric keys of a key set.
+// Keys of other types or intended for encryption are skipped.
+func parseJWKS(data []byte) (map[string]jwk, error) {
+	var set struct {
+		Keys []jwkJSON `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := make(map[string]jwk, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, alg, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %w", k.Kid, err)
+		}
+		if key == nil {
+			continue
+		}
+		if k.Alg != "" {
+			alg = k.Alg
+		}
+		keys[k.Kid] = jwk{alg: alg, key: key}
+	}
+	return keys, nil
+}
+
+func (k jwkJSON) publicKey() (interface{}, string, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, "", err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, AlgRS256, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, "", nil
+		}
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, "", err
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, "", err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, AlgES256, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, "", nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, "", errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), AlgEdDSA, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, "", err
+		}
+		return secret, AlgHS256, nil
+	}
+	return nil, "", nil
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,492 @@
+package gserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sofiworker/gk/gcrypt"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+
+	defaultJWTClockSkew = 30 * time.Second
+)
+
+var (
+	ErrTokenMissing      = errors.New("jwt: token missing")
+	ErrTokenMalformed    = errors.New("jwt: token malformed")
+	ErrTokenAlgorithm    = errors.New("jwt: algorithm not allowed")
+	ErrTokenKeyNotFound  = errors.New("jwt: signing key not found")
+	ErrTokenSignature    = errors.New("jwt: signature invalid")
+	ErrTokenExpired      = errors.New("jwt: token expired")
+	ErrTokenNotYetValid  = errors.New("jwt: token not valid yet")
+	ErrTokenIssuer       = errors.New("jwt: issuer mismatch")
+	ErrTokenAudience     = errors.New("jwt: audience mismatch")
+	ErrInsufficientScope = errors.New("jwt: insufficient scope")
+)
+
+var supportedJWTAlgorithms = []string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA}
+
+// JWTClaims holds the registered claims of a verified token. Raw has every claim.
+type JWTClaims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	ID        string
+	// Scopes comes from the space separated "scope" claim or the "scp" list.
+	Scopes []string
+	Roles  []string
+	Raw    map[string]interface{}
+}
+
+func (c *JWTClaims) HasScope(scope string) bool {
+	return containsString(c.Scopes, scope)
+}
+
+func (c *JWTClaims) HasRole(role string) bool {
+	return containsString(c.Roles, role)
+}
+
+// Get returns a raw claim.
+func (c *JWTClaims) Get(name string) interface{} {
+	return c.Raw[name]
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// JWTKeySet resolves the verification key for a token header. Keys are []byte for
+// HS256, *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+type JWTKeySet interface {
+	Key(ctx context.Context, kid, alg string) (interface{}, error)
+}
+
+type JWTConfig struct {
+	// Key verifies every token. Use KeySet for several or rotating keys.
+	Key    interface{}
+	KeySet JWTKeySet
+	// Algorithms defaults to HS256, RS256, ES256 and EdDSA.
+	Algorithms []string
+
+	Issuer   string
+	Audience []string
+	// ClockSkew tolerated for exp and nbf, default 30s; negative disables it.
+	ClockSkew         time.Duration
+	RequireExpiration bool
+
+	// TokenFunc extracts the token and defaults to the Authorization bearer token.
+	TokenFunc func(ctx *Context) string
+	// Optional lets requests without a token through; invalid tokens are still rejected.
+	Optional bool
+	// OnError builds the response for rejected requests; the default is a 401 ErrorResult.
+	OnError func(ctx *Context, err error) Result
+	Realm   string
+
+	Now func() time.Time
+}
+
+// JWTVerifier checks JWS compact tokens.
+type JWTVerifier struct {
+	cfg JWTConfig
+}
+
+func NewJWTVerifier(cfg JWTConfig) *JWTVerifier {
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = supportedJWTAlgorithms
+	}
+	if cfg.ClockSkew == 0 {
+		cfg.ClockSkew = defaultJWTClockSkew
+	} else if cfg.ClockSkew < 0 {
+		cfg.ClockSkew = 0
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &JWTVerifier{cfg: cfg}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// Verify checks the signature and the exp, nbf, iss and aud claims.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	var header jwtHeader
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if !containsString(v.cfg.Algorithms, header.Alg) {
+		return nil, fmt.Errorf("%w: %q", ErrTokenAlgorithm, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	key := v.cfg.Key
+	if v.cfg.KeySet != nil {
+		key, err = v.cfg.KeySet.Key(ctx, header.Kid, header.Alg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if key == nil {
+		return nil, ErrTokenKeyNotFound
+	}
+	signingInput := token[:len(parts[0])+1+len(parts[1])]
+	if err := verifyJWS(header.Alg, []byte(signingInput), signature, key); err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]interface{})
+	if err := decodeJWTSegment(parts[1], &raw); err != nil {
+		return nil, err
+	}
+	claims, err := newJWTClaims(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *JWTVerifier) validate(c *JWTClaims) error {
+	now := v.cfg.Now()
+	skew := v.cfg.ClockSkew
+	if c.ExpiresAt.IsZero() {
+		if v.cfg.RequireExpiration {
+			return ErrTokenExpired
+		}
+	} else if !now.Before(c.ExpiresAt.Add(skew)) {
+		return ErrTokenExpired
+	}
+	if !c.NotBefore.IsZero() && now.Add(skew).Before(c.NotBefore) {
+		return ErrTokenNotYetValid
+	}
+	if v.cfg.Issuer != "" && c.Issuer != v.cfg.Issuer {
+		return ErrTokenIssuer
+	}
+	if len(v.cfg.Audience) > 0 {
+		for _, aud := range v.cfg.Audience {
+			if containsString(c.Audience, aud) {
+				return nil
+			}
+		}
+		return ErrTokenAudience
+	}
+	return nil
+}
+
+func decodeJWTSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return ErrTokenMalformed
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return ErrTokenMalformed
+	}
+	return nil
+}
+
+func newJWTClaims(raw map[string]interface{}) (*JWTClaims, error) {
+	c := &JWTClaims{Raw: raw}
+	c.Issuer, _ = raw["iss"].(string)
+	c.Subject, _ = raw["sub"].(string)
+	c.ID, _ = raw["jti"].(string)
+	c.Audience = claimStrings(raw["aud"])
+	c.Roles = claimStrings(raw["roles"])
+	if scope, ok := raw["scope"].(string); ok {
+		c.Scopes = strings.Fields(scope)
+	} else if scp, ok := raw["scp"].(string); ok {
+		c.Scopes = strings.Fields(scp)
+	} else {
+		c.Scopes = claimStrings(raw["scp"])
+	}
+
+	for name, dst := range map[string]*time.Time{"exp": &c.ExpiresAt, "nbf": &c.NotBefore, "iat": &c.IssuedAt} {
+		v, ok := raw[name]
+		if !ok {
+			continue
+		}
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, ErrTokenMalformed
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, ErrTokenMalformed
+		}
+		sec, frac := int64(f), f-float64(int64(f))
+		*dst = time.Unix(sec, int64(frac*1e9))
+	}
+	return c, nil
+}
+
+// claimStrings accepts a single string or a list of strings.
+func claimStrings(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// ecdsaSignature is the ASN.1 form gcrypt signs and verifies; JWS uses raw r||s.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+func verifyJWS(alg string, input, signature []byte, key interface{}) error {
+	var err error
+	switch alg {
+	case AlgHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("%w: HS256 needs a []byte key", ErrTokenKeyNotFound)
+		}
+		if !hmac.Equal(gcrypt.HMAC_SHA256(input, secret), signature) {
+			return ErrTokenSignature
+		}
+		return nil
+	case AlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: RS256 needs an *rsa.PublicKey", ErrTokenKeyNotFound)
+		}
+		err = gcrypt.VerifyWithRSA(input, signature, pub)
+	case AlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() {
+			return fmt.Errorf("%w: ES256 needs a P-256 *ecdsa.PublicKey", ErrTokenKeyNotFound)
+		}
+		if len(signature) != 64 {
+			return ErrTokenSignature
+		}
+		der, marshalErr := asn1.Marshal(ecdsaSignature{
+			R: new(big.Int).SetBytes(signature[:32]),
+			S: new(big.Int).SetBytes(signature[32:]),
+		})
+		if marshalErr != nil {
+			return ErrTokenSignature
+		}
+		err = gcrypt.VerifyWithECDSA(input, der, pub)
+	case AlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: EdDSA needs an ed25519.PublicKey", ErrTokenKeyNotFound)
+		}
+		err = gcrypt.VerifyWithEd25519(input, signature, pub)
+	default:
+		return fmt.Errorf("%w: %q", ErrTokenAlgorithm, alg)
+	}
+	if err != nil {
+		return ErrTokenSignature
+	}
+	return nil
+}
+
+// SignJWT issues a compact JWS. key is []byte for HS256, *rsa.PrivateKey,
+// *ecdsa.PrivateKey (P-256) or ed25519.PrivateKey.
+func SignJWT(claims map[string]interface{}, alg string, key interface{}, kid string) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: alg, Kid: kid, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		if alg != AlgHS256 {
+			return "", ErrTokenAlgorithm
+		}
+		sig = gcrypt.HMAC_SHA256([]byte(input), k)
+	case *rsa.PrivateKey:
+		if alg != AlgRS256 {
+			return "", ErrTokenAlgorithm
+		}
+		sig, err = gcrypt.SignWithRSA([]byte(input), k)
+	case *ecdsa.PrivateKey:
+		if alg != AlgES256 {
+			return "", ErrTokenAlgorithm
+		}
+		var der []byte
+		if der, err = gcrypt.SignWithECDSA([]byte(input), k); err == nil {
+			var parsed ecdsaSignature
+			if _, err = asn1.Unmarshal(der, &parsed); err == nil {
+				sig = make([]byte, 64)
+				parsed.R.FillBytes(sig[:32])
+				parsed.S.FillBytes(sig[32:])
+			}
+		}
+	case ed25519.PrivateKey:
+		if alg != AlgEdDSA {
+			return "", ErrTokenAlgorithm
+		}
+		sig, err = gcrypt.SignWithEd25519([]byte(input), k)
+	default:
+		return "", fmt.Errorf("%w: unsupported key type %T", ErrTokenKeyNotFound, key)
+	}
+	if err != nil {
+		return "", err
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// ==================== Middleware ====================
+
+type jwtClaimsKey struct{}
+
+// JWTAuth verifies the bearer token and stores its claims for Context.Claims.
+func JWTAuth(cfg JWTConfig) HandlerFunc {
+	verifier := NewJWTVerifier(cfg)
+	if cfg.TokenFunc == nil {
+		cfg.TokenFunc = BearerToken
+	}
+	realm := ""
+	if cfg.Realm != "" {
+		realm = "realm=" + strconv.Quote(cfg.Realm)
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(ctx *Context, err error) Result {
+			return ErrorStatusCode(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+		}
+	}
+
+	return func(ctx *Context) {
+		token := cfg.TokenFunc(ctx)
+		if token == "" {
+			if cfg.Optional {
+				ctx.Next()
+				return
+			}
+			ctx.Header("WWW-Authenticate", strings.TrimSpace("Bearer "+realm))
+			cfg.OnError(ctx, ErrTokenMissing).Execute(ctx)
+			ctx.Abort()
+			return
+		}
+
+		claims, err := verifier.Verify(ctx.Context(), token)
+		if err != nil {
+			params := []string{`error="invalid_token"`, "error_description=" + strconv.Quote(err.Error())}
+			if realm != "" {
+				params = append([]string{realm}, params...)
+			}
+			ctx.Header("WWW-Authenticate", "Bearer "+strings.Join(params, ", "))
+			cfg.OnError(ctx, err).Execute(ctx)
+			ctx.Abort()
+			return
+		}
+
+		ctx.Set(jwtClaimsKey{}, claims)
+		ctx.Next()
+	}
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" header.
+func BearerToken(ctx *Context) string {
+	auth := ctx.GetHeader("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// Claims returns the claims stored by JWTAuth, or nil.
+func (c *Context) Claims() *JWTClaims {
+	claims, _ := c.Value(jwtClaimsKey{}).(*JWTClaims)
+	return claims
+}
+
+// RequireScopes rejects requests whose token lacks any of scopes. Use it after JWTAuth,
+// typically on a route group.
+func RequireScopes(scopes ...string) HandlerFunc {
+	challenge := `Bearer error="insufficient_scope", scope=` + strconv.Quote(strings.Join(scopes, " "))
+	return func(ctx *Context) {
+		claims := ctx.Claims()
+		if claims == nil {
+			ErrorStatusCode(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized)).Execute(ctx)
+			ctx.Abort()
+			return
+		}
+		for _, scope := range scopes {
+			if !claims.HasScope(scope) {
+				ctx.Header("WWW-Authenticate", challenge)
+				ErrorCode(ErrInsufficientScope, http.StatusForbidden).Execute(ctx)
+				ctx.Abort()
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
+
+// RequireRoles rejects requests whose token has none of roles.
+func RequireRoles(roles ...string) HandlerFunc {
+	return func(ctx *Context) {
+		claims := ctx.Claims()
+		if claims == nil {
+			ErrorStatusCode(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized)).Execute(ctx)
+			ctx.Abort()
+			return
+		}
+		for _, role := range roles {
+			if claims.HasRole(role) {
+				ctx.Next()
+				return
+			}
+		}
+		ErrorStatusCode(http.StatusForbidden, http.StatusText(http.StatusForbidden)).Execute(ctx)
+		ctx.Abort()
+	}
+}
@@ -0,0 +1,201 @@
+package gserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sofiworker/gk/gcrypt"
+)
+
+func TestJWTAuthAndRequireScopes(t *testing.T) {
+	secret := []byte("top-secret")
+	server := NewServer()
+	api := server.Group("/api", JWTAuth(JWTConfig{Key: secret, Issuer: "gk", Audience: []string{"orders"}}))
+	api.GET("/me", func(c *Context) { c.String(http.StatusOK, "%s", c.Claims().Subject) })
+	api.Group("/orders", RequireScopes("orders:write")).POST("", func(c *Context) { c.Status(http.StatusCreated) })
+
+	token := func(scope string) string {
+		tok, err := SignJWT(map[string]interface{}{
+			"sub": "alice", "iss": "gk", "aud": "orders", "scope": scope,
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}, AlgHS256, secret, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	do := func(method, path, tok string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if tok != "" {
+			req.Header.Set("Authorization", "Bearer "+tok)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/api/me", ""); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Fatalf("missing token: %d %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+	if rec := do(http.MethodGet, "/api/me", token("orders:read")); rec.Code != http.StatusOK || rec.Body.String() != "alice" {
+		t.Fatalf("valid token: %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/api/me", token("")+"x"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("tampered token: expected 401, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/orders", token("orders:read")); rec.Code != http.StatusForbidden {
+		t.Fatalf("missing scope: expected 403, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/orders", token("orders:read orders:write")); rec.Code != http.StatusCreated {
+		t.Fatalf("with scope: expected 201, got %d", rec.Code)
+	}
+}
+
+func TestJWTVerifierAlgorithmsAndClaims(t *testing.T) {
+	rsaPriv, rsaPub, _ := gcrypt.GenerateRSAKeyPair(2048)
+	ecPriv, ecPub, _ := gcrypt.GenerateECDSAKeyPair(nil)
+	edPriv, edPub, _ := gcrypt.GenerateEd25519KeyPair()
+	now := time.Unix(1_700_000_000, 0)
+	claims := map[string]interface{}{"sub": "bob", "exp": now.Unix() + 60, "nbf": now.Unix() - 60}
+
+	cases := []struct {
+		alg       string
+		signKey   interface{}
+		verifyKey interface{}
+	}{
+		{AlgRS256, rsaPriv, rsaPub},
+		{AlgES256, ecPriv, ecPub},
+		{AlgEdDSA, edPriv, edPub},
+	}
+	for _, tc := range cases {
+		tok, err := SignJWT(claims, tc.alg, tc.signKey, "")
+		if err != nil {
+			t.Fatalf("%s sign: %v", tc.alg, err)
+		}
+		v := NewJWTVerifier(JWTConfig{Key: tc.verifyKey, Now: func() time.Time { return now }})
+		if c, err := v.Verify(context.Background(), tok); err != nil || c.Subject != "bob" {
+			t.Fatalf("%s verify: %v", tc.alg, err)
+		}
+		// A key of the wrong type must never verify, e.g. an RSA public key used as an HMAC secret.
+		if _, err := NewJWTVerifier(JWTConfig{Key: []byte("x"), Now: func() time.Time { return now }}).Verify(context.Background(), tok); err == nil {
+			t.Fatalf("%s verified with the wrong key", tc.alg)
+		}
+	}
+
+	secret := []byte("k")
+	tok, _ := SignJWT(map[string]interface{}{"exp": now.Unix(), "iss": "other"}, AlgHS256, secret, "")
+	at := func(ts time.Time, cfg JWTConfig) error {
+		cfg.Key = secret
+		cfg.Now = func() time.Time { return ts }
+		_, err := NewJWTVerifier(cfg).Verify(context.Background(), tok)
+		return err
+	}
+	if err := at(now.Add(10*time.Second), JWTConfig{}); err != nil {
+		t.Fatalf("expected clock skew to accept a just-expired token: %v", err)
+	}
+	if err := at(now.Add(10*time.Second), JWTConfig{ClockSkew: -1}); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expected expiry without skew, got %v", err)
+	}
+	if err := at(now.Add(-time.Minute), JWTConfig{Issuer: "gk"}); !errors.Is(err, ErrTokenIssuer) {
+		t.Fatalf("expected issuer mismatch, got %v", err)
+	}
+	if err := at(now.Add(-time.Minute), JWTConfig{Algorithms: []string{AlgRS256}}); !errors.Is(err, ErrTokenAlgorithm) {
+		t.Fatalf("expected algorithm rejection, got %v", err)
+	}
+}
+
+func TestJWKSFileAndURLRotation(t *testing.T) {
+	edPriv, edPub, _ := gcrypt.GenerateEd25519KeyPair()
+	ecPriv, ecPub, _ := gcrypt.GenerateECDSAKeyPair(nil)
+	b64 := base64.RawURLEncoding.EncodeToString
+	edJWK := map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": "ed-1", "x": b64(edPub)}
+	ecJWK := map[string]string{"kty": "EC", "crv": "P-256", "kid": "ec-2", "x": b64(ecPub.X.Bytes()), "y": b64(ecPub.Y.Bytes())}
+	exp := time.Now().Add(time.Minute).Unix()
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	data, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{edJWK}})
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fileSet, err := NewJWKS(JWKSConfig{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, _ := SignJWT(map[string]interface{}{"sub": "file", "exp": exp}, AlgEdDSA, edPriv, "ed-1")
+	if _, err := NewJWTVerifier(JWTConfig{KeySet: fileSet}).Verify(context.Background(), tok); err != nil {
+		t.Fatalf("file jwks: %v", err)
+	}
+
+	// The URL starts with the Ed25519 key only and rotates in the EC key.
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := []interface{}{edJWK}
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			keys = append(keys, ecJWK)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer srv.Close()
+
+	urlSet, err := NewJWKS(JWKSConfig{URL: srv.URL, MinRefreshInterval: time.Nanosecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewJWTVerifier(JWTConfig{KeySet: urlSet})
+	if _, err := verifier.Verify(context.Background(), tok); err != nil {
+		t.Fatalf("url jwks: %v", err)
+	}
+	rotated, _ := SignJWT(map[string]interface{}{"sub": "rotated", "exp": exp}, AlgES256, ecPriv, "ec-2")
+	if c, err := verifier.Verify(context.Background(), rotated); err != nil || c.Subject != "rotated" {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Fatalf("expected 2 fetches, got %d", n)
+	}
+}
+
+func TestJWKSServesCachedKeysWhileRefreshFails(t *testing.T) {
+	_, edPub, _ := gcrypt.GenerateEd25519KeyPair()
+	edJWK := map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": "ed-1", "x": base64.RawURLEncoding.EncodeToString(edPub)}
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{edJWK}})
+	}))
+	defer srv.Close()
+
+	set, err := NewJWKS(JWKSConfig{URL: srv.URL, RefreshInterval: time.Nanosecond, MinRefreshInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Key(context.Background(), "ed-1", AlgEdDSA); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		if _, err := set.Key(context.Background(), "ed-1", AlgEdDSA); err != nil {
+			t.Fatalf("cached key must be served, got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("lookups waited for the refresh: %v", elapsed)
+	}
+	time.Sleep(300 * time.Millisecond)
+	set.Key(context.Background(), "ed-1", AlgEdDSA)
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Fatalf("expected one failed background refresh, got %d fetches", n)
+	}
+}
@@ -0,0 +1,148 @@
+package gserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// ListenConfig is one address served by RunListeners.
+type ListenConfig struct {
+	// Network is "tcp" (default), "tcp4", "tcp6" or "unix".
+	Network string
+	Address string
+	// Listener serves an existing listener, such as one inherited through socket
+	// activation, instead of opening Address.
+	Listener net.Listener
+	// SocketMode sets the permissions of a Unix socket, e.g. 0660.
+	SocketMode os.FileMode
+	// TLS serves HTTPS on this listener.
+	TLS *TLSConfig
+}
+
+// RunListeners serves several TCP addresses and Unix sockets at once. It blocks
+// until the server shuts down or a listener fails, in which case the others are
+// closed and the first error is returned. A stale Unix socket file left by a
+// previous process is removed before listening.
+func (s *Server) RunListeners(listeners ...ListenConfig) error {
+	if len(listeners) == 0 {
+		return errors.New("gserver: no listener configured")
+	}
+	var (
+		opened    []net.Listener
+		reloaders []*CertReloader
+	)
+	defer func() {
+		for _, r := range reloaders {
+			_ = r.Close()
+		}
+	}()
+	closeAll := func() {
+		for _, ln := range opened {
+			_ = ln.Close()
+		}
+	}
+
+	for _, cfg := range listeners {
+		ln, reloader, err := s.listen(cfg)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		opened = append(opened, ln)
+		if reloader != nil {
+			reloaders = append(reloaders, reloader)
+		}
+	}
+
+	errs := make(chan error, len(opened))
+	for _, ln := range opened {
+		go func(ln net.Listener) {
+			errs <- s.server.Serve(ln)
+		}(ln)
+	}
+	var first error
+	for range opened {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+			closeAll()
+		}
+	}
+	return first
+}
+
+func (s *Server) listen(cfg ListenConfig) (net.Listener, *CertReloader, error) {
+	ln := cfg.Listener
+	if ln == nil {
+		network := cfg.Network
+		if network == "" {
+			network = "tcp"
+		}
+		if network == "unix" {
+			removeStaleSocket(cfg.Address)
+		}
+		var err error
+		if ln, err = net.Listen(network, cfg.Address); err != nil {
+			return nil, nil, err
+		}
+		if network == "unix" && cfg.SocketMode != 0 {
+			if err := os.Chmod(cfg.Address, cfg.SocketMode); err != nil {
+				_ = ln.Close()
+				return nil, nil, err
+			}
+		}
+	}
+	ln = s.wrapListener(ln)
+	if cfg.TLS == nil {
+		return ln, nil, nil
+	}
+
+	tlsCfg := *cfg.TLS
+	if tlsCfg.OnReload == nil {
+		tlsCfg.OnReload = func(err error) {
+			if err != nil {
+				s.logger.Warnf("reload TLS certificates: %v", err)
+				return
+			}
+			s.logger.Infof("reloaded TLS certificates")
+		}
+	}
+	reloader, err := NewCertReloader(tlsCfg)
+	if err != nil {
+		_ = ln.Close()
+		return nil, nil, err
+	}
+	return tls.NewListener(ln, reloader.TLSConfig()), reloader, nil
+}
+
+// removeStaleSocket deletes a Unix socket file nobody is listening on anymore.
+func removeStaleSocket(path string) {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return
+	}
+	_ = os.Remove(path)
+}
+
+// TLSConnectionState returns the TLS state of the connection, or nil when the
+// request did not arrive over TLS terminated by this server.
+func (c *Context) TLSConnectionState() *tls.ConnectionState {
+	return c.fastCtx.TLSConnectionState()
+}
+
+// ClientCertificate returns the verified client certificate of a mutual TLS
+// connection, or nil.
+func (c *Context) ClientCertificate() *x509.Certificate {
+	st := c.TLSConnectionState()
+	if st == nil || len(st.VerifiedChains) == 0 || len(st.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return st.VerifiedChains[0][0]
+}
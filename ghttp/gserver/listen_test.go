@@ -0,0 +1,230 @@
+package gserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert issues a certificate for names, signed by parent or self-signed.
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool, names ...string) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		DNSNames:              names,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) CertificateFiles {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := CertificateFiles{CertFile: filepath.Join(dir, name+".crt"), KeyFile: filepath.Join(dir, name+".key")}
+	writeFile(t, files.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}))
+	writeFile(t, files.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return files
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunListenersTCPUnixAndMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test ca", nil, true)
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}))
+	siteA := newTestCert(t, "a", ca, false, "a.test").write(t, dir, "a")
+	siteB := newTestCert(t, "b", ca, false, "b.test").write(t, dir, "b")
+	client := newTestCert(t, "alice", ca, false)
+
+	server := NewServer()
+	server.GET("/who", func(c *Context) {
+		name := "anonymous"
+		if cert := c.ClientCertificate(); cert != nil {
+			name = cert.Subject.CommonName
+		}
+		c.String(http.StatusOK, "%s tls=%v", name, c.IsTLS())
+	})
+
+	plainLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "gserver.sock")
+	done := make(chan error, 1)
+	go func() {
+		done <- server.RunListeners(
+			ListenConfig{Listener: plainLn},
+			ListenConfig{Network: "unix", Address: socket, SocketMode: 0o600},
+			ListenConfig{Listener: tlsLn, TLS: &TLSConfig{
+				Certificates: []CertificateFiles{siteA, siteB},
+				ClientCAFile: caFile,
+			}},
+		)
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+		<-done
+	})
+
+	get := func(c *http.Client, url string) string {
+		t.Helper()
+		var resp *http.Response
+		var err error
+		for i := 0; i < 50; i++ {
+			if resp, err = c.Get(url); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	if body := get(http.DefaultClient, "http://"+plainLn.Addr().String()+"/who"); body != "anonymous tls=false" {
+		t.Fatalf("tcp: %q", body)
+	}
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	if body := get(unixClient, "http://unix/who"); body != "anonymous tls=false" {
+		t.Fatalf("unix: %q", body)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	var served *x509.Certificate
+	tlsClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		ServerName:   "b.test",
+		Certificates: []tls.Certificate{client.tlsCert()},
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			served = cs.PeerCertificates[0]
+			return nil
+		},
+	}}}
+	if body := get(tlsClient, "https://"+tlsLn.Addr().String()+"/who"); body != "alice tls=true" {
+		t.Fatalf("mtls: %q", body)
+	}
+	if served == nil || served.Subject.CommonName != "b" {
+		t.Fatalf("SNI should select the b.test certificate, got %v", served)
+	}
+
+	// Without a client certificate the handshake fails.
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "a.test"}}}
+	if resp, err := anonymous.Get("https://" + tlsLn.Addr().String() + "/who"); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the handshake to require a client certificate")
+	}
+}
+
+func TestCertReloaderWatchesFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := newTestCert(t, "v1", nil, false, "a.test").write(t, dir, "a")
+	reloaded := make(chan error, 8)
+	r, err := NewCertReloader(TLSConfig{
+		Certificates: []CertificateFiles{files},
+		Reload:       true,
+		OnReload:     func(err error) { reloaded <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	commonName := func() string {
+		cert, err := r.GetCertificate(&tls.ClientHelloInfo{ServerName: "a.test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf.Subject.CommonName
+	}
+	if cn := commonName(); cn != "v1" {
+		t.Fatalf("expected v1, got %s", cn)
+	}
+
+	newTestCert(t, "v2", nil, false, "a.test").write(t, dir, "a")
+	deadline := time.After(5 * time.Second)
+	for commonName() != "v2" {
+		select {
+		case <-reloaded:
+		case <-deadline:
+			t.Fatal("certificate was not reloaded")
+		}
+	}
+
+	// A broken rotation keeps serving the last good certificate.
+	writeFile(t, files.KeyFile, []byte("garbage"))
+	select {
+	case err := <-reloaded:
+		for err == nil {
+			err = <-reloaded
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a failed reload")
+	}
+	if cn := commonName(); cn != "v2" {
+		t.Fatalf("expected v2 after a failed reload, got %s", cn)
+	}
+}
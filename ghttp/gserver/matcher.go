@@ -2,6 +2,7 @@ package gserver
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -29,6 +30,16 @@ type Match interface {
 	Stats() *MatcherStats
 }
 
+// MethodReporter 由能够报告路径已注册方法的匹配器实现，用于 405 与自动 OPTIONS
+type MethodReporter interface {
+	AllowedMethods(path string) []string
+}
+
+// PathFixer 由能够按大小写不敏感方式修正请求路径的匹配器实现
+type PathFixer interface {
+	FixedPath(method, path string) (string, bool)
+}
+
 type serverMatcher struct {
 	methodMatcher map[string]*MethodMatcher
 	matchPool     sync.Pool
@@ -48,7 +59,8 @@ func newServerMatcher() Match {
 }
 
 type routeEntry struct {
-	path       string
+	path       string // concrete pattern stored in the tree
+	route      string // pattern as registered, differs from path for optional segments
 	handlers   []HandlerFunc
 	paramNames []string
 }
@@ -56,6 +68,7 @@ type routeEntry struct {
 func newRouteEntry(path string, handlers []HandlerFunc) *routeEntry {
 	return &routeEntry{
 		path:       path,
+		route:      path,
 		handlers:   handlers,
 		paramNames: extractParamNames(path),
 	}
@@ -63,26 +76,17 @@ func newRouteEntry(path string, handlers []HandlerFunc) *routeEntry {
 
 func (r *routeEntry) toResult(params map[string]string) *MatchResult {
 	return &MatchResult{
-		Path:       r.path,
+		Path:       r.route,
 		Handlers:   r.handlers,
 		PathParams: params,
 	}
 }
 
 func extractParamNames(path string) []string {
-	trimmed := strings.Trim(path, "/")
-	if trimmed == "" {
-		return nil
-	}
-	segments := strings.Split(trimmed, "/")
 	var names []string
-	for _, segment := range segments {
-		if len(segment) == 0 {
-			continue
-		}
-		switch segment[0] {
-		case ':', '*':
-			names = append(names, segment[1:])
+	for _, segment := range splitPathSegments(path) {
+		if s, err := parseRouteSegment(segment); err == nil && s.kind != segmentStatic {
+			names = append(names, s.value)
 		}
 	}
 	return names
@@ -120,7 +124,33 @@ func (s *serverMatcher) LookupInto(method, path string, dstParams map[string]str
 	if entry == nil {
 		return nil, "", false
 	}
-	return entry.handlers, entry.path, true
+	return entry.handlers, entry.route, true
+}
+
+// AllowedMethods 返回能匹配 path 的全部方法，按字母排序
+func (s *serverMatcher) AllowedMethods(path string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var methods []string
+	params := make(map[string]string)
+	for method, mm := range s.methodMatcher {
+		if mm.matchInto(path, params) != nil {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (s *serverMatcher) FixedPath(method, path string) (string, bool) {
+	s.mu.RLock()
+	methodRouter := s.methodMatcher[method]
+	s.mu.RUnlock()
+	if methodRouter == nil {
+		return "", false
+	}
+	return methodRouter.fixedPath(path)
 }
 
 func (s *serverMatcher) AddRoute(method, path string, handler ...HandlerFunc) error {
@@ -330,3 +330,135 @@ func BenchmarkMatcherComplexRoutes(b *testing.B) {
 		})
 	}
 }
+
+func TestMatcher_ConstraintsAndPriority(t *testing.T) {
+	m := newServerMatcher()
+	routes := []string{
+		"/users/me",
+		"/users/{id:int}",
+		"/users/{uid:uuid}",
+		"/users/:name",
+		"/files/{name:[a-z]+\\.png}",
+		"/files/*filepath",
+		"/static/*filepath",
+		"/:section/:page",
+	}
+	for _, route := range routes {
+		if err := m.AddRoute("GET", route, h1); err != nil {
+			t.Fatalf("AddRoute(%s): %v", route, err)
+		}
+	}
+
+	cases := []struct {
+		path, route, param, value string
+	}{
+		{"/users/me", "/users/me", "", ""},
+		{"/users/42", "/users/{id:int}", "id", "42"},
+		{"/users/0b6f5c2e-4f43-4a4e-9b1a-3c2d1e0f9a8b", "/users/{uid:uuid}", "uid", "0b6f5c2e-4f43-4a4e-9b1a-3c2d1e0f9a8b"},
+		{"/users/alice", "/users/:name", "name", "alice"},
+		{"/files/logo.png", "/files/{name:[a-z]+\\.png}", "name", "logo.png"},
+		{"/files/Logo.PNG", "/files/*filepath", "filepath", "Logo.PNG"},
+		{"/files/img/logo.png", "/files/*filepath", "filepath", "img/logo.png"},
+		// A static first segment beats a parameter even when the other route is a catch-all.
+		{"/static/app.js", "/static/*filepath", "filepath", "app.js"},
+		{"/docs/intro", "/:section/:page", "page", "intro"},
+	}
+	for _, tc := range cases {
+		res := m.Lookup("GET", tc.path)
+		if res == nil || res.Path != tc.route {
+			t.Fatalf("%s: expected %s, got %+v", tc.path, tc.route, res)
+		}
+		if tc.param != "" && res.PathParams[tc.param] != tc.value {
+			t.Fatalf("%s: expected %s=%s, got %+v", tc.path, tc.param, tc.value, res.PathParams)
+		}
+	}
+}
+
+func TestMatcher_OptionalSegments(t *testing.T) {
+	m := newServerMatcher()
+	if err := m.AddRoute("GET", "/posts/{page?:int}/{size?:int}", h1); err != nil {
+		t.Fatalf("AddRoute failed: %v", err)
+	}
+	for _, path := range []string{"/posts", "/posts/2", "/posts/2/50"} {
+		res := m.Lookup("GET", path)
+		if res == nil || res.Path != "/posts/{page?:int}/{size?:int}" {
+			t.Fatalf("%s: expected the optional route, got %+v", path, res)
+		}
+	}
+	if res := m.Lookup("GET", "/posts/x"); res != nil {
+		t.Fatalf("constraint must apply to optional segments, got %+v", res)
+	}
+	if res := m.Lookup("GET", "/posts/2/50"); res.PathParams["page"] != "2" || res.PathParams["size"] != "50" {
+		t.Fatalf("unexpected params %+v", res.PathParams)
+	}
+
+	if err := m.RemoveRoute("GET", "/posts/{page?:int}/{size?:int}"); err != nil {
+		t.Fatalf("RemoveRoute failed: %v", err)
+	}
+	if res := m.Lookup("GET", "/posts"); res != nil {
+		t.Fatal("all optional variants must be removed")
+	}
+}
+
+func TestMatcher_Conflicts(t *testing.T) {
+	m := newServerMatcher()
+	if err := m.AddRoute("GET", "/users/:id", h1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddRoute("GET", "/files/*path", h1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddRoute("GET", "/items", h1); err != nil {
+		t.Fatal(err)
+	}
+
+	conflicts := []string{
+		"/users/:id",         // duplicate
+		"/users/{id}",        // same route, brace syntax
+		"/users/:uid/posts",  // different name at the same position
+		"/files/*rest",       // different catch-all name
+		"/items/{page?:int}", // optional variant duplicates /items
+	}
+	for _, route := range conflicts {
+		if err := m.AddRoute("GET", route, h2); err == nil {
+			t.Errorf("expected conflict for %s", route)
+		}
+	}
+	// The failed optional route must not leave its other variant behind.
+	if res := m.Lookup("GET", "/items/2"); res != nil {
+		t.Fatalf("partial registration leaked: %+v", res)
+	}
+
+	invalid := []string{"/a/{id:[}", "/a/{id?}/b", "/a/*x/b", "/a/:id/:id", "/a/x{id}"}
+	for _, route := range invalid {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected %s to be rejected", route)
+				}
+			}()
+			_ = m.AddRoute("GET", route, h1)
+		}()
+	}
+}
+
+func BenchmarkMatcherConstrainedRoutes(b *testing.B) {
+	m := newServerMatcher()
+	emptyHandler := func(ctx *Context) {}
+	for i := 0; i < 1000; i++ {
+		_ = m.AddRoute("GET", fmt.Sprintf("/api/%d/users/{id:int}", i), emptyHandler)
+		_ = m.AddRoute("GET", fmt.Sprintf("/api/%d/users/{name:[a-z]+}", i), emptyHandler)
+		_ = m.AddRoute("GET", fmt.Sprintf("/api/%d/users/:other/*rest", i), emptyHandler)
+	}
+	targets := []string{"/api/7/users/123", "/api/8/users/alice", "/api/9/users/A1/x/y"}
+	params := make(map[string]string)
+	fm := m.(*serverMatcher)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, ok := fm.LookupInto("GET", targets[i%len(targets)], params); !ok {
+			b.Fatal("expected a match")
+		}
+	}
+}
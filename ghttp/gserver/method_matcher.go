@@ -14,18 +14,20 @@ type PathFeature struct {
 	hasWild    bool
 }
 
+// MethodMatcher 保存单个方法的路由：纯静态路由走哈希表，
+// 其余路由放入同一棵 radix 树，按段依次尝试 静态 > 约束参数 > 参数 > 通配
 type MethodMatcher struct {
-	staticGroup  map[string]*routeEntry       // 静态路径表（O(1)）
-	segmentIndex map[int]*CompressedRadixTree // 按段数分组的参数路由
-	radixTree    *CompressedRadixTree         // 通配符路由树
-	mu           sync.RWMutex
+	staticGroup map[string]*routeEntry // 静态路径表（O(1)）
+	radixTree   *CompressedRadixTree   // 参数与通配符路由树
+	patterns    map[string]*routeEntry // 全部已注册路由，用于修正路径
+	mu          sync.RWMutex
 }
 
 func newMethodMatcher() *MethodMatcher {
 	return &MethodMatcher{
-		staticGroup:  make(map[string]*routeEntry),
-		segmentIndex: make(map[int]*CompressedRadixTree),
-		radixTree:    newCompressedRadixTree(),
+		staticGroup: make(map[string]*routeEntry),
+		radixTree:   newCompressedRadixTree(),
+		patterns:    make(map[string]*routeEntry),
 	}
 }
 
@@ -37,7 +39,7 @@ func (mr *MethodMatcher) extractPathFeature(path string) *PathFeature {
 		segmentCnt: len(segments),
 	}
 	for _, seg := range segments {
-		if strings.HasPrefix(seg, ":") {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "{") {
 			f.hasParam = true
 		}
 		if strings.HasPrefix(seg, "*") {
@@ -47,34 +49,107 @@ func (mr *MethodMatcher) extractPathFeature(path string) *PathFeature {
 	return f
 }
 
-// 添加路由
+// 添加路由，可选段展开为多条路由，任一冲突则整体回滚
 func (mr *MethodMatcher) addRoute(path string, handler ...HandlerFunc) error {
 	mr.mu.Lock()
 	defer mr.mu.Unlock()
 
 	CheckPathValid(path)
 
-	entry := newRouteEntry(path, handler)
+	variants, err := expandRoute(path)
+	if err != nil {
+		return err
+	}
+	for i, variant := range variants {
+		entry := newRouteEntry(variant, handler)
+		entry.route = path
+		if err := mr.insert(entry); err != nil {
+			for _, added := range variants[:i] {
+				_ = mr.delete(added)
+			}
+			return err
+		}
+		mr.patterns[variant] = entry
+	}
+	return nil
+}
+
+func (mr *MethodMatcher) insert(entry *routeEntry) error {
+	path := entry.path
 	feature := mr.extractPathFeature(path)
 
 	// 优先静态路由
 	if !feature.hasParam && !feature.hasWild {
-		if _, ok := mr.staticGroup[path]; ok {
-			return fmt.Errorf("duplicate static route: %s", path)
+		if existing, ok := mr.staticGroup[path]; ok {
+			return fmt.Errorf("duplicate static route: %s conflicts with %s", entry.route, existing.route)
 		}
 		mr.staticGroup[path] = entry
 		return nil
 	}
 
-	// 通配符走 radix 树
-	if feature.hasWild {
-		return mr.radixTree.insert(entry)
+	return mr.radixTree.insert(entry)
+}
+
+// fixedPath 按大小写不敏感的方式匹配已注册路由，返回使用注册大小写的路径
+func (mr *MethodMatcher) fixedPath(path string) (string, bool) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	target := splitPathSegments(path)
+	best, bestParams := "", -1
+	for pattern, entry := range mr.patterns {
+		fixed, ok := fixPathCase(splitPathSegments(pattern), target)
+		if !ok {
+			continue
+		}
+		// 静态路由优先，其次是参数更少的路由
+		if bestParams < 0 || len(entry.paramNames) < bestParams || (len(entry.paramNames) == bestParams && fixed < best) {
+			if lastChar(pattern) == '/' && fixed != "/" {
+				fixed += "/"
+			}
+			best, bestParams = fixed, len(entry.paramNames)
+		}
 	}
+	return best, bestParams >= 0
+}
 
-	if mr.segmentIndex[feature.segmentCnt] == nil {
-		mr.segmentIndex[feature.segmentCnt] = newCompressedRadixTree()
+func fixPathCase(pattern, target []string) (string, bool) {
+	var b strings.Builder
+	for i, raw := range pattern {
+		seg, err := parseRouteSegment(raw)
+		if err != nil {
+			return "", false
+		}
+		if seg.kind == segmentCatchAll {
+			for _, rest := range target[i:] {
+				b.WriteByte('/')
+				b.WriteString(rest)
+			}
+			return "/" + strings.TrimPrefix(b.String(), "/"), true
+		}
+		if i >= len(target) {
+			return "", false
+		}
+		b.WriteByte('/')
+		switch {
+		case seg.kind == segmentParam:
+			if seg.constraint != nil && !seg.constraint.match(target[i]) {
+				return "", false
+			}
+			b.WriteString(target[i])
+		case strings.EqualFold(seg.value, target[i]):
+			b.WriteString(seg.value)
+		default:
+			return "", false
+		}
+	}
+	if len(pattern) != len(target) {
+		return "", false
 	}
-	return mr.segmentIndex[feature.segmentCnt].insert(entry)
+	if b.Len() == 0 {
+		return "/", true
+	}
+	return b.String(), true
 }
 
 func (mr *MethodMatcher) match(path string) *MatchResult {
@@ -85,20 +160,10 @@ func (mr *MethodMatcher) match(path string) *MatchResult {
 	if entry, ok := mr.staticGroup[path]; ok {
 		return &MatchResult{
 			Handlers: entry.handlers,
-			Path:     path,
+			Path:     entry.route,
 		}
 	}
 
-	// 段数匹配（例如 /user/:id）
-	segCnt := pathSegmentCount(path)
-	if entries, ok := mr.segmentIndex[segCnt]; ok {
-		result := entries.search(path)
-		if result != nil {
-			return result
-		}
-	}
-
-	// 最后走通配树匹配
 	return mr.radixTree.search(path)
 }
 
@@ -117,62 +182,42 @@ func (mr *MethodMatcher) matchInto(path string, params map[string]string) *route
 		return entry
 	}
 
-	// Segment match
-	segCnt := pathSegmentCount(path)
-	if entries, ok := mr.segmentIndex[segCnt]; ok {
-		if entry := entries.searchInto(path, params); entry != nil {
-			return entry
-		}
-	}
-
-	// Wildcard match
 	return mr.radixTree.searchInto(path, params)
 }
 
-func pathSegmentCount(path string) int {
-	if path == "" {
-		return 0
-	}
-	i := 0
-	j := len(path)
-	for i < j && path[i] == '/' {
-		i++
-	}
-	for j > i && path[j-1] == '/' {
-		j--
-	}
-	if i >= j {
-		return 0
+func (mr *MethodMatcher) removeRoute(path string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	variants, err := expandRoute(path)
+	if err != nil {
+		return err
 	}
-	count := 1
-	for k := i; k < j; k++ {
-		if path[k] == '/' {
-			count++
+	for _, variant := range variants {
+		if err := mr.delete(variant); err != nil {
+			return err
 		}
 	}
-	return count
+	return nil
 }
 
-func (mr *MethodMatcher) removeRoute(path string) error {
-	mr.mu.Lock()
-	defer mr.mu.Unlock()
-
+func (mr *MethodMatcher) delete(path string) error {
 	feature := mr.extractPathFeature(path)
 
 	if !feature.hasParam && !feature.hasWild {
 		if _, ok := mr.staticGroup[path]; ok {
 			delete(mr.staticGroup, path)
+			delete(mr.patterns, path)
 			return nil
 		}
 		return fmt.Errorf("route not found: %s", path)
 	}
 
-	if feature.hasWild {
-		return mr.radixTree.remove(path)
+	if err := mr.radixTree.remove(path); err != nil {
+		return err
 	}
-
-	entries := mr.segmentIndex[feature.segmentCnt]
-	return entries.remove(path)
+	delete(mr.patterns, path)
+	return nil
 }
 
 //// 匹配分段路径并提取参数
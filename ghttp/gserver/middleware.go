@@ -195,7 +195,11 @@ func RequestLogger() HandlerFunc {
 		if ctx.Writer != nil {
 			status = ctx.Writer.Status()
 		}
-		logger.Infof("request %s %s -> %d (%s)", method, path, status, time.Since(start))
+		client := ctx.ClientIP()
+		if h := ctx.ProxyHeader(); h != nil && h.TLS != nil {
+			client += " " + h.TLS.Version
+		}
+		logger.Infof("request %s %s -> %d (%s) from %s", method, path, status, time.Since(start), client)
 	}
 }
 
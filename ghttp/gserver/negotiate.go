@@ -0,0 +1,200 @@
+package gserver
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sofiworker/gk/gerr"
+)
+
+// ErrNotAcceptable is rendered with 406 when none of the offered media types
+// matches the Accept header.
+var ErrNotAcceptable = gerr.New(http.StatusNotAcceptable, "not_acceptable", "no acceptable representation")
+
+// defaultCodecs serves contexts created without a server.
+var defaultCodecs = newCodecFactory()
+
+type producesKey struct{}
+
+// Produces restricts the media types negotiated by RespAuto, Auto and Negotiate
+// for the routes it is attached to. Types without a registered codec can only be
+// chosen through Negotiate.
+//
+//	r.GET("/report", gserver.Produces("application/vnd.acme.report+json", "text/csv"), report)
+func Produces(types ...string) HandlerFunc {
+	offers := make([]string, 0, len(types))
+	for _, t := range types {
+		if t = normalizeContentType(t); t != "" {
+			offers = append(offers, t)
+		}
+	}
+	return func(c *Context) {
+		c.Set(producesKey{}, offers)
+		c.Next()
+	}
+}
+
+// Negotiate returns the offer that best matches the Accept header, or "" when
+// none is acceptable. Without offers it uses the types of Produces, else every
+// type registered in the CodecFactory.
+func (c *Context) Negotiate(offers ...string) string {
+	if len(offers) == 0 {
+		offers = c.offers()
+	}
+	return NegotiateContentType(c.requestHeader("Accept"), offers)
+}
+
+func (c *Context) codecs() *CodecFactory {
+	if c.codec != nil {
+		return c.codec
+	}
+	return defaultCodecs
+}
+
+func (c *Context) offers() []string {
+	if offers, ok := c.Value(producesKey{}).([]string); ok {
+		return offers
+	}
+	return c.codecs().Types()
+}
+
+// writeNegotiated encodes data with the codec of the negotiated media type.
+// Binary values are also offered as application/octet-stream and written as is.
+func (c *Context) writeNegotiated(code int, data interface{}) {
+	codecs := c.codecs()
+	_, isBytes := data.([]byte)
+	reader, isReader := data.(io.Reader)
+
+	var offers []string
+	for _, t := range c.offers() {
+		if codecs.Get(t) != nil || (t == MIMEOctetStream && (isBytes || isReader)) {
+			offers = append(offers, t)
+		}
+	}
+	if (isBytes || isReader) && c.Value(producesKey{}) == nil {
+		offers = append(offers, MIMEOctetStream)
+	}
+	addVary(&c.fastCtx.Response.Header, "Accept")
+
+	mediaType := NegotiateContentType(c.requestHeader("Accept"), offers)
+	switch {
+	case mediaType == "":
+		Problem(ErrNotAcceptable).Execute(c)
+	case mediaType == MIMEOctetStream && isReader:
+		c.Header("Content-Type", mediaType)
+		c.Status(code)
+		_, _ = io.Copy(c.Writer, reader)
+	case mediaType == MIMEOctetStream && isBytes:
+		c.Data(code, mediaType, data.([]byte))
+	default:
+		body, err := codecs.Get(mediaType).EncodeBytes(data)
+		if err != nil {
+			if c.logger != nil {
+				c.logger.Errorf("encode %s response: %v", mediaType, err)
+			}
+			(&ErrorResult{Err: err, Code: http.StatusInternalServerError}).Execute(c)
+			return
+		}
+		c.Data(code, mediaType, body)
+	}
+}
+
+type mediaRange struct {
+	typ, sub string
+	q        float64
+}
+
+// NegotiateContentType picks the offer best matching an Accept header (RFC 9110
+// section 12.5.1). Each offer takes the q-value of the most specific range that
+// matches it; the highest q-value wins and ties go to the more specific match,
+// then to the earlier offer. An empty Accept accepts the first offer. It returns
+// "" when no offer is acceptable.
+//
+// Structured syntax suffixes (RFC 6839) match their base type in both directions:
+// an offer application/json satisfies application/vnd.acme+json, which is then
+// returned as is, and an offer application/vnd.acme+json satisfies application/json.
+func NegotiateContentType(accept string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return normalizeContentType(offers[0])
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		name, q := parseQuality(part)
+		typ, sub, ok := strings.Cut(name, "/")
+		if !ok || typ == "" || sub == "" || (typ == "*" && sub != "*") {
+			continue
+		}
+		ranges = append(ranges, mediaRange{typ: typ, sub: sub, q: q})
+	}
+
+	candidates := make([]string, 0, len(offers))
+	for _, o := range offers {
+		candidates = append(candidates, normalizeContentType(o))
+	}
+	// Concrete suffixed ranges become candidates when their base type is offered.
+	for _, r := range ranges {
+		name := r.typ + "/" + r.sub
+		if r.sub == "*" || containsString(candidates, name) {
+			continue
+		}
+		if base := suffixBase(name); base != "" && containsString(candidates, base) {
+			candidates = append(candidates, name)
+		}
+	}
+
+	best, bestQ, bestSpec := "", 0.0, 0
+	for _, cand := range candidates {
+		typ, sub, ok := strings.Cut(cand, "/")
+		if !ok {
+			continue
+		}
+		q, spec := 0.0, 0
+		for _, r := range ranges {
+			s := matchSpecificity(r, typ, sub)
+			if s > spec {
+				q, spec = r.q, s
+			}
+		}
+		if spec == 0 || q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && spec > bestSpec) {
+			best, bestQ, bestSpec = cand, q, spec
+		}
+	}
+	return best
+}
+
+// matchSpecificity ranks how closely r matches typ/sub, 0 meaning no match.
+func matchSpecificity(r mediaRange, typ, sub string) int {
+	switch {
+	case r.typ == typ && r.sub == sub:
+		return 4
+	case r.sub != "*" && suffixMatch(r.typ+"/"+r.sub, typ+"/"+sub):
+		return 3
+	case r.typ == typ && r.sub == "*":
+		return 2
+	case r.typ == "*":
+		return 1
+	}
+	return 0
+}
+
+func suffixMatch(a, b string) bool {
+	return suffixBase(a) == b || suffixBase(b) == a
+}
+
+// suffixBase maps a type with a structured syntax suffix such as
+// application/vnd.acme+json to application/json.
+func suffixBase(mediaType string) string {
+	i := strings.LastIndexByte(mediaType, '+')
+	if i < 0 || i == len(mediaType)-1 || !strings.Contains(mediaType[:i], "/") {
+		return ""
+	}
+	return "application/" + mediaType[i+1:]
+}
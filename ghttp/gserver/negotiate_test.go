@@ -0,0 +1,106 @@
+package gserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sofiworker/gk/gcodec"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{MIMEJSON, MIMEXML, MIMEYAML2, MIMEPlain}
+	cases := []struct {
+		accept string
+		offers []string
+		want   string
+	}{
+		{"", offers, MIMEJSON},
+		{"*/*", offers, MIMEJSON},
+		{"application/xml", offers, MIMEXML},
+		{"application/json;q=0.5, application/yaml", offers, MIMEYAML2},
+		{"text/*, application/json;q=0.2", offers, MIMEPlain},
+		{"*/*;q=0.1, application/xml", offers, MIMEXML},
+		{"application/*;q=0.8, application/json;q=0", offers, MIMEXML},
+		{"image/png", offers, ""},
+		{"application/vnd.acme.v1+json", offers, "application/vnd.acme.v1+json"},
+		{"application/vnd.acme+json;q=0.3, application/xml", offers, MIMEXML},
+		{"application/json", []string{"application/vnd.acme.v2+json", "text/csv"}, "application/vnd.acme.v2+json"},
+		{"text/csv, application/json;q=0.9", []string{"application/vnd.acme.v2+json", "text/csv"}, "text/csv"},
+	}
+	for _, tc := range cases {
+		if got := NegotiateContentType(tc.accept, tc.offers); got != tc.want {
+			t.Errorf("NegotiateContentType(%q, %v) = %q, want %q", tc.accept, tc.offers, got, tc.want)
+		}
+	}
+}
+
+func TestRespAutoNegotiation(t *testing.T) {
+	cf := &CodecFactory{}
+	_ = cf.Register(MIMEJSON, gcodec.NewJSONCodec())
+	_ = cf.Register(MIMEYAML2, gcodec.NewYAMLCodec())
+	_ = cf.Register("text/csv", gcodec.NewPlainCodec())
+
+	server := NewServer(WithCodec(cf))
+	server.GET("/user", func(c *Context) {
+		c.RespAuto(map[string]string{"name": "ann"})
+	})
+	server.GET("/report", Produces("application/vnd.acme.report+json", "text/csv"), Wrap(func(c *Context) Result {
+		return Auto("name\nann\n")
+	}))
+
+	do := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/user", "application/yaml, application/json;q=0.9")
+	if rec.Header().Get("Content-Type") != MIMEYAML2 || strings.TrimSpace(rec.Body.String()) != "name: ann" {
+		t.Fatalf("unexpected yaml response %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	if rec.Header().Get("Vary") != "Accept" {
+		t.Fatalf("expected Vary: Accept, got %q", rec.Header().Get("Vary"))
+	}
+
+	rec = do("/user", "application/vnd.acme.user+json")
+	if rec.Header().Get("Content-Type") != "application/vnd.acme.user+json" || strings.TrimSpace(rec.Body.String()) != `{"name":"ann"}` {
+		t.Fatalf("unexpected vendor response %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	rec = do("/user", "application/xml")
+	if rec.Code != http.StatusNotAcceptable || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/problem+") {
+		t.Fatalf("expected 406 problem, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rec = do("/report", "text/csv")
+	if rec.Header().Get("Content-Type") != "text/csv" || rec.Body.String() != "name\nann\n" {
+		t.Fatalf("unexpected csv response %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	// The route only produces its own types even though YAML is registered.
+	if rec = do("/report", MIMEYAML2); rec.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406, got %d", rec.Code)
+	}
+}
+
+func TestRespAutoEncodeFailure(t *testing.T) {
+	cf := &CodecFactory{}
+	_ = cf.Register(MIMEXML, gcodec.NewXMLCodec())
+	server := NewServer(WithCodec(cf))
+	server.GET("/map", func(c *Context) {
+		c.RespAuto(map[string]interface{}{"name": "ann"})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/map", nil)
+	req.Header.Set("Accept", MIMEXML)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/problem+xml") {
+		t.Fatalf("expected a 500 problem, got %d %q %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
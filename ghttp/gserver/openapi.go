@@ -0,0 +1,972 @@
+package gserver
+
+import (
+	"encoding"
+	"encoding/json"
+	"html"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	OpenAPIVersion = "3.1.0"
+
+	defaultOpenAPIPath   = "/openapi.json"
+	defaultOpenAPIUIPath = "/docs"
+)
+
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	Method    string
+	Path      string
+	Operation *Operation
+}
+
+// Operation annotates a route for the OpenAPI document. Register it with Doc:
+//
+//	r.Doc(&Operation{Summary: "Create user", Request: CreateUser{}, Responses: map[int]interface{}{201: User{}}}).
+//		POST("/users", createUser)
+type Operation struct {
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	// Hidden leaves the route out of the document.
+	Hidden bool
+
+	// Request is a binding struct as accepted by ShouldBind. Fields tagged uri, query,
+	// header or cookie become parameters, form fields a form body and the remaining
+	// fields the body decoded with RequestContentType (application/json by default).
+	Request            interface{}
+	RequestContentType string
+
+	// Response documents the 200 body. Responses maps further status codes to body
+	// values; use nil for responses without a body.
+	Response  interface{}
+	Responses map[int]interface{}
+
+	Security []map[string][]string
+}
+
+// OpenAPIConfig configures ServeOpenAPI.
+type OpenAPIConfig struct {
+	Title       string
+	Version     string
+	Description string
+	Servers     []string
+
+	// Path serves the JSON document, default /openapi.json. The YAML form is served
+	// at the same path with a .yaml extension.
+	Path string
+	// UIPath serves the bundled viewer, default /docs. Set to "-" to disable it.
+	UIPath string
+
+	SecuritySchemes map[string]*SecurityScheme
+	// Security applies to every operation that doesn't set its own.
+	Security []map[string][]string
+}
+
+func (c *OpenAPIConfig) applyDefaults() {
+	if c.Title == "" {
+		c.Title = "API"
+	}
+	if c.Version == "" {
+		c.Version = "1.0.0"
+	}
+	if c.Path == "" {
+		c.Path = defaultOpenAPIPath
+	}
+	if c.UIPath == "" {
+		c.UIPath = defaultOpenAPIUIPath
+	}
+}
+
+// ==================== Document ====================
+
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []OpenAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components *OpenAPIComponents                      `json:"components,omitempty"`
+	Security   []map[string][]string                   `json:"security,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Schema is the JSON Schema subset emitted for Go types.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// JSON encodes the document.
+func (d *OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// YAML encodes the document, keeping the field order of the JSON form.
+func (d *OpenAPIDocument) YAML() ([]byte, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+// blockStyle drops the flow and quoting styles the JSON input implies; the encoder
+// still quotes strings that would otherwise read as another type.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, child := range n.Content {
+		blockStyle(child)
+	}
+}
+
+// ==================== Server ====================
+
+func (s *Server) recordRoute(method, path string, op *Operation) {
+	s.routesMu.Lock()
+	s.routes = append(s.routes, RouteInfo{Method: method, Path: path, Operation: op})
+	s.routesMu.Unlock()
+}
+
+// Routes returns the routes registered through the router, in registration order.
+func (s *Server) Routes() []RouteInfo {
+	s.routesMu.RLock()
+	defer s.routesMu.RUnlock()
+	return append([]RouteInfo(nil), s.routes...)
+}
+
+// OpenAPI builds a document describing the currently registered routes.
+func (s *Server) OpenAPI(cfg OpenAPIConfig) *OpenAPIDocument {
+	cfg.applyDefaults()
+	doc := &OpenAPIDocument{
+		OpenAPI:  OpenAPIVersion,
+		Info:     OpenAPIInfo{Title: cfg.Title, Version: cfg.Version, Description: cfg.Description},
+		Paths:    make(map[string]map[string]*OpenAPIOperation),
+		Security: cfg.Security,
+	}
+	for _, url := range cfg.Servers {
+		doc.Servers = append(doc.Servers, OpenAPIServer{URL: url})
+	}
+
+	gen := newSchemaGenerator()
+	for _, route := range s.Routes() {
+		if route.Operation != nil && route.Operation.Hidden {
+			continue
+		}
+		// Optional segments are documented as one path per variant; operation ids
+		// must be unique, so only the full variant keeps it.
+		variants, err := expandRoute(route.Path)
+		if err != nil {
+			variants = []string{route.Path}
+		}
+		for i, variant := range variants {
+			path, params := openAPIPath(variant)
+			item := doc.Paths[path]
+			if item == nil {
+				item = make(map[string]*OpenAPIOperation)
+				doc.Paths[path] = item
+			}
+			op := gen.operation(route, params)
+			if i < len(variants)-1 {
+				op.OperationID = ""
+			}
+			item[strings.ToLower(route.Method)] = op
+		}
+	}
+
+	if len(gen.schemas) > 0 || len(cfg.SecuritySchemes) > 0 {
+		doc.Components = &OpenAPIComponents{SecuritySchemes: cfg.SecuritySchemes}
+		if len(gen.schemas) > 0 {
+			doc.Components.Schemas = gen.schemas
+		}
+	}
+	return doc
+}
+
+// ServeOpenAPI registers handlers for the JSON and YAML documents and the viewer page.
+// The document is rebuilt per request, so routes added later are included.
+func (s *Server) ServeOpenAPI(cfg OpenAPIConfig) IRouter {
+	cfg.applyDefaults()
+	hidden := &Operation{Hidden: true}
+
+	s.Doc(hidden).GET(cfg.Path, func(c *Context) {
+		data, err := s.OpenAPI(cfg).JSON()
+		if err != nil {
+			ErrorCode(err, http.StatusInternalServerError).Execute(c)
+			return
+		}
+		c.Data(http.StatusOK, MIMEJSON, data)
+	})
+
+	yamlPath := strings.TrimSuffix(cfg.Path, ".json") + ".yaml"
+	if yamlPath != cfg.Path {
+		s.Doc(hidden).GET(yamlPath, func(c *Context) {
+			data, err := s.OpenAPI(cfg).YAML()
+			if err != nil {
+				ErrorCode(err, http.StatusInternalServerError).Execute(c)
+				return
+			}
+			c.Data(http.StatusOK, MIMEYAML, data)
+		})
+	}
+
+	if cfg.UIPath != "-" {
+		page := openAPIViewerPage(cfg.Title, cfg.Path)
+		s.Doc(hidden).GET(cfg.UIPath, func(c *Context) {
+			c.Data(http.StatusOK, MIMEHTML, page)
+		})
+	}
+	return s.IRouter
+}
+
+type openAPIPathParam struct {
+	name   string
+	schema *Schema
+}
+
+// openAPIPath converts :name, {name:constraint} and *name segments to {name} and
+// returns the parameters, typed after their constraints.
+func openAPIPath(path string) (string, []openAPIPathParam) {
+	segments := strings.Split(path, "/")
+	var params []openAPIPathParam
+	for i, raw := range segments {
+		seg, err := parseRouteSegment(raw)
+		if err != nil || seg.kind == segmentStatic {
+			continue
+		}
+		params = append(params, openAPIPathParam{name: seg.value, schema: constraintSchema(seg.constraint)})
+		segments[i] = "{" + seg.value + "}"
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func hasPathParam(params []openAPIPathParam, name string) bool {
+	for _, p := range params {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func constraintSchema(c *routeConstraint) *Schema {
+	if c == nil {
+		return &Schema{Type: "string"}
+	}
+	switch c.expr {
+	case "int":
+		return &Schema{Type: "integer", Format: "int64"}
+	case "uint":
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case "float":
+		return &Schema{Type: "number"}
+	case "bool":
+		return &Schema{Type: "boolean"}
+	case "uuid":
+		return &Schema{Type: "string", Format: "uuid"}
+	case "alpha":
+		return &Schema{Type: "string", Pattern: alphaRegexp.String()}
+	case "alphanum":
+		return &Schema{Type: "string", Pattern: alnumRegexp.String()}
+	}
+	routeConstraintsMu.RLock()
+	_, custom := routeConstraints[c.expr]
+	routeConstraintsMu.RUnlock()
+	if custom {
+		return &Schema{Type: "string"}
+	}
+	return &Schema{Type: "string", Pattern: "^(?:" + c.expr + ")$"}
+}
+
+// ==================== Schema generation ====================
+
+type schemaGenerator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	schemaNameRegexp  = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+func (g *schemaGenerator) operation(route RouteInfo, pathParams []openAPIPathParam) *OpenAPIOperation {
+	op := route.Operation
+	if op == nil {
+		op = &Operation{}
+	}
+	out := &OpenAPIOperation{
+		OperationID: op.OperationID,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Deprecated:  op.Deprecated,
+		Security:    op.Security,
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+
+	if op.Request != nil {
+		g.requestParts(reflect.TypeOf(op.Request), op.RequestContentType, out)
+	}
+	// Path parameters must appear in the template, which an omitted optional segment does not.
+	kept := out.Parameters[:0]
+	for _, p := range out.Parameters {
+		if p.In == "path" && !hasPathParam(pathParams, p.Name) {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	out.Parameters = kept
+	// Route parameters the request struct doesn't describe are typed by their constraint.
+	for _, param := range pathParams {
+		found := false
+		for _, p := range out.Parameters {
+			if p.In == "path" && p.Name == param.name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out.Parameters = append(out.Parameters, &OpenAPIParameter{
+				Name: param.name, In: "path", Required: true, Schema: param.schema,
+			})
+		}
+	}
+
+	if op.Response != nil {
+		out.Responses["200"] = g.response(http.StatusOK, op.Response)
+	}
+	for code, body := range op.Responses {
+		out.Responses[strconv.Itoa(code)] = g.response(code, body)
+	}
+	if len(out.Responses) == 0 {
+		out.Responses["200"] = &OpenAPIResponse{Description: http.StatusText(http.StatusOK)}
+	}
+	return out
+}
+
+func (g *schemaGenerator) response(code int, body interface{}) *OpenAPIResponse {
+	resp := &OpenAPIResponse{Description: http.StatusText(code)}
+	if resp.Description == "" {
+		resp.Description = "Status " + strconv.Itoa(code)
+	}
+	if body != nil {
+		resp.Content = map[string]*OpenAPIMediaType{
+			MIMEJSON: {Schema: g.schemaFor(reflect.TypeOf(body))},
+		}
+	}
+	return resp
+}
+
+func (g *schemaGenerator) requestParts(t reflect.Type, contentType string, out *OpenAPIOperation) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if contentType == "" {
+		contentType = MIMEJSON
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		out.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]*OpenAPIMediaType{contentType: {Schema: g.schemaFor(t)}},
+		}
+		return
+	}
+
+	form := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	multipartForm := false
+	g.collectParams(t, out, form, &multipartForm)
+
+	if len(form.Properties) > 0 {
+		ct := MIMEPOSTForm
+		if multipartForm {
+			ct = MIMEMultipartPOSTForm
+		}
+		out.RequestBody = &OpenAPIRequestBody{Content: map[string]*OpenAPIMediaType{ct: {Schema: form}}}
+		return
+	}
+	if hasBodyFields(t) {
+		out.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]*OpenAPIMediaType{contentType: {Schema: g.schemaFor(t)}},
+		}
+	}
+}
+
+var paramLocations = map[string]string{
+	BindURI:    "path",
+	BindQuery:  "query",
+	BindHeader: "header",
+	BindCookie: "cookie",
+}
+
+// collectParams walks the same plan ShouldBind uses.
+func (g *schemaGenerator) collectParams(t reflect.Type, out *OpenAPIOperation, form *Schema, multipartForm *bool) {
+	for _, bf := range planFor(t).fields {
+		field := t.FieldByIndex(bf.index)
+		if bf.nested != nil {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			g.collectParams(ft, out, form, multipartForm)
+			continue
+		}
+
+		schema, required := g.fieldSchema(field)
+		if bf.hasDefault {
+			schema.Default = defaultValue(field.Type, bf.defaultVal)
+		}
+
+		if bf.source == BindForm {
+			if field.Type == fileHeaderType || field.Type == reflect.SliceOf(fileHeaderType) {
+				*multipartForm = true
+			}
+			form.Properties[bf.name] = schema
+			if required {
+				form.Required = append(form.Required, bf.name)
+			}
+			continue
+		}
+
+		in := paramLocations[bf.source]
+		out.Parameters = append(out.Parameters, &OpenAPIParameter{
+			Name:        bf.name,
+			In:          in,
+			Description: field.Tag.Get("doc"),
+			Required:    required || in == "path",
+			Schema:      schema,
+		})
+	}
+}
+
+func defaultValue(t reflect.Type, raw string) interface{} {
+	v := reflect.New(t).Elem()
+	if err := setFieldValues(v, []string{raw}); err != nil {
+		return raw
+	}
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+// hasBodyFields reports whether t has fields that are decoded from the body.
+func hasBodyFields(t reflect.Type) bool {
+	for _, info := range sortedFields(t) {
+		if _, ok := bodyFieldName(info.Field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyFieldName returns the encoded name of a body field. Fields bound from the URI,
+// query, form, headers or cookies without a json tag aren't part of the body.
+func bodyFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	tag, hasJSON := field.Tag.Lookup("json")
+	if tag == "-" {
+		return "", false
+	}
+	if !hasJSON {
+		for _, source := range bindSources {
+			if _, ok := field.Tag.Lookup(source); ok {
+				return "", false
+			}
+		}
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+func (g *schemaGenerator) fieldSchema(field reflect.StructField) (*Schema, bool) {
+	schema := g.schemaFor(field.Type)
+	if schema.Ref != "" {
+		// Siblings of $ref are allowed in 3.1, but keep the shared schema untouched.
+		schema = &Schema{Ref: schema.Ref}
+	}
+	schema.Description = field.Tag.Get("doc")
+	required := applyValidateTag(schema, field.Type, field.Tag.Get("validate"))
+	return schema, required
+}
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case t == fileHeaderType.Elem():
+		return &Schema{Type: "string", Format: "binary"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: floatPtr(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return g.structRef(t)
+	}
+	// interface{} and anything else accepts any value.
+	return &Schema{}
+}
+
+func (g *schemaGenerator) structRef(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = g.uniqueName(t)
+		g.names[t] = name
+		// Reserve the name first so recursive types terminate.
+		g.schemas[name] = &Schema{}
+		*g.schemas[name] = *g.structSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (g *schemaGenerator) uniqueName(t reflect.Type) string {
+	name := schemaNameRegexp.ReplaceAllString(t.Name(), "_")
+	if _, taken := g.schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	base := schemaNameRegexp.ReplaceAllString(pkg, "_") + "." + name
+	candidate := base
+	for i := 2; ; i++ {
+		if _, taken := g.schemas[candidate]; !taken {
+			return candidate
+		}
+		candidate = base + strconv.Itoa(i)
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, info := range sortedFields(t) {
+		name, ok := bodyFieldName(info.Field)
+		if !ok {
+			continue
+		}
+		prop, required := g.fieldSchema(info.Field)
+		schema.Properties[name] = prop
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// applyValidateTag maps validate rules onto schema and reports whether the field is required.
+func applyValidateTag(s *Schema, t reflect.Type, tag string) bool {
+	if tag == "" || tag == "-" {
+		return false
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	required := false
+	parts := strings.Split(tag, ",")
+	for i := 0; i < len(parts); i++ {
+		name, param, _ := strings.Cut(strings.TrimSpace(parts[i]), "=")
+		switch name {
+		case "required":
+			required = true
+		case "min", "gte", "max", "lte", "len", "gt", "lt":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			applyBound(s, t, name, n)
+		case "oneof":
+			for _, opt := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(t, opt))
+			}
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "alpha":
+			s.Pattern = alphaRegexp.String()
+		case "alphanum":
+			s.Pattern = alnumRegexp.String()
+		case "numeric":
+			s.Pattern = numericRegexp.String()
+		case "regexp":
+			s.Pattern = strings.Join(append([]string{param}, parts[i+1:]...), ",")
+			i = len(parts)
+		}
+	}
+	return required
+}
+
+func applyBound(s *Schema, t reflect.Type, rule string, n float64) {
+	switch t.Kind() {
+	case reflect.String:
+		v := int(n)
+		switch rule {
+		case "min", "gte":
+			s.MinLength = &v
+		case "max", "lte":
+			s.MaxLength = &v
+		case "len":
+			s.MinLength, s.MaxLength = &v, intPtr(v)
+		}
+	case reflect.Slice, reflect.Array:
+		v := int(n)
+		switch rule {
+		case "min", "gte":
+			s.MinItems = &v
+		case "max", "lte":
+			s.MaxItems = &v
+		case "len":
+			s.MinItems, s.MaxItems = &v, intPtr(v)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		switch rule {
+		case "min", "gte":
+			s.Minimum = floatPtr(n)
+		case "max", "lte":
+			s.Maximum = floatPtr(n)
+		case "gt":
+			s.ExclusiveMinimum = floatPtr(n)
+		case "lt":
+			s.ExclusiveMaximum = floatPtr(n)
+		case "len":
+			s.Minimum, s.Maximum = floatPtr(n), floatPtr(n)
+		}
+	}
+}
+
+func enumValue(t reflect.Type, raw string) interface{} {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(raw, 64); err == nil {
+			return n
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+func intPtr(v int) *int { return &v }
+
+// ==================== Viewer ====================
+
+func openAPIViewerPage(title, specPath string) []byte {
+	spec, _ := json.Marshal(specPath)
+	page := strings.NewReplacer(
+		"{{title}}", html.EscapeString(title),
+		"{{spec}}", string(spec),
+	).Replace(openAPIViewerHTML)
+	return []byte(page)
+}
+
+// openAPIViewerHTML is a dependency-free document viewer with a request console,
+// so the docs work without network access to a CDN.
+const openAPIViewerHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{title}}</title>
+<style>
+body{font-family:system-ui,-apple-system,Segoe UI,Roboto,sans-serif;margin:0;background:#fafafa;color:#222}
+header{background:#1f2937;color:#fff;padding:16px 24px}
+header h1{margin:0;font-size:20px}header p{margin:4px 0 0;color:#cbd5e1}
+main{max-width:1100px;margin:0 auto;padding:16px 24px}
+h2{font-size:16px;margin:24px 0 8px;text-transform:uppercase;color:#555}
+details{background:#fff;border:1px solid #ddd;border-radius:4px;margin:6px 0}
+summary{cursor:pointer;padding:8px 12px;display:flex;gap:12px;align-items:center}
+.m{font-weight:700;font-size:12px;color:#fff;border-radius:3px;padding:3px 8px;min-width:56px;text-align:center}
+.get{background:#2563eb}.post{background:#16a34a}.put{background:#d97706}.patch{background:#0d9488}.delete{background:#dc2626}.other{background:#6b7280}
+.path{font-family:ui-monospace,monospace}.sum{color:#666}.dep{text-decoration:line-through}
+.body{padding:8px 16px 16px;border-top:1px solid #eee}
+table{border-collapse:collapse;width:100%;font-size:14px}td,th{text-align:left;padding:4px 8px;border-bottom:1px solid #eee;vertical-align:top}
+pre{background:#f3f4f6;padding:8px;overflow:auto;font-size:13px;margin:4px 0}
+input,textarea{font-family:ui-monospace,monospace;font-size:13px;width:100%;box-sizing:border-box}
+button{margin-top:8px;padding:6px 14px;cursor:pointer}
+.err{color:#dc2626}
+</style>
+</head>
+<body>
+<header><h1 id="title">{{title}}</h1><p id="desc"></p></header>
+<main id="ops"><p>Loading…</p></main>
+<script>
+(function(){
+var specURL = {{spec}};
+var spec;
+function el(tag, attrs, children){
+  var e = document.createElement(tag);
+  for (var k in attrs||{}) { if (k === "text") e.textContent = attrs[k]; else e.setAttribute(k, attrs[k]); }
+  (children||[]).forEach(function(c){ if (c) e.appendChild(c); });
+  return e;
+}
+function resolve(s, depth){
+  if (!s) return {};
+  if (s.$ref) {
+    if (depth > 6) return {$ref: s.$ref};
+    var name = s.$ref.split("/").pop();
+    var target = (spec.components && spec.components.schemas || {})[name] || {};
+    return resolve(Object.assign({}, target, s, {$ref: undefined}), depth + 1);
+  }
+  var out = Object.assign({}, s);
+  if (s.properties) { out.properties = {}; for (var k in s.properties) out.properties[k] = resolve(s.properties[k], depth + 1); }
+  if (s.items) out.items = resolve(s.items, depth + 1);
+  if (s.additionalProperties) out.additionalProperties = resolve(s.additionalProperties, depth + 1);
+  return out;
+}
+function example(s, depth){
+  s = resolve(s, 0);
+  if (depth > 6) return null;
+  if (s.default !== undefined) return s.default;
+  if (s.enum) return s.enum[0];
+  switch (s.type) {
+  case "object":
+    var o = {}; for (var k in s.properties||{}) o[k] = example(s.properties[k], depth + 1); return o;
+  case "array": return [example(s.items, depth + 1)];
+  case "integer": case "number": return s.minimum || 0;
+  case "boolean": return false;
+  case "string": return s.format === "date-time" ? new Date().toISOString() : s.format === "email" ? "user@example.com" : "string";
+  }
+  return null;
+}
+function schemaBlock(s){ return el("pre", {text: JSON.stringify(resolve(s, 0), null, 2)}); }
+function operation(path, method, op){
+  var cls = ["get","post","put","patch","delete"].indexOf(method) >= 0 ? method : "other";
+  var sum = el("summary", {}, [
+    el("span", {"class": "m " + cls, text: method.toUpperCase()}),
+    el("span", {"class": "path" + (op.deprecated ? " dep" : ""), text: path}),
+    el("span", {"class": "sum", text: op.summary || ""})
+  ]);
+  var body = el("div", {"class": "body"});
+  if (op.description) body.appendChild(el("p", {text: op.description}));
+  var inputs = {};
+  if (op.parameters && op.parameters.length) {
+    var rows = op.parameters.map(function(p){
+      var input = el("input", {placeholder: p.schema && p.schema.default !== undefined ? String(p.schema.default) : ""});
+      inputs[p.in + ":" + p.name] = input;
+      return el("tr", {}, [
+        el("td", {text: p.name + (p.required ? " *" : "")}), el("td", {text: p.in}),
+        el("td", {text: (p.schema && (p.schema.type || "")) + (p.description ? " - " + p.description : "")}),
+        el("td", {}, [input])
+      ]);
+    });
+    body.appendChild(el("h4", {text: "Parameters"}));
+    body.appendChild(el("table", {}, [el("tr", {}, ["Name","In","Schema","Value"].map(function(h){ return el("th", {text: h}); }))].concat(rows)));
+  }
+  var bodyInput, bodyType;
+  if (op.requestBody) {
+    body.appendChild(el("h4", {text: "Request body"}));
+    for (var ct in op.requestBody.content) {
+      body.appendChild(el("div", {text: ct}));
+      body.appendChild(schemaBlock(op.requestBody.content[ct].schema));
+      if (!bodyType && ct.indexOf("json") >= 0) {
+        bodyType = ct;
+        bodyInput = el("textarea", {rows: 6});
+        bodyInput.value = JSON.stringify(example(op.requestBody.content[ct].schema, 0), null, 2);
+        body.appendChild(bodyInput);
+      }
+    }
+  }
+  body.appendChild(el("h4", {text: "Responses"}));
+  Object.keys(op.responses||{}).sort().forEach(function(code){
+    var r = op.responses[code];
+    body.appendChild(el("div", {text: code + " " + (r.description || "")}));
+    for (var ct in r.content||{}) body.appendChild(schemaBlock(r.content[ct].schema));
+  });
+  var result = el("pre", {text: ""});
+  var send = el("button", {text: "Send request"});
+  send.onclick = function(){
+    var url = path, query = [], headers = {};
+    (op.parameters||[]).forEach(function(p){
+      var v = inputs[p.in + ":" + p.name].value;
+      if (v === "") return;
+      if (p.in === "path") url = url.replace("{" + p.name + "}", encodeURIComponent(v));
+      else if (p.in === "query") query.push(encodeURIComponent(p.name) + "=" + encodeURIComponent(v));
+      else if (p.in === "header") headers[p.name] = v;
+    });
+    if (query.length) url += "?" + query.join("&");
+    var base = spec.servers && spec.servers.length ? spec.servers[0].url.replace(/\/$/, "") : "";
+    var init = {method: method.toUpperCase(), headers: headers};
+    if (bodyInput) { init.body = bodyInput.value; headers["Content-Type"] = bodyType; }
+    result.textContent = "…";
+    fetch(base + url, init).then(function(resp){
+      return resp.text().then(function(text){
+        try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (e) {}
+        result.textContent = resp.status + " " + resp.statusText + "\n\n" + text;
+      });
+    }).catch(function(err){ result.textContent = String(err); });
+  };
+  body.appendChild(send);
+  body.appendChild(result);
+  return el("details", {}, [sum, body]);
+}
+function render(){
+  document.getElementById("title").textContent = spec.info.title + " " + spec.info.version;
+  document.getElementById("desc").textContent = spec.info.description || "";
+  var groups = {};
+  Object.keys(spec.paths||{}).sort().forEach(function(path){
+    var item = spec.paths[path];
+    Object.keys(item).forEach(function(method){
+      var op = item[method];
+      (op.tags && op.tags.length ? op.tags : ["default"]).forEach(function(tag){
+        (groups[tag] = groups[tag] || []).push(operation(path, method, op));
+      });
+    });
+  });
+  var root = document.getElementById("ops");
+  root.textContent = "";
+  Object.keys(groups).sort().forEach(function(tag){
+    root.appendChild(el("h2", {text: tag}));
+    groups[tag].forEach(function(d){ root.appendChild(d); });
+  });
+}
+fetch(specURL).then(function(r){ return r.json(); }).then(function(s){ spec = s; render(); })
+  .catch(function(err){ document.getElementById("ops").appendChild(el("p", {"class": "err", text: "Failed to load " + specURL + ": " + err})); });
+})();
+</script>
+</body>
+</html>
+`
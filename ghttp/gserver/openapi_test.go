@@ -0,0 +1,142 @@
+package gserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type openAPIUser struct {
+	ID        int          `json:"id"`
+	Name      string       `json:"name" validate:"required,min=2"`
+	Role      string       `json:"role,omitempty" validate:"omitempty,oneof=admin user"`
+	CreatedAt time.Time    `json:"created_at"`
+	Manager   *openAPIUser `json:"manager,omitempty"`
+}
+
+type openAPIUpdateUser struct {
+	ID      int    `uri:"id"`
+	Notify  bool   `query:"notify,default=true"`
+	TraceID string `header:"X-Trace-Id" doc:"correlation id"`
+	Name    string `json:"name" validate:"required,max=32"`
+}
+
+func TestOpenAPIDocumentFromRoutes(t *testing.T) {
+	server := NewServer()
+	server.Doc(&Operation{
+		Summary:   "Update user",
+		Tags:      []string{"users"},
+		Request:   openAPIUpdateUser{},
+		Response:  openAPIUser{},
+		Responses: map[int]interface{}{http.StatusNotFound: nil},
+	}).PUT("/users/:id", func(c *Context) {})
+	server.GET("/files/*path", func(c *Context) {})
+	server.ServeOpenAPI(OpenAPIConfig{Title: "Users", Version: "2.0.0"})
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var doc OpenAPIDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if doc.OpenAPI != OpenAPIVersion || doc.Info.Title != "Users" {
+		t.Fatalf("unexpected header %+v", doc.Info)
+	}
+	if _, ok := doc.Paths["/openapi.json"]; ok {
+		t.Fatal("documentation routes should be hidden")
+	}
+
+	op := doc.Paths["/users/{id}"]["put"]
+	if op == nil {
+		t.Fatalf("missing PUT /users/{id} in %v", doc.Paths)
+	}
+	params := map[string]*OpenAPIParameter{}
+	for _, p := range op.Parameters {
+		params[p.In+":"+p.Name] = p
+	}
+	if p := params["path:id"]; p == nil || !p.Required || p.Schema.Type != "integer" {
+		t.Fatalf("unexpected path parameter %+v", p)
+	}
+	if p := params["query:notify"]; p == nil || p.Schema.Default != true {
+		t.Fatalf("unexpected query parameter %+v", p)
+	}
+	if p := params["header:X-Trace-Id"]; p == nil || p.Description != "correlation id" {
+		t.Fatalf("unexpected header parameter %+v", p)
+	}
+
+	body := op.RequestBody.Content[MIMEJSON].Schema
+	bodySchema := doc.Components.Schemas[strings.TrimPrefix(body.Ref, "#/components/schemas/")]
+	if bodySchema == nil || len(bodySchema.Properties) != 1 || bodySchema.Properties["name"].MaxLength == nil {
+		t.Fatalf("request body should only hold json fields, got %+v", bodySchema)
+	}
+
+	user := doc.Components.Schemas["openAPIUser"]
+	if user == nil || user.Properties["created_at"].Format != "date-time" ||
+		user.Properties["manager"].Ref != "#/components/schemas/openAPIUser" {
+		t.Fatalf("unexpected user schema %+v", user)
+	}
+	if len(user.Required) != 1 || user.Required[0] != "name" || len(user.Properties["role"].Enum) != 2 {
+		t.Fatalf("validate tags not reflected: %+v", user)
+	}
+	if _, ok := op.Responses["404"]; !ok || op.Responses["200"].Content == nil {
+		t.Fatalf("unexpected responses %+v", op.Responses)
+	}
+
+	if files := doc.Paths["/files/{path}"]["get"]; files == nil || len(files.Parameters) != 1 {
+		t.Fatalf("undocumented route should still list its path parameter: %+v", files)
+	}
+}
+
+func TestOpenAPIServesYAMLAndViewer(t *testing.T) {
+	server := NewServer()
+	server.GET("/ping", func(c *Context) {})
+	server.ServeOpenAPI(OpenAPIConfig{Title: "<Ping>", Version: "1.0"})
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.yaml", nil))
+	yamlDoc := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(yamlDoc, "openapi: 3.1.0") || !strings.Contains(yamlDoc, `version: "1.0"`) {
+		t.Fatalf("unexpected yaml document (%d):\n%s", rec.Code, yamlDoc)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	page := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(page, "&lt;Ping&gt;") || !strings.Contains(page, `"/openapi.json"`) {
+		t.Fatalf("unexpected viewer page (%d)", rec.Code)
+	}
+	if strings.Contains(page, "http://") || strings.Contains(page, "https://") {
+		t.Fatal("viewer must not load remote assets")
+	}
+}
+
+func TestOpenAPIConstrainedAndOptionalPaths(t *testing.T) {
+	server := NewServer()
+	server.Doc(&Operation{OperationID: "listPosts"}).GET("/users/{id:int}/posts/{page?:uint}", func(c *Context) {})
+	server.Group("/v2").(Documenter).Doc(&Operation{OperationID: "getUser"}).GET("/users/{id}", func(c *Context) {})
+	doc := server.OpenAPI(OpenAPIConfig{Title: "t", Version: "1"})
+	if op := doc.Paths["/v2/users/{id}"]["get"]; op == nil || op.OperationID != "getUser" {
+		t.Fatalf("groups must be documented through Documenter, got %v", doc.Paths)
+	}
+
+	short := doc.Paths["/users/{id}/posts"]["get"]
+	full := doc.Paths["/users/{id}/posts/{page}"]["get"]
+	if short == nil || full == nil {
+		t.Fatalf("expected both optional variants, got %v", doc.Paths)
+	}
+	if short.OperationID != "" || full.OperationID != "listPosts" {
+		t.Fatalf("operation id must only be kept on the full variant: %q %q", short.OperationID, full.OperationID)
+	}
+	if len(short.Parameters) != 1 || short.Parameters[0].Schema.Type != "integer" {
+		t.Fatalf("unexpected parameters %+v", short.Parameters)
+	}
+	if len(full.Parameters) != 2 || full.Parameters[1].Schema.Minimum == nil {
+		t.Fatalf("unexpected parameters %+v", full.Parameters)
+	}
+}
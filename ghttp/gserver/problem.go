@@ -0,0 +1,89 @@
+package gserver
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sofiworker/gk/gerr"
+)
+
+// Problem returns a Result rendering err as RFC 9457 problem details. The status
+// comes from the *gerr.Err in the chain or the gerr registry, see gerr.From.
+func Problem(err error) Result {
+	return &ErrorResult{Err: err}
+}
+
+// AbortWithError renders err as problem details and stops the handler chain.
+func (c *Context) AbortWithError(err error) {
+	Problem(err).Execute(c)
+	c.Abort()
+}
+
+// problem builds the problem document of an ErrorResult.
+func (r *ErrorResult) problem() *gerr.Err {
+	p := gerr.From(r.Err)
+	if p == nil {
+		p = &gerr.Err{}
+	}
+	if r.Code != 0 {
+		p.Status = r.Code
+	}
+	p.Status = p.HTTPStatus()
+	if r.Msg != "" {
+		p.Message = r.Msg
+	}
+	if p.Message == "" {
+		p.Message = http.StatusText(p.Status)
+	}
+	var verrs ValidationErrors
+	if errors.As(r.Err, &verrs) {
+		p = p.WithDetail("errors", verrs)
+	}
+	return p
+}
+
+// writeProblem writes p as application/problem+xml when the client prefers XML,
+// else as application/problem+json.
+func writeProblem(c *Context, p *gerr.Err) {
+	var (
+		body []byte
+		err  error
+		ct   string
+	)
+	if prefersXML(c.requestHeader("Accept")) {
+		ct = gerr.MediaTypeProblemXML
+		body, err = xml.Marshal(p)
+		if err == nil {
+			body = append([]byte(xml.Header), body...)
+		}
+	} else {
+		ct = gerr.MediaTypeProblemJSON
+		body, err = json.Marshal(p)
+	}
+	if err != nil {
+		if c.logger != nil {
+			c.logger.Warnf("encode problem details: %v", err)
+		}
+		c.String(p.Status, "%s", p.Message)
+		return
+	}
+	c.Data(p.Status, ct, body)
+}
+
+// prefersXML reports whether accept ranks an XML media type above JSON.
+func prefersXML(accept string) bool {
+	var jsonQ, xmlQ float64
+	for _, part := range strings.Split(accept, ",") {
+		name, q := parseQuality(part)
+		switch name {
+		case gerr.MediaTypeProblemXML, "application/xml", "text/xml":
+			xmlQ = max(xmlQ, q)
+		case gerr.MediaTypeProblemJSON, "application/json", "application/*", "*/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return xmlQ > jsonQ
+}
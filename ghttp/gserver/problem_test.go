@@ -0,0 +1,84 @@
+package gserver
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sofiworker/gk/gerr"
+)
+
+var errProblemTestMissing = errors.New("problem test: missing")
+
+func TestProblemRendering(t *testing.T) {
+	gerr.Register(errProblemTestMissing, http.StatusNotFound)
+	errQuota := gerr.New(http.StatusTooManyRequests, "quota_exceeded", "quota exceeded")
+
+	server := NewServer()
+	server.GET("/registered", Wrap(func(c *Context) Result {
+		return Error(fmt.Errorf("load user: %w", errProblemTestMissing))
+	}))
+	server.GET("/typed", func(c *Context) {
+		c.AbortWithError(errQuota.WithDetail("limit", 10).WithInstance("/quota/1"))
+	})
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/registered", nil))
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != gerr.MediaTypeProblemJSON {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var p gerr.Err
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != http.StatusNotFound || p.Title != "Not Found" || p.Message != "load user: problem test: missing" {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/typed", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(&p, errQuota) || p.Instance != "/quota/1" || p.Details["limit"] != float64(10) {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/typed", nil)
+	req.Header.Set("Accept", "application/xml, application/json;q=0.5")
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Type") != gerr.MediaTypeProblemXML {
+		t.Fatalf("expected problem+xml, got %q", rec.Header().Get("Content-Type"))
+	}
+	p = gerr.Err{}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Code != "quota_exceeded" || p.Status != http.StatusTooManyRequests || p.Details["limit"] != "10" {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+}
+
+func TestPrefersXML(t *testing.T) {
+	cases := map[string]bool{
+		"":                                  false,
+		"*/*":                               false,
+		"application/problem+xml":           true,
+		"text/xml, */*;q=0.1":               true,
+		"application/xml;q=0.5, */*":        false,
+		"application/json, application/xml": false,
+	}
+	for accept, want := range cases {
+		if got := prefersXML(accept); got != want {
+			t.Errorf("prefersXML(%q) = %v, want %v", accept, got, want)
+		}
+	}
+}
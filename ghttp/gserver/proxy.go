@@ -0,0 +1,475 @@
+package gserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sofiworker/gk/glb"
+)
+
+// ErrNoUpstream is reported to ProxyConfig.ErrorHandler when the load balancer has
+// no instance left to try.
+var ErrNoUpstream = errors.New("no upstream instance available")
+
+// hopHeaders are connection specific and must not be forwarded (RFC 9110 section 7.6.1).
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+type ProxyConfig struct {
+	// Balancer picks the upstream instance of Service for every attempt. Required.
+	Balancer *glb.LoadBalancer
+	Service  string
+	// Scheme is used for instance addresses without one, default "http".
+	Scheme string
+
+	// StripPrefix is removed from the request path before it is appended to the
+	// instance URL, so a group mounted at /api/users can forward to /.
+	StripPrefix string
+	// PreserveHost forwards the client's Host header instead of the instance address.
+	PreserveHost bool
+	// Rewrite may change the outgoing request, such as its path or headers, after
+	// the default rewriting. It runs once per attempt.
+	Rewrite func(c *Context, out *http.Request)
+	// ModifyResponse may change the upstream response before it is copied. An error
+	// discards the response and is passed to ErrorHandler.
+	ModifyResponse func(c *Context, resp *http.Response) error
+
+	// Transport sends upstream requests, default a clone of http.DefaultTransport
+	// without transparent decompression.
+	Transport http.RoundTripper
+	// TLSConfig and DialTimeout apply to WebSocket and other upgrade tunnels, which
+	// bypass Transport. DialTimeout also bounds the upgrade handshake, default 5s.
+	TLSConfig   *tls.Config
+	DialTimeout time.Duration
+
+	// Retries is how many other instances are tried after a failed attempt, default 2,
+	// negative disables retries. Idempotent requests are retried on any transport
+	// error; other requests only when the connection could not be established.
+	// Streamed request bodies are never retried.
+	Retries int
+	// EjectFor removes an instance from the balancer after a connection failure,
+	// default 10s.
+	EjectFor time.Duration
+
+	// ErrorHandler answers when no upstream produced a response. The default responds
+	// 503 for ErrNoUpstream, 504 for timeouts and 502 otherwise.
+	ErrorHandler func(c *Context, err error) Result
+}
+
+// ReverseProxy forwards requests to the instances of a service chosen by a
+// glb.LoadBalancer. Response bodies are streamed, so server-sent events pass
+// through as they are produced, and WebSocket or other upgrade requests are
+// tunneled over a hijacked connection. X-Forwarded-For, X-Forwarded-Host and
+// X-Forwarded-Proto are appended to every forwarded request.
+func ReverseProxy(cfg ProxyConfig) HandlerFunc {
+	if cfg.Balancer == nil {
+		panic("gserver: ReverseProxy requires a load balancer")
+	}
+	if cfg.Scheme == "" {
+		cfg.Scheme = "http"
+	}
+	if cfg.Transport == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DisableCompression = true
+		cfg.Transport = transport
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = 2
+	} else if cfg.Retries < 0 {
+		cfg.Retries = 0
+	}
+	if cfg.EjectFor == 0 {
+		cfg.EjectFor = 10 * time.Second
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = defaultProxyErrorHandler
+	}
+	p := &reverseProxy{cfg: cfg}
+	return p.serve
+}
+
+type reverseProxy struct {
+	cfg ProxyConfig
+}
+
+func (p *reverseProxy) serve(c *Context) {
+	req := &c.fastCtx.Request
+	var body []byte
+	var stream io.Reader
+	if req.IsBodyStream() {
+		stream = c.fastCtx.RequestBodyStream()
+	} else {
+		body = req.Body()
+	}
+	upgrade := ""
+	if headerContainsToken(req.Header.Peek("Connection"), "upgrade") {
+		upgrade = string(req.Header.Peek("Upgrade"))
+	}
+	idempotent := stream == nil && isIdempotentMethod(string(c.fastCtx.Method()))
+
+	ctx := c.Context()
+	err := ErrNoUpstream
+	for attempt := 0; attempt <= p.cfg.Retries; attempt++ {
+		inst, lbErr := p.cfg.Balancer.GetInstance(ctx, p.cfg.Service)
+		if lbErr != nil {
+			break
+		}
+		target, urlErr := instanceURL(inst, p.cfg.Scheme)
+		if urlErr != nil {
+			err = urlErr
+			break
+		}
+
+		out := p.outgoing(c, ctx, target, body, stream, upgrade)
+		if upgrade != "" {
+			err = p.tunnel(c, inst, out)
+		} else {
+			err = p.forward(c, inst, out)
+		}
+		if err == nil {
+			return
+		}
+
+		connFailed := isDialError(err)
+		if connFailed {
+			p.cfg.Balancer.MarkUnhealthy(inst, p.cfg.EjectFor)
+		}
+		if !idempotent && !(connFailed && stream == nil) {
+			break
+		}
+		ctx = glb.WithExclude(ctx, inst.GetAddress())
+	}
+	if result := p.cfg.ErrorHandler(c, err); result != nil {
+		result.Execute(c)
+	}
+	c.Abort()
+}
+
+// outgoing builds the upstream request for target.
+func (p *reverseProxy) outgoing(c *Context, ctx context.Context, target *url.URL, body []byte, stream io.Reader, upgrade string) *http.Request {
+	fastReq := &c.fastCtx.Request
+	path := string(fastReq.URI().PathOriginal())
+	if prefix := strings.TrimSuffix(p.cfg.StripPrefix, "/"); prefix != "" {
+		if rest, ok := strings.CutPrefix(path, prefix); ok && (rest == "" || rest[0] == '/') {
+			path = rest
+		}
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u := *target
+	u.Path, u.RawPath = "", ""
+	rawURL := u.String() + strings.TrimSuffix(target.EscapedPath(), "/") + path
+	if q := fastReq.URI().QueryString(); len(q) > 0 {
+		rawURL += "?" + string(q)
+	}
+	outURL, err := url.Parse(rawURL)
+	if err != nil {
+		outURL = &u
+	}
+
+	out := (&http.Request{
+		Method:     string(c.fastCtx.Method()),
+		URL:        outURL,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       target.Host,
+	}).WithContext(ctx)
+	fastReq.Header.VisitAll(func(k, v []byte) {
+		out.Header.Add(string(k), string(v))
+	})
+	out.Header.Del("Host")
+	removeHopHeaders(out.Header)
+	if upgrade != "" {
+		out.Header.Set("Connection", "Upgrade")
+		out.Header.Set("Upgrade", upgrade)
+	}
+	if _, ok := out.Header["User-Agent"]; !ok {
+		// Keep net/http from adding its default User-Agent.
+		out.Header.Set("User-Agent", "")
+	}
+	if p.cfg.PreserveHost {
+		out.Host = string(c.fastCtx.Host())
+	}
+
+	clientIP := c.RemoteIP()
+	if prior := out.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+		clientIP = strings.Join(prior, ", ") + ", " + clientIP
+	}
+	out.Header.Set("X-Forwarded-For", clientIP)
+	out.Header.Set("X-Forwarded-Host", string(c.fastCtx.Host()))
+	if c.IsTLS() {
+		out.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		out.Header.Set("X-Forwarded-Proto", "http")
+	}
+
+	switch {
+	case stream != nil:
+		out.Body = io.NopCloser(stream)
+		out.ContentLength = int64(fastReq.Header.ContentLength())
+		if out.ContentLength < 0 {
+			out.ContentLength = -1
+		}
+	case len(body) > 0:
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.ContentLength = int64(len(body))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	default:
+		out.Body = http.NoBody
+	}
+
+	if p.cfg.Rewrite != nil {
+		p.cfg.Rewrite(c, out)
+	}
+	return out
+}
+
+func (p *reverseProxy) forward(c *Context, inst glb.Instance, out *http.Request) error {
+	release := p.cfg.Balancer.Acquire(inst)
+	resp, err := p.cfg.Transport.RoundTrip(out)
+	if err != nil {
+		release()
+		return err
+	}
+	p.respond(c, resp, release)
+	return nil
+}
+
+// respond copies resp to the client and streams its body. done runs once the
+// body has been consumed or discarded.
+func (p *reverseProxy) respond(c *Context, resp *http.Response, done func()) {
+	body := &proxyBody{ReadCloser: resp.Body, done: done}
+	if p.cfg.ModifyResponse != nil {
+		if err := p.cfg.ModifyResponse(c, resp); err != nil {
+			_ = body.Close()
+			if result := p.cfg.ErrorHandler(c, err); result != nil {
+				result.Execute(c)
+			}
+			return
+		}
+		body.ReadCloser = resp.Body
+	}
+
+	removeHopHeaders(resp.Header)
+	header := &c.fastCtx.Response.Header
+	header.SetNoDefaultContentType(true)
+	for key, values := range resp.Header {
+		if strings.EqualFold(key, "Content-Length") {
+			continue
+		}
+		for _, v := range values {
+			header.Add(key, v)
+		}
+	}
+	c.Status(resp.StatusCode)
+
+	switch {
+	case c.fastCtx.IsHead() || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified:
+		_ = body.Close()
+		if resp.ContentLength >= 0 && c.fastCtx.IsHead() {
+			header.SetContentLength(int(resp.ContentLength))
+		}
+	case resp.ContentLength >= 0 && len(c.streamFilters) == 0:
+		c.fastCtx.SetBodyStream(body, int(resp.ContentLength))
+	default:
+		// Unknown lengths are usually streams such as server-sent events, so every
+		// chunk is flushed as soon as the upstream produces it.
+		c.setBodyStreamWriter(flushingStreamWriter(body))
+	}
+}
+
+// tunnel sends an upgrade request over a dedicated connection and, when the
+// upstream switches protocols, splices it with the hijacked client connection.
+func (p *reverseProxy) tunnel(c *Context, inst glb.Instance, out *http.Request) error {
+	conn, err := p.dial(out.Context(), out.URL)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(p.cfg.DialTimeout))
+	if err := out.Write(conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, out)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		p.respond(c, resp, func() { _ = conn.Close() })
+		return nil
+	}
+
+	balancer := p.cfg.Balancer
+	c.fastCtx.HijackSetNoResponse(true)
+	c.fastCtx.Hijack(func(client net.Conn) {
+		release := balancer.Acquire(inst)
+		defer release()
+		defer conn.Close()
+
+		var head bytes.Buffer
+		fmt.Fprintf(&head, "HTTP/1.1 %s\r\n", resp.Status)
+		_ = resp.Header.Write(&head)
+		head.WriteString("\r\n")
+		if _, err := client.Write(head.Bytes()); err != nil {
+			return
+		}
+
+		var once sync.Once
+		finished := make(chan struct{})
+		pipe := func(dst io.Writer, src io.Reader) {
+			_, _ = io.Copy(dst, src)
+			once.Do(func() { close(finished) })
+		}
+		go pipe(conn, client)
+		go pipe(client, br)
+		<-finished
+	})
+	return nil
+}
+
+func (p *reverseProxy) dial(ctx context.Context, u *url.URL) (net.Conn, error) {
+	secure := u.Scheme == "https" || u.Scheme == "wss"
+	host := u.Host
+	if u.Port() == "" {
+		if secure {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	dialer := &net.Dialer{Timeout: p.cfg.DialTimeout}
+	if !secure {
+		return dialer.DialContext(ctx, "tcp", host)
+	}
+	tlsConfig := &tls.Config{}
+	if p.cfg.TLSConfig != nil {
+		tlsConfig = p.cfg.TLSConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = u.Hostname()
+	}
+	return (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", host)
+}
+
+// proxyBody runs done exactly once when the upstream body is closed.
+type proxyBody struct {
+	io.ReadCloser
+	done func()
+	once sync.Once
+}
+
+func (b *proxyBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// flushingStreamWriter copies r into the response and flushes after every read.
+// The reader is closed afterwards when it implements io.Closer.
+func flushingStreamWriter(r io.Reader) func(w *bufio.Writer) {
+	return func(w *bufio.Writer) {
+		if closer, ok := r.(io.Closer); ok {
+			defer closer.Close()
+		}
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				if _, werr := w.Write(buf[:n]); werr != nil {
+					return
+				}
+				if werr := w.Flush(); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func instanceURL(inst glb.Instance, scheme string) (*url.URL, error) {
+	addr := inst.GetAddress()
+	if strings.Contains(addr, "://") {
+		return url.Parse(addr)
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("upstream instance has no address")
+	}
+	return &url.URL{Scheme: scheme, Host: addr}, nil
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, v := range h.Values("Connection") {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				h.Del(field)
+			}
+		}
+	}
+	for _, key := range hopHeaders {
+		h.Del(key)
+	}
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isDialError reports whether err happened before a connection was established,
+// in which case the upstream has not seen the request.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func defaultProxyErrorHandler(c *Context, err error) Result {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrNoUpstream):
+		return ErrorStatusCode(http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorStatusCode(http.StatusGatewayTimeout, http.StatusText(http.StatusGatewayTimeout))
+	}
+	if c.logger != nil {
+		c.logger.Warnf("reverse proxy: %v", err)
+	}
+	return ErrorStatusCode(http.StatusBadGateway, http.StatusText(http.StatusBadGateway))
+}
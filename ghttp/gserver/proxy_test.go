@@ -0,0 +1,231 @@
+package gserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sofiworker/gk/glb"
+)
+
+type staticDiscovery []glb.Instance
+
+func (d staticDiscovery) GetInstances(string) ([]glb.Instance, error) { return d, nil }
+
+func (d staticDiscovery) Watch(string) (<-chan []glb.Instance, error) {
+	return make(chan []glb.Instance), nil
+}
+
+func newTestBalancer(addrs ...string) *glb.LoadBalancer {
+	var instances staticDiscovery
+	for _, addr := range addrs {
+		instances = append(instances, &glb.BaseInstance{Address: addr, Healthy: true, Weight: 1})
+	}
+	return glb.NewLoadBalancer(instances, glb.NewRoundRobinStrategy())
+}
+
+// closedAddr returns a local address that refuses connections.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	return addr
+}
+
+func TestReverseProxyForwards(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Path", r.URL.RequestURI())
+		w.Header().Set("X-Forwarded-For-Seen", r.Header.Get("X-Forwarded-For"))
+		w.Header().Set("X-Forwarded-Host-Seen", r.Header.Get("X-Forwarded-Host"))
+		w.Header().Set("X-Tenant-Seen", r.Header.Get("X-Tenant"))
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	}))
+	defer upstream.Close()
+
+	server := NewServer()
+	server.ANY("/api/*path", ReverseProxy(ProxyConfig{
+		Balancer:    newTestBalancer(upstream.Listener.Addr().String()),
+		Service:     "users",
+		StripPrefix: "/api",
+		Rewrite: func(c *Context, out *http.Request) {
+			out.Header.Set("X-Tenant", "acme")
+		},
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://gateway.example/api/users/1?expand=true", strings.NewReader("payload"))
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != "payload" {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Upstream-Path"); got != "/users/1?expand=true" {
+		t.Fatalf("unexpected upstream path %q", got)
+	}
+	if got := rec.Header().Get("X-Forwarded-For-Seen"); !strings.HasPrefix(got, "10.0.0.1, ") {
+		t.Fatalf("X-Forwarded-For must be appended, got %q", got)
+	}
+	if got := rec.Header().Get("X-Forwarded-Host-Seen"); got != "gateway.example" {
+		t.Fatalf("unexpected X-Forwarded-Host %q", got)
+	}
+	if got := rec.Header().Get("X-Tenant-Seen"); got != "acme" {
+		t.Fatalf("rewrite hook not applied, got %q", got)
+	}
+}
+
+func TestReverseProxyRetriesAndEjects(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer upstream.Close()
+
+	dead := closedAddr(t)
+	balancer := newTestBalancer(dead, upstream.Listener.Addr().String())
+	server := NewServer()
+	server.ANY("/*path", ReverseProxy(ProxyConfig{Balancer: balancer, Service: "svc"}))
+
+	for i := 0; i < 4; i++ {
+		method := http.MethodGet
+		if i%2 == 1 {
+			// Connection failures are retried for any method since nothing was sent.
+			method = http.MethodPost
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(method, "/ping", strings.NewReader("x")))
+		if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+			t.Fatalf("request %d: unexpected response %d %q", i, rec.Code, rec.Body.String())
+		}
+	}
+	if hits.Load() != 4 {
+		t.Fatalf("expected 4 upstream hits, got %d", hits.Load())
+	}
+
+	inst, err := balancer.GetInstance(context.Background(), "svc")
+	if err != nil || inst.GetAddress() == dead {
+		t.Fatalf("the dead instance must be ejected, got %v %v", inst, err)
+	}
+}
+
+func TestReverseProxyNoUpstream(t *testing.T) {
+	server := NewServer()
+	server.GET("/down", ReverseProxy(ProxyConfig{Balancer: newTestBalancer(), Service: "svc"}))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/down", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+
+	dead := closedAddr(t)
+	server = NewServer()
+	server.GET("/down", ReverseProxy(ProxyConfig{Balancer: newTestBalancer(dead), Service: "svc"}))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/down", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", rec.Code)
+	}
+}
+
+func TestReverseProxyStreamsEvents(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = io.WriteString(w, "data: second\n\n")
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	server := NewServer()
+	server.GET("/events", ReverseProxy(ProxyConfig{
+		Balancer: newTestBalancer(upstream.Listener.Addr().String()),
+		Service:  "events",
+	}))
+	ln := startInmemoryServer(t, server)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(context.Context, string, string) (net.Conn, error) { return ln.Dial() },
+	}}
+	resp, err := client.Get("http://gateway.example/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	// The first event must arrive while the upstream is still blocked.
+	line := make(chan string, 1)
+	go func() {
+		s, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		line <- s
+	}()
+	select {
+	case s := <-line:
+		if s != "data: first\n" {
+			t.Fatalf("unexpected event %q", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event was not streamed")
+	}
+}
+
+func TestReverseProxyWebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = conn.WriteMessage(mt, []byte(fmt.Sprintf("echo %s via %s", msg, r.Header.Get("X-Forwarded-Proto"))))
+		}
+	}))
+	defer upstream.Close()
+
+	server := NewServer()
+	server.GET("/ws", ReverseProxy(ProxyConfig{
+		Balancer: newTestBalancer(upstream.Listener.Addr().String()),
+		Service:  "ws",
+	}))
+	ln := startInmemoryServer(t, server)
+
+	conn := dialWebSocket(t, ln, "/ws")
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg) != "echo hi via http" {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
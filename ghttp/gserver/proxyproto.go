@@ -0,0 +1,422 @@
+package gserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidProxyHeader is returned when a connection starts with a malformed PROXY
+// protocol header, or without one when the header is required.
+var ErrInvalidProxyHeader = errors.New("gserver: invalid PROXY protocol header")
+
+// ErrProxyTrustedRequired is returned for a ProxyProtocolConfig without Trusted.
+var ErrProxyTrustedRequired = errors.New("gserver: ProxyProtocolConfig.Trusted is required")
+
+// proxyV2Signature starts every PROXY protocol v2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// PROXY protocol v2 TLV types.
+const (
+	pp2TypeALPN      = 0x01
+	pp2TypeAuthority = 0x02
+	pp2TypeUniqueID  = 0x05
+	pp2TypeSSL       = 0x20
+	pp2SubTypeSSLVer = 0x21
+	pp2SubTypeSSLCN  = 0x22
+	pp2SubTypeCipher = 0x23
+	pp2SubTypeSigAlg = 0x24
+	pp2SubTypeKeyAlg = 0x25
+
+	pp2ClientSSL      = 0x01
+	pp2ClientCertConn = 0x02
+	pp2ClientCertSess = 0x04
+)
+
+// ProxyProtocolConfig enables the HAProxy PROXY protocol, v1 and v2, on the
+// listeners of Run, RunTLS and RunListener.
+type ProxyProtocolConfig struct {
+	// Trusted lists the CIDRs or IPs allowed to send a PROXY header; connections
+	// from other peers are served as they are. It is required, since a trusted
+	// peer chooses the client address the server sees: list only the proxies.
+	// "0.0.0.0/0" and "::/0" trust every peer, for ports only proxies can reach.
+	Trusted []string
+	// Optional also accepts trusted connections that do not start with a header.
+	Optional bool
+	// HeaderTimeout bounds reading the header. Default 5s.
+	HeaderTimeout time.Duration
+}
+
+type proxyProtocol struct {
+	trusted  []*net.IPNet
+	optional bool
+	timeout  time.Duration
+}
+
+func newProxyProtocol(cfg ProxyProtocolConfig) (*proxyProtocol, error) {
+	if len(cfg.Trusted) == 0 {
+		return nil, ErrProxyTrustedRequired
+	}
+	trusted, err := parseCIDRs(cfg.Trusted)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HeaderTimeout <= 0 {
+		cfg.HeaderTimeout = 5 * time.Second
+	}
+	return &proxyProtocol{trusted: trusted, optional: cfg.Optional, timeout: cfg.HeaderTimeout}, nil
+}
+
+// WithProxyProtocol makes the server read a PROXY protocol header in front of each
+// connection, so ClientIP, RemoteIP and ProxyHeader report the original client.
+// It panics on an empty or invalid Trusted.
+func WithProxyProtocol(cfg ProxyProtocolConfig) ServerOption {
+	pp, err := newProxyProtocol(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return func(c *Config) {
+		c.proxyProtocol = pp
+	}
+}
+
+// NewProxyProtocolListener wraps l to read a PROXY protocol header in front of each
+// accepted connection. The header is read on the first Read or RemoteAddr call.
+func NewProxyProtocolListener(l net.Listener, cfg ProxyProtocolConfig) (net.Listener, error) {
+	pp, err := newProxyProtocol(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyListener{Listener: l, pp: pp}, nil
+}
+
+// ProxyHeader is the information sent by a proxy in a PROXY protocol header.
+type ProxyHeader struct {
+	// Version is 1 or 2.
+	Version int
+	// Local is set for v2 LOCAL connections, such as health checks of the proxy
+	// itself, and for v1 UNKNOWN; Source and Destination are nil then.
+	Local       bool
+	Source      net.Addr
+	Destination net.Addr
+
+	// The fields below come from v2 TLVs.
+	ALPN      string
+	Authority string
+	UniqueID  []byte
+	// TLS is set when the client connected to the proxy over TLS.
+	TLS *ProxyTLS
+}
+
+// ProxyTLS describes the TLS connection between the client and the proxy.
+type ProxyTLS struct {
+	Version    string
+	Cipher     string
+	SigAlg     string
+	KeyAlg     string
+	CommonName string
+	// ClientCert reports that the client presented a certificate, and Verified
+	// that the proxy verified it successfully.
+	ClientCert bool
+	Verified   bool
+}
+
+// ProxyHeader returns the PROXY protocol header of the connection, or nil.
+func (c *Context) ProxyHeader() *ProxyHeader {
+	if c.fastCtx == nil {
+		return nil
+	}
+	pc := proxyConnOf(c.fastCtx.Conn())
+	if pc == nil {
+		return nil
+	}
+	pc.init()
+	return pc.header
+}
+
+// proxyConnOf finds the proxyConn under conn, unwrapping TLS connections.
+func proxyConnOf(conn net.Conn) *proxyConn {
+	for conn != nil {
+		switch c := conn.(type) {
+		case *proxyConn:
+			return c
+		case interface{ NetConn() net.Conn }:
+			if inner := c.NetConn(); inner != conn {
+				conn = inner
+				continue
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// IsTLS reports whether the client connected over TLS, either to this server or,
+// as told by a PROXY protocol v2 header, to the proxy in front of it.
+func (c *Context) IsTLS() bool {
+	if c.fastCtx.IsTLS() {
+		return true
+	}
+	h := c.ProxyHeader()
+	return h != nil && h.TLS != nil
+}
+
+func (s *Server) wrapListener(l net.Listener) net.Listener {
+	if s.proxyProtocol == nil {
+		return l
+	}
+	return &proxyListener{Listener: l, pp: s.proxyProtocol}
+}
+
+type proxyListener struct {
+	net.Listener
+	pp *proxyProtocol
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: conn, pp: l.pp}, nil
+}
+
+type proxyConn struct {
+	net.Conn
+	pp *proxyProtocol
+
+	once   sync.Once
+	br     *bufio.Reader
+	header *ProxyHeader
+	remote net.Addr
+	err    error
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.remote = c.Conn.RemoteAddr()
+		if len(c.pp.trusted) > 0 && !containsIP(c.pp.trusted, addrIP(c.remote)) {
+			return
+		}
+		c.br = bufio.NewReader(c.Conn)
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.pp.timeout))
+		c.header, c.err = readProxyHeader(c.br, c.pp.optional)
+		_ = c.Conn.SetReadDeadline(time.Time{})
+		if c.header == nil {
+			return
+		}
+		if c.header.Source != nil {
+			c.remote = c.header.Source
+		}
+	})
+}
+
+func (c *proxyConn) Read(p []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.br != nil {
+		return c.br.Read(p)
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	return c.remote
+}
+
+// readProxyHeader reads a v1 or v2 header. It returns nil without error when the
+// connection has no header and optional is set.
+func readProxyHeader(br *bufio.Reader, optional bool) (*ProxyHeader, error) {
+	prefix, err := br.Peek(len(proxyV2Signature))
+	switch {
+	case bytes.Equal(prefix, proxyV2Signature):
+		return readProxyV2(br)
+	case bytes.HasPrefix(prefix, []byte("PROXY ")):
+		return readProxyV1(br)
+	case optional:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return nil, ErrInvalidProxyHeader
+}
+
+// readProxyV1 parses "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n".
+func readProxyV1(br *bufio.Reader) (*ProxyHeader, error) {
+	var line []byte
+	for len(line) < 107 {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidProxyHeader
+	}
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return &ProxyHeader{Version: 1, Local: true}, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidProxyHeader
+	}
+	src, dst := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	sport, err1 := strconv.ParseUint(fields[4], 10, 16)
+	dport, err2 := strconv.ParseUint(fields[5], 10, 16)
+	if src == nil || dst == nil || err1 != nil || err2 != nil {
+		return nil, ErrInvalidProxyHeader
+	}
+	return &ProxyHeader{
+		Version:     1,
+		Source:      &net.TCPAddr{IP: src, Port: int(sport)},
+		Destination: &net.TCPAddr{IP: dst, Port: int(dport)},
+	}, nil
+}
+
+func readProxyV2(br *bufio.Reader) (*ProxyHeader, error) {
+	var fixed [16]byte
+	if _, err := io.ReadFull(br, fixed[:]); err != nil {
+		return nil, err
+	}
+	if fixed[12]>>4 != 2 {
+		return nil, fmt.Errorf("%w: version %d", ErrInvalidProxyHeader, fixed[12]>>4)
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return nil, err
+	}
+
+	h := &ProxyHeader{Version: 2}
+	switch fixed[12] & 0x0f {
+	case 0x0:
+		// LOCAL: the proxy speaks for itself and the address block is ignored.
+		h.Local = true
+		return h, nil
+	case 0x1:
+	default:
+		return nil, fmt.Errorf("%w: command %d", ErrInvalidProxyHeader, fixed[12]&0x0f)
+	}
+
+	addr := func(ip net.IP, port uint16) net.Addr {
+		return &net.TCPAddr{IP: ip, Port: int(port)}
+	}
+	var n int
+	switch fixed[13] >> 4 {
+	case 0x1:
+		n = 12
+		if len(payload) < n {
+			return nil, ErrInvalidProxyHeader
+		}
+		h.Source = addr(net.IP(payload[0:4]), binary.BigEndian.Uint16(payload[8:]))
+		h.Destination = addr(net.IP(payload[4:8]), binary.BigEndian.Uint16(payload[10:]))
+	case 0x2:
+		n = 36
+		if len(payload) < n {
+			return nil, ErrInvalidProxyHeader
+		}
+		h.Source = addr(net.IP(payload[0:16]), binary.BigEndian.Uint16(payload[32:]))
+		h.Destination = addr(net.IP(payload[16:32]), binary.BigEndian.Uint16(payload[34:]))
+	case 0x3:
+		n = 216
+		if len(payload) < n {
+			return nil, ErrInvalidProxyHeader
+		}
+		h.Source = &net.UnixAddr{Name: cString(payload[:108]), Net: "unix"}
+		h.Destination = &net.UnixAddr{Name: cString(payload[108:216]), Net: "unix"}
+	default:
+		// UNSPEC: unknown protocol, keep the connection address.
+		h.Local = true
+		return h, nil
+	}
+	if err := h.parseTLVs(payload[n:]); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *ProxyHeader) parseTLVs(b []byte) error {
+	return walkTLVs(b, func(typ byte, value []byte) error {
+		switch typ {
+		case pp2TypeALPN:
+			h.ALPN = string(value)
+		case pp2TypeAuthority:
+			h.Authority = string(value)
+		case pp2TypeUniqueID:
+			h.UniqueID = append([]byte(nil), value...)
+		case pp2TypeSSL:
+			if len(value) < 5 {
+				return ErrInvalidProxyHeader
+			}
+			if value[0]&pp2ClientSSL == 0 {
+				return nil
+			}
+			t := &ProxyTLS{
+				ClientCert: value[0]&(pp2ClientCertConn|pp2ClientCertSess) != 0,
+			}
+			t.Verified = t.ClientCert && binary.BigEndian.Uint32(value[1:5]) == 0
+			h.TLS = t
+			return walkTLVs(value[5:], func(sub byte, v []byte) error {
+				switch sub {
+				case pp2SubTypeSSLVer:
+					t.Version = string(v)
+				case pp2SubTypeSSLCN:
+					t.CommonName = string(v)
+				case pp2SubTypeCipher:
+					t.Cipher = string(v)
+				case pp2SubTypeSigAlg:
+					t.SigAlg = string(v)
+				case pp2SubTypeKeyAlg:
+					t.KeyAlg = string(v)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+func walkTLVs(b []byte, fn func(typ byte, value []byte) error) error {
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return ErrInvalidProxyHeader
+		}
+		n := int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < 3+n {
+			return ErrInvalidProxyHeader
+		}
+		if err := fn(b[0], b[3:3+n]); err != nil {
+			return err
+		}
+		b = b[3+n:]
+	}
+	return nil
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func addrIP(addr net.Addr) net.IP {
+	if a, ok := addr.(*net.TCPAddr); ok {
+		return a.IP
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package gserver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func proxyV2Header(src, dst net.IP, sport, dport uint16, tlvs []byte) []byte {
+	var b bytes.Buffer
+	b.Write(proxyV2Signature)
+	b.WriteByte(0x21) // version 2, PROXY
+	b.WriteByte(0x11) // TCP over IPv4
+	_ = binary.Write(&b, binary.BigEndian, uint16(12+len(tlvs)))
+	b.Write(src.To4())
+	b.Write(dst.To4())
+	_ = binary.Write(&b, binary.BigEndian, sport)
+	_ = binary.Write(&b, binary.BigEndian, dport)
+	b.Write(tlvs)
+	return b.Bytes()
+}
+
+func tlv(typ byte, value []byte) []byte {
+	out := []byte{typ, byte(len(value) >> 8), byte(len(value))}
+	return append(out, value...)
+}
+
+func TestProxyProtocol(t *testing.T) {
+	server := NewServer(WithProxyProtocol(ProxyProtocolConfig{Trusted: []string{"127.0.0.0/8"}}))
+	server.GET("/ip", func(c *Context) {
+		h := c.ProxyHeader()
+		if h == nil {
+			c.String(http.StatusOK, "no header")
+			return
+		}
+		tlsVersion := ""
+		if h.TLS != nil {
+			tlsVersion = h.TLS.Version + " " + h.TLS.CommonName
+		}
+		c.String(http.StatusOK, "%s v%d %s tls=%v %s", c.ClientIP(), h.Version, h.Authority, c.IsTLS(), tlsVersion)
+	})
+	ln := startInmemoryServer(t, server)
+
+	get := func(header []byte) (int, string) {
+		t.Helper()
+		conn, err := ln.DialWithLocalAddr(proxyAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_, _ = conn.Write(append(header, "GET /ip HTTP/1.1\r\nHost: example.com\r\n\r\n"...))
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if code, body := get([]byte("PROXY TCP4 198.51.100.7 203.0.113.1 56324 443\r\n")); code != http.StatusOK || body != "198.51.100.7 v1  tls=false " {
+		t.Fatalf("v1: %d %q", code, body)
+	}
+
+	ssl := append([]byte{0x01 | 0x02, 0, 0, 0, 0}, tlv(0x21, []byte("TLSv1.3"))...)
+	ssl = append(ssl, tlv(0x22, []byte("client.example"))...)
+	tlvs := append(tlv(0x02, []byte("api.example.com")), tlv(0x20, ssl)...)
+	header := proxyV2Header(net.ParseIP("198.51.100.8"), net.ParseIP("203.0.113.1"), 40000, 443, tlvs)
+	if code, body := get(header); code != http.StatusOK || body != "198.51.100.8 v2 api.example.com tls=true TLSv1.3 client.example" {
+		t.Fatalf("v2: %d %q", code, body)
+	}
+
+	// The header is required unless Optional is set.
+	if code, _ := get(nil); code == http.StatusOK {
+		t.Fatal("expected a connection without header to be rejected")
+	}
+}
+
+func TestProxyProtocolOptionalAndUntrusted(t *testing.T) {
+	server := NewServer(WithProxyProtocol(ProxyProtocolConfig{Trusted: []string{"127.0.0.1"}, Optional: true}))
+	server.GET("/ip", func(c *Context) {
+		c.String(http.StatusOK, "%v", c.ProxyHeader() == nil)
+	})
+	ln := startInmemoryServer(t, server)
+	conn, err := ln.DialWithLocalAddr(proxyAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("GET /ip HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "true" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	// Headers from peers outside Trusted are not interpreted.
+	pp, err := newProxyProtocol(ProxyProtocolConfig{Trusted: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, peer := net.Pipe()
+	defer client.Close()
+	c := &proxyConn{Conn: &addrConn{Conn: peer, remote: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1}}, pp: pp}
+	if c.RemoteAddr().String() != "192.0.2.1:1" || c.header != nil {
+		t.Fatalf("untrusted peer must keep its address, got %v", c.RemoteAddr())
+	}
+}
+
+func TestProxyProtocolRequiresTrusted(t *testing.T) {
+	if _, err := newProxyProtocol(ProxyProtocolConfig{}); err != ErrProxyTrustedRequired {
+		t.Fatalf("expected ErrProxyTrustedRequired, got %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("WithProxyProtocol must panic without Trusted")
+		}
+	}()
+	WithProxyProtocol(ProxyProtocolConfig{})
+}
+
+func TestProxyHeaderIsPerConnection(t *testing.T) {
+	pp, _ := newProxyProtocol(ProxyProtocolConfig{Trusted: []string{"0.0.0.0/0"}})
+	client, peer := net.Pipe()
+	defer client.Close()
+	c := &proxyConn{Conn: &addrConn{Conn: peer, remote: proxyAddr}, pp: pp}
+	go func() { _, _ = client.Write([]byte("PROXY TCP4 198.51.100.7 203.0.113.1 56324 443\r\n")) }()
+	if c.RemoteAddr().String() != "198.51.100.7:56324" {
+		t.Fatalf("unexpected remote %v", c.RemoteAddr())
+	}
+	if proxyConnOf(tls.Server(c, &tls.Config{})) != c || proxyConnOf(peer) != nil {
+		t.Fatal("the proxyConn must be found under a TLS connection only")
+	}
+}
+
+var proxyAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000}
+
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *addrConn) RemoteAddr() net.Addr { return c.remote }
@@ -7,10 +7,13 @@ import (
 )
 
 type CompressedRadixNode struct {
-	children      map[string]*CompressedRadixNode
-	paramChild    *CompressedRadixNode
+	children map[string]*CompressedRadixNode
+	// paramChildren holds constrained parameters in registration order followed
+	// by the unconstrained parameter, which is the order they are tried in.
+	paramChildren []*CompressedRadixNode
 	wildcardChild *CompressedRadixNode
 	paramName     string
+	constraint    *routeConstraint
 	entry         *routeEntry
 }
 
@@ -36,6 +39,10 @@ func (crt *CompressedRadixTree) insert(entry *routeEntry) error {
 	if entry == nil {
 		return fmt.Errorf("nil route entry")
 	}
+	segments, err := parseRoute(entry.path)
+	if err != nil {
+		return err
+	}
 
 	crt.mu.Lock()
 	defer crt.mu.Unlock()
@@ -44,57 +51,38 @@ func (crt *CompressedRadixTree) insert(entry *routeEntry) error {
 		crt.root = newRadixNode()
 	}
 
-	segments := splitPathSegments(entry.path)
 	current := crt.root
-
-	if len(segments) == 0 {
-		if current.entry != nil {
-			return fmt.Errorf("duplicate route: %s", entry.path)
-		}
-		current.entry = entry
-		crt.size++
-		return nil
-	}
-
-	for i, segment := range segments {
-		isLast := i == len(segments)-1
-		if len(segment) == 0 {
-			continue
-		}
-
-		switch segment[0] {
-		case ':':
-			if current.paramChild == nil {
-				current.paramChild = newRadixNode()
-				current.paramChild.paramName = segment[1:]
-			}
-			current = current.paramChild
-		case '*':
-			if !isLast {
-				return fmt.Errorf("catch-all must be the last segment in route %s", entry.path)
+	for _, segment := range segments {
+		switch segment.kind {
+		case segmentParam:
+			child, err := current.paramNode(segment, entry.path)
+			if err != nil {
+				return err
 			}
+			current = child
+		case segmentCatchAll:
 			if current.wildcardChild == nil {
 				current.wildcardChild = newRadixNode()
-				current.wildcardChild.paramName = segment[1:]
-			}
-			if current.wildcardChild.entry != nil {
-				return fmt.Errorf("duplicate route: %s", entry.path)
+				current.wildcardChild.paramName = segment.value
+			} else if current.wildcardChild.paramName != segment.value {
+				return fmt.Errorf("catch-all '*%s' in route %s conflicts with existing '*%s'", segment.value, entry.path, current.wildcardChild.paramName)
 			}
-			current.wildcardChild.entry = entry
-			crt.size++
-			return nil
+			current = current.wildcardChild
 		default:
-			child, ok := current.children[segment]
+			if segment.value == "" {
+				continue
+			}
+			child, ok := current.children[segment.value]
 			if !ok {
 				child = newRadixNode()
-				current.children[segment] = child
+				current.children[segment.value] = child
 			}
 			current = child
 		}
 	}
 
 	if current.entry != nil {
-		return fmt.Errorf("duplicate route: %s", entry.path)
+		return fmt.Errorf("duplicate route: %s conflicts with %s", entry.path, current.entry.route)
 	}
 
 	current.entry = entry
@@ -102,7 +90,51 @@ func (crt *CompressedRadixTree) insert(entry *routeEntry) error {
 	return nil
 }
 
+// paramNode returns the child for a parameter segment, creating it in priority
+// order. Parameters sharing a position and constraint must share a name.
+func (n *CompressedRadixNode) paramNode(segment routeSegment, path string) (*CompressedRadixNode, error) {
+	key := segment.key()
+	for _, child := range n.paramChildren {
+		if child.constraintKey() != key {
+			continue
+		}
+		if child.paramName != segment.value {
+			return nil, fmt.Errorf("parameter '%s' in route %s conflicts with existing parameter '%s'", segment.value, path, child.paramName)
+		}
+		return child, nil
+	}
+
+	child := newRadixNode()
+	child.paramName = segment.value
+	child.constraint = segment.constraint
+	if segment.constraint == nil {
+		n.paramChildren = append(n.paramChildren, child)
+		return child, nil
+	}
+	// Constrained parameters go before the unconstrained one.
+	i := len(n.paramChildren)
+	if i > 0 && n.paramChildren[i-1].constraint == nil {
+		i--
+	}
+	n.paramChildren = append(n.paramChildren, nil)
+	copy(n.paramChildren[i+1:], n.paramChildren[i:])
+	n.paramChildren[i] = child
+	return child, nil
+}
+
+func (n *CompressedRadixNode) constraintKey() string {
+	if n.constraint == nil {
+		return ""
+	}
+	return n.constraint.expr
+}
+
 func (crt *CompressedRadixTree) remove(path string) error {
+	segments, err := parseRoute(path)
+	if err != nil {
+		return err
+	}
+
 	crt.mu.Lock()
 	defer crt.mu.Unlock()
 
@@ -110,10 +142,7 @@ func (crt *CompressedRadixTree) remove(path string) error {
 		return fmt.Errorf("empty tree")
 	}
 
-	removed, _, err := removeRadixNode(crt.root, splitPathSegments(path))
-	if err != nil {
-		return err
-	}
+	removed, _ := removeRadixNode(crt.root, segments)
 	if !removed {
 		return fmt.Errorf("not found")
 	}
@@ -122,57 +151,54 @@ func (crt *CompressedRadixTree) remove(path string) error {
 	return nil
 }
 
-func removeRadixNode(current *CompressedRadixNode, segments []string) (bool, bool, error) {
+func removeRadixNode(current *CompressedRadixNode, segments []routeSegment) (bool, bool) {
+	for len(segments) > 0 && segments[0].kind == segmentStatic && segments[0].value == "" {
+		segments = segments[1:]
+	}
 	if len(segments) == 0 {
 		if current.entry == nil {
-			return false, false, nil
+			return false, false
 		}
 		current.entry = nil
-		return true, current.isEmpty(), nil
+		return true, current.isEmpty()
 	}
 
 	segment := segments[0]
 	rest := segments[1:]
 
-	if len(segment) > 0 && segment[0] != ':' && segment[0] != '*' {
-		if child, ok := current.children[segment]; ok {
-			removed, prune, err := removeRadixNode(child, rest)
-			if err != nil {
-				return false, false, err
-			}
-			if removed {
+	switch segment.kind {
+	case segmentStatic:
+		if child, ok := current.children[segment.value]; ok {
+			if removed, prune := removeRadixNode(child, rest); removed {
 				if prune {
-					delete(current.children, segment)
+					delete(current.children, segment.value)
 				}
-				return true, current.isEmpty(), nil
+				return true, current.isEmpty()
 			}
 		}
-	}
-
-	if len(segment) > 0 && segment[0] == ':' && current.paramChild != nil {
-		removed, prune, err := removeRadixNode(current.paramChild, rest)
-		if err != nil {
-			return false, false, err
-		}
-		if removed {
-			if prune {
-				current.paramChild = nil
+	case segmentParam:
+		for i, child := range current.paramChildren {
+			if child.constraintKey() != segment.key() || child.paramName != segment.value {
+				continue
+			}
+			if removed, prune := removeRadixNode(child, rest); removed {
+				if prune {
+					current.paramChildren = append(current.paramChildren[:i], current.paramChildren[i+1:]...)
+				}
+				return true, current.isEmpty()
 			}
-			return true, current.isEmpty(), nil
 		}
-	}
-
-	if len(segment) > 0 && segment[0] == '*' && current.wildcardChild != nil {
-		if len(rest) == 0 && current.wildcardChild.paramName == segment[1:] && current.wildcardChild.entry != nil {
-			current.wildcardChild.entry = nil
-			if current.wildcardChild.isEmpty() {
+	case segmentCatchAll:
+		if child := current.wildcardChild; child != nil && child.paramName == segment.value && child.entry != nil {
+			child.entry = nil
+			if child.isEmpty() {
 				current.wildcardChild = nil
 			}
-			return true, current.isEmpty(), nil
+			return true, current.isEmpty()
 		}
 	}
 
-	return false, false, nil
+	return false, false
 }
 
 func (crt *CompressedRadixTree) search(path string) *MatchResult {
@@ -233,13 +259,18 @@ func (n *CompressedRadixNode) find(segments []string, params *map[string]string)
 		}
 	}
 
-	if n.paramChild != nil && len(segment) > 0 {
-		values := ensureParams(params)
-		values[n.paramChild.paramName] = segment
-		if entry := n.paramChild.find(rest, params); entry != nil {
-			return entry
+	if len(segment) > 0 {
+		for _, child := range n.paramChildren {
+			if child.constraint != nil && !child.constraint.match(segment) {
+				continue
+			}
+			values := ensureParams(params)
+			values[child.paramName] = segment
+			if entry := child.find(rest, params); entry != nil {
+				return entry
+			}
+			delete(values, child.paramName)
 		}
-		delete(values, n.paramChild.paramName)
 	}
 
 	if n.wildcardChild != nil && n.wildcardChild.entry != nil {
@@ -254,7 +285,7 @@ func (n *CompressedRadixNode) find(segments []string, params *map[string]string)
 func (n *CompressedRadixNode) isEmpty() bool {
 	return n.entry == nil &&
 		len(n.children) == 0 &&
-		n.paramChild == nil &&
+		len(n.paramChildren) == 0 &&
 		n.wildcardChild == nil
 }
 
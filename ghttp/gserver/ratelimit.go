@@ -0,0 +1,526 @@
+package gserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sofiworker/gk/gcache"
+)
+
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket refills Limit tokens per Window up to Burst and allows short bursts.
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow allows Limit requests in any Window, estimated from the current and
+	// previous fixed windows.
+	SlidingWindow
+	// Concurrency allows at most Limit requests in flight.
+	Concurrency
+)
+
+func (a RateLimitAlgorithm) String() string {
+	switch a {
+	case TokenBucket:
+		return "token_bucket"
+	case SlidingWindow:
+		return "sliding_window"
+	case Concurrency:
+		return "concurrency"
+	}
+	return "unknown"
+}
+
+var ErrRateLimitResponse = errors.New("ratelimit: unexpected store response")
+
+// RateLimit is the policy a RateLimitStore enforces for one key.
+type RateLimit struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+	Burst     int
+}
+
+// RateLimitResult is the state of a key after a Take.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps limiter state. Implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	// Take consumes one unit for key.
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+	// Release returns the unit taken by an allowed Concurrency request.
+	Release(ctx context.Context, key string, limit RateLimit) error
+}
+
+type RateLimitConfig struct {
+	Algorithm RateLimitAlgorithm
+	// Limit is the number of requests per Window, or the number in flight for Concurrency.
+	Limit int
+	// Window defaults to one minute. For Concurrency it bounds how long a slot held by a
+	// crashed instance survives in a distributed store.
+	Window time.Duration
+	// Burst is the token bucket capacity and defaults to Limit.
+	Burst int
+
+	// Key selects the bucket for a request and defaults to RateLimitByIP.
+	// Returning an empty key skips limiting.
+	Key       func(ctx *Context) string
+	Store     RateLimitStore
+	KeyPrefix string
+	Skip      func(ctx *Context) bool
+
+	// OnLimited builds the response for rejected requests; the default is a 429 ErrorResult.
+	OnLimited func(ctx *Context, res RateLimitResult) Result
+	// FailClosed rejects requests with 503 when the store fails instead of letting them through.
+	FailClosed     bool
+	DisableHeaders bool
+}
+
+// RateLimitByIP keys requests by client IP.
+func RateLimitByIP() func(ctx *Context) string {
+	return func(ctx *Context) string {
+		return ctx.ClientIP()
+	}
+}
+
+// RateLimitByHeader keys requests by a header such as an API key.
+func RateLimitByHeader(name string) func(ctx *Context) string {
+	return func(ctx *Context) string {
+		return ctx.GetHeader(name)
+	}
+}
+
+// RateLimitByRoute shares one bucket per route pattern across all clients.
+func RateLimitByRoute() func(ctx *Context) string {
+	return func(ctx *Context) string {
+		if ctx.fastCtx == nil {
+			return ctx.FullPath()
+		}
+		return string(ctx.fastCtx.Method()) + " " + ctx.FullPath()
+	}
+}
+
+func RateLimiter(cfg RateLimitConfig) HandlerFunc {
+	if cfg.Limit <= 0 {
+		return func(ctx *Context) { ctx.Next() }
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = cfg.Limit
+	}
+	if cfg.Key == nil {
+		cfg.Key = RateLimitByIP()
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore()
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "ratelimit:"
+	}
+	if cfg.OnLimited == nil {
+		cfg.OnLimited = func(ctx *Context, res RateLimitResult) Result {
+			return ErrorStatusCode(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+		}
+	}
+
+	limit := RateLimit{Algorithm: cfg.Algorithm, Limit: cfg.Limit, Window: cfg.Window, Burst: cfg.Burst}
+	prefix := cfg.KeyPrefix + cfg.Algorithm.String() + ":"
+	policy := strconv.Itoa(cfg.Limit)
+	if cfg.Algorithm != Concurrency {
+		policy += ";w=" + strconv.FormatInt(int64(math.Ceil(cfg.Window.Seconds())), 10)
+	}
+
+	return func(ctx *Context) {
+		if cfg.Skip != nil && cfg.Skip(ctx) {
+			ctx.Next()
+			return
+		}
+		id := cfg.Key(ctx)
+		if id == "" {
+			ctx.Next()
+			return
+		}
+		key := prefix + id
+
+		res, err := cfg.Store.Take(ctx.Context(), key, limit)
+		if err != nil {
+			if logger := ctx.Logger(); logger != nil {
+				logger.Warnf("rate limit store: %v", err)
+			}
+			if cfg.FailClosed {
+				ErrorCode(err, http.StatusServiceUnavailable).Execute(ctx)
+				ctx.Abort()
+				return
+			}
+			ctx.Next()
+			return
+		}
+
+		if !cfg.DisableHeaders {
+			ctx.Header("RateLimit-Policy", policy)
+			ctx.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+			ctx.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			if cfg.Algorithm != Concurrency {
+				ctx.Header("RateLimit-Reset", strconv.FormatInt(ceilSeconds(res.Reset), 10))
+			}
+		}
+
+		if !res.Allowed {
+			ctx.Header("Retry-After", strconv.FormatInt(ceilSeconds(res.RetryAfter), 10))
+			cfg.OnLimited(ctx, res).Execute(ctx)
+			ctx.Abort()
+			return
+		}
+
+		if cfg.Algorithm == Concurrency {
+			// The request context is canceled with the server; the slot must still be freed.
+			releaseCtx := context.WithoutCancel(ctx.Context())
+			defer func() {
+				if err := cfg.Store.Release(releaseCtx, key, limit); err != nil {
+					if logger := ctx.Logger(); logger != nil {
+						logger.Warnf("rate limit release: %v", err)
+					}
+				}
+			}()
+		}
+		ctx.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// ==================== Memory Store ====================
+
+// MemoryRateLimitStore keeps limiter state in process memory. Idle keys are
+// evicted lazily during Take.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type rateLimitEntry struct {
+	// token bucket
+	tokens float64
+	last   time.Time
+	// sliding window
+	window      int64
+	count, prev int
+	// concurrency
+	inflight int
+
+	expires time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		entries: make(map[string]*rateLimitEntry),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now, limit.Window)
+	e := s.entries[key]
+	if e == nil {
+		e = &rateLimitEntry{tokens: float64(limit.Burst), last: now}
+		s.entries[key] = e
+	}
+
+	var res RateLimitResult
+	switch limit.Algorithm {
+	case TokenBucket:
+		res = e.takeToken(now, limit)
+		e.expires = now.Add(tokenRefillTime(limit, float64(limit.Burst)))
+	case SlidingWindow:
+		res = e.takeWindow(now, limit)
+		e.expires = now.Add(2 * limit.Window)
+	case Concurrency:
+		res = RateLimitResult{Limit: limit.Limit, RetryAfter: time.Second}
+		if e.inflight < limit.Limit {
+			e.inflight++
+			res.Allowed = true
+		}
+		res.Remaining = limit.Limit - e.inflight
+		e.expires = now.Add(limit.Window)
+	default:
+		return RateLimitResult{}, fmt.Errorf("ratelimit: unknown algorithm %d", limit.Algorithm)
+	}
+	return res, nil
+}
+
+func (s *MemoryRateLimitStore) Release(_ context.Context, key string, limit RateLimit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e := s.entries[key]; e != nil && e.inflight > 0 {
+		e.inflight--
+		if e.inflight == 0 {
+			delete(s.entries, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryRateLimitStore) sweep(now time.Time, interval time.Duration) {
+	if now.Sub(s.lastSweep) < interval {
+		return
+	}
+	s.lastSweep = now
+	for k, e := range s.entries {
+		if e.inflight == 0 && now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+}
+
+func tokenRefillTime(limit RateLimit, tokens float64) time.Duration {
+	return time.Duration(tokens * float64(limit.Window) / float64(limit.Limit))
+}
+
+func (e *rateLimitEntry) takeToken(now time.Time, limit RateLimit) RateLimitResult {
+	rate := float64(limit.Limit) / float64(limit.Window)
+	if elapsed := now.Sub(e.last); elapsed > 0 {
+		e.tokens = math.Min(float64(limit.Burst), e.tokens+float64(elapsed)*rate)
+	}
+	e.last = now
+
+	res := RateLimitResult{Limit: limit.Burst}
+	if e.tokens >= 1 {
+		e.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = tokenRefillTime(limit, 1-e.tokens)
+	}
+	res.Remaining = int(e.tokens)
+	res.Reset = tokenRefillTime(limit, float64(limit.Burst)-e.tokens)
+	return res
+}
+
+func (e *rateLimitEntry) takeWindow(now time.Time, limit RateLimit) RateLimitResult {
+	window := int64(limit.Window)
+	cur := now.UnixNano() / window
+	switch {
+	case cur == e.window:
+	case cur == e.window+1:
+		e.prev, e.count = e.count, 0
+	default:
+		e.prev, e.count = 0, 0
+	}
+	e.window = cur
+
+	elapsed := now.UnixNano() - cur*window
+	allowed, remaining, retry, reset := slidingWindow(e.prev, e.count, limit.Limit, window, elapsed)
+	if allowed {
+		e.count++
+	}
+	return RateLimitResult{
+		Allowed:    allowed,
+		Limit:      limit.Limit,
+		Remaining:  remaining,
+		Reset:      time.Duration(reset),
+		RetryAfter: time.Duration(retry),
+	}
+}
+
+// slidingWindow weights the previous window by the part of it still covered by the
+// sliding window. It mirrors slidingWindowScript; all durations share one unit.
+func slidingWindow(prev, count, limit int, window, elapsed int64) (allowed bool, remaining int, retry, reset int64) {
+	weighted := float64(prev)*float64(window-elapsed)/float64(window) + float64(count)
+	reset = window - elapsed
+	if weighted+1 <= float64(limit) {
+		return true, int(float64(limit) - weighted - 1), 0, reset
+	}
+	if count+1 > limit {
+		// Wait for the next window, then for this window's weight to decay.
+		wait := float64(window) * (1 - float64(limit-1)/float64(count))
+		return false, 0, reset + int64(math.Max(0, math.Ceil(wait))), reset
+	}
+	target := float64(window) * (1 - float64(limit-1-count)/float64(prev))
+	return false, 0, int64(math.Ceil(target)) - elapsed, reset
+}
+
+// ==================== Cache Store ====================
+
+// CacheRateLimitStore keeps limiter state in Redis or Valkey through gcache. Each
+// decision is a single Lua script using the server clock, so instances share limits
+// without coordinating their own clocks.
+type CacheRateLimitStore struct {
+	cache gcache.ScriptCacheWithContext
+}
+
+// NewCacheRateLimitStore works with *gcache.RedisCache and *gcache.ValkeyCache.
+func NewCacheRateLimitStore(cache gcache.ScriptCacheWithContext) *CacheRateLimitStore {
+	return &CacheRateLimitStore{cache: cache}
+}
+
+// All scripts return {allowed, remaining, retry_ms, reset_ms}.
+const (
+	tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2]) / tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+if now > ts then
+  tokens = math.min(capacity, tokens + (now - ts) * rate)
+end
+local allowed, retry = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate) + 1000)
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) / rate)}
+`
+
+	slidingWindowScript = `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local cur = math.floor(now / window)
+local state = redis.call('HMGET', KEYS[1], 'win', 'count', 'prev')
+local win = tonumber(state[1]) or cur
+local count = tonumber(state[2]) or 0
+local prev = tonumber(state[3]) or 0
+if win == cur - 1 then
+  prev, count = count, 0
+elseif win ~= cur then
+  prev, count = 0, 0
+end
+local elapsed = now - cur * window
+local weighted = prev * (window - elapsed) / window + count
+local reset = window - elapsed
+local allowed, remaining, retry = 0, 0, 0
+if weighted + 1 <= limit then
+  allowed = 1
+  count = count + 1
+  remaining = math.floor(limit - weighted - 1)
+elseif count + 1 > limit then
+  retry = reset + math.max(0, math.ceil(window * (1 - (limit - 1) / count)))
+else
+  retry = math.ceil(window * (1 - (limit - 1 - count) / prev)) - elapsed
+end
+redis.call('HSET', KEYS[1], 'win', cur, 'count', count, 'prev', prev)
+redis.call('PEXPIRE', KEYS[1], window * 2)
+return {allowed, remaining, retry, reset}
+`
+
+	concurrencyAcquireScript = `
+local limit = tonumber(ARGV[1])
+local n = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if n > limit then
+  redis.call('DECR', KEYS[1])
+  return {0, 0, 1000, 0}
+end
+return {1, limit - n, 0, 0}
+`
+
+	concurrencyReleaseScript = `
+local n = redis.call('DECR', KEYS[1])
+if n <= 0 then
+  redis.call('DEL', KEYS[1])
+end
+return n
+`
+)
+
+func (s *CacheRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	windowMs := limit.Window.Milliseconds()
+	if windowMs <= 0 {
+		windowMs = 1
+	}
+
+	var (
+		reply interface{}
+		err   error
+		max   = limit.Limit
+	)
+	switch limit.Algorithm {
+	case TokenBucket:
+		max = limit.Burst
+		reply, err = s.cache.EvalWithContext(ctx, tokenBucketScript, []string{key}, limit.Burst, limit.Limit, windowMs)
+	case SlidingWindow:
+		reply, err = s.cache.EvalWithContext(ctx, slidingWindowScript, []string{key}, limit.Limit, windowMs)
+	case Concurrency:
+		reply, err = s.cache.EvalWithContext(ctx, concurrencyAcquireScript, []string{key}, limit.Limit, windowMs)
+	default:
+		return RateLimitResult{}, fmt.Errorf("ratelimit: unknown algorithm %d", limit.Algorithm)
+	}
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	values, err := scriptInts(reply, 4)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      max,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		Reset:      time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+func (s *CacheRateLimitStore) Release(ctx context.Context, key string, limit RateLimit) error {
+	if limit.Algorithm != Concurrency {
+		return nil
+	}
+	_, err := s.cache.EvalWithContext(ctx, concurrencyReleaseScript, []string{key})
+	return err
+}
+
+func scriptInts(reply interface{}, n int) ([]int64, error) {
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != n {
+		return nil, fmt.Errorf("%w: %v", ErrRateLimitResponse, reply)
+	}
+	out := make([]int64, n)
+	for i, item := range items {
+		switch v := item.(type) {
+		case int64:
+			out[i] = v
+		case string:
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrRateLimitResponse, reply)
+			}
+			out[i] = parsed
+		default:
+			return nil, fmt.Errorf("%w: %v", ErrRateLimitResponse, reply)
+		}
+	}
+	return out, nil
+}
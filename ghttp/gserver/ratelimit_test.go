@@ -0,0 +1,127 @@
+package gserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sofiworker/gk/gcache"
+)
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	server := NewServer()
+	server.Use(RateLimiter(RateLimitConfig{
+		Limit:  2,
+		Window: time.Minute,
+		Key:    RateLimitByHeader("X-API-Key"),
+	}))
+	server.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	do := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := do("a"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+	rec := do("a")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "30" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("unexpected headers %v", rec.Header())
+	}
+	if rec.Header().Get("RateLimit-Policy") != "2;w=60" {
+		t.Fatalf("unexpected policy %q", rec.Header().Get("RateLimit-Policy"))
+	}
+	if rec := do("b"); rec.Code != http.StatusOK {
+		t.Fatalf("other keys must not share a bucket, got %d", rec.Code)
+	}
+}
+
+func TestMemoryRateLimitStoreSlidingWindow(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	now := time.Unix(600, 0)
+	store.now = func() time.Time { return now }
+	limit := RateLimit{Algorithm: SlidingWindow, Limit: 4, Window: 10 * time.Second}
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		if res, _ := store.Take(ctx, "k", limit); !res.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if res, _ := store.Take(ctx, "k", limit); res.Allowed {
+		t.Fatal("fifth request in the window should be limited")
+	}
+
+	// Halfway through the next window half of the previous count still applies.
+	now = now.Add(15 * time.Second)
+	res, _ := store.Take(ctx, "k", limit)
+	if !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if res, _ = store.Take(ctx, "k", limit); !res.Allowed {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	res, _ = store.Take(ctx, "k", limit)
+	if res.Allowed || res.RetryAfter != 2500*time.Millisecond {
+		t.Fatalf("unexpected limited result %+v", res)
+	}
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Algorithm: Concurrency, Limit: 1, Window: time.Minute}
+	ctx := context.Background()
+
+	if res, _ := store.Take(ctx, "k", limit); !res.Allowed {
+		t.Fatal("first request should be allowed")
+	}
+	if res, _ := store.Take(ctx, "k", limit); res.Allowed {
+		t.Fatal("second concurrent request should be limited")
+	}
+	_ = store.Release(ctx, "k", limit)
+	if res, _ := store.Take(ctx, "k", limit); !res.Allowed {
+		t.Fatal("slot should be free after release")
+	}
+}
+
+func TestCacheRateLimitStoreRedis(t *testing.T) {
+	redisAddr := os.Getenv("REDIS_ADDR")
+	if redisAddr == "" {
+		redisAddr = "localhost:6379"
+	}
+	cache, err := gcache.NewRedisCache(gcache.WithAddress(redisAddr))
+	if err != nil {
+		t.Skipf("skip redis integration test: %v", err)
+	}
+	t.Cleanup(func() { _ = cache.Close() })
+
+	store := NewCacheRateLimitStore(cache)
+	ctx := context.Background()
+	key := "gserver-test:" + time.Now().Format(time.RFC3339Nano)
+	for _, algo := range []RateLimitAlgorithm{TokenBucket, SlidingWindow, Concurrency} {
+		limit := RateLimit{Algorithm: algo, Limit: 2, Burst: 2, Window: time.Minute}
+		k := key + algo.String()
+		for i := 0; i < 2; i++ {
+			if res, err := store.Take(ctx, k, limit); err != nil || !res.Allowed {
+				t.Fatalf("%s request %d: res=%+v err=%v", algo, i, res, err)
+			}
+		}
+		res, err := store.Take(ctx, k, limit)
+		if err != nil || res.Allowed || res.RetryAfter <= 0 {
+			t.Fatalf("%s: expected limit, res=%+v err=%v", algo, res, err)
+		}
+		_ = store.Release(ctx, k, limit)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 // MarshalFunc：自定义marshal函数类型
@@ -16,7 +15,7 @@ type MarshalFunc func(data interface{}) ([]byte, string, error)
 // ==================== AutoResult ====================
 
 // AutoResult：自动marshal返回值
-// 根据Accept header自动选择编码格式（JSON/XML等）
+// 根据Accept header的q值自动选择编码格式（JSON/XML/YAML/纯文本及自定义编码）
 type AutoResult struct {
 	data    interface{}
 	code    int
@@ -67,37 +66,10 @@ func (r *AutoResult) Execute(ctx *Context) {
 	r.autoMarshal(ctx, code)
 }
 
-// autoMarshal：根据Accept header自动选择编码格式
+// autoMarshal：按 Accept header 在 CodecFactory 注册的编码（或路由的 Produces）中协商
+// []byte 和 io.Reader 额外提供 application/octet-stream，无可接受类型时返回 406
 func (r *AutoResult) autoMarshal(ctx *Context, code int) {
-	accept := ctx.GetHeader("Accept")
-	if accept == "" {
-		accept = "*/*"
-	}
-
-	// 根据Accept header的优先级选择编码
-	// 支持的格式：application/json, application/xml, text/plain, application/octet-stream
-	switch {
-	case contains(accept, "application/json") || accept == "*/*":
-		ctx.JSON(code, r.data)
-	case contains(accept, "application/xml") || contains(accept, "text/xml"):
-		ctx.XML(code, r.data)
-	case contains(accept, "text/plain"):
-		ctx.String(code, "%v", r.data)
-	case contains(accept, "application/octet-stream"):
-		// 对于二进制数据，需要调用者提供[]byte或io.Reader
-		if data, ok := r.data.([]byte); ok {
-			ctx.Data(code, "application/octet-stream", data)
-		} else if reader, ok := r.data.(io.Reader); ok {
-			ctx.Header("Content-Type", "application/octet-stream")
-			ctx.Status(code)
-			_, _ = io.Copy(ctx.Writer, reader)
-		} else {
-			panic("AutoResult: binary data must be []byte or io.Reader")
-		}
-	default:
-		// 默认JSON
-		ctx.JSON(code, r.data)
-	}
+	ctx.writeNegotiated(code, r.data)
 }
 
 // WithCode：设置状态码（链式调用）
@@ -132,11 +104,6 @@ func (r *AutoResult) WithMarshal(marshal MarshalFunc) *AutoResult {
 	return r
 }
 
-// contains：辅助函数，检查字符串是否包含子串（不区分大小写）
-func contains(s, substr string) bool {
-	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
-}
-
 // Auto：自动marshal返回值（默认状态码200）
 func Auto(data interface{}) Result {
 	return NewAutoResult(data)
@@ -333,7 +300,8 @@ func RedirectCode(url string, code int) Result {
 
 // ==================== Error Result ====================
 
-// ErrorResult：返回错误
+// ErrorResult：以 RFC 9457 problem details 返回错误
+// Code 和 Msg 非空时覆盖从 Err 推导出的状态码和 detail
 type ErrorResult struct {
 	Err  error
 	Code int
@@ -344,26 +312,12 @@ func (r *ErrorResult) Execute(ctx *Context) {
 	if ctx == nil {
 		return
 	}
-	code := r.Code
-	if code == 0 {
-		code = http.StatusInternalServerError
-	}
-	msg := r.Msg
-	if msg == "" && r.Err != nil {
-		msg = r.Err.Error()
-	}
-	if msg == "" {
-		msg = http.StatusText(code)
-	}
-	ctx.String(code, "%s", msg)
+	writeProblem(ctx, r.problem())
 }
 
+// Error 的状态码由 gerr.From 决定：错误链中的 *gerr.Err、注册表，否则 500
 func Error(err error) Result {
-	msg := ""
-	if err != nil {
-		msg = err.Error()
-	}
-	return &ErrorResult{Err: err, Code: http.StatusInternalServerError, Msg: msg}
+	return &ErrorResult{Err: err}
 }
 
 func ErrorMsg(msg string) Result {
@@ -371,11 +325,7 @@ func ErrorMsg(msg string) Result {
 }
 
 func ErrorCode(err error, code int) Result {
-	msg := ""
-	if err != nil {
-		msg = err.Error()
-	}
-	return &ErrorResult{Err: err, Code: code, Msg: msg}
+	return &ErrorResult{Err: err, Code: code}
 }
 
 func ErrorStatusCode(code int, msg string) Result {
@@ -422,7 +372,11 @@ func (r *StreamResult) Execute(ctx *Context) {
 	}
 	ctx.Header("Content-Type", r.ContentType)
 	ctx.Status(code)
-	_, _ = io.Copy(ctx.Writer, r.Reader)
+	if ctx.fastCtx == nil {
+		_, _ = io.Copy(ctx.Writer, r.Reader)
+		return
+	}
+	ctx.setBodyStream(r.Reader)
 }
 
 func Stream(reader io.Reader) Result {
@@ -435,7 +389,7 @@ func StreamWithContentType(reader io.Reader, contentType string) Result {
 
 // ==================== File Result ====================
 
-// FileResult：返回文件
+// FileResult：返回文件，支持 Range/If-Range 分段下载和条件请求
 type FileResult struct {
 	Path string
 }
@@ -446,7 +400,7 @@ func (r *FileResult) Execute(ctx *Context) {
 	}
 
 	// 检查文件是否存在
-	_, err := os.Stat(r.Path)
+	file, err := os.Open(r.Path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			ctx.Status(http.StatusNotFound)
@@ -455,6 +409,13 @@ func (r *FileResult) Execute(ctx *Context) {
 		}
 		return
 	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
 
 	// 根据扩展名设置Content-Type
 	ext := filepath.Ext(r.Path)
@@ -462,18 +423,20 @@ func (r *FileResult) Execute(ctx *Context) {
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
-
 	ctx.Header("Content-Type", contentType)
-	ctx.Status(http.StatusOK)
 
-	// 读取文件并写入响应
-	file, err := os.ReadFile(r.Path)
-	if err != nil {
+	// 默认使用修改时间和大小生成强校验器，If-Range 需要强 ETag
+	if ctx.Writer.Header().Get("ETag") == "" {
+		ctx.Header("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	}
+
+	req := buildHTTPRequestFromFast(ctx.fastCtx)
+	if req == nil {
 		ctx.Status(http.StatusInternalServerError)
 		return
 	}
-
-	ctx.Writer.Write(file)
+	// http.ServeContent 负责 Range、multipart/byteranges 以及 304/412 条件请求
+	http.ServeContent(ctx.Writer, req, info.Name(), info.ModTime(), file)
 }
 
 func File(filepath string) Result {
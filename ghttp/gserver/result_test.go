@@ -421,10 +421,13 @@ func TestErrorResult_Execute(t *testing.T) {
 	}
 
 	body := gctx.Response().Body()
-	expected := "error message"
+	expected := `{"detail":"error message","status":500,"title":"Internal Server Error"}`
 	if string(body) != expected {
 		t.Errorf("expected body %s, got %s", expected, string(body))
 	}
+	if ct := string(gctx.Response().Header.Peek("Content-Type")); ct != "application/problem+json" {
+		t.Errorf("expected problem+json content-type, got %s", ct)
+	}
 }
 
 func TestErrorCode_Execute(t *testing.T) {
@@ -446,7 +449,7 @@ func TestErrorCode_Execute(t *testing.T) {
 	}
 
 	body := gctx.Response().Body()
-	expected := "not found"
+	expected := `{"detail":"not found","status":404,"title":"Not Found"}`
 	if string(body) != expected {
 		t.Errorf("expected body %s, got %s", expected, string(body))
 	}
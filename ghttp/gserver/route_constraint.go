@@ -0,0 +1,241 @@
+package gserver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Route patterns are made of "/"-separated segments:
+//
+//	/users            static segment
+//	/users/:id        parameter, also written {id}
+//	/users/{id:int}   parameter with a named constraint
+//	/files/{name:[a-z]+\.png}
+//	                  parameter with a regular expression matching the whole segment
+//	/static/*path     catch-all capturing the rest of the path, must be last
+//	/posts/{page?}    optional parameter, also :page? and {page?:int}; optional
+//	                  segments must be trailing
+//
+// At every segment static children are tried first, then constrained parameters
+// in registration order, then the plain parameter and finally the catch-all.
+// Matching backtracks, so a later segment may still select a lower priority branch.
+
+type segmentKind uint8
+
+const (
+	segmentStatic segmentKind = iota
+	segmentParam
+	segmentCatchAll
+)
+
+type routeSegment struct {
+	kind       segmentKind
+	value      string // literal text or parameter name
+	constraint *routeConstraint
+	optional   bool
+}
+
+// key identifies the tree branch of a segment; parameters with the same key share a node.
+func (s routeSegment) key() string {
+	if s.constraint == nil {
+		return ""
+	}
+	return s.constraint.expr
+}
+
+// String renders the segment without its optional marker.
+func (s routeSegment) String() string {
+	switch s.kind {
+	case segmentParam:
+		if s.constraint == nil {
+			return ":" + s.value
+		}
+		return "{" + s.value + ":" + s.constraint.expr + "}"
+	case segmentCatchAll:
+		return "*" + s.value
+	}
+	return s.value
+}
+
+type routeConstraint struct {
+	expr  string
+	match func(segment string) bool
+}
+
+var (
+	routeConstraintsMu sync.RWMutex
+	routeConstraints   = map[string]func(string) bool{
+		"int": func(s string) bool {
+			_, err := strconv.ParseInt(s, 10, 64)
+			return err == nil
+		},
+		"uint": func(s string) bool {
+			_, err := strconv.ParseUint(s, 10, 64)
+			return err == nil
+		},
+		"float": func(s string) bool {
+			_, err := strconv.ParseFloat(s, 64)
+			return err == nil
+		},
+		"bool": func(s string) bool {
+			_, err := strconv.ParseBool(s)
+			return err == nil
+		},
+		"alpha":    alphaRegexp.MatchString,
+		"alphanum": alnumRegexp.MatchString,
+		"uuid":     regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	}
+)
+
+// RegisterRouteConstraint registers a named constraint usable as {name:constraint}.
+// Built-in constraints are int, uint, float, bool, alpha, alphanum and uuid. Routes
+// resolve constraints when they are added, so register before adding routes.
+func RegisterRouteConstraint(name string, match func(segment string) bool) {
+	if name == "" || match == nil {
+		panic("route constraint name and function are required")
+	}
+	routeConstraintsMu.Lock()
+	routeConstraints[name] = match
+	routeConstraintsMu.Unlock()
+}
+
+func lookupRouteConstraint(expr string) (*routeConstraint, error) {
+	routeConstraintsMu.RLock()
+	fn, ok := routeConstraints[expr]
+	routeConstraintsMu.RUnlock()
+	if ok {
+		return &routeConstraint{expr: expr, match: fn}, nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid route constraint %q: %w", expr, err)
+	}
+	return &routeConstraint{expr: expr, match: re.MatchString}, nil
+}
+
+func parseRouteSegment(seg string) (routeSegment, error) {
+	if seg == "" {
+		return routeSegment{kind: segmentStatic}, nil
+	}
+	switch seg[0] {
+	case ':':
+		name, optional := strings.CutSuffix(seg[1:], "?")
+		if err := checkParamName(name); err != nil {
+			return routeSegment{}, err
+		}
+		return routeSegment{kind: segmentParam, value: name, optional: optional}, nil
+	case '*':
+		name := seg[1:]
+		if err := checkParamName(name); err != nil {
+			return routeSegment{}, err
+		}
+		return routeSegment{kind: segmentCatchAll, value: name}, nil
+	case '{':
+		if seg[len(seg)-1] != '}' {
+			return routeSegment{}, fmt.Errorf("parameter %q must span a whole path segment", seg)
+		}
+		name, expr, hasExpr := strings.Cut(seg[1:len(seg)-1], ":")
+		name, optional := strings.CutSuffix(name, "?")
+		if err := checkParamName(name); err != nil {
+			return routeSegment{}, err
+		}
+		s := routeSegment{kind: segmentParam, value: name, optional: optional}
+		if hasExpr {
+			if expr == "" {
+				return routeSegment{}, fmt.Errorf("empty constraint for parameter %q", name)
+			}
+			c, err := lookupRouteConstraint(expr)
+			if err != nil {
+				return routeSegment{}, err
+			}
+			s.constraint = c
+		}
+		return s, nil
+	}
+	if i := strings.IndexAny(seg, "*?{}"); i >= 0 {
+		switch seg[i] {
+		case '*':
+			return routeSegment{}, fmt.Errorf("catch-all %q must start a path segment", seg)
+		case '?':
+			return routeSegment{}, fmt.Errorf("'?' character is not allowed in segment %q", seg)
+		default:
+			return routeSegment{}, fmt.Errorf("parameter %q must span a whole path segment", seg)
+		}
+	}
+	return routeSegment{kind: segmentStatic, value: seg}, nil
+}
+
+func checkParamName(name string) error {
+	if name == "" {
+		return fmt.Errorf("wildcards must be named with a non-empty name")
+	}
+	if strings.ContainsAny(name, ":*?{}") {
+		return fmt.Errorf("only one wildcard per path segment is allowed, found %q", name)
+	}
+	return nil
+}
+
+// parseRoute validates a route pattern and returns its segments.
+func parseRoute(path string) ([]routeSegment, error) {
+	raw := splitPathSegments(path)
+	segments := make([]routeSegment, 0, len(raw))
+	names := make(map[string]struct{})
+	optional := false
+	for i, seg := range raw {
+		s, err := parseRouteSegment(seg)
+		if err != nil {
+			return nil, fmt.Errorf("%w in path '%s'", err, path)
+		}
+		if s.kind != segmentStatic {
+			if _, dup := names[s.value]; dup {
+				return nil, fmt.Errorf("duplicate parameter %q in path '%s'", s.value, path)
+			}
+			names[s.value] = struct{}{}
+		}
+		if s.kind == segmentCatchAll && i != len(raw)-1 {
+			return nil, fmt.Errorf("catch-all routes are only allowed at the end of the path in path '%s'", path)
+		}
+		if optional && !s.optional {
+			return nil, fmt.Errorf("optional segments must be trailing in path '%s'", path)
+		}
+		optional = s.optional
+		segments = append(segments, s)
+	}
+	return segments, nil
+}
+
+// expandRoute returns the concrete patterns of a route with optional segments,
+// shortest first. Routes without optional segments expand to themselves.
+func expandRoute(path string) ([]string, error) {
+	segments, err := parseRoute(path)
+	if err != nil {
+		return nil, err
+	}
+	first := len(segments)
+	for i, s := range segments {
+		if s.optional {
+			first = i
+			break
+		}
+	}
+	if first == len(segments) {
+		return []string{path}, nil
+	}
+
+	variants := make([]string, 0, len(segments)-first+1)
+	for n := first; n <= len(segments); n++ {
+		var b strings.Builder
+		for _, s := range segments[:n] {
+			b.WriteByte('/')
+			b.WriteString(s.String())
+		}
+		if b.Len() == 0 {
+			b.WriteByte('/')
+		}
+		variants = append(variants, b.String())
+	}
+	return variants, nil
+}
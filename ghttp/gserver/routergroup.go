@@ -42,12 +42,24 @@ type IRouter interface {
 	StaticFS(relativePath string, fs http.FileSystem) IRouter
 }
 
+// Documenter is implemented by routers that describe their routes in the OpenAPI
+// document, such as *RouterGroup and *Server. Groups are documented with
+//
+//	server.Group("/api").(gserver.Documenter).Doc(op).GET("/users", listUsers)
+type Documenter interface {
+	Doc(op *Operation) IRouter
+}
+
 // RouterGroup is used to group routes with a common prefix and middlewares.
 type RouterGroup struct {
 	Handlers []HandlerFunc
 	path     string
 	engine   *Server
 	root     bool
+
+	// doc annotates the routes registered through a router returned by Doc.
+	doc    *Operation
+	parent *RouterGroup
 }
 
 // Group creates a new router group. It inherits middlewares from the parent group.
@@ -79,6 +91,7 @@ func (g *RouterGroup) addRoute(method, path string, handlers ...HandlerFunc) {
 	absolutePath := g.calculateAbsolutePath(path)
 	finalHandlers := g.combineHandlers(handlers...)
 	g.engine.addRoute(method, absolutePath, finalHandlers...)
+	g.engine.recordRoute(method, absolutePath, g.doc)
 }
 
 // GET is a shortcut for Handle(http.MethodGet, path, handlers).
@@ -142,6 +155,20 @@ func (g *RouterGroup) StaticFS(relativePath string, fs http.FileSystem) IRouter
 	return g.engine.StaticFS(g.calculateAbsolutePath(relativePath), fs)
 }
 
+// Doc returns a router whose next registrations are described by op in the
+// OpenAPI document. Chained calls return to the original router:
+//
+//	r.Doc(&Operation{Summary: "Get user", Response: User{}}).GET("/users/:id", getUser)
+func (g *RouterGroup) Doc(op *Operation) IRouter {
+	return &RouterGroup{
+		Handlers: g.Handlers,
+		path:     g.path,
+		engine:   g.engine,
+		doc:      op,
+		parent:   g,
+	}
+}
+
 // calculateAbsolutePath calculates the full path for a route, including the group's base path.
 func (g *RouterGroup) calculateAbsolutePath(relativePath string) string {
 	return JoinPaths(g.path, relativePath)
@@ -161,6 +188,9 @@ func (g *RouterGroup) combineHandlers(handlers ...HandlerFunc) []HandlerFunc {
 
 // returnObj returns the correct router instance for method chaining.
 func (g *RouterGroup) returnObj() IRouter {
+	if g.parent != nil {
+		return g.parent.returnObj()
+	}
 	if g.root {
 		return g.engine.IRouter
 	}
@@ -3,7 +3,9 @@ package gserver
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"mime"
 	"net"
 	"net/http"
 	"net/url"
@@ -36,6 +38,17 @@ var (
 
 type Server struct {
 	server *fasthttp.Server
+	hub    *WebSocketHub
+
+	// baseCtx is the parent of every request context and is canceled on shutdown.
+	baseCtx    context.Context
+	cancelBase context.CancelFunc
+
+	shutdownMu    sync.Mutex
+	shutdownHooks []func(ctx context.Context) error
+
+	routesMu sync.RWMutex
+	routes   []RouteInfo
 
 	*Config
 
@@ -49,6 +62,9 @@ func NewServer(opts ...ServerOption) *Server {
 		codec:      newCodecFactory(),
 		logger:     glog.Default(),
 		UseRawPath: false,
+
+		handleMethodNotAllowed: true,
+		handleOptions:          true,
 	}
 
 	for _, opt := range opts {
@@ -90,6 +106,9 @@ func NewServer(opts ...ServerOption) *Server {
 	}
 	r.engine = s
 	s.server.Handler = s.FastHandler
+	s.baseCtx, s.cancelBase = context.WithCancel(context.Background())
+	s.hub = NewWebSocketHub()
+	s.OnShutdown(s.hub.Shutdown)
 
 	return s
 }
@@ -108,21 +127,57 @@ func (s *Server) RunTLS(addr, certFile, keyFile string) error {
 	if err != nil {
 		return err
 	}
-	return s.server.ServeTLS(ln, certFile, keyFile)
+	return s.server.ServeTLS(s.wrapListener(ln), certFile, keyFile)
 }
 
 func (s *Server) RunListener(l net.Listener) error {
-	return s.server.Serve(l)
+	return s.server.Serve(s.wrapListener(l))
 }
 
+// Shutdown runs the shutdown hooks without waiting and then stops the fasthttp server.
 func (s *Server) Shutdown() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.runShutdownHooks(ctx)
 	return s.server.Shutdown()
 }
 
+// ShutdownWithContext runs the shutdown hooks, which may use ctx to bound graceful
+// work such as closing WebSocket connections, and then stops the fasthttp server.
 func (s *Server) ShutdownWithContext(ctx context.Context) error {
+	s.runShutdownHooks(ctx)
 	return s.server.ShutdownWithContext(ctx)
 }
 
+// OnShutdown registers fn to run at the beginning of Shutdown and ShutdownWithContext.
+func (s *Server) OnShutdown(fn func(ctx context.Context) error) {
+	if fn == nil {
+		return
+	}
+	s.shutdownMu.Lock()
+	s.shutdownHooks = append(s.shutdownHooks, fn)
+	s.shutdownMu.Unlock()
+}
+
+func (s *Server) runShutdownHooks(ctx context.Context) {
+	s.cancelBase()
+
+	s.shutdownMu.Lock()
+	hooks := append([]func(ctx context.Context) error(nil), s.shutdownHooks...)
+	s.shutdownMu.Unlock()
+
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Warnf("shutdown hook: %v", err)
+		}
+	}
+}
+
+// WebSocketHub returns the hub that connections join when WebSocketConfig.Hub is nil.
+func (s *Server) WebSocketHub() *WebSocketHub {
+	return s.hub
+}
+
 func resolveAddress(addr []string) string {
 	switch len(addr) {
 	case 0:
@@ -142,6 +197,15 @@ func (s *Server) Use(middleware ...HandlerFunc) IRouter {
 	return s.IRouter
 }
 
+// Doc returns a router whose next registrations are described by op in the
+// OpenAPI document, see RouterGroup.Doc.
+func (s *Server) Doc(op *Operation) IRouter {
+	if d, ok := s.IRouter.(Documenter); ok {
+		return d.Doc(op)
+	}
+	return s.IRouter
+}
+
 func (s *Server) Static(relativePath, root string) IRouter {
 	if root == "" {
 		panic("static root cannot be empty")
@@ -189,6 +253,8 @@ func (s *Server) FastHandler(ctx *fasthttp.RequestCtx) {
 	gctx.Writer = writer // Direct assignment instead of wrapping for better performance
 	gctx.codec = s.codec
 	gctx.render = s.render
+	gctx.engine = s
+	gctx.reqCtx = s.baseCtx
 
 	// Defer cleanup and return objects to pools
 	defer func() {
@@ -200,12 +266,13 @@ func (s *Server) FastHandler(ctx *fasthttp.RequestCtx) {
 
 	// Determine route path
 	method := string(ctx.Method())
-	routePath := string(ctx.URI().PathOriginal())
-	if routePath == "" {
-		routePath = string(ctx.Path())
+	rawPath := string(ctx.URI().PathOriginal())
+	if rawPath == "" {
+		rawPath = string(ctx.Path())
 	}
+	routePath := rawPath
 	if !s.UseRawPath && routePath != "" && !strings.Contains(routePath, "..") {
-		routePath = path.Clean(routePath)
+		routePath = cleanRoutePath(routePath)
 	}
 	if routePath == "" {
 		routePath = "/"
@@ -217,33 +284,13 @@ func (s *Server) FastHandler(ctx *fasthttp.RequestCtx) {
 	}
 
 	// Find matching route
-	if fm, ok := s.Match.(interface {
-		LookupInto(method, path string, dstParams map[string]string) (handlers []HandlerFunc, fullPath string, ok bool)
-	}); ok {
-		handlers, fullPath, ok := fm.LookupInto(method, routePath, gctx.pathParams)
-		if !ok {
-			s.handleNotFound(gctx)
+	if s.lookupRoute(gctx, method, routePath) {
+		if s.redirectFixedPath && rawPath != "" && routePath != rawPath {
+			s.redirectRequest(gctx, routePath)
 			return
 		}
-		gctx.fullPath = fullPath
-		if len(handlers) > 0 {
-			gctx.handlers = append(gctx.handlers, handlers...)
-		}
-	} else {
-		matchResult := s.Lookup(method, routePath)
-		if matchResult == nil {
-			s.handleNotFound(gctx)
-			return
-		}
-		gctx.fullPath = matchResult.Path
-		if len(matchResult.Handlers) > 0 {
-			gctx.handlers = append(gctx.handlers, matchResult.Handlers...)
-		}
-		if len(matchResult.PathParams) > 0 {
-			for k, v := range matchResult.PathParams {
-				gctx.pathParams[k] = v
-			}
-		}
+	} else if !s.handleUnmatched(gctx, method, routePath) {
+		return
 	}
 
 	// Execute middleware chain
@@ -264,6 +311,11 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	s.FastHandler(&fastCtx)
 
+	if fastCtx.Hijacked() {
+		http.Error(w, "connection upgrade requires the fasthttp server", http.StatusNotImplemented)
+		return
+	}
+
 	writeFastResponseToHTTP(w, &fastCtx.Response)
 }
 
@@ -287,12 +339,146 @@ func (s *Server) createStaticHandler(fs http.FileSystem) HandlerFunc {
 			return
 		}
 
-		if !serveFileFromFS(ctx, req, fs, filepath) && !ctx.Writer.Written() {
+		if !serveFileFromFS(ctx, req, fs, filepath, s.precompressed) && !ctx.Writer.Written() {
 			ctx.Status(http.StatusNotFound)
 		}
 	}
 }
 
+// lookupRoute fills the context with the handlers and parameters of the route
+// matching method and path.
+func (s *Server) lookupRoute(gctx *Context, method, routePath string) bool {
+	if fm, ok := s.Match.(interface {
+		LookupInto(method, path string, dstParams map[string]string) (handlers []HandlerFunc, fullPath string, ok bool)
+	}); ok {
+		handlers, fullPath, ok := fm.LookupInto(method, routePath, gctx.pathParams)
+		if !ok {
+			return false
+		}
+		gctx.fullPath = fullPath
+		if len(handlers) > 0 {
+			gctx.handlers = append(gctx.handlers, handlers...)
+		}
+		return true
+	}
+
+	matchResult := s.Lookup(method, routePath)
+	if matchResult == nil {
+		return false
+	}
+	gctx.fullPath = matchResult.Path
+	if len(matchResult.Handlers) > 0 {
+		gctx.handlers = append(gctx.handlers, matchResult.Handlers...)
+	}
+	for k, v := range matchResult.PathParams {
+		gctx.pathParams[k] = v
+	}
+	return true
+}
+
+// handleUnmatched resolves a request without an exact route match. It returns
+// true when the context now holds a handler chain to execute.
+func (s *Server) handleUnmatched(gctx *Context, method, routePath string) bool {
+	// Trailing slash variant
+	if routePath != "/" {
+		alt := routePath + "/"
+		if strings.HasSuffix(routePath, "/") {
+			alt = strings.TrimSuffix(routePath, "/")
+		}
+		if s.lookupRoute(gctx, method, alt) {
+			if !s.redirectTrailingSlash {
+				return true
+			}
+			gctx.handlers = gctx.handlers[:0]
+			s.redirectRequest(gctx, alt)
+			return false
+		}
+	}
+
+	if s.redirectFixedPath {
+		if fixer, ok := s.Match.(PathFixer); ok {
+			if fixed, ok := fixer.FixedPath(method, routePath); ok {
+				s.redirectRequest(gctx, fixed)
+				return false
+			}
+		}
+	}
+
+	if reporter, ok := s.Match.(MethodReporter); ok && (s.handleOptions || s.handleMethodNotAllowed) {
+		if allowed := reporter.AllowedMethods(routePath); len(allowed) > 0 {
+			if s.handleOptions {
+				allowed = appendMethod(allowed, http.MethodOptions)
+			}
+			switch {
+			case method == http.MethodOptions && s.handleOptions:
+				// Global middleware such as CORS still sees preflight requests.
+				gctx.Header("Allow", strings.Join(allowed, ", "))
+				gctx.Status(http.StatusNoContent)
+				gctx.handlers = append(gctx.handlers, s.globalHandlers()...)
+				return len(gctx.handlers) > 0
+			case s.handleMethodNotAllowed:
+				gctx.Header("Allow", strings.Join(allowed, ", "))
+				gctx.Status(http.StatusMethodNotAllowed)
+				if len(s.noMethod) == 0 {
+					return false
+				}
+				gctx.handlers = append(gctx.handlers, s.globalHandlers()...)
+				gctx.handlers = append(gctx.handlers, s.noMethod...)
+				return true
+			}
+		}
+	}
+
+	if len(s.noRoute) == 0 {
+		s.handleNotFound(gctx)
+		return false
+	}
+	gctx.Status(http.StatusNotFound)
+	gctx.handlers = append(gctx.handlers, s.globalHandlers()...)
+	gctx.handlers = append(gctx.handlers, s.noRoute...)
+	return true
+}
+
+// globalHandlers returns the middleware registered with Server.Use.
+func (s *Server) globalHandlers() []HandlerFunc {
+	if root, ok := s.IRouter.(*RouterGroup); ok {
+		return root.Handlers
+	}
+	return nil
+}
+
+// redirectRequest redirects to target keeping the query string. GET and HEAD use
+// 301, other methods 308 so the method and body are preserved.
+func (s *Server) redirectRequest(gctx *Context, target string) {
+	code := http.StatusPermanentRedirect
+	if method := gctx.fastCtx.Method(); string(method) == http.MethodGet || string(method) == http.MethodHead {
+		code = http.StatusMovedPermanently
+	}
+	if query := gctx.fastCtx.URI().QueryString(); len(query) > 0 {
+		target += "?" + string(query)
+	}
+	gctx.Header("Location", target)
+	gctx.Status(code)
+}
+
+func appendMethod(methods []string, method string) []string {
+	for _, m := range methods {
+		if m == method {
+			return methods
+		}
+	}
+	return append(methods, method)
+}
+
+// cleanRoutePath cleans p like path.Clean but keeps a trailing slash.
+func cleanRoutePath(p string) string {
+	cleaned := path.Clean(p)
+	if cleaned != "/" && strings.HasSuffix(p, "/") {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
 func (s *Server) handleNotFound(ctx *Context) {
 	ctx.Writer.WriteHeader(http.StatusNotFound)
 }
@@ -449,8 +635,8 @@ func buildHTTPRequestFromFast(ctx *fasthttp.RequestCtx) *http.Request {
 	return req
 }
 
-func serveFileFromFS(ctx *Context, req *http.Request, fs http.FileSystem, filepath string) bool {
-	file, info, err := openStaticFile(fs, filepath)
+func serveFileFromFS(ctx *Context, req *http.Request, fs http.FileSystem, filepath string, precompressed []string) bool {
+	file, info, name, err := openStaticFile(fs, filepath)
 	if err != nil {
 		return false
 	}
@@ -464,24 +650,85 @@ func serveFileFromFS(ctx *Context, req *http.Request, fs http.FileSystem, filepa
 		req.URL.Path = servedPath
 	}
 
-	http.ServeContent(ctx.Writer, req, info.Name(), info.ModTime(), file)
+	content, modTime := io.ReadSeeker(file), info.ModTime()
+	if len(precompressed) > 0 {
+		ctx.Writer.Header().Add("Vary", "Accept-Encoding")
+		if sibling, siblingInfo, encoding := openPrecompressed(fs, name, precompressed, req.Header.Get("Accept-Encoding")); sibling != nil {
+			defer sibling.Close()
+			ctx.Header("Content-Type", staticContentType(name, file))
+			ctx.Header("Content-Encoding", encoding)
+			content, modTime = sibling, siblingInfo.ModTime()
+		}
+	}
+
+	http.ServeContent(ctx.Writer, req, info.Name(), modTime, content)
 	return true
 }
 
-func openStaticFile(fs http.FileSystem, name string) (http.File, os.FileInfo, error) {
+var precompressedExts = map[string]string{
+	EncodingBrotli: ".br",
+	EncodingGzip:   ".gz",
+	EncodingZstd:   ".zst",
+}
+
+// openPrecompressed opens the sibling of name in the best coding the client accepts.
+func openPrecompressed(fs http.FileSystem, name string, encodings []string, acceptEncoding string) (http.File, os.FileInfo, string) {
+	if acceptEncoding == "" {
+		return nil, nil, ""
+	}
+	available := make([]string, 0, len(encodings))
+	for _, enc := range encodings {
+		if precompressedExts[enc] != "" {
+			available = append(available, enc)
+		}
+	}
+	for len(available) > 0 {
+		enc := NegotiateEncoding(acceptEncoding, available)
+		if enc == "" {
+			return nil, nil, ""
+		}
+		if f, err := fs.Open(name + precompressedExts[enc]); err == nil {
+			if fi, err := f.Stat(); err == nil && !fi.IsDir() {
+				return f, fi, enc
+			}
+			_ = f.Close()
+		}
+		for i, a := range available {
+			if a == enc {
+				available = append(available[:i], available[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil, nil, ""
+}
+
+// staticContentType detects the type of the uncompressed file, since sniffing the
+// compressed sibling would be meaningless.
+func staticContentType(name string, file io.ReadSeeker) string {
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		return ctype
+	}
+	var buf [512]byte
+	n, _ := io.ReadFull(file, buf[:])
+	_, _ = file.Seek(0, io.SeekStart)
+	return http.DetectContentType(buf[:n])
+}
+
+func openStaticFile(fs http.FileSystem, name string) (http.File, os.FileInfo, string, error) {
 	if name == "" {
 		name = "."
 	}
 
 	f, err := fs.Open(name)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 
 	info, err := f.Stat()
 	if err != nil {
 		_ = f.Close()
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 
 	if info.IsDir() {
@@ -489,19 +736,19 @@ func openStaticFile(fs http.FileSystem, name string) (http.File, os.FileInfo, er
 		indexPath := path.Join(name, "index.html")
 		indexFile, err := fs.Open(indexPath)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, "", err
 		}
 		indexInfo, err := indexFile.Stat()
 		if err != nil {
 			_ = indexFile.Close()
-			return nil, nil, err
+			return nil, nil, "", err
 		}
 		if indexInfo.IsDir() {
 			_ = indexFile.Close()
-			return nil, nil, os.ErrNotExist
+			return nil, nil, "", os.ErrNotExist
 		}
-		return indexFile, indexInfo, nil
+		return indexFile, indexInfo, indexPath, nil
 	}
 
-	return f, info, nil
+	return f, info, name, nil
 }
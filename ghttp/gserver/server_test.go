@@ -89,6 +89,103 @@ func TestServerNotFound(t *testing.T) {
 	}
 }
 
+func TestServerMethodNotAllowedAndOptions(t *testing.T) {
+	server := NewServer()
+	server.GET("/users/:id", func(c *Context) { c.Status(http.StatusOK) })
+	server.DELETE("/users/:id", func(c *Context) { c.Status(http.StatusNoContent) })
+
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/users/1", nil))
+	if resp.Code != http.StatusMethodNotAllowed || resp.Header().Get("Allow") != "DELETE, GET, OPTIONS" {
+		t.Fatalf("expected 405 with Allow, got %d %q", resp.Code, resp.Header().Get("Allow"))
+	}
+
+	resp = httptest.NewRecorder()
+	server.ServeHTTP(resp, httptest.NewRequest(http.MethodOptions, "/users/1", nil))
+	if resp.Code != http.StatusNoContent || resp.Header().Get("Allow") != "DELETE, GET, OPTIONS" {
+		t.Fatalf("expected automatic OPTIONS, got %d %q", resp.Code, resp.Header().Get("Allow"))
+	}
+
+	// Global middleware runs for the automatic OPTIONS reply, so CORS preflights work.
+	server.Use(CORS(CORSConfig{}))
+	req := httptest.NewRequest(http.MethodOptions, "/users/1", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	resp = httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNoContent || resp.Header().Get("Access-Control-Allow-Origin") != "https://app.example" ||
+		resp.Header().Get("Access-Control-Allow-Methods") == "" || resp.Header().Get("Allow") != "DELETE, GET, OPTIONS" {
+		t.Fatalf("expected a CORS preflight reply, got %d %v", resp.Code, resp.Header())
+	}
+
+	disabled := NewServer(WithMethodNotAllowed(false), WithAutoOptions(false))
+	disabled.GET("/users/:id", func(c *Context) {})
+	resp = httptest.NewRecorder()
+	disabled.ServeHTTP(resp, httptest.NewRequest(http.MethodOptions, "/users/1", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 when disabled, got %d", resp.Code)
+	}
+}
+
+func TestServerNoRouteAndNoMethodHandlers(t *testing.T) {
+	server := NewServer(
+		WithNoRoute(func(c *Context) { c.JSON(http.StatusNotFound, map[string]string{"error": "no route"}) }),
+		WithNoMethod(func(c *Context) { c.String(http.StatusMethodNotAllowed, "allow: %s", c.Writer.Header().Get("Allow")) }),
+	)
+	var middleware int
+	server.Use(func(c *Context) { middleware++; c.Next() })
+	server.GET("/ping", func(c *Context) {})
+
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if resp.Code != http.StatusNotFound || !strings.Contains(resp.Body.String(), "no route") {
+		t.Fatalf("unexpected NoRoute response %d %q", resp.Code, resp.Body.String())
+	}
+
+	resp = httptest.NewRecorder()
+	server.ServeHTTP(resp, httptest.NewRequest(http.MethodPut, "/ping", nil))
+	if resp.Code != http.StatusMethodNotAllowed || resp.Header().Get("Allow") != "GET, OPTIONS" {
+		t.Fatalf("unexpected NoMethod response %d %v", resp.Code, resp.Header())
+	}
+	if middleware != 2 {
+		t.Fatalf("global middleware should run for NoRoute and NoMethod, ran %d times", middleware)
+	}
+}
+
+func TestServerRedirects(t *testing.T) {
+	server := NewServer(WithRedirectTrailingSlash(true), WithRedirectFixedPath(true))
+	server.GET("/users", func(c *Context) { c.String(http.StatusOK, "users") })
+	server.GET("/Docs/:page", func(c *Context) { c.String(http.StatusOK, "%s", c.Param("page")) })
+	server.POST("/items/", func(c *Context) { c.Status(http.StatusCreated) })
+
+	cases := []struct {
+		method, target, location string
+		code                     int
+	}{
+		{http.MethodGet, "/users/?a=1", "/users?a=1", http.StatusMovedPermanently},
+		{http.MethodPost, "/items", "/items/", http.StatusPermanentRedirect},
+		{http.MethodGet, "/USERS", "/users", http.StatusMovedPermanently},
+		{http.MethodGet, "/docs/Intro", "/Docs/Intro", http.StatusMovedPermanently},
+		{http.MethodGet, "/./users", "/users", http.StatusMovedPermanently},
+	}
+	for _, tc := range cases {
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, httptest.NewRequest(tc.method, tc.target, nil))
+		if resp.Code != tc.code || resp.Header().Get("Location") != tc.location {
+			t.Errorf("%s %s: got %d %q", tc.method, tc.target, resp.Code, resp.Header().Get("Location"))
+		}
+	}
+
+	// Without redirects the other trailing slash form is served directly.
+	lenient := NewServer()
+	lenient.GET("/users", func(c *Context) { c.String(http.StatusOK, "users") })
+	resp := httptest.NewRecorder()
+	lenient.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/users/", nil))
+	if resp.Code != http.StatusOK || resp.Body.String() != "users" {
+		t.Fatalf("expected lenient match, got %d", resp.Code)
+	}
+}
+
 func BenchmarkServerFastHandler(b *testing.B) {
 	server := NewServer()
 	server.GET("/bench/:id", func(c *Context) {
@@ -0,0 +1,448 @@
+package gserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/sofiworker/gk/gcache"
+	"github.com/sofiworker/gk/gcrypt"
+	"github.com/valyala/fasthttp"
+)
+
+// maxSessionCookieSize is the limit browsers are required to support for a single cookie.
+const maxSessionCookieSize = 4096
+
+var (
+	// ErrSessionCookieTooLarge is logged when a cookie-stored session exceeds 4 KiB.
+	// Move the session to a server-side store or keep less data in it.
+	ErrSessionCookieTooLarge = errors.New("session cookie exceeds 4096 bytes")
+
+	errSessionInvalid = errors.New("invalid session cookie")
+)
+
+// SessionStore keeps encoded sessions on the server. Get returns nil data for an
+// unknown or expired id.
+type SessionStore interface {
+	Get(ctx context.Context, id string) ([]byte, error)
+	Set(ctx context.Context, id string, data []byte, ttl time.Duration) error
+	Delete(ctx context.Context, id string) error
+}
+
+type SessionConfig struct {
+	// Keys encrypt and authenticate the session cookie. New cookies use the first
+	// key; the others are still accepted, and sessions read with them are re-issued
+	// with the first key, so keys can be rotated by prepending a new one. Required.
+	Keys [][]byte
+	// Store keeps sessions on the server and only puts the session id in the cookie.
+	// When nil the whole session is kept in the encrypted cookie.
+	Store SessionStore
+
+	// CookieName defaults to "session" and Path to "/". The cookie is always HttpOnly.
+	CookieName string
+	Path       string
+	Domain     string
+	Secure     bool
+	// SameSite defaults to Lax.
+	SameSite fasthttp.CookieSameSite
+	// Persistent gives the cookie an expiry at the absolute timeout instead of
+	// ending it with the browser session.
+	Persistent bool
+
+	// IdleTimeout ends sessions that are not used for this long, default 30 minutes.
+	IdleTimeout time.Duration
+	// AbsoluteTimeout ends sessions this long after they were created regardless of
+	// activity, default 24 hours.
+	AbsoluteTimeout time.Duration
+}
+
+// Session holds per-client values across requests. Values are stored as JSON, so
+// a value read back in a later request has its JSON type, for example float64 for
+// numbers and map[string]interface{} for structs.
+type Session struct {
+	id      string
+	values  map[string]interface{}
+	flashes []interface{}
+	created time.Time
+	touched time.Time
+
+	isNew     bool
+	dirty     bool
+	destroyed bool
+	// staleID is deleted from the store after Regenerate.
+	staleID string
+}
+
+// sessionRecord is the stored form of a Session.
+type sessionRecord struct {
+	ID      string                 `json:"i,omitempty"`
+	Values  map[string]interface{} `json:"v,omitempty"`
+	Flashes []interface{}          `json:"f,omitempty"`
+	Created int64                  `json:"c"`
+	Touched int64                  `json:"t"`
+}
+
+// ID returns the session id. Cookie-stored sessions have an id too, which changes on Regenerate.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// IsNew reports whether the session was created by the current request.
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+func (s *Session) Get(key string) interface{} {
+	return s.values[key]
+}
+
+func (s *Session) Set(key string, value interface{}) {
+	if s.values == nil {
+		s.values = make(map[string]interface{})
+	}
+	s.values[key] = value
+	s.dirty = true
+}
+
+func (s *Session) Delete(key string) {
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.dirty = true
+	}
+}
+
+// AddFlash queues a value that is returned once by Flashes, typically on the next request.
+func (s *Session) AddFlash(value interface{}) {
+	s.flashes = append(s.flashes, value)
+	s.dirty = true
+}
+
+// Flashes returns and clears the queued flash values.
+func (s *Session) Flashes() []interface{} {
+	flashes := s.flashes
+	if len(flashes) > 0 {
+		s.flashes = nil
+		s.dirty = true
+	}
+	return flashes
+}
+
+// Regenerate moves the session to a new id and keeps its values. Call it when the
+// privilege level changes, such as after login, to prevent session fixation.
+func (s *Session) Regenerate() {
+	if !s.isNew && s.staleID == "" {
+		s.staleID = s.id
+	}
+	s.id = newSessionID()
+	s.dirty = true
+}
+
+// Destroy deletes the session and expires its cookie.
+func (s *Session) Destroy() {
+	s.values = nil
+	s.flashes = nil
+	s.destroyed = true
+}
+
+type sessionKey struct{}
+
+// Session returns the session of the request, loading it on first use, or nil
+// when the Sessions middleware is not installed.
+func (c *Context) Session() *Session {
+	state, _ := c.Value(sessionKey{}).(*sessionState)
+	if state == nil {
+		return nil
+	}
+	if state.session == nil {
+		state.session = state.load(c)
+	}
+	return state.session
+}
+
+type sessionState struct {
+	cfg     *SessionConfig
+	codec   *sessionCodec
+	session *Session
+	// reissue is set when the cookie was read with a rotated key.
+	reissue bool
+}
+
+// Sessions adds a lazily loaded session to every request, available through
+// ctx.Session(). The session is saved and its cookie set after the handler
+// returns, only when it was accessed.
+func Sessions(cfg SessionConfig) HandlerFunc {
+	if len(cfg.Keys) == 0 {
+		panic("gserver: Sessions requires at least one key")
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "session"
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.SameSite == fasthttp.CookieSameSiteDisabled {
+		cfg.SameSite = fasthttp.CookieSameSiteLaxMode
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 30 * time.Minute
+	}
+	if cfg.AbsoluteTimeout <= 0 {
+		cfg.AbsoluteTimeout = 24 * time.Hour
+	}
+	codec := newSessionCodec(cfg.CookieName, cfg.Keys)
+
+	return func(c *Context) {
+		state := &sessionState{cfg: &cfg, codec: codec}
+		c.Set(sessionKey{}, state)
+		c.Next()
+		if state.session != nil {
+			state.save(c)
+		}
+	}
+}
+
+func (st *sessionState) load(c *Context) *Session {
+	now := time.Now()
+	fresh := &Session{id: newSessionID(), created: now, touched: now, isNew: true}
+
+	token := c.Cookie(st.cfg.CookieName)
+	if token == "" {
+		return fresh
+	}
+	payload, keyIndex, err := st.codec.decode(token)
+	if err != nil {
+		return fresh
+	}
+	st.reissue = keyIndex > 0
+
+	var record sessionRecord
+	if st.cfg.Store == nil {
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return fresh
+		}
+	} else {
+		record.ID = string(payload)
+		data, err := st.cfg.Store.Get(c.Context(), record.ID)
+		if err != nil {
+			if c.logger != nil {
+				c.logger.Warnf("session store: %v", err)
+			}
+			return fresh
+		}
+		if data == nil || json.Unmarshal(data, &record) != nil {
+			return fresh
+		}
+	}
+
+	s := &Session{
+		id:      record.ID,
+		values:  record.Values,
+		flashes: record.Flashes,
+		created: time.Unix(0, record.Created),
+		touched: time.Unix(0, record.Touched),
+	}
+	if now.Sub(s.touched) >= st.cfg.IdleTimeout || now.Sub(s.created) >= st.cfg.AbsoluteTimeout {
+		// Expired server-side sessions are removed when the new one is saved.
+		fresh.staleID = s.id
+		return fresh
+	}
+	// Refreshing the idle deadline on every request would write the store each
+	// time, so the session is only touched once a tenth of the idle timeout passed.
+	if now.Sub(s.touched) >= min(st.cfg.IdleTimeout/10, time.Minute) {
+		s.touched = now
+		s.dirty = true
+	}
+	return s
+}
+
+func (st *sessionState) save(c *Context) {
+	s := st.session
+	cfg := st.cfg
+	ctx := c.Context()
+	logErr := func(err error) {
+		if c.logger != nil {
+			c.logger.Warnf("session: %v", err)
+		}
+	}
+
+	if cfg.Store != nil && s.staleID != "" {
+		if err := cfg.Store.Delete(ctx, s.staleID); err != nil {
+			logErr(err)
+		}
+	}
+	if s.destroyed {
+		if cfg.Store != nil && !s.isNew {
+			if err := cfg.Store.Delete(ctx, s.id); err != nil {
+				logErr(err)
+			}
+		}
+		if c.Cookie(cfg.CookieName) != "" {
+			st.setCookie(c, "", fasthttp.CookieExpireDelete)
+		}
+		return
+	}
+	if !s.dirty && !st.reissue {
+		return
+	}
+	if s.isNew && len(s.values) == 0 && len(s.flashes) == 0 {
+		// Do not hand out cookies for sessions that never held anything.
+		return
+	}
+
+	deadline := s.created.Add(cfg.AbsoluteTimeout)
+	ttl := min(cfg.IdleTimeout, time.Until(deadline))
+	record := sessionRecord{
+		Values:  s.values,
+		Flashes: s.flashes,
+		Created: s.created.UnixNano(),
+		Touched: s.touched.UnixNano(),
+	}
+
+	var payload []byte
+	if cfg.Store == nil {
+		record.ID = s.id
+		data, err := json.Marshal(record)
+		if err != nil {
+			logErr(err)
+			return
+		}
+		payload = data
+	} else {
+		data, err := json.Marshal(record)
+		if err != nil {
+			logErr(err)
+			return
+		}
+		if err := cfg.Store.Set(ctx, s.id, data, ttl); err != nil {
+			logErr(err)
+			return
+		}
+		payload = []byte(s.id)
+	}
+
+	token, err := st.codec.encode(payload)
+	if err != nil {
+		logErr(err)
+		return
+	}
+	if len(cfg.CookieName)+len(token) > maxSessionCookieSize {
+		logErr(ErrSessionCookieTooLarge)
+		return
+	}
+	var expires time.Time
+	if cfg.Persistent {
+		expires = deadline
+	}
+	st.setCookie(c, token, expires)
+}
+
+func (st *sessionState) setCookie(c *Context, value string, expires time.Time) {
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey(st.cfg.CookieName)
+	cookie.SetValue(value)
+	cookie.SetPath(st.cfg.Path)
+	cookie.SetDomain(st.cfg.Domain)
+	cookie.SetSecure(st.cfg.Secure)
+	cookie.SetHTTPOnly(true)
+	cookie.SetSameSite(st.cfg.SameSite)
+	if !expires.IsZero() {
+		cookie.SetExpire(expires)
+	}
+	c.SetCookie(cookie)
+}
+
+func newSessionID() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// sessionCodec encrypts cookie payloads with AES and authenticates them with
+// HMAC-SHA256 (encrypt-then-MAC). Both keys are derived from each configured key.
+type sessionCodec struct {
+	name string
+	keys []sessionCodecKey
+}
+
+type sessionCodecKey struct {
+	enc, mac []byte
+}
+
+func newSessionCodec(name string, keys [][]byte) *sessionCodec {
+	codec := &sessionCodec{name: name}
+	for _, key := range keys {
+		codec.keys = append(codec.keys, sessionCodecKey{
+			enc: gcrypt.HMAC_SHA256([]byte("gk session encryption"), key),
+			mac: gcrypt.HMAC_SHA256([]byte("gk session authentication"), key),
+		})
+	}
+	return codec
+}
+
+func (sc *sessionCodec) encode(payload []byte) (string, error) {
+	key := sc.keys[0]
+	ciphertext, err := gcrypt.AESEncrypt(payload, key.enc)
+	if err != nil {
+		return "", err
+	}
+	// The cookie name is authenticated too, so a value cannot be replayed under another cookie.
+	mac := gcrypt.HMAC_SHA256(append([]byte(sc.name+"|"), ciphertext...), key.mac)
+	return base64.RawURLEncoding.EncodeToString(append(ciphertext, mac...)), nil
+}
+
+// decode returns the payload and the index of the key that authenticated it.
+func (sc *sessionCodec) decode(token string) ([]byte, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) < 32 {
+		return nil, 0, errSessionInvalid
+	}
+	ciphertext, mac := raw[:len(raw)-32], raw[len(raw)-32:]
+	signed := append([]byte(sc.name+"|"), ciphertext...)
+	for i, key := range sc.keys {
+		if !hmac.Equal(mac, gcrypt.HMAC_SHA256(signed, key.mac)) {
+			continue
+		}
+		payload, err := gcrypt.AESDecrypt(ciphertext, key.enc)
+		if err != nil {
+			return nil, 0, errSessionInvalid
+		}
+		return payload, i, nil
+	}
+	return nil, 0, errSessionInvalid
+}
+
+// CacheSessionStore keeps sessions in a gcache cache under a key prefix.
+type CacheSessionStore struct {
+	cache  gcache.KeyValueCacheWithContext
+	prefix string
+}
+
+// NewCacheSessionStore works with *gcache.MemoryCache, *gcache.RedisCache and
+// *gcache.ValkeyCache. An empty prefix defaults to "session:".
+func NewCacheSessionStore(cache gcache.KeyValueCacheWithContext, prefix string) *CacheSessionStore {
+	if prefix == "" {
+		prefix = "session:"
+	}
+	return &CacheSessionStore{cache: cache, prefix: prefix}
+}
+
+func (s *CacheSessionStore) Get(ctx context.Context, id string) ([]byte, error) {
+	data, err := s.cache.GetWithContext(ctx, s.prefix+id)
+	if errors.Is(err, gcache.ErrCacheMiss) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *CacheSessionStore) Set(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	return s.cache.SetWithContext(ctx, s.prefix+id, data, ttl)
+}
+
+func (s *CacheSessionStore) Delete(ctx context.Context, id string) error {
+	return s.cache.DeleteWithContext(ctx, s.prefix+id)
+}
@@ -0,0 +1,195 @@
+package gserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sofiworker/gk/gcache"
+)
+
+// sessionClient replays the session cookie like a browser.
+type sessionClient struct {
+	t      *testing.T
+	server *Server
+	cookie *http.Cookie
+}
+
+func (sc *sessionClient) get(path string) *httptest.ResponseRecorder {
+	sc.t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if sc.cookie != nil {
+		req.AddCookie(sc.cookie)
+	}
+	rec := httptest.NewRecorder()
+	sc.server.ServeHTTP(rec, req)
+	for _, ck := range rec.Result().Cookies() {
+		if ck.Name == "session" {
+			if ck.MaxAge < 0 || ck.Value == "" {
+				sc.cookie = nil
+			} else {
+				sc.cookie = ck
+			}
+		}
+	}
+	return rec
+}
+
+func newSessionServer(cfg SessionConfig) *Server {
+	server := NewServer()
+	server.Use(Sessions(cfg))
+	server.GET("/set", func(c *Context) {
+		c.Session().Set("user", c.Query("user"))
+		c.Status(http.StatusNoContent)
+	})
+	server.GET("/get", func(c *Context) {
+		c.String(http.StatusOK, "%v", c.Session().Get("user"))
+	})
+	server.GET("/flash", func(c *Context) {
+		c.Session().AddFlash(c.Query("msg"))
+		c.Status(http.StatusNoContent)
+	})
+	server.GET("/flashes", func(c *Context) {
+		c.String(http.StatusOK, "%v", c.Session().Flashes())
+	})
+	server.GET("/login", func(c *Context) {
+		c.Session().Regenerate()
+		c.String(http.StatusOK, "%s", c.Session().ID())
+	})
+	server.GET("/logout", func(c *Context) {
+		c.Session().Destroy()
+		c.Status(http.StatusNoContent)
+	})
+	return server
+}
+
+func TestCookieSession(t *testing.T) {
+	server := newSessionServer(SessionConfig{Keys: [][]byte{[]byte("0123456789abcdef0123456789abcdef")}})
+	client := &sessionClient{t: t, server: server}
+
+	if rec := client.get("/get"); rec.Body.String() != "<nil>" || client.cookie != nil {
+		t.Fatalf("an untouched session must not set a cookie, got %q", rec.Header().Get("Set-Cookie"))
+	}
+	client.get("/set?user=alice")
+	if client.cookie == nil || !client.cookie.HttpOnly {
+		t.Fatal("expected an HttpOnly session cookie")
+	}
+	if rec := client.get("/get"); rec.Body.String() != "alice" {
+		t.Fatalf("unexpected value %q", rec.Body.String())
+	}
+
+	tampered := []byte(client.cookie.Value)
+	tampered[0] ^= 1
+	client.cookie.Value = string(tampered)
+	if rec := client.get("/get"); rec.Body.String() != "<nil>" {
+		t.Fatalf("a tampered cookie must be ignored, got %q", rec.Body.String())
+	}
+}
+
+func TestCacheSessionStore(t *testing.T) {
+	cache, err := gcache.NewMemoryCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	server := newSessionServer(SessionConfig{
+		Keys:  [][]byte{[]byte("server-side session key")},
+		Store: NewCacheSessionStore(cache, ""),
+	})
+	client := &sessionClient{t: t, server: server}
+
+	client.get("/set?user=bob")
+	if rec := client.get("/get"); rec.Body.String() != "bob" {
+		t.Fatalf("unexpected value %q", rec.Body.String())
+	}
+
+	before := client.cookie.Value
+	oldCookie := client.cookie
+	newID := client.get("/login").Body.String()
+	if client.cookie.Value == before {
+		t.Fatal("Regenerate must issue a new cookie")
+	}
+	if ok, _ := cache.Exists("session:" + newID); !ok {
+		t.Fatal("regenerated session must be stored under its new id")
+	}
+	if rec := client.get("/get"); rec.Body.String() != "bob" {
+		t.Fatalf("Regenerate must keep values, got %q", rec.Body.String())
+	}
+	stale := &sessionClient{t: t, server: server, cookie: oldCookie}
+	if rec := stale.get("/get"); rec.Body.String() != "<nil>" {
+		t.Fatalf("the pre-login session must be gone, got %q", rec.Body.String())
+	}
+
+	client.get("/logout")
+	if client.cookie != nil {
+		t.Fatal("Destroy must expire the cookie")
+	}
+	if ok, _ := cache.Exists("session:" + newID); ok {
+		t.Fatal("Destroy must delete the stored session")
+	}
+}
+
+func TestSessionFlashes(t *testing.T) {
+	server := newSessionServer(SessionConfig{Keys: [][]byte{[]byte("flash key")}})
+	client := &sessionClient{t: t, server: server}
+
+	client.get("/flash?msg=saved")
+	client.get("/flash?msg=again")
+	if rec := client.get("/flashes"); rec.Body.String() != "[saved again]" {
+		t.Fatalf("unexpected flashes %q", rec.Body.String())
+	}
+	if rec := client.get("/flashes"); rec.Body.String() != "[]" {
+		t.Fatalf("flashes must be consumed, got %q", rec.Body.String())
+	}
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	for name, cfg := range map[string]SessionConfig{
+		"idle":     {IdleTimeout: 30 * time.Millisecond},
+		"absolute": {AbsoluteTimeout: 30 * time.Millisecond},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg.Keys = [][]byte{[]byte("timeout key")}
+			client := &sessionClient{t: t, server: newSessionServer(cfg)}
+			client.get("/set?user=carol")
+			time.Sleep(50 * time.Millisecond)
+			if rec := client.get("/get"); rec.Body.String() != "<nil>" {
+				t.Fatalf("expected an expired session, got %q", rec.Body.String())
+			}
+		})
+	}
+
+	// Activity within the idle timeout keeps the session alive.
+	client := &sessionClient{t: t, server: newSessionServer(SessionConfig{
+		Keys:        [][]byte{[]byte("timeout key")},
+		IdleTimeout: 60 * time.Millisecond,
+	})}
+	client.get("/set?user=dave")
+	for i := 0; i < 4; i++ {
+		time.Sleep(25 * time.Millisecond)
+		if rec := client.get("/get"); rec.Body.String() != "dave" {
+			t.Fatalf("request %d: session expired while active", i)
+		}
+	}
+}
+
+func TestSessionKeyRotation(t *testing.T) {
+	oldKey, newKey := []byte("old key"), []byte("new key")
+	client := &sessionClient{t: t, server: newSessionServer(SessionConfig{Keys: [][]byte{oldKey}})}
+	client.get("/set?user=erin")
+
+	client.server = newSessionServer(SessionConfig{Keys: [][]byte{newKey, oldKey}})
+	before := client.cookie.Value
+	if rec := client.get("/get"); rec.Body.String() != "erin" {
+		t.Fatalf("cookies encrypted with a rotated key must be accepted, got %q", rec.Body.String())
+	}
+	if client.cookie.Value == before {
+		t.Fatal("expected the cookie to be re-issued with the new key")
+	}
+
+	client.server = newSessionServer(SessionConfig{Keys: [][]byte{newKey}})
+	if rec := client.get("/get"); rec.Body.String() != "erin" {
+		t.Fatalf("re-issued cookie must use the new key, got %q", rec.Body.String())
+	}
+}
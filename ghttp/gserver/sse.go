@@ -0,0 +1,365 @@
+package gserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MIMEEventStream = "text/event-stream"
+
+	defaultSSEHeartbeat = 15 * time.Second
+)
+
+var ErrSSEClosed = errors.New("sse: stream closed")
+
+// SSEEvent mirrors gclient.SSEEvent. Retry is expressed in milliseconds.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry int
+}
+
+// SSEHandler produces events for one client. Returning ends the stream.
+type SSEHandler func(w *SSEWriter) error
+
+// SSEReplayBuffer stores recently published events so reconnecting clients can
+// resume after the id they send in Last-Event-ID.
+type SSEReplayBuffer interface {
+	// Append stores ev, assigning an id when ev.ID is empty, and returns the stored event.
+	Append(ev SSEEvent) SSEEvent
+	// Since returns the events published after lastEventID. ok is false when the
+	// id is unknown, e.g. because it has already been evicted.
+	Since(lastEventID string) (events []SSEEvent, ok bool)
+}
+
+// ==================== SSE Writer ====================
+
+// SSEWriter writes event-stream frames and flushes after each one.
+// It is safe for concurrent use.
+type SSEWriter struct {
+	mu          sync.Mutex
+	w           *bufio.Writer
+	ctx         context.Context
+	cancel      context.CancelFunc
+	lastEventID string
+	err         error
+}
+
+// Context is canceled when the client disconnects, the server shuts down or the result context ends.
+func (w *SSEWriter) Context() context.Context {
+	return w.ctx
+}
+
+// LastEventID returns the Last-Event-ID sent by a reconnecting client.
+func (w *SSEWriter) LastEventID() string {
+	return w.lastEventID
+}
+
+// Send writes one event and flushes it to the client.
+func (w *SSEWriter) Send(ev SSEEvent) error {
+	var buf strings.Builder
+	writeSSEEvent(&buf, ev)
+	return w.writeFrame(buf.String())
+}
+
+// SendData sends an unnamed event.
+func (w *SSEWriter) SendData(data string) error {
+	return w.Send(SSEEvent{Data: data})
+}
+
+// SendJSON sends v encoded as JSON under the given event name.
+func (w *SSEWriter) SendJSON(event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return w.Send(SSEEvent{Event: event, Data: string(data)})
+}
+
+// Comment writes a comment line, which clients ignore.
+func (w *SSEWriter) Comment(text string) error {
+	var buf strings.Builder
+	for _, line := range splitSSELines(text) {
+		buf.WriteString(": ")
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return w.writeFrame(buf.String())
+}
+
+func (w *SSEWriter) writeFrame(frame string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.ctx.Err(); err != nil {
+		w.err = ErrSSEClosed
+		return w.err
+	}
+	if _, err := w.w.WriteString(frame); err != nil {
+		w.fail(err)
+		return err
+	}
+	if err := w.w.Flush(); err != nil {
+		w.fail(err)
+		return err
+	}
+	return nil
+}
+
+func (w *SSEWriter) fail(err error) {
+	w.err = err
+	w.cancel()
+}
+
+func writeSSEEvent(buf *strings.Builder, ev SSEEvent) {
+	if ev.ID != "" {
+		buf.WriteString("id: ")
+		buf.WriteString(stripSSENewlines(ev.ID))
+		buf.WriteByte('\n')
+	}
+	if ev.Event != "" {
+		buf.WriteString("event: ")
+		buf.WriteString(stripSSENewlines(ev.Event))
+		buf.WriteByte('\n')
+	}
+	if ev.Retry > 0 {
+		buf.WriteString("retry: ")
+		buf.WriteString(strconv.Itoa(ev.Retry))
+		buf.WriteByte('\n')
+	}
+	for _, line := range splitSSELines(ev.Data) {
+		buf.WriteString("data: ")
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+}
+
+func splitSSELines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
+
+func stripSSENewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// ==================== SSE Result ====================
+
+// SSEResult streams Server-Sent Events through fasthttp's body stream writer.
+// The handler runs after the middleware chain has returned, so it must not use the *Context.
+type SSEResult struct {
+	Handler   SSEHandler
+	Heartbeat time.Duration
+	Retry     int
+	Replay    SSEReplayBuffer
+	Code      int
+	ctx       context.Context
+	headers   map[string]string
+}
+
+// SSE streams the events produced by handler.
+func SSE(handler SSEHandler) *SSEResult {
+	return &SSEResult{Handler: handler, Heartbeat: defaultSSEHeartbeat, Code: http.StatusOK}
+}
+
+// SSEChannel streams events received from ch until it is closed.
+func SSEChannel(ch <-chan SSEEvent) *SSEResult {
+	return SSE(func(w *SSEWriter) error {
+		for {
+			select {
+			case <-w.Context().Done():
+				return nil
+			case ev, ok := <-ch:
+				if !ok {
+					return nil
+				}
+				if err := w.Send(ev); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+// WithHeartbeat sets the comment heartbeat interval; zero or negative disables it.
+func (r *SSEResult) WithHeartbeat(interval time.Duration) *SSEResult {
+	r.Heartbeat = interval
+	return r
+}
+
+// WithRetry advertises the client reconnection delay in milliseconds.
+func (r *SSEResult) WithRetry(retryMillis int) *SSEResult {
+	r.Retry = retryMillis
+	return r
+}
+
+// WithReplay replays buffered events after the client's Last-Event-ID before the handler runs.
+func (r *SSEResult) WithReplay(buf SSEReplayBuffer) *SSEResult {
+	r.Replay = buf
+	return r
+}
+
+// WithContext ends the stream when ctx is done.
+func (r *SSEResult) WithContext(ctx context.Context) *SSEResult {
+	r.ctx = ctx
+	return r
+}
+
+func (r *SSEResult) WithHeader(key, value string) *SSEResult {
+	if r.headers == nil {
+		r.headers = make(map[string]string)
+	}
+	r.headers[key] = value
+	return r
+}
+
+func (r *SSEResult) Execute(ctx *Context) {
+	if ctx == nil || ctx.fastCtx == nil {
+		return
+	}
+	code := r.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+
+	for k, v := range r.headers {
+		ctx.Header(k, v)
+	}
+	ctx.Header("Content-Type", MIMEEventStream)
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(code)
+
+	lastEventID := ctx.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = ctx.Query("lastEventId")
+	}
+
+	// The request context is recycled once the chain returns; keep its values only.
+	base, cancel := context.WithCancel(context.WithoutCancel(ctx.Context()))
+	var stops []func() bool
+	if ctx.engine != nil {
+		stops = append(stops, context.AfterFunc(ctx.engine.baseCtx, cancel))
+	}
+	if r.ctx != nil {
+		stops = append(stops, context.AfterFunc(r.ctx, cancel))
+	}
+	logger := ctx.logger
+
+	ctx.setBodyStreamWriter(func(bw *bufio.Writer) {
+		defer func() {
+			cancel()
+			for _, stop := range stops {
+				stop()
+			}
+		}()
+		w := &SSEWriter{w: bw, ctx: base, cancel: cancel, lastEventID: lastEventID}
+		if err := r.stream(w); err != nil && !errors.Is(err, ErrSSEClosed) && logger != nil {
+			logger.Debugf("sse stream ended: %v", err)
+		}
+	})
+}
+
+func (r *SSEResult) stream(w *SSEWriter) error {
+	// An initial frame pushes the headers out so clients see the stream open.
+	first := ": ok\n\n"
+	if r.Retry > 0 {
+		first = "retry: " + strconv.Itoa(r.Retry) + "\n\n"
+	}
+	if err := w.writeFrame(first); err != nil {
+		return err
+	}
+
+	if r.Replay != nil && w.lastEventID != "" {
+		if events, ok := r.Replay.Since(w.lastEventID); ok {
+			for _, ev := range events {
+				if err := w.Send(ev); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if r.Heartbeat > 0 {
+		go func() {
+			ticker := time.NewTicker(r.Heartbeat)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-w.ctx.Done():
+					return
+				case <-ticker.C:
+					if w.Comment("heartbeat") != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	if r.Handler == nil {
+		<-w.ctx.Done()
+		return nil
+	}
+	return r.Handler(w)
+}
+
+// ==================== Replay Buffer ====================
+
+// SSEMemoryReplay is a fixed-size in-memory SSEReplayBuffer.
+// Events without an id get a monotonically increasing numeric id.
+type SSEMemoryReplay struct {
+	mu     sync.RWMutex
+	events []SSEEvent
+	size   int
+	seq    uint64
+}
+
+func NewSSEMemoryReplay(size int) *SSEMemoryReplay {
+	if size <= 0 {
+		size = 100
+	}
+	return &SSEMemoryReplay{size: size, events: make([]SSEEvent, 0, size)}
+}
+
+func (b *SSEMemoryReplay) Append(ev SSEEvent) SSEEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	if ev.ID == "" {
+		ev.ID = strconv.FormatUint(b.seq, 10)
+	}
+	if len(b.events) == b.size {
+		copy(b.events, b.events[1:])
+		b.events = b.events[:b.size-1]
+	}
+	b.events = append(b.events, ev)
+	return ev
+}
+
+func (b *SSEMemoryReplay) Since(lastEventID string) ([]SSEEvent, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for i := len(b.events) - 1; i >= 0; i-- {
+		if b.events[i].ID == lastEventID {
+			out := make([]SSEEvent, len(b.events)-i-1)
+			copy(out, b.events[i+1:])
+			return out, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,64 @@
+package gserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSSEResultWritesFrames(t *testing.T) {
+	server := NewServer()
+	server.GET("/events", Wrap(func(c *Context) Result {
+		return SSE(func(w *SSEWriter) error {
+			if err := w.Send(SSEEvent{ID: "1", Event: "greeting", Data: "hello\nworld"}); err != nil {
+				return err
+			}
+			return w.SendJSON("count", map[string]int{"n": 2})
+		}).WithRetry(1500).WithHeartbeat(0)
+	}))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != MIMEEventStream {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Fatalf("unexpected cache control %q", cc)
+	}
+	expected := "retry: 1500\n\n" +
+		"id: 1\nevent: greeting\ndata: hello\ndata: world\n\n" +
+		"event: count\ndata: {\"n\":2}\n\n"
+	if rec.Body.String() != expected {
+		t.Fatalf("unexpected body %q", rec.Body.String())
+	}
+}
+
+func TestSSEResultReplaysAfterLastEventID(t *testing.T) {
+	replay := NewSSEMemoryReplay(2)
+	replay.Append(SSEEvent{Data: "a"})
+	replay.Append(SSEEvent{Data: "b"})
+	replay.Append(SSEEvent{Data: "c"})
+
+	if _, ok := replay.Since("1"); ok {
+		t.Fatalf("expected evicted id to be unknown")
+	}
+
+	server := NewServer()
+	server.GET("/events", Wrap(func(c *Context) Result {
+		ch := make(chan SSEEvent)
+		close(ch)
+		return SSEChannel(ch).WithReplay(replay).WithHeartbeat(0)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "id: 3\ndata: c\n\n") || strings.Contains(body, "data: b") {
+		t.Fatalf("unexpected replay body %q", body)
+	}
+}
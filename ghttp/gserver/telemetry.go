@@ -0,0 +1,219 @@
+package gserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sofiworker/gk/gotel"
+)
+
+// instrumentationName is the OpenTelemetry scope of the spans and metrics of Telemetry.
+const instrumentationName = "github.com/sofiworker/gk/ghttp/gserver"
+
+// durationBuckets are the boundaries advised by the HTTP semantic conventions.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodConnect: true,
+	http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// TelemetryConfig configures Telemetry.
+type TelemetryConfig struct {
+	// Tracer and Meter default to a gotel.OTELProvider over the global
+	// OpenTelemetry providers and the W3C trace context and baggage propagators.
+	Tracer gotel.Tracer
+	Meter  gotel.Meter
+	// SpanName defaults to the method followed by the route template, such as
+	// "GET /users/:id".
+	SpanName func(c *Context) string
+	// SkipPaths are request paths or route templates that are not traced nor
+	// measured, such as health checks.
+	SkipPaths []string
+}
+
+// Telemetry traces requests and records HTTP server metrics following the
+// OpenTelemetry semantic conventions. It extracts the W3C traceparent and
+// baggage headers, starts a server span and stores it in Context.Context, so
+// handlers and glog *Context calls see the trace. The request duration is
+// recorded in the http.server.request.duration histogram and, when the Meter
+// implements gotel.UpDownCounterMeter, in-flight requests in the
+// http.server.active_requests counter.
+func Telemetry(cfg TelemetryConfig) HandlerFunc {
+	if cfg.Tracer == nil || cfg.Meter == nil {
+		p := gotel.NewOTELProvider(instrumentationName)
+		if cfg.Tracer == nil {
+			cfg.Tracer = p
+		}
+		if cfg.Meter == nil {
+			cfg.Meter = p
+		}
+	}
+	if cfg.SpanName == nil {
+		cfg.SpanName = defaultSpanName
+	}
+	skipPaths := make(map[string]bool, len(cfg.SkipPaths))
+	for _, p := range cfg.SkipPaths {
+		skipPaths[p] = true
+	}
+	duration := cfg.Meter.Histogram("http.server.request.duration",
+		gotel.WithDescription("Duration of HTTP server requests."),
+		gotel.WithUnit("s"),
+		gotel.WithBuckets(durationBuckets...))
+	var active gotel.UpDownCounter
+	if m, ok := cfg.Meter.(gotel.UpDownCounterMeter); ok {
+		active = m.UpDownCounter("http.server.active_requests",
+			gotel.WithDescription("Number of active HTTP server requests."),
+			gotel.WithUnit("{request}"))
+	}
+
+	return func(c *Context) {
+		if skipPaths[string(c.fastCtx.Path())] || skipPaths[c.FullPath()] {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		req := &c.fastCtx.Request
+		method := telemetryMethod(string(c.fastCtx.Method()))
+		scheme := "http"
+		if c.IsTLS() {
+			scheme = "https"
+		}
+		protocol := strings.TrimPrefix(string(req.Header.Protocol()), "HTTP/")
+		route := c.FullPath()
+
+		ctx, _ := cfg.Tracer.Extract(c.Context(), requestCarrier{c})
+		attrs := []gotel.KeyValue{
+			gotel.KV("http.request.method", method),
+			gotel.KV("url.scheme", scheme),
+			gotel.KV("url.path", string(c.fastCtx.Path())),
+			gotel.KV("network.protocol.version", protocol),
+			gotel.KV("client.address", c.ClientIP()),
+		}
+		if method == "_OTHER" {
+			attrs = append(attrs, gotel.KV("http.request.method_original", string(c.fastCtx.Method())))
+		}
+		if route != "" {
+			attrs = append(attrs, gotel.KV("http.route", route))
+		}
+		if query := c.fastCtx.QueryArgs().QueryString(); len(query) > 0 {
+			attrs = append(attrs, gotel.KV("url.query", string(query)))
+		}
+		if host, port := splitHostPort(string(c.fastCtx.Host())); host != "" {
+			attrs = append(attrs, gotel.KV("server.address", host))
+			if port > 0 {
+				attrs = append(attrs, gotel.KV("server.port", port))
+			}
+		}
+		if ua := req.Header.UserAgent(); len(ua) > 0 {
+			attrs = append(attrs, gotel.KV("user_agent.original", string(ua)))
+		}
+		ctx, span := cfg.Tracer.Start(ctx, cfg.SpanName(c),
+			gotel.WithSpanKind(gotel.SpanKindServer), gotel.WithAttributes(attrs...))
+		c.SetContext(ctx)
+
+		activeAttrs := []gotel.KeyValue{gotel.KV("http.request.method", method), gotel.KV("url.scheme", scheme)}
+		if active != nil {
+			active.Add(ctx, 1, activeAttrs...)
+		}
+
+		panicked := true
+		defer func() {
+			status := c.StatusCode()
+			var errType string
+			if panicked {
+				if rec := recover(); rec != nil {
+					span.RecordError(fmt.Errorf("panic: %v", rec))
+					status, errType = http.StatusInternalServerError, "panic"
+					// Let the panic continue to outer middleware once the span has ended.
+					defer panic(rec)
+				}
+			}
+			span.SetAttributes(gotel.KV("http.response.status_code", status))
+			if status >= http.StatusInternalServerError {
+				if errType == "" {
+					errType = strconv.Itoa(status)
+				}
+				span.SetAttributes(gotel.KV("error.type", errType))
+				span.SetStatus(gotel.StatusCodeError, http.StatusText(status))
+			}
+			span.End()
+
+			metricAttrs := []gotel.KeyValue{
+				gotel.KV("http.request.method", method),
+				gotel.KV("url.scheme", scheme),
+				gotel.KV("network.protocol.version", protocol),
+				gotel.KV("http.response.status_code", status),
+			}
+			if route != "" {
+				metricAttrs = append(metricAttrs, gotel.KV("http.route", route))
+			}
+			if errType != "" {
+				metricAttrs = append(metricAttrs, gotel.KV("error.type", errType))
+			}
+			duration.Record(ctx, time.Since(start).Seconds(), metricAttrs...)
+			if active != nil {
+				active.Add(ctx, -1, activeAttrs...)
+			}
+		}()
+
+		c.Next()
+		panicked = false
+	}
+}
+
+func defaultSpanName(c *Context) string {
+	method := telemetryMethod(string(c.fastCtx.Method()))
+	if method == "_OTHER" {
+		method = "HTTP"
+	}
+	if route := c.FullPath(); route != "" {
+		return method + " " + route
+	}
+	return method
+}
+
+// telemetryMethod bounds the cardinality of the method attribute as the
+// semantic conventions require.
+func telemetryMethod(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return "_OTHER"
+}
+
+func splitHostPort(hostport string) (string, int) {
+	host, portStr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport, 0
+	}
+	port, _ := strconv.Atoi(portStr)
+	return host, port
+}
+
+// requestCarrier exposes the request headers to gotel propagators.
+type requestCarrier struct {
+	c *Context
+}
+
+func (r requestCarrier) Get(key string) string {
+	return string(r.c.fastCtx.Request.Header.Peek(key))
+}
+
+func (r requestCarrier) Set(key, value string) {
+	r.c.fastCtx.Request.Header.Set(key, value)
+}
+
+func (r requestCarrier) Keys() []string {
+	var keys []string
+	r.c.fastCtx.Request.Header.All()(func(k, _ []byte) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+	return keys
+}
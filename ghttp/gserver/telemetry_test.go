@@ -0,0 +1,191 @@
+package gserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sofiworker/gk/gotel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type recordedSpan struct {
+	gotel.Span
+	name   string
+	attrs  map[string]interface{}
+	status gotel.StatusCode
+	ended  bool
+}
+
+func (s *recordedSpan) SetAttributes(attributes ...gotel.KeyValue) {
+	for _, kv := range attributes {
+		s.attrs[kv.Key] = kv.Value
+	}
+}
+
+func (s *recordedSpan) SetStatus(code gotel.StatusCode, _ string) { s.status = code }
+func (s *recordedSpan) End(...gotel.SpanEndOption)                { s.ended = true }
+
+type recordedPoint struct {
+	name  string
+	value float64
+	attrs map[string]interface{}
+}
+
+// recordingTelemetry propagates through a real gotel.OTELProvider and records
+// the spans and measurements of the middleware.
+type recordingTelemetry struct {
+	*gotel.OTELProvider
+	mu     sync.Mutex
+	spans  []*recordedSpan
+	points []recordedPoint
+}
+
+func (r *recordingTelemetry) Start(ctx context.Context, name string, opts ...gotel.SpanStartOption) (context.Context, gotel.Span) {
+	ctx, span := r.OTELProvider.Start(ctx, name, opts...)
+	rec := &recordedSpan{Span: span, name: name, attrs: make(map[string]interface{})}
+	for _, opt := range opts {
+		if kv, ok := opt.(gotel.SpanAttributes); ok {
+			rec.SetAttributes(kv...)
+		}
+	}
+	r.mu.Lock()
+	r.spans = append(r.spans, rec)
+	r.mu.Unlock()
+	return ctx, rec
+}
+
+type recordingInstrument struct {
+	r    *recordingTelemetry
+	name string
+}
+
+func (i recordingInstrument) Record(_ context.Context, value float64, attributes ...gotel.KeyValue) {
+	attrs := make(map[string]interface{})
+	for _, kv := range attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	i.r.mu.Lock()
+	i.r.points = append(i.r.points, recordedPoint{name: i.name, value: value, attrs: attrs})
+	i.r.mu.Unlock()
+}
+
+func (i recordingInstrument) Add(ctx context.Context, value float64, attributes ...gotel.KeyValue) {
+	i.Record(ctx, value, attributes...)
+}
+
+func (r *recordingTelemetry) Histogram(name string, _ ...gotel.InstrumentOption) gotel.Histogram {
+	return recordingInstrument{r, name}
+}
+
+func (r *recordingTelemetry) UpDownCounter(name string, _ ...gotel.InstrumentOption) gotel.UpDownCounter {
+	return recordingInstrument{r, name}
+}
+
+func TestTelemetrySpansAndMetrics(t *testing.T) {
+	rec := &recordingTelemetry{OTELProvider: gotel.NewOTELProvider("test")}
+	logger := &recordLogger{}
+	server := NewServer()
+	server.Use(Telemetry(TelemetryConfig{Tracer: rec, Meter: rec, SkipPaths: []string{"/health"}}),
+		AccessLog(AccessLogConfig{Logger: logger}))
+	var handlerTrace string
+	server.GET("/users/:id", func(c *Context) {
+		handlerTrace = trace.SpanContextFromContext(c.Context()).TraceID().String()
+		c.String(http.StatusOK, "ok")
+	})
+	server.GET("/fail", func(c *Context) { c.Status(http.StatusBadGateway) })
+	server.GET("/health", func(c *Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "http://api.test:8080/users/7?v=1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("User-Agent", "tester")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if handlerTrace != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("handler context lacks the incoming trace: %q", handlerTrace)
+	}
+	if entries := logger.take(); len(entries) != 3 || entries[0].kv["trace_id"] != handlerTrace {
+		t.Fatalf("access log should carry the trace id: %v", entries)
+	}
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(rec.spans))
+	}
+	span := rec.spans[0]
+	if span.name != "GET /users/:id" || !span.ended || span.status != gotel.StatusCodeUnset {
+		t.Fatalf("unexpected span %q ended=%v status=%v", span.name, span.ended, span.status)
+	}
+	want := map[string]interface{}{
+		"http.request.method":       "GET",
+		"http.route":                "/users/:id",
+		"url.path":                  "/users/7",
+		"url.query":                 "v=1",
+		"url.scheme":                "http",
+		"server.address":            "api.test",
+		"server.port":               8080,
+		"user_agent.original":       "tester",
+		"network.protocol.version":  "1.1",
+		"http.response.status_code": http.StatusOK,
+	}
+	for k, v := range want {
+		if span.attrs[k] != v {
+			t.Errorf("%s = %v, want %v", k, span.attrs[k], v)
+		}
+	}
+	if failed := rec.spans[1]; failed.status != gotel.StatusCodeError || failed.attrs["error.type"] != "502" {
+		t.Fatalf("5xx span should be an error: %v %v", failed.status, failed.attrs)
+	}
+
+	var durations, active int
+	var inFlight float64
+	for _, p := range rec.points {
+		switch p.name {
+		case "http.server.request.duration":
+			durations++
+			if p.attrs["http.route"] == nil || p.attrs["http.response.status_code"] == nil || p.value < 0 {
+				t.Errorf("unexpected duration point %v", p)
+			}
+		case "http.server.active_requests":
+			active++
+			inFlight += p.value
+		}
+	}
+	if durations != 2 || active != 4 || inFlight != 0 {
+		t.Fatalf("unexpected metrics: durations=%d active=%d inFlight=%v", durations, active, inFlight)
+	}
+}
+
+func TestTelemetryRecordsPanics(t *testing.T) {
+	rec := &recordingTelemetry{OTELProvider: gotel.NewOTELProvider("test")}
+	server := NewServer()
+	server.Use(Recovery(), Telemetry(TelemetryConfig{Tracer: rec, Meter: rec}))
+	server.GET("/panic", func(c *Context) { panic("boom") })
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected Recovery to answer 500, got %d", w.Code)
+	}
+	if len(rec.spans) != 1 || !rec.spans[0].ended || rec.spans[0].attrs["error.type"] != "panic" {
+		t.Fatalf("panic not recorded on the span: %v", rec.spans)
+	}
+}
+
+// basicMeter hides the optional UpDownCounter of its Meter.
+type basicMeter struct{ gotel.Meter }
+
+func TestTelemetryWithoutUpDownCounter(t *testing.T) {
+	rec := &recordingTelemetry{OTELProvider: gotel.NewOTELProvider("test")}
+	server := NewServer()
+	server.Use(Telemetry(TelemetryConfig{Tracer: rec, Meter: basicMeter{rec}}))
+	server.GET("/ok", func(c *Context) { c.Status(http.StatusOK) })
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+
+	if len(rec.points) != 1 || rec.points[0].name != "http.server.request.duration" {
+		t.Fatalf("expected only the duration metric, got %v", rec.points)
+	}
+}
@@ -33,6 +33,7 @@ func lastChar(str string) uint8 {
 	return str[len(str)-1]
 }
 
+// CheckPathValid panics when path is not a valid route pattern, see parseRoute.
 func CheckPathValid(path string) {
 	if path == "" {
 		panic("empty path")
@@ -40,33 +41,8 @@ func CheckPathValid(path string) {
 	if path[0] != '/' {
 		panic("path must begin with '/'")
 	}
-	for i, c := range []byte(path) {
-		switch c {
-		case ':':
-			if (i < len(path)-1 && path[i+1] == '/') || i == (len(path)-1) {
-				panic("wildcards must be named with a non-empty name in path '" + path + "'")
-			}
-			i++
-			for ; i < len(path) && path[i] != '/'; i++ {
-				if path[i] == ':' || path[i] == '*' {
-					panic("only one wildcard per path segment is allowed, find multi in path '" + path + "'")
-				}
-			}
-		case '*':
-			if i == len(path)-1 {
-				panic("wildcards must be named with a non-empty name in path '" + path + "'")
-			}
-			if i > 0 && path[i-1] != '/' {
-				panic(" no / before wildcards in path " + path)
-			}
-			for ; i < len(path); i++ {
-				if path[i] == '/' {
-					panic("catch-all routes are only allowed at the end of the path in path '" + path + "'")
-				}
-			}
-		case '?':
-			panic("'?' character is not allowed in path '" + path + "'")
-		}
+	if _, err := parseRoute(path); err != nil {
+		panic(err.Error())
 	}
 }
 
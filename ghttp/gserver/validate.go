@@ -0,0 +1,441 @@
+package gserver
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError describes one field that failed binding or validation.
+type FieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Tag     string `json:"tag" xml:"tag"`
+	Param   string `json:"param,omitempty" xml:"param,omitempty"`
+	Message string `json:"message" xml:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors is returned by ShouldBind and Validate when one or more fields are invalid.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validator may be implemented by bound structs for checks that tags can't express.
+// It runs after the tag rules pass.
+type Validator interface {
+	Validate() error
+}
+
+// ValidationFunc reports whether v satisfies a custom rule with the given parameter.
+type ValidationFunc func(v reflect.Value, param string) bool
+
+var (
+	validationPlans sync.Map // map[reflect.Type]*validationPlan
+	regexpCache     sync.Map // map[string]*regexp.Regexp
+
+	customRulesMu sync.RWMutex
+	customRules   = make(map[string]ValidationFunc)
+)
+
+// RegisterValidation adds a custom rule usable as `validate:"name=param"`.
+func RegisterValidation(name string, fn ValidationFunc) {
+	if name == "" || fn == nil {
+		return
+	}
+	customRulesMu.Lock()
+	customRules[name] = fn
+	customRulesMu.Unlock()
+	// Plans capture rule functions, so drop any that might reference the old one.
+	validationPlans.Range(func(key, _ interface{}) bool {
+		validationPlans.Delete(key)
+		return true
+	})
+}
+
+type validationRule struct {
+	name  string
+	param string
+	check func(v reflect.Value) bool
+}
+
+type validationField struct {
+	index     []int
+	name      string
+	omitempty bool
+	required  bool
+	rules     []validationRule
+}
+
+type validationPlan struct {
+	fields []validationField
+	err    error
+}
+
+// Validate checks obj against its `validate` tags, recursing into nested structs and
+// slices or maps of structs. Supported rules: required, omitempty, min, max, len, eq,
+// ne, gt, gte, lt, lte, oneof (space separated), email, url, alpha, alphanum, numeric
+// and regexp. Because patterns may contain commas, regexp must be the last rule.
+func Validate(obj interface{}) error {
+	rv := reflect.ValueOf(obj)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	var errs ValidationErrors
+	if rv.Kind() == reflect.Struct {
+		if err := validateStruct(rv, "", &errs); err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if v, ok := obj.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) error {
+	plan := validationPlanFor(v.Type())
+	if plan.err != nil {
+		return plan.err
+	}
+	for _, f := range plan.fields {
+		fv := v.FieldByIndex(f.index)
+		path := prefix + f.name
+		if err := validateValue(fv, f, path, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(fv reflect.Value, f validationField, path string, errs *ValidationErrors) error {
+	empty := isZeroValue(fv)
+	if empty {
+		if f.required {
+			*errs = append(*errs, FieldError{Field: path, Tag: "required", Message: path + " is required"})
+			return nil
+		}
+		if f.omitempty || fv.Kind() == reflect.Ptr {
+			return nil
+		}
+	}
+
+	target := fv
+	for target.Kind() == reflect.Ptr || target.Kind() == reflect.Interface {
+		if target.IsNil() {
+			return nil
+		}
+		target = target.Elem()
+	}
+
+	for _, rule := range f.rules {
+		if !rule.check(target) {
+			*errs = append(*errs, FieldError{
+				Field:   path,
+				Tag:     rule.name,
+				Param:   rule.param,
+				Message: ruleMessage(path, rule),
+			})
+			return nil
+		}
+	}
+
+	return validateNested(target, path, errs)
+}
+
+func validateNested(v reflect.Value, path string, errs *ValidationErrors) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return nil
+		}
+		return validateStruct(v, path+".", errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			elem := reflect.Indirect(v.Index(i))
+			if elem.Kind() == reflect.Struct && elem.Type() != timeType {
+				if err := validateStruct(elem, path+"["+strconv.Itoa(i)+"].", errs); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.Indirect(iter.Value())
+			if elem.Kind() == reflect.Struct && elem.Type() != timeType {
+				if err := validateStruct(elem, fmt.Sprintf("%s[%v].", path, iter.Key().Interface()), errs); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validationPlanFor(t reflect.Type) *validationPlan {
+	if v, ok := validationPlans.Load(t); ok {
+		return v.(*validationPlan)
+	}
+	plan := &validationPlan{}
+	for _, info := range sortedFields(t) {
+		field := info.Field
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		vf := validationField{index: info.Index, name: fieldDisplayName(field)}
+		if tag != "" {
+			if err := parseValidationTag(tag, &vf); err != nil {
+				plan.err = fmt.Errorf("validate: %s.%s: %w", t.Name(), field.Name, err)
+				break
+			}
+		}
+		if tag == "" && !mayContainStructs(field.Type) {
+			continue
+		}
+		plan.fields = append(plan.fields, vf)
+	}
+	actual, _ := validationPlans.LoadOrStore(t, plan)
+	return actual.(*validationPlan)
+}
+
+func mayContainStructs(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return t != timeType
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return mayContainStructs(t.Elem())
+	}
+	return false
+}
+
+// fieldDisplayName prefers the name clients actually send.
+func fieldDisplayName(field reflect.StructField) string {
+	for _, key := range []string{"json", BindQuery, BindURI, BindForm, BindHeader, BindCookie, "xml", "yaml"} {
+		tag := field.Tag.Get(key)
+		if tag == "" || tag == "-" {
+			continue
+		}
+		if name := strings.TrimSpace(strings.Split(tag, ",")[0]); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func parseValidationTag(tag string, vf *validationField) error {
+	parts := strings.Split(tag, ",")
+	for i := 0; i < len(parts); i++ {
+		part := strings.TrimSpace(parts[i])
+		if part == "" {
+			continue
+		}
+		name, param, _ := strings.Cut(part, "=")
+		if name == "regexp" {
+			// Everything after regexp= belongs to the pattern.
+			param = strings.Join(append([]string{param}, parts[i+1:]...), ",")
+			i = len(parts)
+		}
+		switch name {
+		case "required":
+			vf.required = true
+			continue
+		case "omitempty":
+			vf.omitempty = true
+			continue
+		}
+		check, err := buildRule(name, param)
+		if err != nil {
+			return err
+		}
+		vf.rules = append(vf.rules, validationRule{name: name, param: param, check: check})
+	}
+	return nil
+}
+
+var (
+	alphaRegexp    = regexp.MustCompile(`^[a-zA-Z]+$`)
+	alnumRegexp    = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+	numericRegexp  = regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`)
+	urlSchemeRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://[^\s/?#]+[^\s]*$`)
+)
+
+func buildRule(name, param string) (func(reflect.Value) bool, error) {
+	switch name {
+	case "min", "gte":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s needs a number", name)
+		}
+		return func(v reflect.Value) bool { m, ok := measure(v); return !ok || m >= n }, nil
+	case "max", "lte":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s needs a number", name)
+		}
+		return func(v reflect.Value) bool { m, ok := measure(v); return !ok || m <= n }, nil
+	case "gt":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s needs a number", name)
+		}
+		return func(v reflect.Value) bool { m, ok := measure(v); return !ok || m > n }, nil
+	case "lt":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s needs a number", name)
+		}
+		return func(v reflect.Value) bool { m, ok := measure(v); return !ok || m < n }, nil
+	case "len":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s needs a number", name)
+		}
+		return func(v reflect.Value) bool { m, ok := measure(v); return !ok || m == n }, nil
+	case "eq":
+		return func(v reflect.Value) bool { return valueString(v) == param }, nil
+	case "ne":
+		return func(v reflect.Value) bool { return valueString(v) != param }, nil
+	case "oneof":
+		options := strings.Fields(param)
+		return func(v reflect.Value) bool {
+			s := valueString(v)
+			for _, opt := range options {
+				if s == opt {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "email":
+		return func(v reflect.Value) bool {
+			s := valueString(v)
+			addr, err := mail.ParseAddress(s)
+			return err == nil && addr.Address == s
+		}, nil
+	case "url":
+		return func(v reflect.Value) bool { return urlSchemeRegex.MatchString(valueString(v)) }, nil
+	case "alpha":
+		return func(v reflect.Value) bool { return alphaRegexp.MatchString(valueString(v)) }, nil
+	case "alphanum":
+		return func(v reflect.Value) bool { return alnumRegexp.MatchString(valueString(v)) }, nil
+	case "numeric":
+		return func(v reflect.Value) bool { return numericRegexp.MatchString(valueString(v)) }, nil
+	case "regexp":
+		re, err := compileRegexp(param)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) bool { return re.MatchString(valueString(v)) }, nil
+	}
+
+	customRulesMu.RLock()
+	fn := customRules[name]
+	customRulesMu.RUnlock()
+	if fn == nil {
+		return nil, fmt.Errorf("unknown rule %q", name)
+	}
+	return func(v reflect.Value) bool { return fn(v, param) }, nil
+}
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if v, ok := regexpCache.Load(pattern); ok {
+		return v.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache.Store(pattern, re)
+	return re, nil
+}
+
+// measure returns the length of strings and collections, or the numeric value of numbers.
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func valueString(v reflect.Value) string {
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Slice, reflect.Map:
+		return v.IsNil() || v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+func ruleMessage(field string, rule validationRule) string {
+	switch rule.name {
+	case "min", "gte":
+		return fmt.Sprintf("%s must be at least %s", field, rule.param)
+	case "max", "lte":
+		return fmt.Sprintf("%s must be at most %s", field, rule.param)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, rule.param)
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, rule.param)
+	case "len":
+		return fmt.Sprintf("%s must have length %s", field, rule.param)
+	case "eq":
+		return fmt.Sprintf("%s must equal %s", field, rule.param)
+	case "ne":
+		return fmt.Sprintf("%s must not equal %s", field, rule.param)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", field, rule.param)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", field)
+	case "regexp":
+		return fmt.Sprintf("%s must match %s", field, rule.param)
+	}
+	return fmt.Sprintf("%s failed the %s rule", field, rule.name)
+}
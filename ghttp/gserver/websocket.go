@@ -0,0 +1,790 @@
+package gserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	ErrWebSocketHandshake  = errors.New("websocket: bad handshake")
+	ErrWebSocketOrigin     = errors.New("websocket: origin not allowed")
+	ErrWebSocketClosed     = errors.New("websocket: connection closed")
+	ErrWebSocketQueueFull  = errors.New("websocket: write queue is full")
+	ErrWebSocketHubClosed  = errors.New("websocket: hub closed")
+	ErrWebSocketNilHandler = errors.New("websocket: handler is nil")
+)
+
+// WebSocket message types, re-exported so handlers don't need to import gorilla/websocket.
+const (
+	TextMessage   = websocket.TextMessage
+	BinaryMessage = websocket.BinaryMessage
+)
+
+const (
+	defaultWebSocketPongWait   = 60 * time.Second
+	defaultWebSocketWriteWait  = 10 * time.Second
+	defaultWebSocketQueueSize  = 256
+	defaultWebSocketHandshake  = 10 * time.Second
+	defaultWebSocketBufferSize = 4096
+)
+
+// WebSocketHandler is invoked on its own goroutine once the connection is upgraded.
+// The originating *Context is recycled before the handler runs, so everything the
+// handler needs from the request must be read through the *WebSocketConn.
+type WebSocketHandler func(conn *WebSocketConn)
+
+type WebSocketConfig struct {
+	// Hub the connection joins. Defaults to the server hub, which is closed by ShutdownWithContext.
+	Hub *WebSocketHub
+
+	ReadBufferSize    int
+	WriteBufferSize   int
+	HandshakeTimeout  time.Duration
+	Subprotocols      []string
+	EnableCompression bool
+	// CheckOrigin reports whether the Origin header is acceptable. Defaults to same-origin.
+	CheckOrigin func(ctx *Context) bool
+
+	// PingInterval defaults to 9/10 of PongWait; a negative value disables pings.
+	PingInterval   time.Duration
+	PongWait       time.Duration
+	WriteWait      time.Duration
+	MaxMessageSize int64
+	// SendQueueSize bounds the per-connection write queue.
+	SendQueueSize int
+}
+
+func (cfg *WebSocketConfig) applyDefaults() {
+	if cfg.ReadBufferSize <= 0 {
+		cfg.ReadBufferSize = defaultWebSocketBufferSize
+	}
+	if cfg.WriteBufferSize <= 0 {
+		cfg.WriteBufferSize = defaultWebSocketBufferSize
+	}
+	if cfg.HandshakeTimeout <= 0 {
+		cfg.HandshakeTimeout = defaultWebSocketHandshake
+	}
+	if cfg.PongWait <= 0 {
+		cfg.PongWait = defaultWebSocketPongWait
+	}
+	if cfg.PingInterval == 0 {
+		cfg.PingInterval = cfg.PongWait * 9 / 10
+	}
+	if cfg.WriteWait <= 0 {
+		cfg.WriteWait = defaultWebSocketWriteWait
+	}
+	if cfg.SendQueueSize <= 0 {
+		cfg.SendQueueSize = defaultWebSocketQueueSize
+	}
+}
+
+// WebSocket returns a route handler that upgrades the request and runs handler.
+func WebSocket(handler WebSocketHandler, cfgs ...WebSocketConfig) HandlerFunc {
+	return func(ctx *Context) {
+		if err := ctx.Upgrade(handler, cfgs...); err != nil {
+			ctx.Abort()
+		}
+	}
+}
+
+// Upgrade validates the WebSocket handshake and hijacks the underlying fasthttp
+// connection. On a bad handshake it writes an error status and returns the error.
+// The handler runs after the current middleware chain returns.
+func (c *Context) Upgrade(handler WebSocketHandler, cfgs ...WebSocketConfig) error {
+	if handler == nil {
+		return ErrWebSocketNilHandler
+	}
+	var cfg WebSocketConfig
+	if len(cfgs) > 0 {
+		cfg = cfgs[0]
+	}
+	cfg.applyDefaults()
+
+	if status, err := c.checkWebSocketHandshake(&cfg); err != nil {
+		c.Header("Sec-Websocket-Version", "13")
+		c.String(status, "%s", http.StatusText(status))
+		return err
+	}
+
+	hub := cfg.Hub
+	if hub == nil && c.engine != nil {
+		hub = c.engine.hub
+	}
+	if hub == nil {
+		hub = NewWebSocketHub()
+	}
+
+	req := buildHTTPRequestFromFast(c.fastCtx)
+	info := newWebSocketRequestInfo(c)
+	upgrader := &websocket.Upgrader{
+		HandshakeTimeout:  cfg.HandshakeTimeout,
+		ReadBufferSize:    cfg.ReadBufferSize,
+		WriteBufferSize:   cfg.WriteBufferSize,
+		Subprotocols:      cfg.Subprotocols,
+		EnableCompression: cfg.EnableCompression,
+		// Origin has already been checked against the *Context above.
+		CheckOrigin: func(*http.Request) bool { return true },
+	}
+	logger := c.logger
+
+	c.fastCtx.HijackSetNoResponse(true)
+	c.fastCtx.Hijack(func(netConn net.Conn) {
+		ws, err := upgrader.Upgrade(&hijackResponseWriter{conn: netConn}, req, nil)
+		if err != nil {
+			if logger != nil {
+				logger.Warnf("websocket upgrade failed: %v", err)
+			}
+			return
+		}
+		conn := newWebSocketConn(ws, hub, cfg, info)
+		go conn.writePump()
+		if err := hub.register(conn); err != nil {
+			_ = conn.CloseWithReason(websocket.CloseGoingAway, "server shutting down")
+			conn.wait()
+			_ = ws.Close()
+			return
+		}
+		conn.serve(handler, logger)
+	})
+	return nil
+}
+
+func (c *Context) checkWebSocketHandshake(cfg *WebSocketConfig) (int, error) {
+	if c.fastCtx == nil {
+		return http.StatusInternalServerError, ErrWebSocketHandshake
+	}
+	header := &c.fastCtx.Request.Header
+	if !headerContainsToken(header.Peek("Connection"), "upgrade") ||
+		!headerContainsToken(header.Peek("Upgrade"), "websocket") {
+		return http.StatusBadRequest, ErrWebSocketHandshake
+	}
+	if !bytesEq(c.fastCtx.Method(), http.MethodGet) {
+		return http.StatusMethodNotAllowed, ErrWebSocketHandshake
+	}
+	if !headerContainsToken(header.Peek("Sec-Websocket-Version"), "13") {
+		return http.StatusUpgradeRequired, ErrWebSocketHandshake
+	}
+	if len(header.Peek("Sec-Websocket-Key")) == 0 {
+		return http.StatusBadRequest, ErrWebSocketHandshake
+	}
+	checkOrigin := cfg.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(c) {
+		return http.StatusForbidden, ErrWebSocketOrigin
+	}
+	return 0, nil
+}
+
+func sameOrigin(c *Context) bool {
+	origin := c.requestHeader("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, string(c.fastCtx.Host()))
+}
+
+func headerContainsToken(value []byte, token string) bool {
+	for _, part := range strings.Split(string(value), ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// hijackResponseWriter lets gorilla's Upgrader take over a connection hijacked from fasthttp.
+type hijackResponseWriter struct {
+	conn   net.Conn
+	header http.Header
+}
+
+func (w *hijackResponseWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *hijackResponseWriter) Write(data []byte) (int, error) {
+	return w.conn.Write(data)
+}
+
+func (w *hijackResponseWriter) WriteHeader(statusCode int) {
+	_, _ = w.conn.Write([]byte("HTTP/1.1 " + strconv.Itoa(statusCode) + " " + http.StatusText(statusCode) + "\r\n\r\n"))
+}
+
+func (w *hijackResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.conn, bufio.NewReadWriter(bufio.NewReader(w.conn), bufio.NewWriter(w.conn)), nil
+}
+
+// webSocketRequestInfo is a snapshot of the upgrade request, taken before the *Context is recycled.
+type webSocketRequestInfo struct {
+	path     string
+	fullPath string
+	clientIP string
+	params   map[string]string
+	query    url.Values
+	header   http.Header
+	values   map[interface{}]interface{}
+	ctx      context.Context
+}
+
+func newWebSocketRequestInfo(c *Context) *webSocketRequestInfo {
+	info := &webSocketRequestInfo{
+		fullPath: c.fullPath,
+		params:   make(map[string]string, len(c.pathParams)),
+		query:    make(url.Values),
+		header:   make(http.Header),
+		values:   make(map[interface{}]interface{}, len(c.values)),
+		ctx:      context.WithoutCancel(c.Context()),
+	}
+	for k, v := range c.pathParams {
+		info.params[k] = v
+	}
+	for k, v := range c.values {
+		info.values[k] = v
+	}
+	if c.fastCtx != nil {
+		info.path = string(c.fastCtx.Path())
+		info.clientIP = c.ClientIP()
+		c.fastCtx.QueryArgs().VisitAll(func(k, v []byte) {
+			info.query.Add(string(k), string(v))
+		})
+		c.fastCtx.Request.Header.VisitAll(func(k, v []byte) {
+			info.header.Add(string(k), string(v))
+		})
+	}
+	return info
+}
+
+type wsOutbound struct {
+	messageType int
+	data        []byte
+	prepared    *websocket.PreparedMessage
+}
+
+var wsConnSeq uint64
+
+// WebSocketConn is a server-side WebSocket connection with a bounded write queue.
+// Writes are safe for concurrent use; reads must happen on the handler goroutine.
+type WebSocketConn struct {
+	id   string
+	ws   *websocket.Conn
+	hub  *WebSocketHub
+	cfg  WebSocketConfig
+	info *webSocketRequestInfo
+
+	send        chan wsOutbound
+	closing     chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+	closed      atomic.Bool
+	closeCode   int
+	closeReason string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	rooms  map[string]struct{}
+	values map[interface{}]interface{}
+}
+
+func newWebSocketConn(ws *websocket.Conn, hub *WebSocketHub, cfg WebSocketConfig, info *webSocketRequestInfo) *WebSocketConn {
+	ctx, cancel := context.WithCancel(info.ctx)
+	conn := &WebSocketConn{
+		id:      strconv.FormatUint(atomic.AddUint64(&wsConnSeq, 1), 10),
+		ws:      ws,
+		hub:     hub,
+		cfg:     cfg,
+		info:    info,
+		send:    make(chan wsOutbound, cfg.SendQueueSize),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+		rooms:   make(map[string]struct{}),
+		values:  info.values,
+	}
+	if cfg.MaxMessageSize > 0 {
+		ws.SetReadLimit(cfg.MaxMessageSize)
+	}
+	_ = ws.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	})
+	return conn
+}
+
+func (c *WebSocketConn) serve(handler WebSocketHandler, logger Logger) {
+	defer func() {
+		if rec := recover(); rec != nil && logger != nil {
+			logger.Errorf("websocket handler panic: %v", rec)
+		}
+		_ = c.CloseWithReason(websocket.CloseNormalClosure, "")
+		c.wait()
+		c.hub.unregister(c)
+		_ = c.ws.Close()
+	}()
+	handler(c)
+}
+
+func (c *WebSocketConn) writePump() {
+	defer close(c.done)
+
+	var ping <-chan time.Time
+	if c.cfg.PingInterval > 0 {
+		ticker := time.NewTicker(c.cfg.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.writeOutbound(msg); err != nil {
+				c.shutdown()
+				return
+			}
+		case <-ping:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.cfg.WriteWait)); err != nil {
+				c.shutdown()
+				return
+			}
+		case <-c.closing:
+			c.flushAndClose()
+			return
+		}
+	}
+}
+
+// flushAndClose drains what is already queued so the close frame follows pending messages.
+func (c *WebSocketConn) flushAndClose() {
+	if c.closeCode <= 0 {
+		return
+	}
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.writeOutbound(msg); err != nil {
+				return
+			}
+		default:
+			deadline := time.Now().Add(c.cfg.WriteWait)
+			_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason), deadline)
+			// Unblock a reader whose peer never answers the close frame.
+			_ = c.ws.SetReadDeadline(deadline)
+			return
+		}
+	}
+}
+
+func (c *WebSocketConn) writeOutbound(msg wsOutbound) error {
+	_ = c.ws.SetWriteDeadline(time.Now().Add(c.cfg.WriteWait))
+	if msg.prepared != nil {
+		return c.ws.WritePreparedMessage(msg.prepared)
+	}
+	return c.ws.WriteMessage(msg.messageType, msg.data)
+}
+
+func (c *WebSocketConn) startClose(code int, reason string) bool {
+	first := false
+	c.closeOnce.Do(func() {
+		first = true
+		c.closeCode = code
+		c.closeReason = reason
+		c.closed.Store(true)
+		close(c.closing)
+		c.cancel()
+	})
+	return first
+}
+
+// shutdown closes the connection without a close handshake.
+func (c *WebSocketConn) shutdown() {
+	c.startClose(-1, "")
+	_ = c.ws.Close()
+}
+
+func (c *WebSocketConn) wait() {
+	select {
+	case <-c.done:
+	case <-time.After(c.cfg.WriteWait):
+	}
+}
+
+func (c *WebSocketConn) enqueue(msg wsOutbound) error {
+	if c.closed.Load() {
+		return ErrWebSocketClosed
+	}
+	select {
+	case c.send <- msg:
+		return nil
+	case <-c.closing:
+		return ErrWebSocketClosed
+	default:
+		return ErrWebSocketQueueFull
+	}
+}
+
+// ID returns a process-unique connection identifier.
+func (c *WebSocketConn) ID() string {
+	return c.id
+}
+
+// Conn exposes the underlying gorilla connection. Writing to it directly bypasses the write queue.
+func (c *WebSocketConn) Conn() *websocket.Conn {
+	return c.ws
+}
+
+func (c *WebSocketConn) Hub() *WebSocketHub {
+	return c.hub
+}
+
+// Context is canceled when the connection closes.
+func (c *WebSocketConn) Context() context.Context {
+	return c.ctx
+}
+
+func (c *WebSocketConn) Subprotocol() string {
+	return c.ws.Subprotocol()
+}
+
+func (c *WebSocketConn) Path() string {
+	return c.info.path
+}
+
+func (c *WebSocketConn) FullPath() string {
+	return c.info.fullPath
+}
+
+func (c *WebSocketConn) ClientIP() string {
+	return c.info.clientIP
+}
+
+func (c *WebSocketConn) Param(key string) string {
+	return c.info.params[key]
+}
+
+func (c *WebSocketConn) Query(key string) string {
+	return c.info.query.Get(key)
+}
+
+func (c *WebSocketConn) GetHeader(key string) string {
+	return c.info.header.Get(key)
+}
+
+func (c *WebSocketConn) Set(key, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+}
+
+// Value returns values set on the connection, falling back to those set on the upgrade *Context.
+func (c *WebSocketConn) Value(key interface{}) interface{} {
+	c.mu.RLock()
+	v, ok := c.values[key]
+	c.mu.RUnlock()
+	if ok {
+		return v
+	}
+	return c.info.ctx.Value(key)
+}
+
+// ReadMessage blocks until the next data message arrives.
+func (c *WebSocketConn) ReadMessage() (int, []byte, error) {
+	return c.ws.ReadMessage()
+}
+
+func (c *WebSocketConn) ReadJSON(v interface{}) error {
+	_, data, err := c.ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage queues a message; it never blocks on the network.
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	return c.enqueue(wsOutbound{messageType: messageType, data: data})
+}
+
+func (c *WebSocketConn) WriteText(text string) error {
+	return c.WriteMessage(websocket.TextMessage, []byte(text))
+}
+
+func (c *WebSocketConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(websocket.TextMessage, data)
+}
+
+func (c *WebSocketConn) Join(room string) {
+	c.hub.join(c, room)
+}
+
+func (c *WebSocketConn) Leave(room string) {
+	c.hub.leave(c, room)
+}
+
+func (c *WebSocketConn) Rooms() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		out = append(out, room)
+	}
+	return out
+}
+
+func (c *WebSocketConn) Close() error {
+	return c.CloseWithReason(websocket.CloseNormalClosure, "")
+}
+
+// CloseWithReason flushes queued messages, then sends a close frame.
+// The peer's close reply surfaces as an error from ReadMessage.
+func (c *WebSocketConn) CloseWithReason(code int, reason string) error {
+	if !c.startClose(code, reason) {
+		return ErrWebSocketClosed
+	}
+	return nil
+}
+
+// WebSocketHub tracks connections and rooms for broadcasting.
+type WebSocketHub struct {
+	mu     sync.RWMutex
+	conns  map[*WebSocketConn]struct{}
+	rooms  map[string]map[*WebSocketConn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+
+	// OnConnect and OnDisconnect are optional lifecycle callbacks.
+	OnConnect    func(conn *WebSocketConn)
+	OnDisconnect func(conn *WebSocketConn)
+}
+
+func NewWebSocketHub() *WebSocketHub {
+	return &WebSocketHub{
+		conns: make(map[*WebSocketConn]struct{}),
+		rooms: make(map[string]map[*WebSocketConn]struct{}),
+	}
+}
+
+func (h *WebSocketHub) register(c *WebSocketConn) error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ErrWebSocketHubClosed
+	}
+	h.conns[c] = struct{}{}
+	h.wg.Add(1)
+	h.mu.Unlock()
+
+	if h.OnConnect != nil {
+		h.OnConnect(c)
+	}
+	return nil
+}
+
+func (h *WebSocketHub) unregister(c *WebSocketConn) {
+	h.mu.Lock()
+	if _, ok := h.conns[c]; !ok {
+		h.mu.Unlock()
+		return
+	}
+	delete(h.conns, c)
+	c.mu.Lock()
+	for room := range c.rooms {
+		h.removeFromRoomLocked(c, room)
+	}
+	c.rooms = make(map[string]struct{})
+	c.mu.Unlock()
+	h.mu.Unlock()
+
+	if h.OnDisconnect != nil {
+		h.OnDisconnect(c)
+	}
+	h.wg.Done()
+}
+
+func (h *WebSocketHub) join(c *WebSocketConn, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.conns[c]; !ok {
+		return
+	}
+	members := h.rooms[room]
+	if members == nil {
+		members = make(map[*WebSocketConn]struct{})
+		h.rooms[room] = members
+	}
+	members[c] = struct{}{}
+	c.mu.Lock()
+	c.rooms[room] = struct{}{}
+	c.mu.Unlock()
+}
+
+func (h *WebSocketHub) leave(c *WebSocketConn, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeFromRoomLocked(c, room)
+	c.mu.Lock()
+	delete(c.rooms, room)
+	c.mu.Unlock()
+}
+
+func (h *WebSocketHub) removeFromRoomLocked(c *WebSocketConn, room string) {
+	members := h.rooms[room]
+	if members == nil {
+		return
+	}
+	delete(members, c)
+	if len(members) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+// Len returns the number of live connections.
+func (h *WebSocketHub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.conns)
+}
+
+// RoomLen returns the number of connections in room.
+func (h *WebSocketHub) RoomLen(room string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms[room])
+}
+
+// Rooms returns the names of all non-empty rooms.
+func (h *WebSocketHub) Rooms() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make([]string, 0, len(h.rooms))
+	for room := range h.rooms {
+		out = append(out, room)
+	}
+	return out
+}
+
+// Broadcast queues a message on every connection and returns how many accepted it.
+func (h *WebSocketHub) Broadcast(messageType int, data []byte) (int, error) {
+	return h.broadcast(h.snapshot(""), messageType, data, nil)
+}
+
+// BroadcastTo queues a message on every connection in room.
+func (h *WebSocketHub) BroadcastTo(room string, messageType int, data []byte) (int, error) {
+	return h.broadcast(h.snapshot(room), messageType, data, nil)
+}
+
+// BroadcastExcept queues a message on every connection in room except the sender.
+// An empty room targets the whole hub.
+func (h *WebSocketHub) BroadcastExcept(room string, except *WebSocketConn, messageType int, data []byte) (int, error) {
+	return h.broadcast(h.snapshot(room), messageType, data, except)
+}
+
+func (h *WebSocketHub) BroadcastJSON(room string, v interface{}) (int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	return h.broadcast(h.snapshot(room), websocket.TextMessage, data, nil)
+}
+
+func (h *WebSocketHub) snapshot(room string) []*WebSocketConn {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	src := h.conns
+	if room != "" {
+		src = h.rooms[room]
+	}
+	out := make([]*WebSocketConn, 0, len(src))
+	for c := range src {
+		out = append(out, c)
+	}
+	return out
+}
+
+func (h *WebSocketHub) broadcast(targets []*WebSocketConn, messageType int, data []byte, except *WebSocketConn) (int, error) {
+	if len(targets) == 0 {
+		return 0, nil
+	}
+	prepared, err := websocket.NewPreparedMessage(messageType, data)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, c := range targets {
+		if c == except {
+			continue
+		}
+		if c.enqueue(wsOutbound{prepared: prepared}) == nil {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// Shutdown stops accepting connections, sends a going-away close frame to every
+// connection and waits for their handlers to return or ctx to expire, after
+// which remaining connections are closed forcibly.
+func (h *WebSocketHub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	h.mu.Unlock()
+
+	for _, c := range h.snapshot("") {
+		_ = c.CloseWithReason(websocket.CloseGoingAway, "server shutting down")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, c := range h.snapshot("") {
+			c.shutdown()
+		}
+		return ctx.Err()
+	}
+}
+
+// Close shuts the hub down without waiting for handlers.
+func (h *WebSocketHub) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := h.Shutdown(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+var _ http.Hijacker = (*hijackResponseWriter)(nil)
@@ -0,0 +1,167 @@
+package gserver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func startInmemoryServer(t *testing.T, server *Server) *fasthttputil.InmemoryListener {
+	t.Helper()
+	ln := fasthttputil.NewInmemoryListener()
+	go func() {
+		_ = server.RunListener(ln)
+	}()
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+	return ln
+}
+
+func dialWebSocket(t *testing.T, ln *fasthttputil.InmemoryListener, path string) *websocket.Conn {
+	t.Helper()
+	dialer := websocket.Dialer{
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return ln.Dial()
+		},
+		HandshakeTimeout: time.Second,
+	}
+	conn, resp, err := dialer.Dial("ws://example.com"+path, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", path, err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	return conn
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	server := NewServer()
+	server.GET("/ws/:room", WebSocket(func(conn *WebSocketConn) {
+		if conn.Param("room") != "lobby" || conn.Query("name") != "bob" {
+			_ = conn.CloseWithReason(websocket.ClosePolicyViolation, "bad request")
+			return
+		}
+		for {
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(mt, data); err != nil {
+				return
+			}
+		}
+	}))
+	ln := startInmemoryServer(t, server)
+
+	client := dialWebSocket(t, ln, "/ws/lobby?name=bob")
+	defer client.Close()
+
+	if err := client.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("unexpected echo %q", data)
+	}
+}
+
+func TestWebSocketRejectsPlainRequest(t *testing.T) {
+	server := NewServer()
+	server.GET("/ws", WebSocket(func(conn *WebSocketConn) {}))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestWebSocketHubRoomsAndShutdown(t *testing.T) {
+	server := NewServer()
+	hub := server.WebSocketHub()
+	joined := make(chan struct{}, 2)
+	server.GET("/ws/:room", WebSocket(func(conn *WebSocketConn) {
+		conn.Join(conn.Param("room"))
+		joined <- struct{}{}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	ln := startInmemoryServer(t, server)
+
+	red := dialWebSocket(t, ln, "/ws/red")
+	defer red.Close()
+	blue := dialWebSocket(t, ln, "/ws/blue")
+	defer blue.Close()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-joined:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for connections")
+		}
+	}
+
+	if hub.Len() != 2 || hub.RoomLen("red") != 1 {
+		t.Fatalf("unexpected hub state len=%d red=%d", hub.Len(), hub.RoomLen("red"))
+	}
+
+	n, err := hub.BroadcastTo("red", websocket.TextMessage, []byte("only-red"))
+	if err != nil || n != 1 {
+		t.Fatalf("broadcast to room: n=%d err=%v", n, err)
+	}
+	_ = red.SetReadDeadline(time.Now().Add(time.Second))
+	if _, data, err := red.ReadMessage(); err != nil || string(data) != "only-red" {
+		t.Fatalf("unexpected room message %q err=%v", data, err)
+	}
+
+	if n, _ := hub.Broadcast(websocket.TextMessage, []byte("all")); n != 2 {
+		t.Fatalf("expected broadcast to 2 conns, got %d", n)
+	}
+	_ = blue.SetReadDeadline(time.Now().Add(time.Second))
+	if _, data, err := blue.ReadMessage(); err != nil || string(data) != "all" {
+		t.Fatalf("unexpected broadcast message %q err=%v", data, err)
+	}
+
+	// Clients must keep reading to answer the server's close frame.
+	closeErrs := make(chan error, 2)
+	for _, conn := range []*websocket.Conn{red, blue} {
+		go func(conn *websocket.Conn) {
+			_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					closeErrs <- err
+					return
+				}
+			}
+		}(conn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.ShutdownWithContext(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := <-closeErrs; !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Fatalf("expected going-away close, got %v", err)
+		}
+	}
+	if hub.Len() != 0 {
+		t.Fatalf("expected hub to be empty after shutdown, got %d", hub.Len())
+	}
+}
@@ -3,6 +3,7 @@ package glb
 import (
 	"context"
 	"testing"
+	"time"
 )
 
 // MockDiscovery
@@ -141,3 +142,57 @@ func TestEmptyInstances(t *testing.T) {
 	s4 := NewLeastConnectionsStrategy()
 	if _, err := s4.Next(ctx, instances); err == nil { t.Error("expected error") }
 }
+
+func TestPassiveEjection(t *testing.T) {
+	discovery := &MockDiscovery{
+		instances: map[string][]Instance{
+			"svc": {
+				&BaseInstance{Address: "a1", Healthy: true, Weight: 1},
+				&BaseInstance{Address: "a2", Healthy: true, Weight: 1},
+			},
+		},
+	}
+	lb := NewLoadBalancer(discovery, NewRoundRobinStrategy())
+	ctx := context.Background()
+
+	a1 := discovery.instances["svc"][0]
+	lb.MarkUnhealthy(a1, time.Hour)
+	for i := 0; i < 4; i++ {
+		inst, err := lb.GetInstance(ctx, "svc")
+		if err != nil || inst.GetAddress() != "a2" {
+			t.Fatalf("expected a2 while a1 is ejected, got %v %v", inst, err)
+		}
+	}
+	if _, err := lb.GetInstance(WithExclude(ctx, "a2"), "svc"); err == nil {
+		t.Fatal("expected no instance when the only healthy one is excluded")
+	}
+
+	lb.MarkHealthy(a1)
+	inst, err := lb.GetInstance(WithExclude(ctx, "a2"), "svc")
+	if err != nil || inst.GetAddress() != "a1" {
+		t.Fatalf("expected a1 after recovery, got %v %v", inst, err)
+	}
+
+	lb.MarkUnhealthy(a1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if inst, err := lb.GetInstance(WithExclude(ctx, "a2"), "svc"); err != nil || inst.GetAddress() != "a1" {
+		t.Fatalf("expected a1 after the ejection expired, got %v %v", inst, err)
+	}
+}
+
+func TestAcquireTracksConnections(t *testing.T) {
+	lcs := NewLeastConnectionsStrategy()
+	lb := NewLoadBalancer(&MockDiscovery{}, lcs)
+	a1 := &BaseInstance{Address: "a1", Healthy: true}
+	instances := []Instance{a1, &BaseInstance{Address: "a2", Healthy: true}}
+
+	release := lb.Acquire(a1)
+	if inst, _ := lcs.Next(context.Background(), instances); inst.GetAddress() != "a2" {
+		t.Fatal("expected a2 while a1 has an active connection")
+	}
+	release()
+	release()
+	if inst, _ := lcs.Next(context.Background(), instances); inst.GetAddress() != "a1" {
+		t.Fatal("expected a1 after release")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 )
 
 // 服务实例
@@ -50,12 +51,20 @@ func (i *BaseInstance) GetMetadata() map[string]string {
 	return i.Metadata
 }
 
+// 连接数感知的策略，例如 LeastConnectionsStrategy
+type ConnectionTracker interface {
+	IncreaseConnections(address string)
+	DecreaseConnections(address string)
+}
+
 // 负载均衡器
 type LoadBalancer struct {
 	discovery Discovery
 	strategy  Strategy
 	mu        sync.RWMutex
 	instances map[string][]Instance
+	// 被动健康检查摘除的实例地址及恢复时间
+	ejected map[string]time.Time
 }
 
 func NewLoadBalancer(discovery Discovery, strategy Strategy) *LoadBalancer {
@@ -63,6 +72,57 @@ func NewLoadBalancer(discovery Discovery, strategy Strategy) *LoadBalancer {
 		discovery: discovery,
 		strategy:  strategy,
 		instances: make(map[string][]Instance),
+		ejected:   make(map[string]time.Time),
+	}
+}
+
+type excludeKey struct{}
+
+// WithExclude 返回的 context 传给 GetInstance 时跳过指定地址的实例，用于在其他实例上重试
+func WithExclude(ctx context.Context, addresses ...string) context.Context {
+	excluded := make(map[string]struct{})
+	if prev, ok := ctx.Value(excludeKey{}).(map[string]struct{}); ok {
+		for addr := range prev {
+			excluded[addr] = struct{}{}
+		}
+	}
+	for _, addr := range addresses {
+		excluded[addr] = struct{}{}
+	}
+	return context.WithValue(ctx, excludeKey{}, excluded)
+}
+
+// MarkUnhealthy 将实例摘除 d 时长（被动健康检查），到期后自动恢复
+func (lb *LoadBalancer) MarkUnhealthy(instance Instance, d time.Duration) {
+	if instance == nil || d <= 0 {
+		return
+	}
+	lb.mu.Lock()
+	lb.ejected[instance.GetAddress()] = time.Now().Add(d)
+	lb.mu.Unlock()
+}
+
+// MarkHealthy 立即恢复被摘除的实例
+func (lb *LoadBalancer) MarkHealthy(instance Instance) {
+	if instance == nil {
+		return
+	}
+	lb.mu.Lock()
+	delete(lb.ejected, instance.GetAddress())
+	lb.mu.Unlock()
+}
+
+// Acquire 在策略支持时记录实例的活跃连接，返回的函数在请求结束时调用
+func (lb *LoadBalancer) Acquire(instance Instance) (release func()) {
+	tracker, ok := lb.strategy.(ConnectionTracker)
+	if !ok || instance == nil {
+		return func() {}
+	}
+	addr := instance.GetAddress()
+	tracker.IncreaseConnections(addr)
+	var once sync.Once
+	return func() {
+		once.Do(func() { tracker.DecreaseConnections(addr) })
 	}
 }
 
@@ -72,6 +132,16 @@ func (lb *LoadBalancer) GetInstance(ctx context.Context, serviceName string) (In
 		return nil, err
 	}
 
+	if excluded, ok := ctx.Value(excludeKey{}).(map[string]struct{}); ok && len(excluded) > 0 {
+		remaining := instances[:0:0]
+		for _, instance := range instances {
+			if _, skip := excluded[instance.GetAddress()]; !skip {
+				remaining = append(remaining, instance)
+			}
+		}
+		instances = remaining
+	}
+
 	if len(instances) == 0 {
 		return nil, errors.New("no available instances")
 	}
@@ -93,13 +163,20 @@ func (lb *LoadBalancer) getHealthyInstances(serviceName string) ([]Instance, err
 		lb.mu.RUnlock()
 	}
 
-	// 过滤健康实例
+	// 过滤健康实例，并跳过仍在摘除期内的实例
+	now := time.Now()
 	var healthy []Instance
+	lb.mu.RLock()
 	for _, instance := range instances {
-		if instance.IsHealthy() {
-			healthy = append(healthy, instance)
+		if !instance.IsHealthy() {
+			continue
+		}
+		if until, ok := lb.ejected[instance.GetAddress()]; ok && now.Before(until) {
+			continue
 		}
+		healthy = append(healthy, instance)
 	}
+	lb.mu.RUnlock()
 
 	return healthy, nil
 }
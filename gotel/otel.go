@@ -1,132 +1,271 @@
 package gotel
 
 import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// OTELProvider 基于 OpenTelemetry API 的 Provider 实现。
+// 默认使用全局的 TracerProvider 和 MeterProvider，因此在应用中通过
+// otel.SetTracerProvider / otel.SetMeterProvider 安装 SDK 后即可生效。
 type OTELProvider struct {
-	tracer     trace.Tracer
-	meter      metric.Meter
-	propagator propagation.TextMapPropagator
-}
-
-//func NewOTELProvider(serviceName string) Provider {
-//	tp := sdktrace.NewTracerProvider()
-//	mp := metric.NewMeterProvider()
-//
-//	return &OTELProvider{
-//		tracer:     tp.Tracer(serviceName),
-//		meter:      mp.Meter(serviceName),
-//		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
-//	}
-//}
-//
-//func (p *OTELProvider) Start(ctx context.Context, spanName string, opts ...SpanStartOption) (context.Context, Span) {
-//	ctx, span := p.tracer.Start(ctx, spanName)
-//	return ctx, &OTELSpan{span: span}
-//}
-//
-//func (p *OTELProvider) Extract(ctx context.Context, carrier TextMapCarrier) (context.Context, SpanContext) {
-//	carrierImpl := &textMapCarrier{carrier: carrier}
-//	ctx = p.propagator.Extract(ctx, carrierImpl)
-//
-//	if span := trace.SpanFromContext(ctx); span != nil {
-//		return ctx, &OTELSpanContext{spanContext: span.SpanContext()}
-//	}
-//	return ctx, nil
-//}
-//
-//func (p *OTELProvider) Inject(ctx context.Context, carrier TextMapCarrier, spanContext SpanContext) error {
-//	carrierImpl := &textMapCarrier{carrier: carrier}
-//
-//	// 创建包含指定 SpanContext 的上下文
-//	var sc trace.SpanContext
-//	if otelSC, ok := spanContext.(*OTELSpanContext); ok {
-//		sc = otelSC.spanContext
-//	}
-//
-//	ctx = trace.ContextWithSpanContext(ctx, sc)
-//	p.propagator.Inject(ctx, carrierImpl)
-//	return nil
-//}
-//
-//func (p *OTELProvider) Counter(name string, opts ...InstrumentOption) Counter {
-//	counter, _ := p.meter.Float64Counter(name)
-//	return &OTELCounter{counter: counter}
-//}
-//
-//func (p *OTELProvider) Histogram(name string, opts ...InstrumentOption) Histogram {
-//	histogram, _ := p.meter.Float64Histogram(name)
-//	return &OTELHistogram{histogram: histogram}
-//}
-//
-//func (p *OTELProvider) Gauge(name string, opts ...InstrumentOption) Gauge {
-//	gauge, _ := p.meter.Float64ObservableGauge(name)
-//	return &OTELGauge{gauge: gauge}
-//}
-//
-//// OTELSpan 实现
-//type OTELSpan struct {
-//	span trace.Span
-//}
-//
-//func (s *OTELSpan) Context() SpanContext {
-//	return &OTELSpanContext{spanContext: s.span.SpanContext()}
-//}
-//
-//func (s *OTELSpan) SetAttributes(attributes ...KeyValue) {
-//	attrs := convertAttributes(attributes)
-//	s.span.SetAttributes(attrs...)
-//}
-//
-//func (s *OTELSpan) SetStatus(code StatusCode, description string) {
-//	var otelCode otelcodes.Code
-//	switch code {
-//	case StatusCodeOk:
-//		otelCode = otelcodes.Ok
-//	case StatusCodeError:
-//		otelCode = otelcodes.Error
-//	default:
-//		otelCode = otelcodes.Unset
-//	}
-//	s.span.SetStatus(otelCode, description)
-//}
-//
-//func (s *OTELSpan) RecordError(err error, attributes ...KeyValue) {
-//	attrs := convertAttributes(attributes)
-//	s.span.RecordError(err, trace.WithAttributes(attrs...))
-//}
-//
-//func (s *OTELSpan) AddEvent(name string, attributes ...KeyValue) {
-//	attrs := convertAttributes(attributes)
-//	s.span.AddEvent(name, trace.WithAttributes(attrs...))
-//}
-//
-//func (s *OTELSpan) End(options ...SpanEndOption) {
-//	s.span.End()
-//}
-//
-//// OTELSpanContext 实现
-//type OTELSpanContext struct {
-//	spanContext trace.SpanContext
-//}
-//
-//func (sc *OTELSpanContext) TraceID() string {
-//	return sc.spanContext.TraceID().String()
-//}
-//
-//func (sc *OTELSpanContext) SpanID() string {
-//	return sc.spanContext.SpanID().String()
-//}
-//
-//func (sc *OTELSpanContext) IsSampled() bool {
-//	return sc.spanContext.IsSampled()
-//}
-//
-//func (sc *OTELSpanContext) Serialize() map[string]string {
-//	return map[string]string{
-//		"traceparent": sc.spanContext.TraceID().String() + "-" + sc.spanContext.SpanID().String(),
-//	}
-//}
+	tracer         trace.Tracer
+	meter          metric.Meter
+	propagator     propagation.TextMapPropagator
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// OTELOption 配置 OTELProvider。
+type OTELOption func(*OTELProvider)
+
+// WithTracerProvider 指定 TracerProvider，默认为全局 TracerProvider。
+func WithTracerProvider(tp trace.TracerProvider) OTELOption {
+	return func(p *OTELProvider) {
+		p.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider 指定 MeterProvider，默认为全局 MeterProvider。
+func WithMeterProvider(mp metric.MeterProvider) OTELOption {
+	return func(p *OTELProvider) {
+		p.meterProvider = mp
+	}
+}
+
+// WithPropagator 指定传播器，默认为 W3C TraceContext 与 Baggage。
+func WithPropagator(propagator propagation.TextMapPropagator) OTELOption {
+	return func(p *OTELProvider) {
+		p.propagator = propagator
+	}
+}
+
+// NewOTELProvider 创建 OTELProvider，name 为 instrumentation scope 名称。
+func NewOTELProvider(name string, opts ...OTELOption) *OTELProvider {
+	p := &OTELProvider{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.tracerProvider == nil {
+		p.tracerProvider = otel.GetTracerProvider()
+	}
+	if p.meterProvider == nil {
+		p.meterProvider = otel.GetMeterProvider()
+	}
+	if p.propagator == nil {
+		p.propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+	p.tracer = p.tracerProvider.Tracer(name)
+	p.meter = p.meterProvider.Meter(name)
+	return p
+}
+
+// Start 开始新跨度，返回的 context 中携带 OpenTelemetry 跨度。
+func (p *OTELProvider) Start(ctx context.Context, spanName string, opts ...SpanStartOption) (context.Context, Span) {
+	cfg := newSpanStartConfig(opts)
+	startOpts := []trace.SpanStartOption{trace.WithSpanKind(convertSpanKind(cfg.kind))}
+	if len(cfg.attributes) > 0 {
+		startOpts = append(startOpts, trace.WithAttributes(convertAttributes(cfg.attributes)...))
+	}
+	ctx, span := p.tracer.Start(ctx, spanName, startOpts...)
+	return ctx, &OTELSpan{span: span}
+}
+
+// Extract 从载体提取跨度上下文与 baggage。载体中没有有效跨度时返回 nil。
+func (p *OTELProvider) Extract(ctx context.Context, carrier TextMapCarrier) (context.Context, SpanContext) {
+	ctx = p.propagator.Extract(ctx, &textMapCarrier{carrier: carrier})
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ctx, nil
+	}
+	return ctx, &OTELSpanContext{spanContext: sc}
+}
+
+// Inject 将跨度上下文注入载体，spanContext 为 nil 时注入 ctx 中的跨度。
+func (p *OTELProvider) Inject(ctx context.Context, carrier TextMapCarrier, spanContext SpanContext) error {
+	if spanContext != nil {
+		otelSC, ok := spanContext.(*OTELSpanContext)
+		if !ok {
+			return errors.New("gotel: span context was not created by OTELProvider")
+		}
+		ctx = trace.ContextWithSpanContext(ctx, otelSC.spanContext)
+	}
+	p.propagator.Inject(ctx, &textMapCarrier{carrier: carrier})
+	return nil
+}
+
+// Counter 创建单调递增计数器。
+func (p *OTELProvider) Counter(name string, options ...InstrumentOption) Counter {
+	cfg := newInstrumentConfig(options)
+	counter, err := p.meter.Float64Counter(name, metric.WithDescription(cfg.description), metric.WithUnit(cfg.unit))
+	if err != nil || counter == nil {
+		counter, _ = metricnoop.Meter{}.Float64Counter(name)
+	}
+	return &OTELCounter{counter: counter}
+}
+
+// UpDownCounter 创建可增可减的计数器。
+func (p *OTELProvider) UpDownCounter(name string, options ...InstrumentOption) UpDownCounter {
+	cfg := newInstrumentConfig(options)
+	counter, err := p.meter.Float64UpDownCounter(name, metric.WithDescription(cfg.description), metric.WithUnit(cfg.unit))
+	if err != nil || counter == nil {
+		counter, _ = metricnoop.Meter{}.Float64UpDownCounter(name)
+	}
+	return &OTELUpDownCounter{counter: counter}
+}
+
+// Histogram 创建直方图。
+func (p *OTELProvider) Histogram(name string, options ...InstrumentOption) Histogram {
+	cfg := newInstrumentConfig(options)
+	opts := []metric.Float64HistogramOption{metric.WithDescription(cfg.description), metric.WithUnit(cfg.unit)}
+	if len(cfg.buckets) > 0 {
+		opts = append(opts, metric.WithExplicitBucketBoundaries(cfg.buckets...))
+	}
+	histogram, err := p.meter.Float64Histogram(name, opts...)
+	if err != nil || histogram == nil {
+		histogram, _ = metricnoop.Meter{}.Float64Histogram(name)
+	}
+	return &OTELHistogram{histogram: histogram}
+}
+
+// Gauge 创建同步仪表。
+func (p *OTELProvider) Gauge(name string, options ...InstrumentOption) Gauge {
+	cfg := newInstrumentConfig(options)
+	gauge, err := p.meter.Float64Gauge(name, metric.WithDescription(cfg.description), metric.WithUnit(cfg.unit))
+	if err != nil || gauge == nil {
+		gauge, _ = metricnoop.Meter{}.Float64Gauge(name)
+	}
+	return &OTELGauge{gauge: gauge}
+}
+
+// Shutdown 关闭底层的 TracerProvider 和 MeterProvider（如 SDK 实现支持）。
+func (p *OTELProvider) Shutdown(ctx context.Context) error {
+	type shutdowner interface {
+		Shutdown(ctx context.Context) error
+	}
+	var errs []error
+	if s, ok := p.tracerProvider.(shutdowner); ok {
+		errs = append(errs, s.Shutdown(ctx))
+	}
+	if s, ok := p.meterProvider.(shutdowner); ok {
+		errs = append(errs, s.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// OTELSpan 实现 Span。
+type OTELSpan struct {
+	span trace.Span
+}
+
+func (s *OTELSpan) Context() SpanContext {
+	return &OTELSpanContext{spanContext: s.span.SpanContext()}
+}
+
+func (s *OTELSpan) SetAttributes(attributes ...KeyValue) {
+	s.span.SetAttributes(convertAttributes(attributes)...)
+}
+
+func (s *OTELSpan) SetStatus(code StatusCode, description string) {
+	s.span.SetStatus(convertStatusCode(code), description)
+}
+
+func (s *OTELSpan) RecordError(err error, attributes ...KeyValue) {
+	s.span.RecordError(err, trace.WithAttributes(convertAttributes(attributes)...))
+}
+
+func (s *OTELSpan) AddEvent(name string, attributes ...KeyValue) {
+	s.span.AddEvent(name, trace.WithAttributes(convertAttributes(attributes)...))
+}
+
+func (s *OTELSpan) End(options ...SpanEndOption) {
+	s.span.End()
+}
+
+// OTELSpanContext 实现 SpanContext。
+type OTELSpanContext struct {
+	spanContext trace.SpanContext
+}
+
+func (sc *OTELSpanContext) TraceID() string {
+	return sc.spanContext.TraceID().String()
+}
+
+func (sc *OTELSpanContext) SpanID() string {
+	return sc.spanContext.SpanID().String()
+}
+
+func (sc *OTELSpanContext) IsSampled() bool {
+	return sc.spanContext.IsSampled()
+}
+
+// Serialize 按 W3C Trace Context 序列化，返回 traceparent 和 tracestate。
+func (sc *OTELSpanContext) Serialize() map[string]string {
+	carrier := propagation.MapCarrier{}
+	ctx := trace.ContextWithSpanContext(context.Background(), sc.spanContext)
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier
+}
+
+// OTELCounter 实现 Counter。
+type OTELCounter struct {
+	counter metric.Float64Counter
+}
+
+func (c *OTELCounter) Add(ctx context.Context, value float64, attributes ...KeyValue) {
+	c.counter.Add(ctx, value, metric.WithAttributes(convertAttributes(attributes)...))
+}
+
+func (c *OTELCounter) Increment(ctx context.Context, attributes ...KeyValue) {
+	c.Add(ctx, 1, attributes...)
+}
+
+// OTELUpDownCounter 实现 UpDownCounter。
+type OTELUpDownCounter struct {
+	counter metric.Float64UpDownCounter
+}
+
+func (c *OTELUpDownCounter) Add(ctx context.Context, value float64, attributes ...KeyValue) {
+	c.counter.Add(ctx, value, metric.WithAttributes(convertAttributes(attributes)...))
+}
+
+// OTELHistogram 实现 Histogram。
+type OTELHistogram struct {
+	histogram metric.Float64Histogram
+}
+
+func (h *OTELHistogram) Record(ctx context.Context, value float64, attributes ...KeyValue) {
+	h.histogram.Record(ctx, value, metric.WithAttributes(convertAttributes(attributes)...))
+}
+
+// OTELGauge 实现 Gauge。
+type OTELGauge struct {
+	gauge metric.Float64Gauge
+}
+
+func (g *OTELGauge) Record(ctx context.Context, value float64, attributes ...KeyValue) {
+	g.gauge.Record(ctx, value, metric.WithAttributes(convertAttributes(attributes)...))
+}
+
+// textMapCarrier 将 TextMapCarrier 适配为 propagation.TextMapCarrier。
+type textMapCarrier struct {
+	carrier TextMapCarrier
+}
+
+func (c *textMapCarrier) Get(key string) string {
+	return c.carrier.Get(key)
+}
+
+func (c *textMapCarrier) Set(key string, value string) {
+	c.carrier.Set(key, value)
+}
+
+func (c *textMapCarrier) Keys() []string {
+	return c.carrier.Keys()
+}
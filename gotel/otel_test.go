@@ -0,0 +1,64 @@
+package gotel
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+type headerCarrier http.Header
+
+func (h headerCarrier) Get(key string) string { return http.Header(h).Get(key) }
+func (h headerCarrier) Set(key, value string) { http.Header(h).Set(key, value) }
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestOTELProviderPropagation(t *testing.T) {
+	p := NewOTELProvider("test")
+	in := headerCarrier{}
+	in.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	in.Set("baggage", "tenant=acme")
+
+	ctx, sc := p.Extract(context.Background(), in)
+	if sc == nil || sc.TraceID() != "4bf92f3577b34da6a3ce929d0e0e4736" || !sc.IsSampled() {
+		t.Fatalf("unexpected span context %v", sc)
+	}
+	if got := baggage.FromContext(ctx).Member("tenant").Value(); got != "acme" {
+		t.Fatalf("baggage not extracted: %q", got)
+	}
+	if sc.Serialize()["traceparent"] != in.Get("traceparent") {
+		t.Fatalf("unexpected serialization %v", sc.Serialize())
+	}
+
+	// Without an SDK the span is non-recording but keeps the remote trace.
+	ctx, span := p.Start(ctx, "op", WithSpanKind(SpanKindServer), WithAttributes(KV("k", "v")))
+	defer span.End()
+	if span.Context().TraceID() != sc.TraceID() {
+		t.Fatalf("span lost the trace: %s", span.Context().TraceID())
+	}
+
+	out := headerCarrier{}
+	if err := p.Inject(ctx, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.Get("traceparent") != in.Get("traceparent") || out.Get("baggage") != "tenant=acme" {
+		t.Fatalf("unexpected injected headers %v", out)
+	}
+
+	if _, sc := p.Extract(context.Background(), headerCarrier{}); sc != nil {
+		t.Fatalf("expected no span context, got %v", sc)
+	}
+
+	p.Histogram("h", WithUnit("s"), WithBuckets(0.1, 1)).Record(ctx, 0.5)
+	p.UpDownCounter("u").Add(ctx, -1)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
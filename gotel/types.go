@@ -56,6 +56,13 @@ type Meter interface {
 	Gauge(name string, options ...InstrumentOption) Gauge
 }
 
+// UpDownCounterMeter 可选的指标器扩展，通过类型断言检测。
+// 未实现它的 Meter 不记录依赖可增可减计数器的指标。
+type UpDownCounterMeter interface {
+	// UpDownCounter 创建可增可减的计数器
+	UpDownCounter(name string, options ...InstrumentOption) UpDownCounter
+}
+
 // Counter 计数器抽象
 type Counter interface {
 	// Add 增加计数值
@@ -64,6 +71,12 @@ type Counter interface {
 	Increment(ctx context.Context, attributes ...KeyValue)
 }
 
+// UpDownCounter 可增可减的计数器抽象，如活跃请求数
+type UpDownCounter interface {
+	// Add 增加计数值，value 可以为负数
+	Add(ctx context.Context, value float64, attributes ...KeyValue)
+}
+
 // Histogram 直方图抽象
 type Histogram interface {
 	// Record 记录值用于分布统计
@@ -114,3 +127,88 @@ type TextMapCarrier interface {
 type SpanStartOption interface{}
 type SpanEndOption interface{}
 type InstrumentOption interface{}
+
+// SpanKind 跨度类型
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer
+	SpanKindClient
+	SpanKindProducer
+	SpanKindConsumer
+)
+
+// SpanAttributes 是 WithAttributes 返回的选项，Tracer 实现据此读取初始属性
+type SpanAttributes []KeyValue
+
+// WithSpanKind 设置跨度类型，默认为 SpanKindInternal
+func WithSpanKind(kind SpanKind) SpanStartOption {
+	return kind
+}
+
+// WithAttributes 设置跨度开始时的属性，采样器可以使用这些属性
+func WithAttributes(attributes ...KeyValue) SpanStartOption {
+	return SpanAttributes(attributes)
+}
+
+type spanStartConfig struct {
+	kind       SpanKind
+	attributes []KeyValue
+}
+
+func newSpanStartConfig(opts []SpanStartOption) spanStartConfig {
+	var cfg spanStartConfig
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case SpanKind:
+			cfg.kind = o
+		case SpanAttributes:
+			cfg.attributes = append(cfg.attributes, o...)
+		}
+	}
+	return cfg
+}
+
+// 指标选项的具体类型，Meter 实现据此读取配置
+type (
+	InstrumentDescription string
+	InstrumentUnit        string
+	HistogramBuckets      []float64
+)
+
+// WithDescription 设置指标描述
+func WithDescription(description string) InstrumentOption {
+	return InstrumentDescription(description)
+}
+
+// WithUnit 设置指标单位，使用 UCUM 格式，如 "s"、"By"、"{request}"
+func WithUnit(unit string) InstrumentOption {
+	return InstrumentUnit(unit)
+}
+
+// WithBuckets 设置直方图的桶边界
+func WithBuckets(boundaries ...float64) InstrumentOption {
+	return HistogramBuckets(boundaries)
+}
+
+type instrumentConfig struct {
+	description string
+	unit        string
+	buckets     []float64
+}
+
+func newInstrumentConfig(opts []InstrumentOption) instrumentConfig {
+	var cfg instrumentConfig
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case InstrumentDescription:
+			cfg.description = string(o)
+		case InstrumentUnit:
+			cfg.unit = string(o)
+		case HistogramBuckets:
+			cfg.buckets = o
+		}
+	}
+	return cfg
+}
@@ -5,6 +5,7 @@ import (
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // convertAttributes 将通用 KeyValue 转换为 OpenTelemetry 的 attribute.KeyValue
@@ -82,3 +83,19 @@ func convertStatusCode(code StatusCode) codes.Code {
 		return codes.Unset
 	}
 }
+
+// convertSpanKind 转换跨度类型
+func convertSpanKind(kind SpanKind) trace.SpanKind {
+	switch kind {
+	case SpanKindServer:
+		return trace.SpanKindServer
+	case SpanKindClient:
+		return trace.SpanKindClient
+	case SpanKindProducer:
+		return trace.SpanKindProducer
+	case SpanKindConsumer:
+		return trace.SpanKindConsumer
+	default:
+		return trace.SpanKindInternal
+	}
+}
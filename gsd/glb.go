@@ -0,0 +1,74 @@
+package gsd
+
+import (
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/sofiworker/gk/glb"
+)
+
+// WeightMetadataKey 元数据中表示实例权重的键，供加权负载均衡策略使用
+const WeightMetadataKey = "weight"
+
+// lbDiscovery 将 ServiceDiscovery 适配为 glb.Discovery
+type lbDiscovery struct {
+	sd ServiceDiscovery
+}
+
+// NewLBDiscovery 将 ServiceDiscovery 适配为 glb.Discovery，以便配合 glb.LoadBalancer
+// 和 gclient.DiscoveryTransport 使用。实例地址为 "Address:Port"，状态为
+// ServiceStatusUnhealthy 的实例视为不健康，权重取自元数据 weight，默认为 1
+func NewLBDiscovery(sd ServiceDiscovery) glb.Discovery {
+	return &lbDiscovery{sd: sd}
+}
+
+func (d *lbDiscovery) GetInstances(serviceName string) ([]glb.Instance, error) {
+	services, err := d.sd.GetService(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return ToInstances(services), nil
+}
+
+// Watch 基于 WatchService 推送实例列表，消费不及时时只保留最新的列表
+func (d *lbDiscovery) Watch(serviceName string) (<-chan []glb.Instance, error) {
+	ch := make(chan []glb.Instance, 1)
+	var mu sync.Mutex
+	err := d.sd.WatchService(serviceName, func(services []ServiceInfo) {
+		instances := ToInstances(services)
+		mu.Lock()
+		defer mu.Unlock()
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- instances
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// ToInstances 将服务信息转换为负载均衡实例
+func ToInstances(services []ServiceInfo) []glb.Instance {
+	instances := make([]glb.Instance, 0, len(services))
+	for _, s := range services {
+		address := s.Address
+		if s.Port > 0 {
+			address = net.JoinHostPort(s.Address, strconv.Itoa(s.Port))
+		}
+		weight := 1
+		if w, err := strconv.Atoi(s.Metadata[WeightMetadataKey]); err == nil && w > 0 {
+			weight = w
+		}
+		instances = append(instances, &glb.BaseInstance{
+			Address:  address,
+			Healthy:  s.Status != ServiceStatusUnhealthy,
+			Weight:   weight,
+			Metadata: s.Metadata,
+		})
+	}
+	return instances
+}
@@ -146,3 +146,47 @@ func TestBuildErrorHandlingOptions(t *testing.T) {
 		t.Error("WithRetryDelay failed")
 	}
 }
+
+type fakeServiceDiscovery struct {
+	services []ServiceInfo
+	handler  func([]ServiceInfo)
+}
+
+func (f *fakeServiceDiscovery) GetService(string) ([]ServiceInfo, error) { return f.services, nil }
+func (f *fakeServiceDiscovery) SelectService(string) (*ServiceInfo, error) {
+	return &f.services[0], nil
+}
+func (f *fakeServiceDiscovery) WatchService(_ string, handler func([]ServiceInfo)) error {
+	f.handler = handler
+	return nil
+}
+func (f *fakeServiceDiscovery) Close() error { return nil }
+
+func TestLBDiscovery(t *testing.T) {
+	sd := &fakeServiceDiscovery{services: []ServiceInfo{
+		{Address: "10.0.0.1", Port: 8080, Status: ServiceStatusHealthy, Metadata: map[string]string{"weight": "5"}},
+		{Address: "10.0.0.2", Port: 8080, Status: ServiceStatusUnhealthy},
+	}}
+	d := NewLBDiscovery(sd)
+
+	instances, err := d.GetInstances("orders")
+	if err != nil || len(instances) != 2 {
+		t.Fatalf("unexpected instances %v %v", instances, err)
+	}
+	if instances[0].GetAddress() != "10.0.0.1:8080" || instances[0].GetWeight() != 5 || !instances[0].IsHealthy() {
+		t.Errorf("unexpected first instance %+v", instances[0])
+	}
+	if instances[1].GetWeight() != 1 || instances[1].IsHealthy() {
+		t.Errorf("unexpected second instance %+v", instances[1])
+	}
+
+	ch, err := d.Watch("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd.handler([]ServiceInfo{{Address: "10.0.0.3", Port: 80}})
+	sd.handler([]ServiceInfo{{Address: "10.0.0.4", Port: 80}})
+	if latest := <-ch; len(latest) != 1 || latest[0].GetAddress() != "10.0.0.4:80" {
+		t.Errorf("expected only the latest instances, got %v", latest)
+	}
+}